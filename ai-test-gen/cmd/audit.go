@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/knbr13/aitestgen/pkg/audit"
+	"github.com/knbr13/aitestgen/pkg/generator"
+)
+
+// auditKeyEnv names the environment variable holding the key audit entries
+// are signed and checked with
+const auditKeyEnv = "AITESTGEN_AUDIT_KEY"
+
+// auditLog is --audit, which records generated files in the audit log
+var auditLog bool
+
+// auditing reports whether generated files are recorded, with --audit or the
+// audit setting of the config
+func auditing() bool {
+	return auditLog || projectConfig.Audit
+}
+
+// auditKey returns the signing key of the audit log, nil when unset
+func auditKey() []byte {
+	if key := os.Getenv(auditKeyEnv); key != "" {
+		return []byte(key)
+	}
+	return nil
+}
+
+// projectPath returns path relative to projectDir with forward slashes, as
+// the audit log records it, or the cleaned absolute path when it is outside
+func projectPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	root, err := filepath.Abs(projectDir)
+	if err != nil {
+		return filepath.ToSlash(abs)
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(abs)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// audit records output, generated from input with the prompts digested, in
+// the audit log. Outputs that aren't files, such as stdout, and files made
+// without a prompt, such as skeletons, are not recorded.
+func (r *runReport) audit(input, output string, prompts *generator.PromptDigest) {
+	if !auditing() || prompts.Count() == 0 {
+		return
+	}
+	if info, err := os.Stat(output); err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	sum, err := audit.SumFile(output)
+	if err != nil {
+		slog.Error("audit entry not recorded", "file", output, "err", err)
+		return
+	}
+	e := audit.Entry{
+		Time:         time.Now().UTC(),
+		Command:      r.Command,
+		Output:       projectPath(output),
+		OutputSHA256: sum,
+		PromptSHA256: prompts.Sum(),
+		Prompts:      prompts.Count(),
+		Provider:     r.Provider,
+		Model:        r.Model,
+	}
+	if info, err := os.Stat(input); err == nil && info.Mode().IsRegular() {
+		e.Source = projectPath(input)
+		e.SourceSHA256, _ = audit.SumFile(input)
+	}
+	if err := audit.Append(projectDir, e, auditKey()); err != nil {
+		slog.Error("audit entry not recorded", "file", output, "err", err)
+	}
+}
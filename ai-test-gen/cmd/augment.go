@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+var (
+	augmentTestFile   string
+	augmentSourceFile string
+	augmentTest       string
+	augmentMaxRepairs int
+	augmentVerify     bool
+	augmentProvider   providerOptions
+)
+
+var augmentCmd = &cobra.Command{
+	Use:   "augment",
+	Short: "Add missing cases to the tables of existing table-driven tests",
+	Long: `Find the table-driven tests in an existing test file and ask the model for
+edge, boundary and error cases their tables are missing. The new rows are added
+to the tables in place; the rest of the file is left as it is.
+
+The code under test is read from the source file matching the test file
+(foo.go for foo_test.go) unless --source is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		if augmentTestFile == "" {
+			fmt.Println("You must specify --file.")
+			os.Exit(1)
+		}
+		if augmentSourceFile == "" {
+			augmentSourceFile = strings.TrimSuffix(augmentTestFile, "_test.go") + ".go"
+		}
+
+		content, err := os.ReadFile(augmentTestFile)
+		if err != nil {
+			fmt.Printf("Error reading test file: %v\n", err)
+			os.Exit(1)
+		}
+		code, err := os.ReadFile(augmentSourceFile)
+		if err != nil {
+			fmt.Printf("Error reading source file (use --source): %v\n", err)
+			os.Exit(1)
+		}
+		parsed, err := source.Parse(augmentTestFile, content)
+		if err != nil {
+			fmt.Printf("Error parsing test file: %v\n", err)
+			os.Exit(1)
+		}
+
+		tables := parsed.Tables()
+		if len(tables) == 0 || (augmentTest != "" && !hasTableFor(tables, augmentTest)) {
+			fmt.Printf("No table-driven tests found in %s.\n", augmentTestFile)
+			os.Exit(1)
+		}
+
+		provider, err := augmentProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		rows := make(map[int]string)
+		for i, table := range tables {
+			if augmentTest != "" && table.Test != augmentTest {
+				continue
+			}
+			cases, err := generator.GenerateTableCases(ctx, string(code), parsed.TestText(table), table.Cases, provider)
+			if err != nil {
+				fmt.Printf("Error generating cases for %s: %v\n", table.Test, err)
+				os.Exit(1)
+			}
+			if cases != "" {
+				rows[i] = cases
+			}
+		}
+		if len(rows) == 0 {
+			fmt.Println("No cases to add.")
+			return
+		}
+
+		updated, err := parsed.AppendCases(rows)
+		if err != nil {
+			fmt.Printf("Error adding cases: %v\n", err)
+			os.Exit(1)
+		}
+
+		// the cases join the file as it is, internal or external
+		opts := generator.TestOptions{Prompt: projectConfig.Prompts.Tests, BlackBox: strings.HasSuffix(parsed.Package(), "_test"), Conventions: testConventions(), Imports: importPolicy()}
+		w := testWriter{provider: provider, maxRepairs: augmentMaxRepairs, verify: augmentVerify, opts: opts, review: reviewer()}
+		if err := w.write(ctx, string(code), updated, augmentTestFile); err != nil {
+			if errors.Is(err, errDeclined) {
+				fmt.Printf("Not written: %s\n", augmentTestFile)
+				return
+			}
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Cases added to %d tables in %s\n", len(rows), augmentTestFile)
+	},
+}
+
+// hasTableFor reports whether one of tables belongs to the named test
+func hasTableFor(tables []source.Table, test string) bool {
+	for _, t := range tables {
+		if t.Test == test {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	rootCmd.AddCommand(augmentCmd)
+	augmentCmd.Flags().StringVarP(&augmentTestFile, "file", "f", "", "Test file whose tables to extend")
+	augmentCmd.Flags().StringVar(&augmentSourceFile, "source", "", "Source file under test (default: the test file without _test)")
+	augmentCmd.Flags().StringVar(&augmentTest, "test", "", "Only extend the table of this test function")
+	augmentCmd.Flags().IntVar(&augmentMaxRepairs, "max-repairs", 2, "Maximum attempts to fix the test file if the new cases break compilation (0 disables the check)")
+	augmentCmd.Flags().BoolVar(&augmentVerify, "verify", false, "Run the tests and only write them if they pass (failures are sent back for repair)")
+	augmentCmd.Flags().BoolVar(&showDiff, "diff", false, "Show a diff against the existing file and ask before writing")
+	augmentCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "With --diff, write without asking")
+	augmentProvider.addFlags(augmentCmd)
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/knbr13/aitestgen/pkg/auth"
+	"github.com/knbr13/aitestgen/pkg/generator"
+)
+
+// passphraseEnv unlocks the encrypted key file without a prompt, e.g. in CI
+const passphraseEnv = "AITESTGEN_PASSPHRASE"
+
+var (
+	authProfile  string
+	authProvider string
+	authUseFile  bool
+)
+
+// openAuthStore opens the profile store in the user config directory
+func openAuthStore() (*auth.Store, error) {
+	dir, err := auth.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return auth.Open(dir, readPassphrase), nil
+}
+
+// readPassphrase returns the key file passphrase from the environment, or
+// asks for it on the terminal
+func readPassphrase() ([]byte, error) {
+	if p := os.Getenv(passphraseEnv); p != "" {
+		return []byte(p), nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("the key file is locked: set %s to its passphrase", passphraseEnv)
+	}
+	return readSecret("Key file passphrase: ")
+}
+
+// readSecret asks for a value on the terminal without echoing it
+func readSecret(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return secret, err
+}
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage saved API keys",
+	Long: `Save provider API keys under named profiles so they don't have to be passed
+with --key, where they end up in shell history and process lists. Keys are
+kept in the OS keychain, or in a file encrypted with a passphrase where no
+keychain is available (or with --file). Set ` + passphraseEnv + ` to unlock
+the file without a prompt.
+
+Select a profile with --profile on any command that calls a model; the
+"default" profile is used when no key is given otherwise.`,
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Save an API key under a profile",
+	Long: `Save an API key under a profile. The key is read from the terminal without
+echo, or from stdin when it is piped:
+
+  aigen auth login --profile work --provider openai
+  echo "$OPENAI_API_KEY" | aigen auth login --profile ci --provider openai`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !generator.KnownProvider(authProvider) {
+			fmt.Printf("Error: unknown provider %q\n", authProvider)
+			os.Exit(1)
+		}
+		store, err := openAuthStore()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var key []byte
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			key, err = readSecret(fmt.Sprintf("API key for %s: ", authProvider))
+		} else {
+			key, err = io.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			fmt.Printf("Error reading the key: %v\n", err)
+			os.Exit(1)
+		}
+		if strings.TrimSpace(string(key)) == "" {
+			fmt.Println("Error: empty API key")
+			os.Exit(1)
+		}
+
+		p, err := store.Save(authProfile, authProvider, strings.TrimSpace(string(key)), authUseFile)
+		if err != nil {
+			fmt.Printf("Error saving the key: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved the %s key of profile %q in the %s\n", p.Provider, p.Name, storeName(p.Store))
+	},
+}
+
+var authListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved profiles",
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := openAuthStore()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		profiles, err := store.List()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(profiles) == 0 {
+			fmt.Println("No saved profiles, add one with: aigen auth login")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROFILE\tPROVIDER\tSTORE\tUPDATED")
+		for _, p := range profiles {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Name, p.Provider, p.Store, p.Updated.Local().Format("2006-01-02 15:04"))
+		}
+		w.Flush()
+	},
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove a saved profile and its key",
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := openAuthStore()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := store.Delete(authProfile); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed profile %q\n", authProfile)
+	},
+}
+
+// storeName describes where a profile's key is kept
+func storeName(store string) string {
+	if store == auth.StoreKeychain {
+		return "OS keychain"
+	}
+	return "encrypted key file"
+}
+
+// profileKey returns the provider and key saved under profile. A missing
+// default profile is not an error: it returns no key.
+func profileKey(profile string) (provider, key string, err error) {
+	store, err := openAuthStore()
+	if err != nil {
+		return "", "", err
+	}
+	p, key, err := store.Key(profile)
+	if errors.Is(err, auth.ErrNoProfile) && profile == auth.DefaultProfile {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return p.Provider, key, nil
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authLoginCmd, authListCmd, authLogoutCmd)
+
+	for _, c := range []*cobra.Command{authLoginCmd, authLogoutCmd} {
+		c.Flags().StringVar(&authProfile, "profile", auth.DefaultProfile, "Profile name")
+	}
+	authLoginCmd.Flags().StringVar(&authProvider, "provider", generator.ProviderGemini, "Provider the key is for (gemini, openai, anthropic, ollama, azure)")
+	authLoginCmd.Flags().BoolVar(&authUseFile, "file", false, "Save the key in the encrypted key file even when an OS keychain is available")
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// batchMaxFileBytes is the size above which a file is generated for on its
+// own rather than batched with others
+const batchMaxFileBytes = 8 << 10
+
+// batchSize is the --batch-size number of files of a package sent in one
+// prompt; 1 sends every file separately
+var batchSize int
+
+// testBatch is a group of files of one package whose tests are generated in
+// a single request, made by the first of them to be processed
+type testBatch struct {
+	files []string
+	once  sync.Once
+	tests map[string]string
+}
+
+// testBatcher hands out the tests generated for each batched file
+type testBatcher struct {
+	provider generator.Provider
+	opts     generator.TestOptions
+	batches  map[string]*testBatch
+}
+
+// batcher is the testBatcher of a folder run with --batch-size, nil otherwise
+var batcher *testBatcher
+
+// newTestBatcher groups the small files among files that would be generated
+// for as a whole into batches of up to --batch-size files of the same
+// package. It returns nil when nothing can be batched.
+func newTestBatcher(files []string, provider generator.Provider) *testBatcher {
+	if batchSize <= 1 || perFunction || withMocks || mutateTests {
+		return nil
+	}
+
+	byDir := make(map[string][]string)
+	var dirs []string
+	for _, file := range files {
+		if !batchable(file) {
+			continue
+		}
+		dir := filepath.Dir(file)
+		if byDir[dir] == nil {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], file)
+	}
+
+	b := &testBatcher{provider: provider, opts: testOptions(), batches: make(map[string]*testBatch)}
+	for _, dir := range dirs {
+		group := byDir[dir]
+		for len(group) > 1 {
+			n := min(batchSize, len(group))
+			batch := &testBatch{files: group[:n]}
+			for _, file := range batch.files {
+				b.batches[file] = batch
+			}
+			group = group[n:]
+		}
+	}
+	if len(b.batches) == 0 {
+		return nil
+	}
+	return b
+}
+
+// batchable reports whether tests for file would be generated from the whole
+// file in one request, into a new or overwritten test file, and the file is
+// small enough to share a prompt. Files with HTTP handlers or, with
+// --db-mock, --grpc, --golden and --property, database code, gRPC servers,
+// functions returning large values and pure functions get their own prompt.
+func batchable(file string) bool {
+	info, err := os.Stat(file)
+	if err != nil || info.Size() > batchMaxFileBytes {
+		return false
+	}
+	if _, err := os.Stat(generateOutputFor(file)); err == nil && !forceOverwrite {
+		return false
+	}
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return false
+	}
+	parsed, err := source.Parse(file, src)
+	if err != nil {
+		return false
+	}
+	targets, all, err := testTargets(parsed)
+	return err == nil && all && len(targets) > 0 && len(parsed.HTTPHandlers(targets)) == 0 &&
+		(!dbMock || len(parsed.SQLFuncs(targets)) == 0) && (!grpcMode || len(parsed.GRPCServices()) == 0) &&
+		(!goldenMode || len(parsed.GoldenCandidates(targets)) == 0) &&
+		(!propertyMode || len(parsed.PureFuncs(targets)) == 0)
+}
+
+// tests returns the tests generated for file by its batch, requesting them
+// for the whole batch on first use. It reports false for files that are not
+// batched or that the batch response left out, which are generated for on
+// their own. The request's tokens count towards the file that made it.
+func (b *testBatcher) tests(ctx context.Context, file string) (string, bool) {
+	if b == nil {
+		return "", false
+	}
+	batch, ok := b.batches[file]
+	if !ok {
+		return "", false
+	}
+	batch.once.Do(func() {
+		files := make([]generator.BatchFile, 0, len(batch.files))
+		for _, f := range batch.files {
+			code, err := os.ReadFile(f)
+			if err != nil {
+				return
+			}
+			files = append(files, generator.BatchFile{Name: filepath.Base(f), Code: string(code)})
+		}
+		tests, err := generator.GenerateBatchTests(ctx, files, b.provider, b.opts)
+		if err != nil {
+			slog.Warn("batch request failed, generating files separately", "files", len(files), "err", err)
+			return
+		}
+		slog.Debug("batch generated", "files", len(files), "returned", len(tests))
+		batch.tests = tests
+	})
+	tests, ok := batch.tests[filepath.Base(file)]
+	return tests, ok
+}
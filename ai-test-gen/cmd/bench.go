@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/pipeline"
+	"github.com/knbr13/aitestgen/pkg/runner"
+)
+
+var (
+	benchInputFile   string
+	benchOutputFile  string
+	benchInputFolder string
+	benchConcurrency int
+	benchMaxRepairs  int
+	benchForce       bool
+	benchProvider    providerOptions
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Generate benchmarks",
+	Run: func(cmd *cobra.Command, args []string) {
+		report, ctx := newReport(cmd.Context(), "bench")
+
+		provider, err := benchProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		report.setModel(benchProvider.name, benchProvider.modelName())
+
+		output := benchFileFor
+		if benchInputFile != "" && benchOutputFile != "" {
+			output = func(string) string { return benchOutputFile }
+		}
+		p := filePipeline(report, benchConcurrency, output, func(ctx context.Context, job pipeline.Job) error {
+			return generateBenchFile(ctx, provider, job.Input, job.Output)
+		}, "benchmarks generated", "benchmark generation failed")
+
+		if benchInputFile != "" {
+			// a failure is printed rather than logged, as it ends the run
+			p.Report = func(job pipeline.Job, err error) {
+				logOutcome(job, err, "benchmarks generated", "")
+			}
+			err := p.Do(ctx, benchInputFile)
+			report.finish()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if benchInputFolder != "" {
+			p.Walk = func(ctx context.Context) ([]string, error) {
+				return inputFiles(ctx, "", benchInputFolder)
+			}
+			_, err := p.Run(ctx)
+			report.finish()
+			if errors.Is(err, pipeline.ErrNoFiles) {
+				fmt.Println("No Go files found in folder.")
+				os.Exit(1)
+			}
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if ctx.Err() != nil {
+				fmt.Println(stopReason(ctx))
+				os.Exit(1)
+			}
+			if report.failed() {
+				os.Exit(1)
+			}
+			return
+		}
+
+		fmt.Println("You must specify either --file or --folder.")
+		os.Exit(1)
+	},
+}
+
+// benchFileFor returns the benchmark file name for a Go source file
+func benchFileFor(file string) string {
+	return strings.TrimSuffix(file, ".go") + "_bench_test.go"
+}
+
+// generateBenchFile generates benchmarks for inFile and writes them to outFile
+func generateBenchFile(ctx context.Context, provider generator.Provider, inFile, outFile string) error {
+	content, err := os.ReadFile(inFile)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+	if _, err := os.Stat(outFile); err == nil && !benchForce {
+		return fmt.Errorf("%s already exists (use --force)", outFile)
+	}
+
+	benchmarks, err := generator.GenerateBenchmarks(ctx, string(content), provider)
+	if err != nil {
+		return fmt.Errorf("generation error: %w", err)
+	}
+
+	w := testWriter{provider: provider, maxRepairs: benchMaxRepairs}
+	return w.write(ctx, string(content), benchmarks, outFile)
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+	benchCmd.Flags().StringVarP(&benchInputFile, "file", "f", "", "Input Go file")
+	benchCmd.Flags().StringVarP(&benchOutputFile, "output", "o", "", "Output benchmark file (only for single file mode)")
+	benchCmd.Flags().StringVarP(&benchInputFolder, "folder", "d", "", "Input folder (recursively processes all Go files)")
+	benchCmd.Flags().IntVarP(&benchConcurrency, "concurrency", "c", runner.DefaultConcurrency, "Number of files processed in parallel in folder mode")
+	benchCmd.Flags().IntVar(&benchMaxRepairs, "max-repairs", 2, "Maximum attempts to fix generated benchmarks that fail to compile (0 disables the check)")
+	benchCmd.Flags().BoolVar(&benchForce, "force", false, "Overwrite existing benchmark files")
+	addFileFilterFlags(benchCmd)
+	benchProvider.addFlags(benchCmd)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// openBrowser opens the file at path in the default browser, or the one in
+// $BROWSER
+func openBrowser(ctx context.Context, path string) error {
+	var name string
+	var args []string
+	switch {
+	case os.Getenv("BROWSER") != "":
+		name = os.Getenv("BROWSER")
+	case runtime.GOOS == "windows":
+		name, args = "rundll32", []string{"url.dll,FileProtocolHandler"}
+	case runtime.GOOS == "darwin":
+		name = "open"
+	default:
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return errors.New("no display to open a browser on")
+		}
+		name = "xdg-open"
+	}
+	return exec.CommandContext(ctx, name, append(args, path)...).Run()
+}
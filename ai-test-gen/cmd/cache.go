@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/cache"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of model responses",
+	Long: `Responses are cached on disk keyed by the provider, model and prompt, so
+rerunning on unchanged files reuses earlier results instead of calling the
+API again. Pass --no-cache to a command to bypass the cache.`,
+}
+
+var cacheDirCmd = &cobra.Command{
+	Use:   "dir",
+	Short: "Print the cache directory",
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := cache.DefaultDir()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(dir)
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached responses",
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := cache.DefaultDir()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		n, err := cache.Open(dir).Clear()
+		if err != nil {
+			fmt.Printf("Error clearing cache: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %d cached responses from %s\n", n, dir)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheDirCmd, cacheClearCmd)
+}
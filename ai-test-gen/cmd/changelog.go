@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/changelog"
+	"github.com/knbr13/aitestgen/pkg/formatter"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/vcs"
+)
+
+var (
+	changelogDir      string
+	changelogFrom     string
+	changelogTo       string
+	changelogVersion  string
+	changelogOutput   string
+	changelogProvider providerOptions
+)
+
+var changelogCmd = &cobra.Command{
+	Use:   "changelog",
+	Short: "Generate release notes from the git log",
+	Long: `Read the commits between two refs, usually tags, group them by the kind of
+change and the area of the project they touch, and ask the model for the
+release notes in Keep a Changelog format. The notes are added to CHANGELOG.md
+above the previous release, replacing the section of the same version if there
+is one.
+
+--from defaults to the tag before --to, and --version to --to when it is a tag,
+or Unreleased.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		report, ctx := newReport(cmd.Context(), "changelog")
+
+		from := changelogFrom
+		if from == "" {
+			tag, err := vcs.PreviousTag(ctx, changelogDir, changelogTo)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			from = tag
+		}
+		version, date := changelogVersion, ""
+		if version == "" {
+			version = "Unreleased"
+			if vcs.IsTag(ctx, changelogDir, changelogTo) {
+				version = changelogTo
+			}
+		}
+		if version != "Unreleased" {
+			date = time.Now().Format(time.DateOnly)
+			if vcs.IsTag(ctx, changelogDir, changelogTo) {
+				if d, err := vcs.CommitDate(ctx, changelogDir, changelogTo); err == nil {
+					date = d
+				}
+			}
+		}
+		output := changelogOutput
+		if output == "" {
+			output = filepath.Join(changelogDir, "CHANGELOG.md")
+		}
+
+		commits, err := vcs.Log(ctx, changelogDir, from, changelogTo)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(commits) == 0 {
+			fmt.Println("No commits found in range.")
+			os.Exit(1)
+		}
+		described := changelog.Describe(changelog.Group(commits))
+		existing, _ := os.ReadFile(output)
+
+		if dryRun {
+			plan := dryRunPlan{model: changelogProvider.modelName()}
+			plan.add(commitRange(from, changelogTo), output, []string{generator.ChangelogRequestPrompt(version, date, described)}, nil)
+			plan.summary()
+			return
+		}
+
+		provider, err := changelogProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		report.setModel(changelogProvider.name, changelogProvider.modelName())
+
+		err = report.track(ctx, commitRange(from, changelogTo), output, func(ctx context.Context) error {
+			section, err := generator.GenerateChangelog(ctx, version, date, described, provider)
+			if err != nil {
+				return fmt.Errorf("generation error: %w", err)
+			}
+			updated := changelog.Insert(string(existing), formatter.FormatDocumentation(section))
+			if showDiff && !reviewChange(output, string(existing), updated) {
+				return errDeclined
+			}
+			if err := os.WriteFile(output, []byte(updated), 0644); err != nil {
+				return fmt.Errorf("write error: %w", err)
+			}
+			return nil
+		})
+		report.finish()
+		if errors.Is(err, errDeclined) {
+			fmt.Printf("Not written: %s\n", output)
+			return
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Release notes for %s (%d commits) written: %s\n", version, len(commits), output)
+	},
+}
+
+// commitRange names the commits between from and to as git does
+func commitRange(from, to string) string {
+	if from == "" {
+		return to
+	}
+	return from + ".." + to
+}
+
+func init() {
+	rootCmd.AddCommand(changelogCmd)
+	changelogCmd.Flags().StringVarP(&changelogDir, "dir", "d", ".", "Git repository to read the log of")
+	changelogCmd.Flags().StringVar(&changelogFrom, "from", "", "Ref the release starts after (default the previous tag)")
+	changelogCmd.Flags().StringVar(&changelogTo, "to", "HEAD", "Ref the release ends at")
+	changelogCmd.Flags().StringVar(&changelogVersion, "version", "", "Version of the release (default --to if it is a tag, else Unreleased)")
+	changelogCmd.Flags().StringVarP(&changelogOutput, "output", "o", "", "Changelog file to update (default CHANGELOG.md in --dir)")
+	changelogCmd.Flags().BoolVar(&showDiff, "diff", false, "Show a diff against the existing changelog and ask before writing")
+	changelogCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "With --diff, write without asking")
+	changelogCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the prompt size without calling the API or writing files")
+	changelogCmd.Flags().BoolVar(&showPrompt, "show-prompt", false, "With --dry-run, print the full prompt")
+	changelogProvider.addFlags(changelogCmd)
+}
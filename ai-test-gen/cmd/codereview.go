@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/pipeline"
+	"github.com/knbr13/aitestgen/pkg/runner"
+	"github.com/knbr13/aitestgen/pkg/sarif"
+	"github.com/knbr13/aitestgen/pkg/vcs"
+)
+
+// Output formats of review-code
+const (
+	findingsText  = "text"
+	findingsJSON  = "json"
+	findingsSARIF = "sarif"
+)
+
+// informationURI is the home of the tool, linked from SARIF logs
+const informationURI = "https://github.com/knbr13/aitestgen"
+
+var (
+	reviewCodeFile        string
+	reviewCodeFolder      string
+	reviewCodeFormat      string
+	reviewCodeOutput      string
+	reviewCodeFailOn      string
+	reviewCodeConcurrency int
+	reviewCodeProvider    providerOptions
+)
+
+// fileFinding is a review finding in a file, as written by --format json
+type fileFinding struct {
+	File string `json:"file"`
+	generator.Finding
+}
+
+var reviewCodeCmd = &cobra.Command{
+	Use:   "review-code",
+	Short: "Review Go code and report findings with severities and suggested fixes",
+	Long: `Send each file to the model with a review prompt and report its findings:
+severity, line range, what is wrong and how to fix it. With --changed only the
+changes since --base are reviewed, the rest of the file serving as context.
+
+Findings are printed as text, or with --format json or sarif written as JSON or
+as a SARIF log that GitHub code scanning can upload. --fail-on exits with
+status 1 when a finding is at least that severe, to fail a CI job.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		report, ctx := newReport(cmd.Context(), "review-code")
+
+		if reviewCodeFormat != findingsText && reviewCodeFormat != findingsJSON && reviewCodeFormat != findingsSARIF {
+			fmt.Printf("Unknown format %q (use text, json or sarif).\n", reviewCodeFormat)
+			os.Exit(1)
+		}
+		if reviewCodeFailOn != "" && generator.SeverityRank(reviewCodeFailOn) == 0 {
+			fmt.Printf("Unknown severity %q (use error, warning or info).\n", reviewCodeFailOn)
+			os.Exit(1)
+		}
+		if jsonOutput && reviewCodeFormat != findingsText && reviewCodeOutput == "" {
+			fmt.Println("--json prints the run report to stdout; write the findings with --output.")
+			os.Exit(1)
+		}
+		// the findings alone go to stdout, so they can be piped
+		out := io.Writer(os.Stdout)
+		if reviewCodeFormat != findingsText && reviewCodeOutput == "" {
+			os.Stdout = os.Stderr
+		}
+
+		files, err := inputFiles(ctx, reviewCodeFile, reviewCodeFolder)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(files) == 0 {
+			fmt.Println("No Go files to review.")
+			return
+		}
+		root := reviewCodeFolder
+		if root == "" {
+			root = "."
+		}
+
+		if dryRun {
+			plan := dryRunPlan{model: reviewCodeProvider.modelName()}
+			for _, file := range files {
+				code, diff, err := reviewInput(ctx, root, file)
+				plan.add(file, "findings", []string{generator.ReviewRequestPrompt(file, code, diff)}, err)
+			}
+			plan.summary()
+			return
+		}
+
+		provider, err := reviewCodeProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		report.setModel(reviewCodeProvider.name, reviewCodeProvider.modelName())
+
+		var (
+			mu       sync.Mutex
+			findings []fileFinding
+		)
+		p := filePipeline(report, reviewCodeConcurrency, nil, func(ctx context.Context, job pipeline.Job) error {
+			code, diff, err := reviewInput(ctx, root, job.Input)
+			if err != nil {
+				return err
+			}
+			found, err := generator.ReviewCode(ctx, job.Input, code, diff, provider)
+			if err != nil {
+				return fmt.Errorf("review error: %w", err)
+			}
+			mu.Lock()
+			for _, f := range found {
+				findings = append(findings, fileFinding{File: job.Input, Finding: f})
+			}
+			mu.Unlock()
+			slog.Info("reviewed", "file", job.Input, "findings", len(found))
+			return nil
+		}, "", "review failed")
+		p.Walk = func(context.Context) ([]string, error) { return files, nil }
+		if _, err := p.Run(ctx); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		report.finish()
+		sort.SliceStable(findings, func(i, j int) bool {
+			if findings[i].File != findings[j].File {
+				return findings[i].File < findings[j].File
+			}
+			return findings[i].StartLine < findings[j].StartLine
+		})
+
+		if reviewCodeOutput != "" {
+			f, err := os.Create(reviewCodeOutput)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			out = f
+		}
+		if err := writeFindings(out, findings); err != nil {
+			fmt.Printf("Error writing findings: %v\n", err)
+			os.Exit(1)
+		}
+		if reviewCodeOutput != "" {
+			fmt.Printf("%d findings written: %s\n", len(findings), reviewCodeOutput)
+		}
+
+		if ctx.Err() != nil {
+			fmt.Printf("%s: not every file was reviewed\n", stopReason(ctx))
+			os.Exit(1)
+		}
+		if report.failed() || failsOn(findings, reviewCodeFailOn) {
+			os.Exit(1)
+		}
+	},
+}
+
+// reviewInput returns the code of file and, with --changed, its diff from
+// --base
+func reviewInput(ctx context.Context, root, file string) (code, diff string, err error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return "", "", err
+	}
+	if changedOnly {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return "", "", err
+		}
+		if diff, err = vcs.FileDiff(ctx, root, baseRef, rel); err != nil {
+			return "", "", err
+		}
+	}
+	return string(src), diff, nil
+}
+
+// writeFindings writes findings to w in the --format format. SARIF logs give
+// paths relative to the current directory, as code scanning expects them
+// relative to the repository root.
+func writeFindings(w io.Writer, findings []fileFinding) error {
+	switch reviewCodeFormat {
+	case findingsJSON:
+		return writeJSON(w, findings)
+	case findingsSARIF:
+		log := sarif.New("aitestgen", "", informationURI)
+		for _, f := range findings {
+			text := f.Message
+			if f.Suggestion != "" {
+				text += "\n\nSuggestion: " + f.Suggestion
+			}
+			category := f.Category
+			if category == "" {
+				category = "general"
+			}
+			log.Add(sarif.Result{
+				RuleID:    "review/" + category,
+				Level:     sarifLevel(f.Severity),
+				Message:   sarif.Message{Text: text},
+				Locations: []sarif.Location{sarif.FileLocation(".", f.File, f.StartLine, f.EndLine)},
+			}, "Code review: "+category)
+		}
+		return log.Write(w)
+	}
+
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(w, "No findings.")
+		return err
+	}
+	for _, f := range findings {
+		lines := fmt.Sprint(f.StartLine)
+		if f.EndLine > f.StartLine {
+			lines += fmt.Sprintf("-%d", f.EndLine)
+		}
+		fmt.Fprintf(w, "%s:%s: %s", f.File, lines, f.Severity)
+		if f.Category != "" {
+			fmt.Fprintf(w, " [%s]", f.Category)
+		}
+		fmt.Fprintf(w, ": %s\n", f.Message)
+		if f.Suggestion != "" {
+			fmt.Fprintf(w, "    suggestion: %s\n", strings.ReplaceAll(f.Suggestion, "\n", "\n    "))
+		}
+	}
+	return nil
+}
+
+// writeJSON writes v to w as indented JSON
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// sarifLevel maps a finding severity to a SARIF level
+func sarifLevel(severity string) string {
+	switch severity {
+	case generator.SeverityError:
+		return sarif.LevelError
+	case generator.SeverityInfo:
+		return sarif.LevelNote
+	}
+	return sarif.LevelWarning
+}
+
+// failsOn reports whether one of findings is at least as severe as severity
+func failsOn(findings []fileFinding, severity string) bool {
+	if severity == "" {
+		return false
+	}
+	for _, f := range findings {
+		if generator.SeverityRank(f.Severity) >= generator.SeverityRank(severity) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	rootCmd.AddCommand(reviewCodeCmd)
+	reviewCodeCmd.Flags().StringVarP(&reviewCodeFile, "file", "f", "", "Input Go file")
+	reviewCodeCmd.Flags().StringVarP(&reviewCodeFolder, "folder", "d", "", "Input folder (recursively processes all Go files)")
+	reviewCodeCmd.Flags().IntVarP(&reviewCodeConcurrency, "concurrency", "c", runner.DefaultConcurrency, "Number of files reviewed in parallel in folder mode")
+	reviewCodeCmd.Flags().StringVar(&reviewCodeFormat, "format", findingsText, "Output format (text, json, sarif)")
+	reviewCodeCmd.Flags().StringVarP(&reviewCodeOutput, "output", "o", "", "File to write the findings to (default stdout)")
+	reviewCodeCmd.Flags().StringVar(&reviewCodeFailOn, "fail-on", "", "Exit with status 1 if a finding is at least this severe (error, warning, info)")
+	reviewCodeCmd.Flags().BoolVar(&changedOnly, "changed", false, "Only review the changes to Go files since --base (within --folder, default the current directory)")
+	reviewCodeCmd.Flags().StringVar(&baseRef, "base", "HEAD", "Git ref that --changed compares the working tree against")
+	reviewCodeCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the prompt size without calling the API")
+	reviewCodeCmd.Flags().BoolVar(&showPrompt, "show-prompt", false, "With --dry-run, print the full prompt")
+	addFileFilterFlags(reviewCodeCmd)
+	reviewCodeProvider.addFlags(reviewCodeCmd)
+}
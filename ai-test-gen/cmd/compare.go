@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/coverage"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/gotool"
+	"github.com/knbr13/aitestgen/pkg/source"
+	"github.com/knbr13/aitestgen/pkg/tokens"
+)
+
+var (
+	compareFile       string
+	compareModels     string
+	compareMaxRepairs int
+	compareProvider   providerOptions
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "Compare the tests of several models for the same file",
+	Long: `Generate tests for one Go file with each of two or more models, then compile
+and run every result and print them side by side: whether the tests compile,
+how many pass, the coverage of the file they reach on their own, the time the
+model took, the tokens used and their cost.
+
+--models lists the entries like --fallback: provider/model, a provider with its
+default model, or a model of the provider known to serve it or else of
+--provider. The API key of another provider comes from its <PROVIDER>_API_KEY
+environment variable or the saved profile named after it.
+
+The models are run one after the other and their tests written next to the file
+as <name>_aitestgen_compare_test.go, removed afterwards. The file's own test file is
+moved aside meanwhile, so its tests don't clash with the generated ones. No
+repair is attempted unless --max-repairs is set, so the raw outputs are
+compared. Use --no-cache so that cached responses don't skew the latency.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if compareFile == "" {
+			fmt.Println("You must specify --file.")
+			os.Exit(1)
+		}
+		entries := parseFallback(compareModels, compareProvider.name)
+		if len(entries) < 2 {
+			fmt.Println("--models must list at least two models to compare.")
+			os.Exit(1)
+		}
+		content, err := os.ReadFile(compareFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// the existing tests would clash with the generated ones
+		existing := testFileFor(compareFile)
+		backup := existing + ".aitestgen.bak"
+		if err := os.Rename(existing, backup); err == nil {
+			defer os.Rename(backup, existing)
+		}
+
+		ctx := cmd.Context()
+		results := make([]comparison, 0, len(entries))
+		for _, e := range entries {
+			res := comparison{name: e.provider + "/" + e.model}
+			provider, err := compareProvider.entryProvider(e)
+			if err == nil {
+				res.name = e.provider + "/" + compareProvider.entryModel(e)
+				err = compareModel(ctx, provider, compareFile, string(content), &res)
+			}
+			res.err = err
+			results = append(results, res)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		printComparison(results)
+
+		if ctx.Err() != nil {
+			fmt.Println("Interrupted")
+			os.Exit(1)
+		}
+		for _, res := range results {
+			if res.generated {
+				return
+			}
+		}
+		os.Exit(1)
+	},
+}
+
+// comparison is the outcome of one model in the compare command
+type comparison struct {
+	name      string
+	generated bool
+	compiled  bool
+	passed    int
+	total     int
+	coverage  float64
+	latency   time.Duration
+	usage     *generator.Usage
+	err       error
+}
+
+// entryModel returns the model of a --models entry, the provider's default
+// when none is given
+func (o *providerOptions) entryModel(e fallbackEntry) string {
+	fo := *o
+	fo.name, fo.model = e.provider, e.model
+	return fo.modelName()
+}
+
+// entryProvider returns the provider of a --models entry, built like those of
+// the --fallback chain
+func (o *providerOptions) entryProvider(e fallbackEntry) (generator.Provider, error) {
+	fo := *o
+	fo.name, fo.model, fo.profile, fo.fallback = e.provider, e.model, "", ""
+	if !strings.EqualFold(e.provider, o.name) {
+		// the endpoint flags only concern --provider
+		fo.baseURL, fo.deployment, fo.apiVersion = "", "", ""
+	}
+	var err error
+	if fo.apiKey, err = o.fallbackKey(e.provider); err != nil {
+		return nil, fmt.Errorf("%s: %w", e.provider, err)
+	}
+	return fo.newProvider()
+}
+
+// compareModel generates tests for file with provider, writes them next to
+// it and records in res whether they compile, how many pass and what they
+// cover
+func compareModel(ctx context.Context, provider generator.Provider, file, code string, res *comparison) error {
+	parsed, err := source.Parse(file, []byte(code))
+	if err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+	opts := testOptions()
+	opts.PackageContext = packageContext(ctx, file, parsed)
+	opts.Generics = genericFuncs(ctx, parsed, parsed.Funcs())
+
+	res.usage = &generator.Usage{}
+	ctx = generator.WithUsage(ctx, res.usage)
+	start := time.Now()
+	tests, err := generator.GenerateUnitTests(ctx, code, provider, opts)
+	res.latency = time.Since(start)
+	if err != nil {
+		return fmt.Errorf("generation error: %w", err)
+	}
+	res.generated = true
+
+	outFile := strings.TrimSuffix(file, ".go") + "_aitestgen_compare_test.go"
+	defer os.Remove(outFile)
+	w := testWriter{provider: provider, maxRepairs: compareMaxRepairs, opts: opts, pkgDir: filepath.Dir(file)}
+	if err := w.write(ctx, code, tests, outFile); err != nil {
+		return err
+	}
+	dir := filepath.Dir(file)
+	if out, err := gotool.Vet(ctx, dir); err != nil {
+		if compileErrors := gotool.ErrorsFor(out, outFile); compileErrors != "" {
+			return fmt.Errorf("tests don't compile:\n%s", compileErrors)
+		}
+		return fmt.Errorf("the package doesn't build: %w\n%s", err, strings.TrimSpace(out))
+	}
+	res.compiled = true
+
+	written, err := os.ReadFile(outFile)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+	generated, err := source.Parse(outFile, written)
+	if err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+	names := generated.TestNames()
+	res.total = len(names)
+	if res.total == 0 {
+		return nil
+	}
+
+	tmp, err := os.MkdirTemp("", "aitestgen-compare-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	profile := filepath.Join(tmp, "cover.out")
+	out, testErr := gotool.CoverTests(ctx, dir, profile, names)
+	res.passed = res.total - len(ownTests(gotool.FailedTests(out), names))
+	if testErr != nil && res.passed == res.total {
+		// not a test failure, e.g. a panic or a timeout taking the run down
+		res.passed = 0
+	}
+	profiles, err := coverage.ParseProfiles(profile)
+	if err != nil {
+		return nil
+	}
+	for _, p := range profiles {
+		if filepath.Base(p.FileName) == filepath.Base(file) {
+			res.coverage = p.Percent()
+		}
+	}
+	return nil
+}
+
+// printComparison prints the outcome of every model, then the errors of those
+// that failed
+func printComparison(results []comparison) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tCOMPILES\tPASSED\tCOVERAGE\tLATENCY\tTOKENS\tCOST")
+	for _, res := range results {
+		if !res.generated {
+			fmt.Fprintf(w, "%s\tfailed\t-\t-\t-\t-\t-\n", res.name)
+			continue
+		}
+		compiles, passed, cover := "no", "-", "-"
+		if res.compiled {
+			compiles = "yes"
+			passed = fmt.Sprintf("%d/%d", res.passed, res.total)
+			cover = fmt.Sprintf("%.1f%%", res.coverage)
+		}
+		p, r := res.usage.PromptTokens(), res.usage.ResponseTokens()
+		count := fmt.Sprintf("%d/%d", p, r)
+		if res.usage.Estimated() {
+			count = "~" + count
+		}
+		cost := "-"
+		if usd, ok := tokens.Cost(modelOf(res.name), p, r); ok {
+			cost = tokens.FormatCost(usd)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", res.name, compiles, passed, cover, res.latency.Round(100*time.Millisecond), count, cost)
+	}
+	w.Flush()
+
+	for _, res := range results {
+		if res.err != nil {
+			fmt.Printf("\n%s: %v\n", res.name, res.err)
+		}
+	}
+}
+
+// modelOf returns the model of a provider/model name
+func modelOf(name string) string {
+	_, model, _ := strings.Cut(name, "/")
+	return model
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+	compareCmd.Flags().StringVarP(&compareFile, "file", "f", "", "Input Go file")
+	compareCmd.Flags().StringVar(&compareModels, "models", "", `Models to compare, comma-separated, e.g. "gemini/gemini-1.5-pro, ollama/llama3"`)
+	compareCmd.Flags().IntVar(&compareMaxRepairs, "max-repairs", 0, "Maximum attempts to fix tests that fail to compile, for every model")
+	compareProvider.addFlags(compareCmd)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/config"
+)
+
+var configForce bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the project configuration file",
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a default " + config.FileName + " in the current directory",
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := os.Stat(config.FileName); err == nil && !configForce {
+			fmt.Printf("%s already exists (use --force to overwrite)\n", config.FileName)
+			os.Exit(1)
+		}
+		if err := config.Default().Save(config.FileName); err != nil {
+			fmt.Printf("Error writing config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Config written: %s\n", config.FileName)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a value in the project configuration file",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := configFile
+		if path == "" {
+			found, err := config.Find(".")
+			if err != nil {
+				fmt.Printf("Error locating config: %v\n", err)
+				os.Exit(1)
+			}
+			path = found
+		}
+		if path == "" {
+			path = config.FileName
+		}
+
+		c := projectConfig
+		if err := c.Set(args[0], args[1]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := c.Save(path); err != nil {
+			fmt.Printf("Error writing config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s set in %s\n", args[0], path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configSetCmd)
+
+	configInitCmd.Flags().BoolVar(&configForce, "force", false, "Overwrite an existing config file")
+}
@@ -1,62 +1,314 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/coverage"
 )
 
 var (
 	coverProfile string
 	testPackage  string
+	coverFuncs   bool
+	coverMin     float64
+	// coverBadge is the SVG badge written by cover and view-cover
+	coverBadge string
+	// viewCoverOutput is where view-cover writes the page instead of opening it
+	viewCoverOutput string
+	viewCoverMode   string
+
+	diffBase string
+	diffHead string
 )
 
 var coverCmd = &cobra.Command{
 	Use:   "cover",
 	Short: "Run tests and generate coverage profile",
+	Long: `Run the tests with coverage enabled and print the coverage of each package,
+and with --func of each function. With --min, the command fails when total
+coverage is below the threshold, for use as a CI gate.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		report, ctx := newReport(cmd.Context(), "cover")
 		if testPackage == "" {
 			testPackage = "./..."
 		}
 
-		testCmd := exec.Command("go", "test", testPackage, "-coverprofile", coverProfile)
+		testCmd := exec.CommandContext(ctx, "go", "test", testPackage, "-coverprofile", coverProfile)
 		testCmd.Stdout = os.Stdout
 		testCmd.Stderr = os.Stderr
 
 		fmt.Printf("Running tests for package: %s\n", testPackage)
 		if err := testCmd.Run(); err != nil {
+			report.add(fileResult{Input: testPackage, Output: coverProfile, Status: statusFailed, Error: err.Error()})
+			report.finish()
 			fmt.Printf("Error running tests: %v\n", err)
 			os.Exit(1)
 		}
 
+		profiles, err := coverage.ParseProfiles(coverProfile)
+		if err != nil {
+			fmt.Printf("Error reading coverage profile: %v\n", err)
+			os.Exit(1)
+		}
+		total := coverage.Percent(profiles)
+
+		if jsonOutput {
+			reportCoverage(report, coverProfile, profiles)
+		} else {
+			printCoverage(ctx, profiles)
+			report.Coverage = &total
+		}
+		report.finish()
+
 		fmt.Printf("Coverage profile generated: %s\n", coverProfile)
+		if coverBadge != "" {
+			writeBadge(total)
+		}
+		if coverMin > 0 && total < coverMin {
+			fmt.Printf("Coverage %.1f%% is below the minimum of %.1f%%\n", total, coverMin)
+			os.Exit(1)
+		}
+	},
+}
+
+var coverDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Report which functions gained or lost coverage between two profiles",
+	Long: `Compare two coverage profiles, for example taken before and after adding
+generated tests, and list the functions whose coverage changed. Source files
+are read from the current module, so run it from the module the profiles were
+taken in.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		base, err := coverage.ParseProfiles(diffBase)
+		if err != nil {
+			fmt.Printf("Error reading base profile: %v\n", err)
+			os.Exit(1)
+		}
+		head, err := coverage.ParseProfiles(diffHead)
+		if err != nil {
+			fmt.Printf("Error reading head profile: %v\n", err)
+			os.Exit(1)
+		}
+
+		baseFuncs, err := profileFuncs(ctx, base)
+		if err != nil {
+			fmt.Printf("Error computing function coverage: %v\n", err)
+			os.Exit(1)
+		}
+		headFuncs, err := profileFuncs(ctx, head)
+		if err != nil {
+			fmt.Printf("Error computing function coverage: %v\n", err)
+			os.Exit(1)
+		}
+
+		deltas := coverage.DiffFuncs(baseFuncs, headFuncs)
+		if len(deltas) == 0 {
+			fmt.Println("No function changed coverage.")
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "FUNCTION\tLOCATION\tBASE\tHEAD\tCHANGE")
+			for _, d := range deltas {
+				fmt.Fprintf(w, "%s\t%s:%d\t%s\t%s\t%+.1f\n", d.Name, relPath(d.File), d.StartLine, funcPercent(d.Before), funcPercent(d.After), d.Change())
+			}
+			w.Flush()
+		}
+
+		before, after := coverage.Percent(base), coverage.Percent(head)
+		fmt.Printf("Total: %.1f%% -> %.1f%% (%+.1f)\n", before, after, after-before)
 	},
 }
 
+// funcPercent formats a function's coverage, or "-" when it is not in the profile
+func funcPercent(f *coverage.FuncCoverage) string {
+	if f == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", f.Percent())
+}
+
+// relPath returns file relative to the working directory when it lies below it
+func relPath(file string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return file
+	}
+	if rel, err := filepath.Rel(wd, file); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return rel
+	}
+	return file
+}
+
+// view-cover modes
+const (
+	viewModeHTML = "html"
+	viewModeFunc = "func"
+)
+
 var viewCoverCmd = &cobra.Command{
 	Use:   "view-cover",
 	Short: "Visualize coverage profile in browser",
+	Long: `Render the coverage profile as HTML and open it in the default browser, or
+the one named by $BROWSER. With --output, or when no browser can be opened,
+the page is written to a file instead. --mode func prints the coverage of each
+function like go tool cover -func, and --badge writes an SVG coverage badge,
+without opening the page unless --mode is given too.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		viewCmd := exec.Command("go", "tool", "cover", "-html", coverProfile)
-		viewCmd.Stdout = os.Stdout
-		viewCmd.Stderr = os.Stderr
+		if viewCoverMode != viewModeHTML && viewCoverMode != viewModeFunc {
+			fmt.Printf("Unknown mode %q (use html or func).\n", viewCoverMode)
+			os.Exit(1)
+		}
+		if coverBadge != "" {
+			profiles, err := coverage.ParseProfiles(coverProfile)
+			if err != nil {
+				fmt.Printf("Error reading coverage profile: %v\n", err)
+				os.Exit(1)
+			}
+			writeBadge(coverage.Percent(profiles))
+			if !cmd.Flags().Changed("mode") {
+				return
+			}
+		}
+		if viewCoverMode == viewModeFunc {
+			if err := printFuncCoverage(cmd.Context()); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
 
-		fmt.Printf("Opening coverage visualization for: %s\n", coverProfile)
-		if err := viewCmd.Run(); err != nil {
-			fmt.Printf("Error opening coverage: %v\n", err)
+		out := viewCoverOutput
+		if out == "" {
+			dir, err := os.MkdirTemp("", "aitestgen-cover-")
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			// left behind for the browser to read once this command exits
+			out = filepath.Join(dir, "coverage.html")
+		}
+		renderCmd := exec.CommandContext(cmd.Context(), "go", "tool", "cover", "-html", coverProfile, "-o", out)
+		renderCmd.Stdout = os.Stdout
+		renderCmd.Stderr = os.Stderr
+		if err := renderCmd.Run(); err != nil {
+			fmt.Printf("Error rendering coverage: %v\n", err)
 			os.Exit(1)
 		}
+		if viewCoverOutput != "" {
+			fmt.Printf("Coverage page written: %s\n", out)
+			return
+		}
+
+		fmt.Printf("Opening coverage visualization for: %s\n", coverProfile)
+		if err := openBrowser(cmd.Context(), out); err != nil {
+			fmt.Printf("Could not open a browser (%v); the coverage page is at %s\n", err, out)
+		}
 	},
 }
 
+// printFuncCoverage prints the coverage of each function of the profile and
+// the total, in the format of go tool cover -func
+func printFuncCoverage(ctx context.Context) error {
+	profiles, err := coverage.ParseProfiles(coverProfile)
+	if err != nil {
+		return fmt.Errorf("reading coverage profile: %w", err)
+	}
+	funcs, err := profileFuncs(ctx, profiles)
+	if err != nil {
+		return fmt.Errorf("computing function coverage: %w", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 1, 8, 1, '\t', 0)
+	for _, fn := range funcs {
+		fmt.Fprintf(w, "%s:%d:\t%s\t%.1f%%\n", fn.ProfileFile, fn.StartLine, fn.Name, fn.Percent())
+	}
+	fmt.Fprintf(w, "total:\t(statements)\t%.1f%%\n", coverage.Percent(profiles))
+	return w.Flush()
+}
+
+// writeBadge writes the coverage badge for pct to --badge
+func writeBadge(pct float64) {
+	if err := os.WriteFile(coverBadge, []byte(coverage.Badge(pct)), 0644); err != nil {
+		fmt.Printf("Error writing badge: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Coverage badge written: %s\n", coverBadge)
+}
+
+// reportCoverage adds the per-file and total coverage in profiles, read from
+// the profile file, to the report
+func reportCoverage(report *runReport, profile string, profiles []*coverage.Profile) {
+	for _, p := range profiles {
+		pct := p.Percent()
+		report.add(fileResult{Input: p.FileName, Output: profile, Status: statusCovered, Coverage: &pct})
+	}
+	pct := coverage.Percent(profiles)
+	report.Coverage = &pct
+}
+
+// printCoverage prints a table of the coverage per package and, with --func,
+// per function
+func printCoverage(ctx context.Context, profiles []*coverage.Profile) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tSTATEMENTS\tCOVERAGE")
+	for _, pkg := range coverage.Packages(profiles) {
+		fmt.Fprintf(w, "%s\t%d/%d\t%.1f%%\n", pkg.Path, pkg.Covered, pkg.Total, pkg.Percent())
+	}
+	fmt.Fprintf(w, "total\t\t%.1f%%\n", coverage.Percent(profiles))
+	w.Flush()
+
+	if !coverFuncs {
+		return
+	}
+	funcs, err := profileFuncs(ctx, profiles)
+	if err != nil {
+		fmt.Printf("Error computing function coverage: %v\n", err)
+		return
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FUNCTION\tLOCATION\tCOVERAGE")
+	for _, fn := range funcs {
+		fmt.Fprintf(w, "%s\t%s:%d\t%.1f%%\n", fn.Name, relPath(fn.File), fn.StartLine, fn.Percent())
+	}
+	w.Flush()
+}
+
+// profileFuncs returns the per-function coverage of profiles, locating the
+// source files from the current directory
+func profileFuncs(ctx context.Context, profiles []*coverage.Profile) ([]coverage.FuncCoverage, error) {
+	resolve, err := coverage.PackageResolver(ctx, ".")
+	if err != nil {
+		return nil, err
+	}
+	return coverage.Funcs(profiles, resolve)
+}
+
 func init() {
 	rootCmd.AddCommand(coverCmd)
 	rootCmd.AddCommand(viewCoverCmd)
+	coverCmd.AddCommand(coverDiffCmd)
 
 	coverCmd.Flags().StringVarP(&coverProfile, "output", "o", "coverage.out", "Coverage profile filename")
 	coverCmd.Flags().StringVarP(&testPackage, "package", "p", "", "Package to test (default './...')")
+	coverCmd.Flags().BoolVar(&coverFuncs, "func", false, "Also print the coverage of each function")
+	coverCmd.Flags().Float64Var(&coverMin, "min", 0, "Fail when total coverage is below this percentage")
+	coverCmd.Flags().StringVar(&coverBadge, "badge", "", "Also write an SVG badge of the total coverage to this file, e.g. coverage.svg")
+
+	coverDiffCmd.Flags().StringVar(&diffBase, "base", "", "Coverage profile before the change (required)")
+	coverDiffCmd.Flags().StringVar(&diffHead, "head", "coverage.out", "Coverage profile after the change")
+	coverDiffCmd.MarkFlagRequired("base")
 
 	viewCoverCmd.Flags().StringVarP(&coverProfile, "input", "i", "coverage.out", "Coverage profile filename")
+	viewCoverCmd.Flags().StringVarP(&viewCoverOutput, "output", "o", "", "Write the HTML page to this file instead of opening it in a browser")
+	viewCoverCmd.Flags().StringVar(&viewCoverMode, "mode", viewModeHTML, "What to show: html opens the annotated source, func prints the coverage of each function")
+	viewCoverCmd.Flags().StringVar(&coverBadge, "badge", "", "Write an SVG badge of the total coverage to this file, e.g. coverage.svg")
 }
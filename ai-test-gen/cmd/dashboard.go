@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/formatter"
+	"github.com/knbr13/aitestgen/pkg/history"
+)
+
+var (
+	dashboardHistory string
+	dashboardSince   string
+	dashboardFormat  string
+	dashboardOutput  string
+)
+
+var dashboardCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Summarize past runs in a Markdown or HTML dashboard",
+	Long: `Aggregate the runs logged in ` + history.FileName + ` (every command that calls a
+model or measures coverage adds one, unless run with --no-history) into a
+dashboard: files processed and failure rates, tokens and cost per model,
+command and week, and how coverage moved with the cover and improve-coverage
+runs. The log is kept next to the config file, or in the current directory.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		format := dashboardFormat
+		if format == "" {
+			format = formatMarkdown
+			if ext := strings.ToLower(filepath.Ext(dashboardOutput)); ext == ".html" || ext == ".htm" {
+				format = formatHTML
+			}
+		}
+		if format != formatMarkdown && format != formatHTML {
+			fmt.Printf("Unknown format %q (use markdown or html).\n", format)
+			os.Exit(1)
+		}
+		since, err := parseSince(dashboardSince)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		path := dashboardHistory
+		if path == "" {
+			path = filepath.Join(projectDir, history.FileName)
+		}
+		runs, err := history.Load(path)
+		if err != nil {
+			fmt.Printf("Error reading run history: %v\n", err)
+			os.Exit(1)
+		}
+
+		const title = "aigen report"
+		out := history.Summarize(runs, since).Markdown(title)
+		if format == formatHTML {
+			if out, err = formatter.RenderHTML(title, out, ""); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if dashboardOutput == "" {
+			fmt.Print(out)
+			return
+		}
+		if err := os.WriteFile(dashboardOutput, []byte(out), 0644); err != nil {
+			fmt.Printf("Error writing report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Report written: %s\n", dashboardOutput)
+	},
+}
+
+// parseSince parses --since, a date such as 2024-01-31 or a duration back
+// from now such as 720h. "" means the whole history.
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q: use a date (2024-01-31) or a duration (720h)", value)
+	}
+	return t, nil
+}
+
+func init() {
+	rootCmd.AddCommand(dashboardCmd)
+	dashboardCmd.Flags().StringVar(&dashboardHistory, "history", "", "Run history to read (default: "+history.FileName+" next to the config file, or in the current directory)")
+	dashboardCmd.Flags().StringVar(&dashboardSince, "since", "", "Only include runs since this date (2024-01-31) or for this long back (720h)")
+	dashboardCmd.Flags().StringVar(&dashboardFormat, "format", "", "Output format, markdown or html (default: html for an --output ending in .html, else markdown)")
+	dashboardCmd.Flags().StringVarP(&dashboardOutput, "output", "o", "", "File to write the report to (default: stdout)")
+}
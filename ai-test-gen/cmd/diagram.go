@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/formatter"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/runner"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// diagramStart and diagramEnd mark the diagrams embedded in a document with
+// --embed, so that later runs replace them
+const (
+	diagramStart = "<!-- aigen:diagrams -->"
+	diagramEnd   = "<!-- /aigen:diagrams -->"
+)
+
+var (
+	diagramDir      string
+	diagramOutput   string
+	diagramFormat   string
+	diagramEmbed    string
+	diagramProvider providerOptions
+)
+
+var diagramCmd = &cobra.Command{
+	Use:   "diagram",
+	Short: "Generate architecture diagrams of the module",
+	Long: `Analyze the imports between the module's packages, its key types and its HTTP
+handlers and routes, and ask the model for Mermaid (or PlantUML) diagrams: a
+package dependency graph, the relationships between types and the flow of HTTP
+requests. The diagrams are written to ARCHITECTURE.md, or with --embed into an
+existing document such as README.md, between ` + diagramStart + ` and
+` + diagramEnd + ` markers that later runs replace.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		report, ctx := newReport(cmd.Context(), "diagram")
+
+		if diagramFormat != "mermaid" && diagramFormat != "plantuml" {
+			fmt.Printf("Error: unknown --format %q (mermaid, plantuml)\n", diagramFormat)
+			os.Exit(1)
+		}
+		if diagramEmbed != "" && diagramOutput != "" {
+			fmt.Println("Error: --embed and --output can't be used together")
+			os.Exit(1)
+		}
+		output := diagramOutput
+		switch {
+		case diagramEmbed != "":
+			output = diagramEmbed
+		case output == "":
+			output = filepath.Join(diagramDir, "ARCHITECTURE.md")
+		}
+		filter, err := fileFilter(diagramDir)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		files, err := runner.GoFiles(diagramDir, filter)
+		if err != nil {
+			fmt.Printf("Error listing files: %v\n", err)
+			os.Exit(1)
+		}
+		if len(files) == 0 {
+			fmt.Println("No Go files found in folder.")
+			os.Exit(1)
+		}
+		summary, err := source.ArchitectureSummary(diagramDir, files)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		existing, _ := os.ReadFile(output)
+		if diagramEmbed != "" && existing == nil {
+			fmt.Printf("Error: --embed: %s not found\n", diagramEmbed)
+			os.Exit(1)
+		}
+
+		if dryRun {
+			plan := dryRunPlan{model: diagramProvider.modelName()}
+			plan.add(diagramDir, output, []string{generator.DiagramRequestPrompt(summary, diagramFormat)}, nil)
+			plan.summary()
+			return
+		}
+
+		provider, err := diagramProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		report.setModel(diagramProvider.name, diagramProvider.modelName())
+
+		err = report.track(ctx, diagramDir, output, func(ctx context.Context) error {
+			diagrams, err := generator.GenerateDiagrams(ctx, summary, diagramFormat, provider)
+			if err != nil {
+				return fmt.Errorf("generation error: %w", err)
+			}
+			diagrams = formatter.FormatDocumentation(diagrams)
+			doc := diagrams + "\n"
+			if diagramEmbed != "" {
+				doc = embedDiagrams(string(existing), formatter.NormalizeMarkdown(diagrams, formatter.MarkdownOptions{HeadingLevel: 2}))
+			}
+			if showDiff && !reviewChange(output, string(existing), doc) {
+				return errDeclined
+			}
+			if err := os.WriteFile(output, []byte(doc), 0644); err != nil {
+				return fmt.Errorf("write error: %w", err)
+			}
+			return nil
+		})
+		report.finish()
+		if errors.Is(err, errDeclined) {
+			fmt.Printf("Not written: %s\n", output)
+			return
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Diagrams written: %s\n", output)
+	},
+}
+
+// embedDiagrams returns doc with the section between the diagram markers
+// replaced by diagrams, or with a marked section appended when doc has none
+func embedDiagrams(doc, diagrams string) string {
+	section := diagramStart + "\n" + diagrams + "\n" + diagramEnd
+	start := strings.Index(doc, diagramStart)
+	end := strings.Index(doc, diagramEnd)
+	if start >= 0 && end > start {
+		return doc[:start] + section + doc[end+len(diagramEnd):]
+	}
+	return strings.TrimRight(doc, "\n") + "\n\n" + section + "\n"
+}
+
+func init() {
+	rootCmd.AddCommand(diagramCmd)
+	diagramCmd.Flags().StringVarP(&diagramDir, "dir", "d", ".", "Root of the module to draw")
+	diagramCmd.Flags().StringVarP(&diagramOutput, "output", "o", "", "File to write the diagrams to (default ARCHITECTURE.md in --dir)")
+	diagramCmd.Flags().StringVar(&diagramFormat, "format", "mermaid", "Diagram language (mermaid, plantuml)")
+	diagramCmd.Flags().StringVar(&diagramEmbed, "embed", "", "Embed the diagrams in this existing Markdown file instead, replacing those of earlier runs")
+	diagramCmd.Flags().BoolVar(&showDiff, "diff", false, "Show a diff against the existing file and ask before writing")
+	diagramCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "With --diff, write without asking")
+	diagramCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the prompt size without calling the API or writing files")
+	diagramCmd.Flags().BoolVar(&showPrompt, "show-prompt", false, "With --dry-run, print the full prompt")
+	addFileFilterFlags(diagramCmd)
+	diagramProvider.addFlags(diagramCmd)
+}
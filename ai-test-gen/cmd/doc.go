@@ -1,14 +1,23 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 
+	"github.com/knbr13/aitestgen/pkg/diff"
+	"github.com/knbr13/aitestgen/pkg/docindex"
 	"github.com/knbr13/aitestgen/pkg/formatter"
 	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/pipeline"
+	"github.com/knbr13/aitestgen/pkg/postprocess"
+	"github.com/knbr13/aitestgen/pkg/runner"
+	"github.com/knbr13/aitestgen/pkg/source"
+	"github.com/knbr13/aitestgen/pkg/state"
 	"github.com/spf13/cobra"
 )
 
@@ -16,100 +25,196 @@ var (
 	docInputFile   string
 	docOutputFile  string
 	docInputFolder string
-	docAPIKey      string
+	docConcurrency int
+	docInline      bool
+	docPatch       bool
+	docFormat      string
+	docNoIndex     bool
+	docOutDir      string
+	// docMarkdown holds --heading-level and --wrap
+	docMarkdown formatter.MarkdownOptions
+	docProvider providerOptions
 )
 
 var docCmd = &cobra.Command{
 	Use:   "doc",
 	Short: "Generate documentation for Go code",
+	Long: `Generate Markdown or HTML documentation for Go code. See doc site for
+publishing the documentation of a whole folder.
+
+With --package, a whole package is documented in one document, so that types
+are described once and the relations between its files are covered. Packages
+too large for one request are documented in parts that are then combined.
+
+With --openapi, the HTTP handlers of --folder (net/http, chi, gin and echo) and
+the routes registering them are described in an OpenAPI 3 document, written to
+openapi.yaml in the folder after checking it with an OpenAPI parser.
+
+With --inline, godoc comments are instead written above the exported
+declarations that lack one, rewriting the source files in place. Add --patch to
+get a unified diff of those changes instead of modifying the files.
+
+Every documentation file records the hash of the source it was written from.
+With --check, nothing is generated: the files of --file or --folder are compared
+against those hashes and the command exits with status 1 listing the ones whose
+documentation is stale, missing or predates the hash, so CI can keep it in sync.
+
+A --folder run also writes ` + docindex.FileName + ` at the root of the folder, linking the
+documentation of every file by package, with the dependencies between the
+packages and the files each type is used in.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if docAPIKey == "" {
-			docAPIKey = os.Getenv("API_KEY")
+		report, ctx := newReport(cmd.Context(), "doc")
+
+		if docPatch && !docInline {
+			fmt.Println("--patch requires --inline.")
+			os.Exit(1)
+		}
+		if docOutDir != "" && docInline {
+			fmt.Println("--out-dir can't be used with --inline, which edits the source files.")
+			os.Exit(1)
+		}
+		if docFormat != formatMarkdown && docFormat != formatHTML {
+			fmt.Printf("Unknown format %q (use markdown or html).\n", docFormat)
+			os.Exit(1)
 		}
-		if docAPIKey == "" {
-			fmt.Println("Missing API key")
+		if _, ok := generator.DocLanguages[generator.DocLanguage]; !ok && generator.DocLanguage != "" {
+			fmt.Printf("Unknown language %q (use %s).\n", generator.DocLanguage, strings.Join(generator.DocLanguageCodes(), ", "))
+			os.Exit(1)
+		}
+		if docMarkdown.HeadingLevel < 0 || docMarkdown.HeadingLevel > 6 {
+			fmt.Println("--heading-level must be between 1 and 6.")
 			os.Exit(1)
 		}
 
-		if docInputFile != "" {
-			content, err := os.ReadFile(docInputFile)
-			if err != nil {
-				fmt.Printf("Error reading file: %v\n", err)
+		if docCheck {
+			if docInline || docPackage != "" || docOpenAPI || watchMode || dryRun {
+				fmt.Println("--check can't be used with --inline, --package, --openapi, --watch or --dry-run.")
 				os.Exit(1)
 			}
+			runDocCheck(cmd.Context())
+			return
+		}
 
-			docs, err := generator.GenerateDocumentation(string(content), docAPIKey)
-			if err != nil {
-				fmt.Printf("Error generating documentation: %v\n", err)
+		if docOpenAPI {
+			if docInputFile != "" || docPackage != "" || docInline || watchMode || docFormat != formatMarkdown {
+				fmt.Println("--openapi can't be used with --file, --package, --inline, --watch or --format.")
 				os.Exit(1)
 			}
+			runOpenAPIDoc(ctx, report)
+			return
+		}
 
-			if docOutputFile == "" {
-				ext := filepath.Ext(docInputFile)
-				docOutputFile = strings.TrimSuffix(docInputFile, ext) + "_doc.md"
+		if docPackage != "" {
+			if docInputFile != "" || docInputFolder != "" || docInline || watchMode {
+				fmt.Println("--package can't be used with --file, --folder, --inline or --watch.")
+				os.Exit(1)
 			}
+			runPackageDoc(ctx, report)
+			return
+		}
 
-			docs = formatter.FormatDocumentation(docs)
-
-			if err := os.WriteFile(docOutputFile, []byte(docs), 0644); err != nil {
-				fmt.Printf("Error writing documentation: %v\n", err)
+		if dryRun {
+			files, err := inputFiles(cmd.Context(), docInputFile, docInputFolder)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
 				os.Exit(1)
 			}
+			plan := dryRunPlan{model: docProvider.modelName()}
+			for _, file := range files {
+				outFile := docOutputFor(file)
+				switch {
+				case docInline && docPatch:
+					outFile = "patch"
+				case docInline:
+					outFile = file
+				case docInputFile != "" && docOutputFile != "":
+					outFile = docOutputFile
+				}
+				prompts, err := planDocFile(file)
+				plan.add(file, outFile, prompts, err)
+			}
+			plan.summary()
+			return
+		}
+
+		provider, err := docProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		report.setModel(docProvider.name, docProvider.modelName())
 
-			fmt.Printf("documentation generated for file: %s\n", docOutputFile)
-		} else if docInputFolder != "" {
-			var files []string
-			err := filepath.Walk(docInputFolder, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
+		var outMu sync.Mutex
+		output, done := docOutputFor, "documentation generated"
+		process := func(ctx context.Context, job pipeline.Job) error {
+			return documentFile(ctx, provider, job.Input, job.Output)
+		}
+		if docInline {
+			// the source files are rewritten, or their patches printed
+			output, done = func(file string) string { return file }, ""
+			process = func(ctx context.Context, job pipeline.Job) error {
+				patch, err := documentInline(ctx, provider, job.Input)
+				switch {
+				case err != nil:
 					return err
-				}
-				if info.IsDir() {
-					return nil
-				}
-				if strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
-					files = append(files, path)
+				case docPatch && docInputFile != "" && docOutputFile != "":
+					if err := os.WriteFile(docOutputFile, []byte(patch), 0644); err != nil {
+						return fmt.Errorf("write error: %w", err)
+					}
+					slog.Info("patch written", "output", docOutputFile)
+				case docPatch:
+					outMu.Lock()
+					fmt.Print(patch)
+					outMu.Unlock()
+				case patch != "":
+					slog.Info("doc comments written", "file", job.Input)
 				}
 				return nil
-			})
-			if err != nil {
-				fmt.Printf("Error walking folder: %v\n", err)
-				os.Exit(1)
 			}
-			if len(files) == 0 {
-				fmt.Println("No Go files found in folder.")
+		}
+		p := filePipeline(report, docConcurrency, output, process, done, "documentation failed")
+
+		if watchMode {
+			if err := watchFiles(ctx, docInputFile, docInputFolder, func(ctx context.Context, file string) { p.Do(ctx, file) }); err != nil {
+				fmt.Printf("Error watching files: %v\n", err)
 				os.Exit(1)
 			}
-			var wg sync.WaitGroup
-			wg.Add(len(files))
-			for _, file := range files {
-				go func(file string) {
-					defer wg.Done()
-					content, err := os.ReadFile(file)
-					if err != nil {
-						fmt.Printf("Error reading file: %v\n", err)
-						os.Exit(1)
-					}
-
-					docs, err := generator.GenerateDocumentation(string(content), docAPIKey)
-					if err != nil {
-						fmt.Printf("Error generating documentation: %v\n", err)
-						os.Exit(1)
-					}
-
-					ext := filepath.Ext(file)
-					outf := strings.TrimSuffix(file, ext) + "_doc.md"
-
-					docs = formatter.FormatDocumentation(docs)
+			return
+		}
 
-					if err := os.WriteFile(outf, []byte(docs), 0644); err != nil {
-						fmt.Printf("Error writing documentation: %v\n", err)
-						os.Exit(1)
-					}
+		if docInputFile != "" {
+			if docOutputFile != "" && !docInline {
+				p.Output = func(string) string { return docOutputFile }
+			}
+			// a failure is printed rather than logged, as it ends the run
+			p.Report = func(job pipeline.Job, err error) { logOutcome(job, err, done, "") }
+			err := p.Do(ctx, docInputFile)
+			report.finish()
+			if err != nil && !leftAlone(err) {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
 
-					fmt.Printf("documentation generated for file: %s\n", outf)
-				}(file)
+		if docInputFolder != "" || changedOnly {
+			res, ok := runFolder(ctx, report, p, docInputFolder)
+			if !ok {
+				return
+			}
+			if !docInline && !docNoIndex && docInputFolder != "" && ctx.Err() == nil {
+				if err := writeDocIndex(docInputFolder, res.Files); err != nil {
+					slog.Error("writing the documentation index failed", "err", err)
+				}
+			}
+			report.finish()
+			if ctx.Err() != nil {
+				fmt.Printf("%s: documentation generated for %d of %d files\n", stopReason(ctx), report.Processed, len(res.Pending))
+				os.Exit(1)
+			}
+			if report.failed() {
+				os.Exit(1)
 			}
-			wg.Wait()
 			return
 		}
 		fmt.Println("You must specify either --file or --folder.")
@@ -117,10 +222,175 @@ var docCmd = &cobra.Command{
 	},
 }
 
+// Documentation output formats
+const (
+	formatMarkdown = "markdown"
+	formatHTML     = "html"
+)
+
+// docOutputFor returns the documentation file written for file in the selected
+// format, next to it or in the same place under --out-dir
+func docOutputFor(file string) string {
+	out := docFileFor(file)
+	if docFormat == formatHTML {
+		out = strings.TrimSuffix(out, filepath.Ext(out)) + ".html"
+	}
+	if docOutDir != "" {
+		out = mirrorPath(docOutDir, stateDir(docInputFolder), out)
+	}
+	return out
+}
+
+// writeDocIndex writes the index linking the documentation of files to the
+// root of folder, or of --out-dir, in the selected format
+func writeDocIndex(folder string, files []string) error {
+	dir := folder
+	if docOutDir != "" {
+		dir = docOutDir
+	}
+	index, err := docindex.Build(folder, dir, "Documentation", files, docOutputFor)
+	if err != nil {
+		return err
+	}
+	out := filepath.Join(dir, docindex.FileName)
+	if docFormat == formatHTML {
+		out = strings.TrimSuffix(out, ".md") + ".html"
+		if index, err = formatter.RenderHTML("Documentation", index, "."); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(out, []byte(index), 0644); err != nil {
+		return err
+	}
+	slog.Info("documentation index written", "output", out)
+	return nil
+}
+
+// generateDocs returns the documentation of code, written with the doc
+// prompt of the config when it sets one
+func generateDocs(ctx context.Context, code string, provider generator.Provider) (string, error) {
+	client, err := generator.New(generator.WithCustomProvider(provider), generator.WithDocPrompt(projectConfig.Prompts.Docs))
+	if err != nil {
+		return "", err
+	}
+	return client.Documentation(ctx, code)
+}
+
+// documentFile writes documentation for inFile to outFile in the selected format
+func documentFile(ctx context.Context, provider generator.Provider, inFile, outFile string) error {
+	content, err := os.ReadFile(inFile)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+
+	docs, err := generateDocs(ctx, string(content), provider)
+	if err != nil {
+		return fmt.Errorf("generation error: %w", err)
+	}
+
+	docs = formatter.NormalizeMarkdown(docs, docMarkdown)
+	if docFormat == formatHTML {
+		if docs, err = formatter.RenderHTML(filepath.Base(inFile), docs, ""); err != nil {
+			return fmt.Errorf("render error: %w", err)
+		}
+	}
+	return writeDocFile(ctx, outFile, withSourceHash(docs, content))
+}
+
+// writeDocFile writes docs to outFile, after passing them through the
+// post-processors and showing the change with --diff
+func writeDocFile(ctx context.Context, outFile, docs string) error {
+	processed, err := postProcess(ctx, outFile, postprocess.KindDoc, []byte(docs))
+	if err != nil {
+		return err
+	}
+	old, _ := os.ReadFile(outFile)
+	docs = source.KeepLineEndings(string(old), string(processed))
+	if showDiff {
+		if !reviewChange(outFile, string(old), docs) {
+			return errDeclined
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outFile), 0755); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+	if err := os.WriteFile(outFile, []byte(docs), 0644); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+	return nil
+}
+
+// documentInline adds doc comments to the undocumented exported declarations
+// in file and returns the change as a unified diff. The file is rewritten
+// unless --patch is set.
+func documentInline(ctx context.Context, provider generator.Provider, file string) (string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("read error: %w", err)
+	}
+	parsed, err := source.Parse(file, content)
+	if err != nil {
+		return "", fmt.Errorf("parse error: %w", err)
+	}
+
+	targets := parsed.Undocumented()
+	if len(targets) == 0 {
+		return "", nil
+	}
+	names := make([]string, len(targets))
+	for i, t := range targets {
+		names[i] = t.Key
+	}
+
+	comments, err := generator.GenerateDocComments(ctx, string(content), names, provider)
+	if err != nil {
+		return "", fmt.Errorf("generation error: %w", err)
+	}
+	updated, err := parsed.InsertDocComments(comments)
+	if err != nil {
+		return "", fmt.Errorf("format error: %w", err)
+	}
+	updated = source.KeepLineEndings(string(content), updated)
+
+	if !docPatch && updated != string(content) {
+		if showDiff && !reviewChange(file, string(content), updated) {
+			return "", errDeclined
+		}
+		if err := os.WriteFile(file, []byte(updated), 0644); err != nil {
+			return "", fmt.Errorf("write error: %w", err)
+		}
+	}
+	return diff.Unified(file, file, string(content), updated), nil
+}
+
 func init() {
 	rootCmd.AddCommand(docCmd)
 	docCmd.Flags().StringVarP(&docInputFile, "file", "f", "", "Input Go file (required)")
 	docCmd.Flags().StringVarP(&docInputFolder, "folder", "d", "", "Input folder (recursively processes all Go files)")
-	docCmd.Flags().StringVarP(&docOutputFile, "output", "o", "", "Output documentation file")
-	docCmd.Flags().StringVarP(&docAPIKey, "key", "k", "", "Gemini API key")
+	docCmd.Flags().StringVar(&docPackage, "package", "", "Document a whole package, e.g. ./pkg/foo, in a single document (written to <name>_doc.md in its directory)")
+	docCmd.Flags().BoolVar(&docOpenAPI, "openapi", false, "Write an OpenAPI 3 document for the HTTP handlers in --folder (default openapi.yaml in the folder)")
+	docCmd.Flags().StringVarP(&docOutputFile, "output", "o", "", "Output documentation file (the patch file with --inline --patch)")
+	docCmd.Flags().IntVarP(&docConcurrency, "concurrency", "c", runner.DefaultConcurrency, "Number of files processed in parallel in folder mode")
+	docCmd.Flags().StringVar(&docFormat, "format", formatMarkdown, "Output format (markdown, html)")
+	docCmd.Flags().StringVar(&generator.DocLanguage, "language", "", "Write the documentation prose, or the --inline comments, in this language ("+strings.Join(generator.DocLanguageCodes(), ", ")+"); code samples stay in Go")
+	docCmd.Flags().IntVar(&docMarkdown.HeadingLevel, "heading-level", 0, "Shift the headings of the documentation so the highest has this level, 1 to 6 (0 keeps them)")
+	docCmd.Flags().IntVar(&docMarkdown.Wrap, "wrap", 0, "Rewrap paragraphs to lines of at most this many characters (0 leaves them)")
+	docCmd.Flags().StringVar(&docOutDir, "out-dir", "", "Write documentation under this directory, mirroring the package layout, instead of next to each source file")
+	docCmd.Flags().BoolVar(&docNoIndex, "no-index", false, "Don't write the "+docindex.FileName+" index linking the documentation of a --folder run")
+	docCmd.Flags().BoolVar(&docInline, "inline", false, "Insert godoc comments above undocumented exported declarations instead of writing Markdown")
+	docCmd.Flags().BoolVar(&docCheck, "check", false, "Don't generate anything; exit with status 1 listing the files whose documentation is out of date with their source")
+	docCmd.Flags().BoolVar(&docPatch, "patch", false, "With --inline, print a unified diff instead of rewriting the source files")
+	docCmd.Flags().BoolVar(&watchMode, "watch", false, "Keep running and regenerate documentation for source files as they change")
+	docCmd.Flags().BoolVar(&changedOnly, "changed", false, "Only process Go files changed since --base (within --folder, default the current directory)")
+	docCmd.Flags().StringVar(&baseRef, "base", "HEAD", "Git ref that --changed compares the working tree against")
+	addFileFilterFlags(docCmd)
+	docCmd.Flags().BoolVar(&resumeRun, "resume", false, "In folder mode, skip the files an interrupted earlier run already finished (see "+state.FileName+")")
+	docCmd.Flags().BoolVar(&failFast, "fail-fast", false, "In folder mode, stop at the first file that fails")
+	docCmd.Flags().Float64Var(&maxCost, "max-cost", 0, "Stop a folder run once its estimated cost in US dollars exceeds this budget (0 for no limit)")
+	docCmd.Flags().BoolVar(&showDiff, "diff", false, "Show a diff against the existing file and ask before writing")
+	docCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "With --diff, write without asking")
+	docCmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the files and prompts that would be processed without calling the API or writing files")
+	docCmd.Flags().BoolVar(&showPrompt, "show-prompt", false, "With --dry-run, print the full prompts")
+	docProvider.addFlags(docCmd)
 }
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/audit"
+)
+
+// docCheck is --check, listing the documentation files that are out of date
+var docCheck bool
+
+// sourceHashLine matches the comment recording the hash of the source a
+// documentation file was written from
+var sourceHashLine = regexp.MustCompile(`<!-- aitestgen:source sha256=([0-9a-f]{64}) -->`)
+
+// withSourceHash appends to docs the comment recording the hash of source
+func withSourceHash(docs string, source []byte) string {
+	return strings.TrimRight(docs, "\n") + "\n\n<!-- aitestgen:source sha256=" + audit.Sum(source) + " -->\n"
+}
+
+// storedSourceHash returns the source hash recorded in docs, or "" when none is
+func storedSourceHash(docs []byte) string {
+	m := sourceHashLine.FindSubmatch(docs)
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
+
+// staleDoc is a source file whose documentation is out of date
+type staleDoc struct {
+	file, doc, reason string
+}
+
+// runDocCheck compares the files of --file or --folder against the source
+// hash recorded in their documentation and exits with status 1 listing the
+// ones whose documentation is stale, missing or has no hash
+func runDocCheck(ctx context.Context) {
+	files, err := inputFiles(ctx, docInputFile, docInputFolder)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	var stale []staleDoc
+	for _, file := range files {
+		doc := docOutputFor(file)
+		if docInputFile != "" && docOutputFile != "" {
+			doc = docOutputFile
+		}
+		reason, err := docStaleness(file, doc)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if reason != "" {
+			stale = append(stale, staleDoc{file, doc, reason})
+		}
+	}
+
+	if len(stale) == 0 {
+		fmt.Printf("Documentation of %d files is up to date\n", len(files))
+		return
+	}
+	for _, s := range stale {
+		fmt.Printf("%s: %s (%s)\n", s.file, s.reason, s.doc)
+	}
+	fmt.Printf("Documentation of %d of %d files is out of date; run doc to regenerate it\n", len(stale), len(files))
+	os.Exit(1)
+}
+
+// docStaleness returns why the documentation doc of file is out of date, or
+// "" when it was written from the current source
+func docStaleness(file, doc string) (string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("read error: %w", err)
+	}
+	docs, err := os.ReadFile(doc)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "no documentation", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("read error: %w", err)
+	}
+	switch stored := storedSourceHash(docs); stored {
+	case "":
+		return "no source hash recorded", nil
+	case audit.Sum(content):
+		return "", nil
+	default:
+		return "source changed since it was documented", nil
+	}
+}
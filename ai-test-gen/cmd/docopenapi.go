@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/runner"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// docOpenAPI is --openapi, writing an OpenAPI document for the HTTP handlers
+var docOpenAPI bool
+
+// runOpenAPIDoc writes an OpenAPI 3 document for the HTTP handlers found in
+// --folder, or the current directory
+func runOpenAPIDoc(ctx context.Context, report *runReport) {
+	root := docInputFolder
+	if root == "" {
+		root = "."
+	}
+	outFile := docOutputFile
+	if outFile == "" {
+		outFile = filepath.Join(root, "openapi.yaml")
+	}
+	filter, err := fileFilter(root)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	files, err := runner.GoFiles(root, filter)
+	if err != nil {
+		fmt.Printf("Error listing files: %v\n", err)
+		os.Exit(1)
+	}
+	summary, err := source.APISummary(root, files)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if summary == "" {
+		fmt.Println("No HTTP handlers found in folder.")
+		os.Exit(1)
+	}
+
+	if dryRun {
+		plan := dryRunPlan{model: docProvider.modelName()}
+		plan.add(root, outFile, []string{generator.OpenAPIRequestPrompt(summary)}, nil)
+		plan.summary()
+		return
+	}
+
+	provider, err := docProvider.newProvider()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	report.setModel(docProvider.name, docProvider.modelName())
+
+	err = report.track(ctx, root, outFile, func(ctx context.Context) error {
+		spec, err := generator.GenerateOpenAPI(ctx, summary, provider)
+		if err != nil {
+			return fmt.Errorf("generation error: %w", err)
+		}
+		return writeDocFile(ctx, outFile, spec)
+	})
+	report.finish()
+	if errors.Is(err, errDeclined) {
+		slog.Info("not written", "output", outFile)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	slog.Info("OpenAPI document generated", "output", outFile)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/formatter"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// docPackage is the --package pattern of the package documented as a whole
+var docPackage string
+
+// runPackageDoc documents the --package package in a single document
+func runPackageDoc(ctx context.Context, report *runReport) {
+	pkg, err := source.LoadPackage(ctx, ".", docPackage)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	files := make([]generator.PackageFile, 0, len(pkg.Files))
+	for _, path := range pkg.Files {
+		code, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		files = append(files, generator.PackageFile{Name: filepath.Base(path), Code: string(code)})
+	}
+	outFile := packageDocOutput(pkg)
+	budget := generator.PromptBudget(docProvider.name, docProvider.modelName())
+
+	if dryRun {
+		plan := dryRunPlan{model: docProvider.modelName()}
+		plan.add(pkg.Dir, outFile, generator.PackageDocumentationPrompts(pkg.Name, files, budget), nil)
+		plan.summary()
+		return
+	}
+
+	provider, err := docProvider.newProvider()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	report.setModel(docProvider.name, docProvider.modelName())
+
+	err = report.track(ctx, pkg.Dir, outFile, func(ctx context.Context) error {
+		docs, err := generator.GeneratePackageDocumentation(ctx, pkg.Name, files, budget, provider)
+		if err != nil {
+			return fmt.Errorf("generation error: %w", err)
+		}
+		docs = formatter.NormalizeMarkdown(docs, docMarkdown)
+		if docFormat == formatHTML {
+			if docs, err = formatter.RenderHTML(pkg.Name, docs, ""); err != nil {
+				return fmt.Errorf("render error: %w", err)
+			}
+		}
+		return writeDocFile(ctx, outFile, docs)
+	})
+	report.finish()
+	if errors.Is(err, errDeclined) {
+		slog.Info("not written", "output", outFile)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	slog.Info("package documentation generated", "package", pkg.Path, "files", len(files), "output", outFile)
+}
+
+// packageDocOutput returns the document written for pkg: --output, or
+// <name>_doc.md in the package directory, or in the same place under
+// --out-dir
+func packageDocOutput(pkg *source.Package) string {
+	if docOutputFile != "" {
+		return docOutputFile
+	}
+	out := filepath.Join(pkg.Dir, pkg.Name+projectConfig.DocSuffix())
+	if docFormat == formatHTML {
+		out = strings.TrimSuffix(out, filepath.Ext(out)) + ".html"
+	}
+	if docOutDir != "" {
+		if wd, err := os.Getwd(); err == nil {
+			out = mirrorPath(docOutDir, wd, out)
+		}
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/mockgen"
+	"github.com/knbr13/aitestgen/pkg/source"
+	"github.com/knbr13/aitestgen/pkg/tokens"
+)
+
+var (
+	dryRun     bool
+	showPrompt bool
+)
+
+// dryRunPlan accumulates what a --dry-run would have done
+type dryRunPlan struct {
+	model  string
+	files  int
+	tokens int
+}
+
+// add prints the prompts that would be sent for input, with the policy
+// appended, and what would be written
+func (p *dryRunPlan) add(input, output string, prompts []string, err error) {
+	if err != nil {
+		fmt.Printf("%s: %v\n", input, err)
+		return
+	}
+	if len(prompts) == 0 {
+		fmt.Printf("%s: nothing to do\n", input)
+		return
+	}
+
+	prompts = slices.Clone(prompts)
+	estimate := 0
+	for i, prompt := range prompts {
+		prompts[i] = generator.ApplyPolicy(prompt, policyPrompt)
+		estimate += tokens.Estimate(prompts[i])
+	}
+	p.files++
+	p.tokens += estimate
+
+	fmt.Printf("%s -> %s (%d prompts, ~%d tokens)\n", input, output, len(prompts), estimate)
+	if showPrompt {
+		for i, prompt := range prompts {
+			fmt.Printf("--- prompt %d/%d for %s ---\n%s\n", i+1, len(prompts), input, prompt)
+		}
+	}
+}
+
+// summary prints the totals of the plan
+func (p *dryRunPlan) summary() {
+	cost := ""
+	if usd, ok := tokens.Cost(p.model, int64(p.tokens), 0); ok {
+		cost = fmt.Sprintf(" (at least %s on %s)", tokens.FormatCost(usd), p.model)
+	}
+	fmt.Printf("Dry run: %d files would be processed, ~%d prompt tokens%s. Nothing was sent or written.\n", p.files, p.tokens, cost)
+}
+
+// planTestFile returns the prompts generateTestFile would send for inFile,
+// without calling the provider or writing any files. Repair prompts, which
+// depend on the responses, are not included.
+func planTestFile(ctx context.Context, inFile, outFile string) ([]string, error) {
+	content, err := os.ReadFile(inFile)
+	if err != nil {
+		return nil, fmt.Errorf("read error: %w", err)
+	}
+	file, err := source.Parse(inFile, content)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+
+	existing, readErr := os.ReadFile(outFile)
+	if readErr == nil && !forceOverwrite && !appendTests {
+		if skipExisting {
+			return nil, errSkipped
+		}
+		return nil, fmt.Errorf("%s already exists (use --append, --skip-existing or --force)", outFile)
+	}
+
+	opts := testOptions()
+	if withMocks {
+		if mocks, _, err := mockgen.Generate(file, mocksStyle); err == nil {
+			opts.Mocks = mocks
+		}
+	}
+
+	targets, all, err := testTargets(file)
+	if err != nil {
+		return nil, err
+	}
+	opts.PackageContext = packageContext(ctx, inFile, file)
+	opts.HTTPHandlers = funcKeys(file.HTTPHandlers(targets))
+	opts.DBMock = dbMockFuncs(inFile, file, targets)
+	opts.Property = propertyFuncs(inFile, file, targets)
+	opts.Generics = genericFuncs(ctx, file, targets)
+	opts.StyleExamples = styleExamples(inFile, outFile)
+	grpcOptions(&opts, inFile, file)
+	if goldenMode {
+		opts.Golden = funcKeys(file.GoldenCandidates(targets))
+	}
+	if readErr == nil && appendTests {
+		existingFile, err := source.Parse(outFile, existing)
+		if err != nil {
+			return nil, fmt.Errorf("parse error: %w", err)
+		}
+		return functionPrompts(file, targets, existingFile.TestNames(), opts)
+	}
+	if perFunction || !all {
+		return functionPrompts(file, targets, nil, opts)
+	}
+	if chunks := fileChunks(file, targets, opts); chunks != nil {
+		prompts := make([]string, len(chunks))
+		for i, c := range chunks {
+			prompts[i] = generator.UnitTestPrompt(c.Code, opts)
+		}
+		return prompts, nil
+	}
+	return []string{generator.UnitTestPrompt(string(content), opts)}, nil
+}
+
+// functionPrompts returns one prompt per function of funcs without a test in testNames
+func functionPrompts(file *source.File, funcs []source.Func, testNames []string, opts generator.TestOptions) ([]string, error) {
+	var prompts []string
+	for _, fn := range funcs {
+		if source.HasTest(testNames, fn) {
+			continue
+		}
+		snippet, err := file.Context(fn.Key())
+		if err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, generator.UnitTestPrompt(snippet, opts))
+	}
+	return prompts, nil
+}
+
+// planDocFile returns the prompts doc would send for inFile
+func planDocFile(inFile string) ([]string, error) {
+	content, err := os.ReadFile(inFile)
+	if err != nil {
+		return nil, fmt.Errorf("read error: %w", err)
+	}
+	if !docInline {
+		return []string{generator.CustomDocumentationPrompt(projectConfig.Prompts.Docs, string(content))}, nil
+	}
+
+	file, err := source.Parse(inFile, content)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+	targets := file.Undocumented()
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	names := make([]string, len(targets))
+	for i, t := range targets {
+		names[i] = t.Key
+	}
+	return []string{generator.DocCommentsPrompt(string(content), names)}, nil
+}
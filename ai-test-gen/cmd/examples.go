@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+var (
+	examplesInputFile  string
+	examplesOutputFile string
+	examplesMaxRepairs int
+	examplesProvider   providerOptions
+)
+
+var examplesCmd = &cobra.Command{
+	Use:   "examples",
+	Short: "Generate runnable ExampleXxx functions for godoc",
+	Long: `Generate ExampleXxx functions with // Output: comments for a Go file.
+The examples are run with go test and only written once they compile and their
+output matches. New examples are added to the package's example_test.go.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		if examplesInputFile == "" {
+			fmt.Println("You must specify --file.")
+			os.Exit(1)
+		}
+		if examplesOutputFile == "" {
+			examplesOutputFile = filepath.Join(filepath.Dir(examplesInputFile), "example_test.go")
+		}
+
+		provider, err := examplesProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		content, err := os.ReadFile(examplesInputFile)
+		if err != nil {
+			fmt.Printf("Error reading file: %v\n", err)
+			os.Exit(1)
+		}
+
+		examples, err := generator.GenerateExamples(ctx, string(content), provider)
+		if err != nil {
+			fmt.Printf("Error generating examples: %v\n", err)
+			os.Exit(1)
+		}
+
+		if existing, err := os.ReadFile(examplesOutputFile); err == nil {
+			examples, err = source.AppendTests(string(existing), []string{examples})
+			if err != nil {
+				fmt.Printf("Error merging examples: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		w := testWriter{provider: provider, maxRepairs: examplesMaxRepairs, verify: true, opts: generator.ExampleOptions()}
+		if err := w.write(ctx, string(content), examples, examplesOutputFile); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Examples generated: %s\n", examplesOutputFile)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(examplesCmd)
+	examplesCmd.Flags().StringVarP(&examplesInputFile, "file", "f", "", "Input Go file")
+	examplesCmd.Flags().StringVarP(&examplesOutputFile, "output", "o", "", "Output file (default example_test.go next to the input)")
+	examplesCmd.Flags().IntVar(&examplesMaxRepairs, "max-repairs", 2, "Maximum attempts to fix examples that fail to compile or whose output does not match")
+	examplesProvider.addFlags(examplesCmd)
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/formatter"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/pipeline"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+var (
+	explainFile     string
+	explainFunc     string
+	explainOutput   string
+	explainProvider providerOptions
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Explain what a file or function does in plain language",
+	Long: `Explain a Go file, or with --func one of its functions, in plain language:
+what it is for, how it works, its complexity, side effects and error paths, and
+what deserves a closer look. Handy when reviewing changes to an unfamiliar
+package.
+
+The explanation is printed to stdout, or written as Markdown to --output
+through the same post-processing as doc.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		report, ctx := newReport(cmd.Context(), "explain")
+
+		if explainFile == "" {
+			fmt.Println("You must specify --file.")
+			os.Exit(1)
+		}
+		code, err := os.ReadFile(explainFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if explainFunc != "" {
+			if err := findFunc(explainFile, code, explainFunc); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if dryRun {
+			output := explainOutput
+			if output == "" {
+				output = "stdout"
+			}
+			plan := dryRunPlan{model: explainProvider.modelName()}
+			plan.add(explainFile, output, []string{generator.ExplainRequestPrompt(string(code), explainFunc)}, nil)
+			plan.summary()
+			return
+		}
+
+		// the explanation alone goes to stdout, so it can be piped
+		out := io.Writer(os.Stdout)
+		done := "explanation written"
+		if explainOutput == "" {
+			os.Stdout = os.Stderr
+			done = ""
+		}
+
+		provider, err := explainProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		report.setModel(explainProvider.name, explainProvider.modelName())
+
+		p := filePipeline(report, 1, func(string) string { return explainOutput }, func(ctx context.Context, job pipeline.Job) error {
+			text, err := generator.Explain(ctx, string(code), explainFunc, provider)
+			if err != nil {
+				return fmt.Errorf("generation error: %w", err)
+			}
+			text = formatter.NormalizeMarkdown(text, formatter.MarkdownOptions{})
+			if job.Output == "" {
+				_, err := fmt.Fprintln(out, text)
+				return err
+			}
+			return writeDocFile(ctx, job.Output, text+"\n")
+		}, done, "")
+		err = p.Do(ctx, explainFile)
+		report.finish()
+		if err != nil && !leftAlone(err) {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// findFunc returns an error unless the Go file at path, with content src,
+// declares the function name, given as Name or Type.Method
+func findFunc(path string, src []byte, name string) error {
+	file, err := source.Parse(path, src)
+	if err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+	for _, fn := range file.Funcs() {
+		if fn.Key() == name || (fn.Receiver == "" && fn.Name == name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no function %s in %s", name, path)
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+	explainCmd.Flags().StringVarP(&explainFile, "file", "f", "", "Go file to explain (required)")
+	explainCmd.Flags().StringVar(&explainFunc, "func", "", "Only explain this function of --file (Name or Type.Method)")
+	explainCmd.Flags().StringVarP(&explainOutput, "output", "o", "", "Write the explanation to this Markdown file instead of stdout")
+	explainCmd.Flags().BoolVar(&showDiff, "diff", false, "With --output, show a diff against the existing file and ask before writing")
+	explainCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "With --diff, write without asking")
+	explainCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the prompt that would be sent without calling the API or writing files")
+	explainCmd.Flags().BoolVar(&showPrompt, "show-prompt", false, "With --dry-run, print the full prompt")
+	explainProvider.addFlags(explainCmd)
+}
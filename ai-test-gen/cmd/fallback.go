@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/auth"
+	"github.com/knbr13/aitestgen/pkg/generator"
+)
+
+// fallbackEntry is a provider and model of the --fallback chain
+type fallbackEntry struct {
+	provider, model string
+}
+
+// parseFallback reads a chain such as "gemini-1.5-pro, ollama/llama3" or
+// "gemini-1.5-pro -> ollama/llama3". Entries are provider/model, a provider
+// with its default model, or a model, of the provider known to serve it or
+// else of primary.
+func parseFallback(spec, primary string) []fallbackEntry {
+	var entries []fallbackEntry
+	for _, e := range strings.Split(strings.ReplaceAll(spec, "->", ","), ",") {
+		e = strings.TrimSpace(e)
+		switch i := strings.Index(e, "/"); {
+		case e == "":
+			continue
+		case i > 0 && generator.KnownProvider(e[:i]):
+			entries = append(entries, fallbackEntry{provider: strings.ToLower(e[:i]), model: e[i+1:]})
+		case generator.KnownProvider(e):
+			entries = append(entries, fallbackEntry{provider: strings.ToLower(e)})
+		default:
+			provider := primary
+			if info, ok := generator.LookupModel(e); ok {
+				provider = info.Provider
+			}
+			entries = append(entries, fallbackEntry{provider: provider, model: e})
+		}
+	}
+	return entries
+}
+
+// chainProvider returns the provider of the options, followed by the
+// --fallback chain when one is given
+func (o *providerOptions) chainProvider() (generator.Provider, error) {
+	primary, err := o.cachedProvider()
+	if err != nil || o.fallback == "" {
+		return primary, err
+	}
+	chain := []generator.FallbackProvider{{Provider: primary, Name: o.name + "/" + o.modelName()}}
+	for _, e := range parseFallback(o.fallback, o.name) {
+		fo := *o
+		fo.name, fo.model, fo.profile, fo.fallback = e.provider, e.model, "", ""
+		// the endpoint flags only concern the primary provider
+		fo.baseURL, fo.deployment, fo.apiVersion = "", "", ""
+		if fo.apiKey, err = o.fallbackKey(e.provider); err != nil {
+			return nil, fmt.Errorf("fallback %s: %w", e.provider, err)
+		}
+		p, err := fo.cachedProvider()
+		if err != nil {
+			return nil, fmt.Errorf("fallback %s: %w", e.provider, err)
+		}
+		chain = append(chain, generator.FallbackProvider{Provider: p, Name: fo.name + "/" + fo.modelName()})
+	}
+	return generator.Fallback(chain), nil
+}
+
+// fallbackKey returns the API key of a fallback provider: the primary key for
+// the same provider, else the <PROVIDER>_API_KEY environment variable, else
+// the key of the saved profile named after the provider
+func (o *providerOptions) fallbackKey(provider string) (string, error) {
+	if strings.EqualFold(provider, o.name) || !generator.RequiresAPIKey(provider) {
+		return o.apiKey, nil
+	}
+	env := providerKeyEnv(provider)
+	if key := os.Getenv(env); key != "" {
+		return key, nil
+	}
+	saved, key, err := profileKey(provider)
+	if err != nil && !errors.Is(err, auth.ErrNoProfile) {
+		return "", err
+	}
+	if key == "" || !strings.EqualFold(saved, provider) {
+		return "", fmt.Errorf("%w: set %s or save a profile named %s with the auth command", errMissingAPIKey, env, provider)
+	}
+	return key, nil
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"go/build"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/gotool"
+	"github.com/knbr13/aitestgen/pkg/runner"
+	"github.com/knbr13/aitestgen/pkg/vcs"
+	"github.com/knbr13/aitestgen/pkg/watch"
+)
+
+var (
+	changedOnly bool
+	baseRef     string
+	watchMode   bool
+
+	excludeGlobs []string
+	includeGlobs []string
+
+	// buildGOOS, buildGOARCH, buildTags and buildCgo are the target of the
+	// build constraints files are selected by, the host's by default
+	buildGOOS   = build.Default.GOOS
+	buildGOARCH = build.Default.GOARCH
+	buildTags   []string
+	buildCgo    = build.Default.CgoEnabled
+)
+
+// buildContext returns the build context of --goos, --goarch, --tags and
+// --cgo, which files are selected by in folder mode
+func buildContext() *build.Context {
+	bc := build.Default
+	bc.GOOS, bc.GOARCH = buildGOOS, buildGOARCH
+	bc.BuildTags = buildTags
+	bc.CgoEnabled = buildCgo
+	return &bc
+}
+
+// crossBuild reports whether --goos or --goarch target another platform than
+// the host, where the tests can be compiled but not run
+func crossBuild() bool {
+	return buildGOOS != runtime.GOOS || buildGOARCH != runtime.GOARCH
+}
+
+// setupBuildEnv makes the go commands run check the tests for --goos, --goarch
+// and --cgo
+func setupBuildEnv() {
+	gotool.Env = nil
+	if buildGOOS != build.Default.GOOS {
+		gotool.Env = append(gotool.Env, "GOOS="+buildGOOS)
+	}
+	if buildGOARCH != build.Default.GOARCH {
+		gotool.Env = append(gotool.Env, "GOARCH="+buildGOARCH)
+	}
+	switch {
+	case buildCgo && !build.Default.CgoEnabled:
+		gotool.Env = append(gotool.Env, "CGO_ENABLED=1")
+	case !buildCgo && build.Default.CgoEnabled:
+		gotool.Env = append(gotool.Env, "CGO_ENABLED=0")
+	}
+}
+
+// fileFilter combines the exclude globs of the config file, the IgnoreFile in
+// root and --exclude with the --include globs, and leaves out the files the
+// build constraints exclude for --goos, --goarch, --tags and --cgo
+func fileFilter(root string) (runner.Filter, error) {
+	ignored, err := runner.ReadIgnoreFile(root)
+	if err != nil {
+		return runner.Filter{}, err
+	}
+	exclude := append(append(append([]string(nil), projectConfig.Exclude...), ignored...), excludeGlobs...)
+	return runner.Filter{Exclude: exclude, Include: includeGlobs, Build: buildContext()}, nil
+}
+
+// inputFiles returns the Go files selected by --file, --folder or --changed
+func inputFiles(ctx context.Context, file, folder string) ([]string, error) {
+	if file != "" {
+		return []string{file}, nil
+	}
+	if changedOnly {
+		if folder == "" {
+			folder = "."
+		}
+		return changedGoFiles(ctx, folder)
+	}
+	if folder == "" {
+		return nil, errors.New("you must specify either --file or --folder")
+	}
+	filter, err := fileFilter(folder)
+	if err != nil {
+		return nil, err
+	}
+	return runner.GoFiles(folder, filter)
+}
+
+// watchFiles calls process for Go files as they change until ctx is cancelled.
+// It watches folder, or the directory of file and then only reports file.
+func watchFiles(ctx context.Context, file, folder string, process func(ctx context.Context, file string)) error {
+	root := folder
+	if file != "" {
+		root = filepath.Dir(file)
+	}
+	if root == "" {
+		root = "."
+	}
+
+	filter, err := fileFilter(root)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("watching for changes (Ctrl+C to stop)", "root", root)
+	return watch.Files(ctx, root, filter, watch.DefaultDebounce, func(ctx context.Context, changed string) {
+		if file != "" && filepath.Clean(changed) != filepath.Clean(file) {
+			return
+		}
+		process(ctx, changed)
+	})
+}
+
+// changedGoFiles returns the non-test Go files under root that differ from
+// --base, leaving out the files folder mode would skip
+func changedGoFiles(ctx context.Context, root string) ([]string, error) {
+	filter, err := fileFilter(root)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := vcs.ChangedFiles(ctx, root, baseRef)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, rel := range changed {
+		if !strings.HasSuffix(rel, ".go") || strings.HasSuffix(rel, "_test.go") || filter.Skip(rel, false) || ignoredPath(rel) {
+			continue
+		}
+		file := filepath.Join(root, filepath.FromSlash(rel))
+		if generated, _ := runner.IsGenerated(file); generated {
+			continue
+		}
+		if ok, err := runner.Buildable(filter.Build, file); err == nil && !ok {
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// ignoredPath reports whether rel lies in a directory the go tool ignores
+func ignoredPath(rel string) bool {
+	dirs := strings.Split(rel, "/")
+	for _, dir := range dirs[:len(dirs)-1] {
+		if runner.IgnoredDir(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// addFileFilterFlags registers --exclude, --include and the build constraint
+// flags on cmd
+func addFileFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&excludeGlobs, "exclude", nil, "Skip files and folders matching these globs in folder mode (adds to the config file and "+runner.IgnoreFile+")")
+	cmd.Flags().StringSliceVar(&includeGlobs, "include", nil, "Only process files matching these globs in folder mode")
+	cmd.Flags().StringVar(&buildGOOS, "goos", build.Default.GOOS, "Only process files built for this operating system in folder mode, and check the tests for it")
+	cmd.Flags().StringVar(&buildGOARCH, "goarch", build.Default.GOARCH, "Only process files built for this architecture in folder mode, and check the tests for it")
+	cmd.Flags().StringSliceVar(&buildTags, "tags", nil, "Build tags that select files in folder mode and that the tests are checked with")
+	cmd.Flags().BoolVar(&buildCgo, "cgo", build.Default.CgoEnabled, "Process files that import \"C\" in folder mode, and check the tests with cgo enabled")
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/knbr13/aitestgen/pkg/sarif"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// sarifFile is where generate writes the --sarif report of the functions and
+// files left without tests
+var sarifFile string
+
+// skippedFunc is a function left without tests because tests were only
+// generated for the exported API
+type skippedFunc struct {
+	file       string
+	name       string
+	start, end int
+}
+
+var (
+	skippedMu sync.Mutex
+	skipped   []skippedFunc
+)
+
+// recordSkipped notes for --sarif the unexported functions of file, read from
+// path, that are not among targets. Functions left out by directives are
+// skipped on purpose and not reported.
+func recordSkipped(path string, file *source.File, targets []source.Func) {
+	if sarifFile == "" || !(onlyExport || blackBox) || len(funcNames) > 0 {
+		return
+	}
+	chosen := make(map[string]bool, len(targets))
+	for _, fn := range targets {
+		if fn.Directive() == source.DirectiveGenerate {
+			return
+		}
+		chosen[fn.Key()] = true
+	}
+
+	skippedMu.Lock()
+	defer skippedMu.Unlock()
+	for _, fn := range file.Funcs() {
+		if chosen[fn.Key()] || fn.IsExported() || fn.Directive() == source.DirectiveSkip {
+			continue
+		}
+		skipped = append(skipped, skippedFunc{
+			file:  path,
+			name:  fn.Key(),
+			start: file.Fset.Position(fn.Decl.Pos()).Line,
+			end:   file.Fset.Position(fn.Decl.End()).Line,
+		})
+	}
+}
+
+// writeGapReport writes the --sarif report of a generate run: the files that
+// failed or were cut short and the functions recordSkipped noted. Paths are
+// relative to the current directory, which code scanning expects to be the
+// repository root.
+func writeGapReport(report *runReport) {
+	if sarifFile == "" {
+		return
+	}
+	log := sarif.New("aitestgen", "", informationURI)
+	for _, res := range report.Files {
+		switch res.Status {
+		case statusFailed:
+			log.Add(sarif.Result{
+				RuleID:    "generate/failed",
+				Level:     sarif.LevelError,
+				Message:   sarif.Message{Text: "No tests were generated for this file: " + res.Error},
+				Locations: []sarif.Location{sarif.FileLocation(".", res.Input, 0, 0)},
+			}, "Test generation failed")
+		case statusCancelled:
+			log.Add(sarif.Result{
+				RuleID:    "generate/cancelled",
+				Level:     sarif.LevelWarning,
+				Message:   sarif.Message{Text: "No tests were generated for this file: the run was stopped before it finished"},
+				Locations: []sarif.Location{sarif.FileLocation(".", res.Input, 0, 0)},
+			}, "Test generation stopped")
+		}
+	}
+	skippedMu.Lock()
+	for _, fn := range skipped {
+		log.Add(sarif.Result{
+			RuleID:    "generate/unexported",
+			Level:     sarif.LevelNote,
+			Message:   sarif.Message{Text: fmt.Sprintf("%s has no generated tests: only the exported API was tested", fn.name)},
+			Locations: []sarif.Location{sarif.FileLocation(".", fn.file, fn.start, fn.end)},
+		}, "Unexported function skipped")
+	}
+	skippedMu.Unlock()
+
+	f, err := os.Create(sarifFile)
+	if err == nil {
+		err = log.Write(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", sarifFile, err)
+	}
+}
@@ -1,118 +1,204 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 
 	"github.com/spf13/cobra"
 
-	"github.com/knbr13/aitestgen/pkg/formatter"
 	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/lang"
+	"github.com/knbr13/aitestgen/pkg/pipeline"
+	"github.com/knbr13/aitestgen/pkg/runner"
+	"github.com/knbr13/aitestgen/pkg/source"
+	"github.com/knbr13/aitestgen/pkg/state"
+	"github.com/knbr13/aitestgen/pkg/tokens"
 )
 
 var (
-	inputFile    string
-	outputFile   string
-	inputFolder  string
-	openaiAPIKey string
+	inputFile   string
+	outputFile  string
+	inputFolder string
+	maxRepairs  int
+	verifyTests bool
+	perFunction bool
+	concurrency int
+	framework   string
+	withMocks   bool
+	onlyExport  bool
+	noPkgCtx    bool
+	blackBox    bool
+	testOutDir  string
+	// funcNames are the --func functions to generate tests for
+	funcNames []string
+	dbMock    bool
+	grpcMode  bool
+	// propertyMode is --property: pure functions get rapid property tests
+	propertyMode bool
+	// stabilityRuns is the --validate-stability number of race detector runs
+	stabilityRuns int
+	// maxPromptTokens is --max-prompt-tokens, 0 to derive it from the model
+	maxPromptTokens int
+
+	forceOverwrite bool
+	skipExisting   bool
+	appendTests    bool
+	genProvider    providerOptions
 )
 
 var generateCmd = &cobra.Command{
 	Use:   "generate",
 	Short: "Generate unit tests",
 	Run: func(cmd *cobra.Command, args []string) {
-		if openaiAPIKey == "" {
-			openaiAPIKey = os.Getenv("API_KEY")
+		report, ctx := newReport(cmd.Context(), "generate")
+
+		if genLang != lang.Go {
+			generateLanguageTests(ctx, cmd, report)
+			return
 		}
-		if openaiAPIKey == "" {
-			fmt.Println("Missing API key")
+
+		if err := generator.ValidateFramework(framework); err != nil {
+			fmt.Println(err)
 			os.Exit(1)
 		}
 
-		if inputFile != "" {
-			content, err := os.ReadFile(inputFile)
-			if err != nil {
-				fmt.Printf("Error reading file: %v\n", err)
+		if testOutDir != "" {
+			if mutateTests {
+				fmt.Println("--mutate can't be used with --out-dir: mutants are only run against the tests in their package.")
 				os.Exit(1)
 			}
+			// tests outside the package can only reach its exported API
+			blackBox = true
+		}
+
+		if len(funcNames) > 0 && inputFile == "" {
+			fmt.Println("--func selects functions within a single --file.")
+			os.Exit(1)
+		}
+
+		if (verifyTests || stabilityRuns > 0 || mutateTests || runTests) && crossBuild() {
+			fmt.Println("--verify, --validate-stability, --mutate and --run-tests run the tests, which can't be done for another --goos or --goarch.")
+			os.Exit(1)
+		}
+
+		if runTests && (inputFile != "" || watchMode || (inputFolder == "" && !changedOnly)) {
+			fmt.Println("--run-tests only applies to --folder or --changed runs.")
+			os.Exit(1)
+		}
 
-			tests, err := generator.GenerateUnitTests(string(content), openaiAPIKey)
+		if err := checkSkeletonFlags(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if inputFile == stdio || outputFile == stdio {
+			if inputFolder != "" || watchMode || changedOnly {
+				fmt.Println("Reading stdin or printing to stdout only works for a single --file.")
+				os.Exit(1)
+			}
+			if err := usePipe(); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+
+		if dryRun {
+			files, err := inputFiles(cmd.Context(), inputFile, inputFolder)
 			if err != nil {
-				fmt.Printf("Error generating tests: %v\n", err)
+				fmt.Printf("Error: %v\n", err)
 				os.Exit(1)
 			}
+			plan := dryRunPlan{model: genProvider.modelName()}
+			for _, file := range files {
+				outFile := generateOutputFor(file)
+				if inputFile != "" && outputFile != "" {
+					outFile = outputFile
+				}
+				prompts, err := planTestFile(cmd.Context(), file, outFile)
+				plan.add(file, outFile, prompts, err)
+			}
+			plan.summary()
+			return
+		}
 
-			if outputFile == "" {
-				outputFile = strings.TrimSuffix(inputFile, ".go") + "_test.go"
+		// skeletons are written without a model
+		var provider generator.Provider
+		if !skeletonMode {
+			var err error
+			if provider, err = genProvider.newProvider(); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
 			}
+			report.setModel(genProvider.name, genProvider.modelName())
+		}
+
+		p := filePipeline(report, concurrency, generateOutputFor, func(ctx context.Context, job pipeline.Job) error {
+			return generateTestFile(ctx, provider, job.Input, job.Output)
+		}, "tests generated", "generation failed")
 
-			if err := os.WriteFile(outputFile, []byte(tests), 0644); err != nil {
-				fmt.Printf("Error writing tests: %v\n", err)
+		if watchMode {
+			if err := watchFiles(ctx, inputFile, inputFolder, func(ctx context.Context, file string) { p.Do(ctx, file) }); err != nil {
+				fmt.Printf("Error watching files: %v\n", err)
 				os.Exit(1)
 			}
+			return
+		}
 
-			if err := formatter.RunGoImports(outputFile); err != nil {
-				fmt.Printf("Failed to run goimports: %v\n", err)
+		if inputFile == stdio || outputFile == stdio {
+			err := generateToPipe(ctx, report, provider)
+			report.finish()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
 				os.Exit(1)
 			}
+			return
+		}
 
-			fmt.Printf("Tests generated: %s\n", outputFile)
+		if inputFile != "" {
+			if outputFile != "" {
+				p.Output = func(string) string { return outputFile }
+			}
+			// a failure is printed rather than logged, as it ends the run
+			p.Report = func(job pipeline.Job, err error) { logOutcome(job, err, "tests generated", "") }
+			err := p.Do(ctx, inputFile)
+			report.finish()
+			writeGapReport(report)
+			if err != nil && !leftAlone(err) {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
 			return
 		}
 
-		if inputFolder != "" {
-			var files []string
-			err := filepath.Walk(inputFolder, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-				if info.IsDir() {
-					return nil
-				}
-				if strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
-					files = append(files, path)
-				}
-				return nil
-			})
-			if err != nil {
-				fmt.Printf("Error walking folder: %v\n", err)
+		if inputFolder != "" || changedOnly {
+			p.Filter = func(files []string) ([]string, error) {
+				batcher = newTestBatcher(files, provider)
+				return files, nil
+			}
+			res, ok := runFolder(ctx, report, p, inputFolder)
+			if !ok {
+				return
+			}
+			passed := true
+			if runTests && ctx.Err() == nil {
+				passed = testPackages(ctx, report)
+			}
+			report.finish()
+			writeGapReport(report)
+			if ctx.Err() != nil {
+				fmt.Printf("%s: tests generated for %d of %d files\n", stopReason(ctx), report.Processed, len(res.Pending))
 				os.Exit(1)
 			}
-			if len(files) == 0 {
-				fmt.Println("No Go files found in folder.")
+			if report.failed() || !passed {
 				os.Exit(1)
 			}
-			var wg sync.WaitGroup
-			wg.Add(len(files))
-			for _, file := range files {
-				go func(file string) {
-					defer wg.Done()
-					content, err := os.ReadFile(file)
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "read error: %v\n", err)
-						return
-					}
-					tests, err := generator.GenerateUnitTests(string(content), openaiAPIKey)
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "generation error: %v\n", err)
-						return
-					}
-					outFile := strings.TrimSuffix(file, ".go") + "_test.go"
-					if err := os.WriteFile(outFile, []byte(tests), 0644); err != nil {
-						fmt.Fprintf(os.Stderr, "write error: %v\n", err)
-						return
-					}
-					if err := formatter.RunGoImports(outFile); err != nil {
-						fmt.Fprintf(os.Stderr, "goimports error: %v\n", err)
-						return
-					}
-					fmt.Printf("tests generated for file: %s\n", outFile)
-				}(file)
-			}
-			wg.Wait()
 			return
 		}
 
@@ -121,10 +207,437 @@ var generateCmd = &cobra.Command{
 	},
 }
 
+// generateOutputFor returns the test file written for file, next to it or in
+// the same place under --out-dir
+func generateOutputFor(file string) string {
+	if testOutDir == "" {
+		return testFileFor(file)
+	}
+	return mirrorPath(testOutDir, stateDir(inputFolder), testFileFor(file))
+}
+
+// testOptions collects the generation options selected by flags and the
+// test prompt of the config
+func testOptions() generator.TestOptions {
+	return generator.TestOptions{Framework: framework, Prompt: projectConfig.Prompts.Tests, BlackBox: blackBox, Conventions: testConventions(), Imports: importPolicy()}
+}
+
+// packageContext returns the declarations from the rest of inFile's package
+// that file refers to, or "" with --no-package-context or when the package
+// cannot be loaded
+func packageContext(ctx context.Context, inFile string, file *source.File) string {
+	if noPkgCtx {
+		return ""
+	}
+	decls, err := source.PackageContext(ctx, inFile, file)
+	if err != nil {
+		slog.Debug("package context unavailable", "file", inFile, "err", err)
+		return ""
+	}
+	return decls
+}
+
+// errSkipped is returned for test files left untouched, either because of
+// --skip-existing or because --append found nothing to add
+var errSkipped = errors.New("test file already exists, skipped")
+
+// errNoTargets is returned for files whose functions are all excluded by
+// --only-exported or aitestgen directives
+var errNoTargets = errors.New("no functions selected for testing")
+
+// testTargets returns the functions of file to generate tests for, and
+// whether that is every function in it
+func testTargets(file *source.File) ([]source.Func, bool, error) {
+	if len(funcNames) > 0 {
+		return namedTargets(file)
+	}
+	// black-box tests can't reach unexported functions
+	targets := file.TestTargets(onlyExport || blackBox)
+	all := len(targets) == len(file.Funcs())
+	if len(targets) == 0 && !all {
+		return nil, false, errNoTargets
+	}
+	return targets, all, nil
+}
+
+// namedTargets returns the --func functions of file, named as Func or
+// Type.Method, which take precedence over directives and --only-exported
+func namedTargets(file *source.File) ([]source.Func, bool, error) {
+	funcs := file.Funcs()
+	var targets []source.Func
+	for _, name := range funcNames {
+		found := false
+		for _, fn := range funcs {
+			if fn.Key() == name || (fn.Receiver == "" && fn.Name == name) {
+				if blackBox && !fn.IsExported() {
+					return nil, false, fmt.Errorf("%s is unexported and can't be tested from outside its package", name)
+				}
+				targets = append(targets, fn)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false, fmt.Errorf("no function %s in %s", name, file.Fset.Position(file.AST.Package).Filename)
+		}
+	}
+	return targets, len(targets) == len(funcs), nil
+}
+
+// funcKeys returns the keys of funcs
+func funcKeys(funcs []source.Func) []string {
+	keys := make([]string, len(funcs))
+	for i, fn := range funcs {
+		keys[i] = fn.Key()
+	}
+	return keys
+}
+
+// sqlmockWarning makes sure a module missing go-sqlmock is only reported once
+var sqlmockWarning sync.Once
+
+// dbMockFuncs returns, with --db-mock, the keys of the functions among
+// targets of inFile that use a database. It warns when the module doesn't
+// require go-sqlmock, as the tests won't compile without it.
+func dbMockFuncs(inFile string, file *source.File, targets []source.Func) []string {
+	if !dbMock {
+		return nil
+	}
+	funcs := funcKeys(file.SQLFuncs(targets))
+	if len(funcs) == 0 {
+		return nil
+	}
+	if mod, err := source.FindModule(filepath.Dir(inFile)); err == nil && !slices.Contains(mod.Requires, generator.SQLMockModule) {
+		sqlmockWarning.Do(func() {
+			slog.Warn("the module doesn't require go-sqlmock, run: go get " + generator.SQLMockModule)
+		})
+	}
+	return funcs
+}
+
+// rapidWarning makes sure a module missing rapid is only reported once
+var rapidWarning sync.Once
+
+// propertyFuncs returns, with --property, the keys of the pure functions
+// among targets of inFile. It warns when the module doesn't require rapid, as
+// the tests won't compile without it.
+func propertyFuncs(inFile string, file *source.File, targets []source.Func) []string {
+	if !propertyMode {
+		return nil
+	}
+	funcs := funcKeys(file.PureFuncs(targets))
+	if len(funcs) == 0 {
+		return nil
+	}
+	if mod, err := source.FindModule(filepath.Dir(inFile)); err == nil && !slices.Contains(mod.Requires, generator.RapidModule) {
+		rapidWarning.Do(func() {
+			slog.Warn("the module doesn't require rapid, run: go get " + generator.RapidModule)
+		})
+	}
+	return funcs
+}
+
+// genericFuncs returns the generic functions among targets of file, with
+// the instantiations that compile against its package
+func genericFuncs(ctx context.Context, file *source.File, targets []source.Func) []source.Generic {
+	generics := file.Generics(targets)
+	if len(generics) == 0 {
+		return nil
+	}
+	checked, err := file.CheckInstantiations(ctx, generics)
+	if err != nil {
+		slog.Debug("instantiations not checked", "file", file.Fset.Position(file.AST.Package).Filename, "err", err)
+		return generics
+	}
+	return checked
+}
+
+// grpcOptions sets, with --grpc, the gRPC services inFile implements and the
+// declarations their tests need from the generated code, when it can be found
+// in the module
+func grpcOptions(opts *generator.TestOptions, inFile string, file *source.File) {
+	if !grpcMode {
+		return
+	}
+	opts.GRPCServices = file.GRPCServices()
+	var decls []string
+	for _, svc := range opts.GRPCServices {
+		dir, ok := filepath.Dir(inFile), true
+		if svc.ImportPath != "" {
+			mod, err := source.FindModule(dir)
+			if err != nil {
+				continue
+			}
+			if dir, ok = mod.PackageDir(svc.ImportPath); !ok {
+				slog.Debug("generated gRPC code not in the module", "service", svc.Service, "package", svc.ImportPath)
+				continue
+			}
+		}
+		if ctx := svc.Context(dir); ctx != "" {
+			decls = append(decls, ctx)
+		}
+	}
+	opts.GRPCContext = strings.Join(decls, "\n\n")
+}
+
+// generateTestFile generates tests for inFile, or with --skeleton scaffolds
+// them, and writes them to outFile
+func generateTestFile(ctx context.Context, provider generator.Provider, inFile, outFile string) error {
+	if skeletonMode {
+		return writeSkeleton(ctx, inFile, outFile)
+	}
+	if err := writeTestFile(ctx, provider, inFile, outFile, "", reviewer()); err != nil {
+		return err
+	}
+	if mutateTests {
+		// the tests are written either way, so a failure here is only reported
+		if err := strengthenTests(ctx, provider, inFile, outFile); err != nil && ctx.Err() == nil {
+			slog.Warn("mutation testing failed", "file", inFile, "err", err)
+		}
+	}
+	return nil
+}
+
+// writeTestFile generates tests for inFile, adding instructions to the
+// prompt, and writes them to outFile once review, if set, accepts them
+func writeTestFile(ctx context.Context, provider generator.Provider, inFile, outFile, instructions string, review func(path, old, new string) bool) error {
+	content, err := os.ReadFile(inFile)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+
+	existing, readErr := os.ReadFile(outFile)
+	if readErr == nil && !forceOverwrite && !appendTests {
+		if skipExisting {
+			return errSkipped
+		}
+		return fmt.Errorf("%s already exists (use --append, --skip-existing or --force)", outFile)
+	}
+
+	opts := testOptions()
+	opts.Instructions = instructions
+	if withMocks {
+		mockFile := mockFileFor(inFile)
+		if err := writeMocks(inFile, mockFile, mocksStyle); err == nil {
+			mocks, _ := os.ReadFile(mockFile)
+			opts.Mocks = string(mocks)
+		}
+	}
+
+	file, err := source.Parse(inFile, content)
+	if err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+	targets, all, err := testTargets(file)
+	recordSkipped(inFile, file, targets)
+	if err != nil {
+		return err
+	}
+	opts.PackageContext = packageContext(ctx, inFile, file)
+	opts.HTTPHandlers = funcKeys(file.HTTPHandlers(targets))
+	opts.DBMock = dbMockFuncs(inFile, file, targets)
+	opts.Property = propertyFuncs(inFile, file, targets)
+	opts.Generics = genericFuncs(ctx, file, targets)
+	opts.StyleExamples = styleExamples(inFile, outFile)
+	grpcOptions(&opts, inFile, file)
+	if goldenMode {
+		opts.Golden = funcKeys(file.GoldenCandidates(targets))
+	}
+
+	if readErr == nil && appendTests {
+		return appendTestFile(ctx, provider, file, targets, outFile, string(existing), opts, review)
+	}
+
+	var tests string
+	// a selection of functions is sent one at a time so the others stay untested
+	if perFunction || !all {
+		tests, err = generatePerFunction(ctx, provider, file, targets, opts)
+	} else if chunks := fileChunks(file, targets, opts); chunks != nil {
+		tests, err = generateChunks(ctx, provider, chunks, opts)
+	} else if batched, ok := batcher.tests(ctx, inFile); ok {
+		tests = batched
+	} else {
+		tests, err = generator.GenerateUnitTests(ctx, string(content), provider, opts)
+	}
+	if errors.Is(err, generator.ErrOutputLimit) && !perFunction && all && len(targets) > 1 {
+		// the tests of the whole file don't fit in one response, those of
+		// each function may
+		slog.Warn("response reached the output token limit, generating per function", "file", inFile)
+		tests, err = generatePerFunction(ctx, provider, file, targets, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("generation error: %w", err)
+	}
+
+	w := testWriter{provider: provider, maxRepairs: maxRepairs, verify: verifyTests, stability: stabilityRuns, golden: len(opts.Golden) > 0, opts: opts, pkgDir: filepath.Dir(inFile), review: review}
+	return w.write(ctx, string(content), tests, outFile)
+}
+
+// appendTestFile generates tests for the target functions of file that have
+// no TestXxx in the existing outFile and adds them to it
+func appendTestFile(ctx context.Context, provider generator.Provider, file *source.File, targets []source.Func, outFile, existing string, opts generator.TestOptions, review func(path, old, new string) bool) error {
+	existingFile, err := source.Parse(outFile, []byte(existing))
+	if err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+	testNames := existingFile.TestNames()
+	// new tests join the existing file, so they take its form
+	opts.BlackBox = strings.HasSuffix(existingFile.Package(), "_test")
+
+	var chunks []string
+	for _, fn := range targets {
+		if source.HasTest(testNames, fn) {
+			continue
+		}
+		snippet, err := file.Context(fn.Key())
+		if err != nil {
+			return err
+		}
+		tests, err := generator.GenerateUnitTests(ctx, snippet, provider, opts)
+		if err != nil {
+			return fmt.Errorf("generation error: %s: %w", fn.Key(), err)
+		}
+		chunks = append(chunks, tests)
+	}
+	if len(chunks) == 0 {
+		return errSkipped
+	}
+
+	tests, err := source.AppendTests(existing, chunks)
+	if err != nil {
+		return err
+	}
+
+	pkgDir := filepath.Dir(file.Fset.Position(file.AST.Package).Filename)
+	w := testWriter{provider: provider, maxRepairs: maxRepairs, verify: verifyTests, stability: stabilityRuns, golden: len(opts.Golden) > 0, opts: opts, pkgDir: pkgDir, review: review}
+	return w.write(ctx, string(file.Src), tests, outFile)
+}
+
+// generatePerFunction prompts for each of funcs separately, sending only the
+// declarations it depends on, and assembles the results into one test file
+func generatePerFunction(ctx context.Context, provider generator.Provider, file *source.File, funcs []source.Func, opts generator.TestOptions) (string, error) {
+	if len(funcs) == 0 {
+		return "", fmt.Errorf("no functions found in %s", file.Fset.Position(file.AST.Package).Filename)
+	}
+
+	chunks := make([]string, 0, len(funcs))
+	for _, fn := range funcs {
+		snippet, err := file.Context(fn.Key())
+		if err != nil {
+			return "", err
+		}
+		checkPromptSize(file, fn.Key(), generator.UnitTestPrompt(snippet, opts))
+		tests, err := generator.GenerateUnitTests(ctx, snippet, provider, opts)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", fn.Key(), err)
+		}
+		chunks = append(chunks, tests)
+	}
+	return source.MergeTests(chunks)
+}
+
+// promptBudget returns the tokens a prompt can use: --max-prompt-tokens, or
+// what the model's context window leaves for it after the response
+func promptBudget() int {
+	if maxPromptTokens > 0 {
+		return maxPromptTokens
+	}
+	return generator.PromptBudget(genProvider.name, genProvider.modelName())
+}
+
+// fileChunks returns the parts of file to generate tests for separately when
+// the prompt for the whole of it would exceed the prompt budget, or nil when
+// it fits. Functions too large on their own are sent anyway, with a warning.
+func fileChunks(file *source.File, targets []source.Func, opts generator.TestOptions) []source.Chunk {
+	budget := promptBudget()
+	if promptTokens(generator.UnitTestPrompt(string(file.Src), opts)) <= budget {
+		return nil
+	}
+	// the instructions are sent with every chunk
+	chunks := file.Chunks(targets, budget-promptTokens(generator.UnitTestPrompt("", opts)))
+	filename := file.Fset.Position(file.AST.Package).Filename
+	slog.Info("file too large for one prompt, split", "file", filename, "chunks", len(chunks), "budget", budget)
+	for _, c := range chunks {
+		if c.Oversized {
+			checkPromptSize(file, c.Funcs[0], generator.UnitTestPrompt(c.Code, opts))
+		}
+	}
+	return chunks
+}
+
+// checkPromptSize warns when the prompt for the function key of file exceeds
+// the prompt budget, as the provider may reject or truncate it
+func checkPromptSize(file *source.File, key, prompt string) {
+	if n, budget := promptTokens(prompt), promptBudget(); n > budget {
+		slog.Warn("function too large for the model's context window, sent anyway",
+			"file", file.Fset.Position(file.AST.Package).Filename, "func", key, "tokens", n, "budget", budget)
+	}
+}
+
+// promptTokens estimates the size of prompt as sent, with the policy
+func promptTokens(prompt string) int {
+	return tokens.Estimate(generator.ApplyPolicy(prompt, policyPrompt))
+}
+
+// generateChunks generates tests for each of chunks and merges them into one
+// test file
+func generateChunks(ctx context.Context, provider generator.Provider, chunks []source.Chunk, opts generator.TestOptions) (string, error) {
+	results := make([]string, 0, len(chunks))
+	for i, c := range chunks {
+		tests, err := generator.GenerateUnitTests(ctx, c.Code, provider, opts)
+		if err != nil {
+			return "", fmt.Errorf("part %d of %d: %w", i+1, len(chunks), err)
+		}
+		results = append(results, tests)
+	}
+	return source.MergeTests(results)
+}
+
 func init() {
 	rootCmd.AddCommand(generateCmd)
-	generateCmd.Flags().StringVarP(&inputFile, "file", "f", "", "Input Go file")
-	generateCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output test file (only for single file mode)")
+	generateCmd.Flags().StringVarP(&inputFile, "file", "f", "", "Input Go file (- reads the source from stdin and prints the tests to stdout)")
+	generateCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output test file (only for single file mode, - for stdout)")
 	generateCmd.Flags().StringVarP(&inputFolder, "folder", "d", "", "Input folder (recursively processes all Go files)")
-	generateCmd.Flags().StringVarP(&openaiAPIKey, "key", "k", "", "OpenAI API key")
+	generateCmd.Flags().IntVarP(&concurrency, "concurrency", "c", runner.DefaultConcurrency, "Number of files processed in parallel in folder mode")
+	generateCmd.Flags().StringSliceVar(&funcNames, "func", nil, "Only generate tests for these functions of --file (Name or Type.Method, comma separated)")
+	generateCmd.Flags().IntVar(&batchSize, "batch-size", 1, "In folder mode, send up to this many small files of a package in one prompt to cut API calls")
+	generateCmd.Flags().IntVar(&maxRepairs, "max-repairs", 2, "Maximum attempts to fix generated tests that fail to compile (0 disables the check)")
+	generateCmd.Flags().BoolVar(&verifyTests, "verify", false, "Run the generated tests and only write them if they pass (failures are sent back for repair)")
+	generateCmd.Flags().BoolVar(&runTests, "run-tests", false, "After a folder run, run the tests of the packages that got new tests and print their results and coverage")
+	generateCmd.Flags().BoolVar(&goldenMode, "golden", false, "Compare the large results of functions (strings, slices, maps, structs) against golden files under testdata, created by running the tests with -update")
+	generateCmd.Flags().IntVar(&stabilityRuns, "validate-stability", 0, "Run the generated tests this many times with -race, fixing flaky tests up to --max-repairs times and then dropping them (0 disables the check)")
+	generateCmd.Flags().IntVar(&maxPromptTokens, "max-prompt-tokens", 0, "Split files whose prompt would exceed this many tokens into parts generated for separately (0 uses the model's context window less its output limit)")
+	generateCmd.Flags().BoolVar(&skeletonMode, "skeleton", false, "Write table-driven test skeletons with empty TODO tables from the function signatures, without calling the API (fill them with the augment command)")
+	generateCmd.Flags().BoolVar(&perFunction, "per-function", false, "Generate tests one function at a time, sending only the declarations each depends on")
+	generateCmd.Flags().BoolVar(&onlyExport, "only-exported", false, "Only generate tests for exported functions and methods (//aitestgen:generate and //aitestgen:skip comments also select functions)")
+	generateCmd.Flags().StringVar(&genLang, "lang", lang.Go, "Language of the code to test ("+strings.Join(lang.Names(), ", ")+"); python writes pytest test_*.py files and ts Jest or Vitest *.spec.ts files")
+	generateCmd.Flags().StringVar(&framework, "framework", generator.FrameworkStdlib, "Test framework to generate for (stdlib, testify, ginkgo)")
+	generateCmd.Flags().BoolVar(&mutateTests, "mutate", false, "Run the tests against mutated copies of the source and generate tests that catch the mutations they miss")
+	generateCmd.Flags().IntVar(&mutateIterations, "mutate-iterations", 2, "Maximum rounds of strengthening tests against surviving mutants with --mutate")
+	generateCmd.Flags().StringVar(&testOutDir, "out-dir", "", "Write test files under this directory, mirroring the package layout, as black-box tests of the exported API")
+	generateCmd.Flags().BoolVar(&blackBox, "black-box", false, "Generate external package foo_test tests that only exercise the exported API")
+	generateCmd.Flags().BoolVar(&noStyleExamples, "no-style-examples", false, "Don't add existing tests of the module to the prompt as examples of its test style")
+	generateCmd.Flags().BoolVar(&noPkgCtx, "no-package-context", false, "Don't add the declarations the file uses from the rest of its package to the prompt")
+	generateCmd.Flags().BoolVar(&propertyMode, "property", false, "Also write property-based tests with pgregory.net/rapid for pure functions of basic types")
+	generateCmd.Flags().BoolVar(&dbMock, "db-mock", false, "Test functions using *sql.DB, *sql.Tx or *sql.Conn with go-sqlmock expectations instead of a real database")
+	generateCmd.Flags().BoolVar(&grpcMode, "grpc", false, "Test gRPC servers generated with protoc-gen-go-grpc through an in-memory bufconn connection, asserting responses and status codes")
+	generateCmd.Flags().BoolVar(&withMocks, "mocks", false, "Generate mocks for the file's interfaces and use them in the tests")
+	generateCmd.Flags().StringVar(&mocksStyle, "mock-style", "func", "Mock style used with --mocks (func, mockery)")
+	generateCmd.Flags().BoolVar(&forceOverwrite, "force", false, "Overwrite existing test files")
+	generateCmd.Flags().BoolVar(&skipExisting, "skip-existing", false, "Leave existing test files untouched")
+	generateCmd.Flags().BoolVar(&appendTests, "append", false, "Add tests to existing test files for functions that have none")
+	generateCmd.MarkFlagsMutuallyExclusive("force", "skip-existing", "append")
+	generateCmd.Flags().BoolVar(&watchMode, "watch", false, "Keep running and regenerate tests for source files as they change")
+	generateCmd.Flags().BoolVar(&changedOnly, "changed", false, "Only process Go files changed since --base (within --folder, default the current directory)")
+	generateCmd.Flags().StringVar(&baseRef, "base", "HEAD", "Git ref that --changed compares the working tree against")
+	addFileFilterFlags(generateCmd)
+	generateCmd.Flags().BoolVar(&resumeRun, "resume", false, "In folder mode, skip the files an interrupted earlier run already finished (see "+state.FileName+")")
+	generateCmd.Flags().BoolVar(&failFast, "fail-fast", false, "In folder mode, stop at the first file that fails")
+	generateCmd.Flags().Float64Var(&maxCost, "max-cost", 0, "Stop a folder run once its estimated cost in US dollars exceeds this budget (0 for no limit)")
+	generateCmd.Flags().BoolVar(&showDiff, "diff", false, "Show a diff against the existing file and ask before writing")
+	generateCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "With --diff, write without asking")
+	generateCmd.Flags().StringVar(&sarifFile, "sarif", "", "Write a SARIF report of the files that failed and the functions left without tests, for GitHub code scanning")
+	generateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the files and prompts that would be processed without calling the API or writing files")
+	generateCmd.Flags().BoolVar(&showPrompt, "show-prompt", false, "With --dry-run, print the full prompts")
+	genProvider.addFlags(generateCmd)
 }
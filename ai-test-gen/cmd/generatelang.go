@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/lang"
+	"github.com/knbr13/aitestgen/pkg/pipeline"
+	"github.com/knbr13/aitestgen/pkg/postprocess"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// genLang is the --lang of generate
+var genLang string
+
+// goOnlyFlags are the generate flags that only apply to Go code
+var goOnlyFlags = []string{
+	"func", "framework", "mutate", "mutate-iterations", "out-dir", "black-box", "per-function", "only-exported",
+	"no-package-context", "property", "db-mock", "grpc", "mocks", "mock-style", "append", "watch", "changed",
+	"verify", "max-repairs", "validate-stability", "golden", "resume", "sarif", "run-tests",
+}
+
+// generateLanguageTests runs generate for --lang, a language other than Go:
+// the tests of each source file are generated in one request, formatted with
+// the language's formatter when one is installed and written next to it
+func generateLanguageTests(ctx context.Context, cmd *cobra.Command, report *runReport) {
+	l, err := lang.Lookup(genLang)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for _, name := range goOnlyFlags {
+		if f := cmd.Flags().Lookup(name); f != nil && f.Changed {
+			fmt.Printf("--%s only applies to Go code.\n", name)
+			os.Exit(1)
+		}
+	}
+	if inputFile == stdio || outputFile == stdio {
+		fmt.Println("Reading stdin or printing to stdout only works for Go code.")
+		os.Exit(1)
+	}
+
+	if inputFile == "" && inputFolder == "" {
+		fmt.Println("You must specify either --file or --folder.")
+		os.Exit(1)
+	}
+	if inputFile != "" && !l.IsSource(inputFile) {
+		fmt.Printf("%s is not a %s source file.\n", inputFile, l.Name)
+		os.Exit(1)
+	}
+	outputFor := func(file string) string {
+		if inputFile != "" && outputFile != "" {
+			return outputFile
+		}
+		return l.TestFile(file)
+	}
+
+	if dryRun {
+		files, err := languageFiles(l)
+		if err != nil {
+			fmt.Printf("Error listing files: %v\n", err)
+			os.Exit(1)
+		}
+		plan := dryRunPlan{model: genProvider.modelName()}
+		for _, file := range files {
+			code, err := os.ReadFile(file)
+			plan.add(file, outputFor(file), []string{generator.LanguageTestPrompt(l.Prompt(file), string(code))}, err)
+		}
+		plan.summary()
+		return
+	}
+
+	provider, err := genProvider.newProvider()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	report.setModel(genProvider.name, genProvider.modelName())
+
+	p := filePipeline(report, concurrency, outputFor, func(ctx context.Context, job pipeline.Job) error {
+		return generateLanguageFile(ctx, provider, l, job.Input, job.Output)
+	}, "tests generated", "generation failed")
+	p.Walk = func(ctx context.Context) ([]string, error) {
+		return languageFiles(l)
+	}
+	_, err = p.Run(ctx)
+	report.finish()
+	if errors.Is(err, pipeline.ErrNoFiles) {
+		fmt.Printf("No %s files found in folder.\n", l.Name)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if ctx.Err() != nil {
+		fmt.Println(stopReason(ctx))
+		os.Exit(1)
+	}
+	if report.failed() {
+		os.Exit(1)
+	}
+}
+
+// languageFiles returns the source files in l selected by --file or --folder
+func languageFiles(l *lang.Language) ([]string, error) {
+	if inputFile != "" {
+		return []string{inputFile}, nil
+	}
+	filter, err := fileFilter(inputFolder)
+	if err != nil {
+		return nil, err
+	}
+	return l.Files(inputFolder, filter)
+}
+
+// generateLanguageFile generates tests in l for inFile and writes them to
+// outFile once formatted, post-processed and, with --diff, accepted
+func generateLanguageFile(ctx context.Context, provider generator.Provider, l *lang.Language, inFile, outFile string) error {
+	content, err := os.ReadFile(inFile)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+	old, err := os.ReadFile(outFile)
+	if err == nil {
+		if skipExisting {
+			return errSkipped
+		}
+		if !forceOverwrite {
+			return fmt.Errorf("%s already exists (use --skip-existing or --force)", outFile)
+		}
+	}
+
+	tests, err := generator.GenerateLanguageTests(ctx, l.Prompt(inFile), string(content), l.Fences, provider)
+	if err != nil {
+		return fmt.Errorf("generation error: %w", err)
+	}
+
+	// formatted under a name of its own, with the same extension for the
+	// formatter to recognize, next to the project configuration it reads
+	dir := filepath.Dir(outFile)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+	tmp := filepath.Join(dir, ".aitestgen-"+filepath.Base(outFile))
+	defer os.Remove(tmp)
+	if err := os.WriteFile(tmp, []byte(tests), 0644); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+	if err := l.Format(ctx, tmp); err != nil {
+		slog.Warn("formatting failed", "file", outFile, "err", err)
+	}
+	formatted, err := os.ReadFile(tmp)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+	processed, err := postProcess(ctx, outFile, postprocess.KindTest, formatted)
+	if err != nil {
+		return err
+	}
+	tests = source.KeepLineEndings(string(old), string(processed))
+
+	if showDiff && !reviewChange(outFile, string(old), tests) {
+		return errDeclined
+	}
+	if err := os.WriteFile(outFile, []byte(tests), 0644); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+	return nil
+}
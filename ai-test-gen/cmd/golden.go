@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/gotool"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// goldenMode is --golden: functions returning large values are tested
+// against golden files
+var goldenMode bool
+
+// writeGoldens creates the golden files of the tests in target, a test file
+// of the package in dir, by running them with -update. They run in a scratch
+// copy of the package next to it, so that only the .golden files they write
+// under testdata reach the package; files already there are left alone. The
+// tests are built with tags.
+func writeGoldens(ctx context.Context, dir, target string, tags []string) error {
+	src, err := os.ReadFile(target)
+	if err != nil {
+		return err
+	}
+	parsed, err := source.Parse(target, src)
+	if err != nil {
+		return err
+	}
+	tests := parsed.TestNames()
+	if len(tests) == 0 {
+		return nil
+	}
+
+	// a sibling of the package keeps its imports, internal ones included,
+	// valid; the _ prefix hides it from ./... patterns meanwhile
+	scratch, err := os.MkdirTemp(filepath.Dir(dir), "_aitestgen-golden-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(scratch)
+	if err := copyPackage(dir, scratch, target); err != nil {
+		return err
+	}
+
+	if out, err := gotool.TestArgs(ctx, scratch, tests, tags, "-"+generator.GoldenFlag); err != nil {
+		return fmt.Errorf("running the tests with -%s failed:\n%s", generator.GoldenFlag, out)
+	}
+
+	written := 0
+	testdata := filepath.Join(scratch, "testdata")
+	err = filepath.WalkDir(testdata, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".golden") {
+			return err
+		}
+		rel, err := filepath.Rel(scratch, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(dir, rel)
+		if _, err := os.Stat(dest); err == nil {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		written++
+		return os.WriteFile(dest, data, 0644)
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if written > 0 {
+		slog.Info("golden files written", "dir", filepath.Join(dir, "testdata"), "files", written)
+	}
+	return nil
+}
+
+// copyPackage copies the non-test Go files and testdata of the package in dir
+// to scratch, along with the test file target
+func copyPackage(dir, scratch, target string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if err := copyFile(filepath.Join(dir, name), filepath.Join(scratch, name)); err != nil {
+			return err
+		}
+	}
+	if err := copyFile(target, filepath.Join(scratch, filepath.Base(target))); err != nil {
+		return err
+	}
+	testdata := filepath.Join(dir, "testdata")
+	if _, err := os.Stat(testdata); err != nil {
+		return nil
+	}
+	return os.CopyFS(filepath.Join(scratch, "testdata"), os.DirFS(testdata))
+}
+
+// copyFile copies the file src to dst
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
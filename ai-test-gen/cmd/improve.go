@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/coverage"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/gotool"
+)
+
+var (
+	improveDir        string
+	coverageTarget    float64
+	maxIterations     int
+	improveMaxRepairs int
+	improveFramework  string
+	improveProvider   providerOptions
+)
+
+var improveCoverageCmd = &cobra.Command{
+	Use:   "improve-coverage",
+	Short: "Generate tests for uncovered code until a coverage target is reached",
+	Run: func(cmd *cobra.Command, args []string) {
+		report, ctx := newReport(cmd.Context(), "improve-coverage")
+
+		provider, err := improveProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		report.setModel(improveProvider.name, improveProvider.modelName())
+
+		profile, err := os.CreateTemp("", "aitestgen-*.out")
+		if err != nil {
+			fmt.Printf("Error creating coverage profile: %v\n", err)
+			os.Exit(1)
+		}
+		profile.Close()
+		defer os.Remove(profile.Name())
+
+		if err := generator.ValidateFramework(improveFramework); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		w := testWriter{
+			provider:   provider,
+			maxRepairs: improveMaxRepairs,
+			verify:     true,
+			opts:       generator.TestOptions{Framework: improveFramework, Prompt: projectConfig.Prompts.Tests, Conventions: testConventions(), Imports: importPolicy()},
+		}
+		// exit ends the run with the coverage reached so far
+		exit := func(code int) {
+			report.finish()
+			if code != 0 {
+				os.Exit(code)
+			}
+		}
+		for iteration := 0; ; iteration++ {
+			funcs, pct, err := packageCoverage(ctx, improveDir, profile.Name())
+			if err != nil {
+				fmt.Printf("Error measuring coverage: %v\n", err)
+				exit(1)
+			}
+			if iteration == 0 {
+				report.CoverageBefore = &pct
+			}
+			report.Coverage = &pct
+
+			fmt.Printf("Coverage: %.1f%% (target %.1f%%)\n", pct, coverageTarget)
+			if pct >= coverageTarget {
+				fmt.Println("Coverage target reached.")
+				exit(0)
+				return
+			}
+			if iteration >= maxIterations {
+				fmt.Printf("Coverage target not reached after %d iterations.\n", maxIterations)
+				exit(1)
+			}
+
+			gaps := uncoveredByFile(funcs)
+			if len(gaps) == 0 {
+				fmt.Println("No uncovered functions left to target.")
+				exit(1)
+			}
+
+			for _, file := range sortedKeys(gaps) {
+				outFile := strings.TrimSuffix(file, ".go") + "_coverage_test.go"
+				err := report.track(ctx, file, outFile, func(ctx context.Context) error {
+					return generateCoverageFile(ctx, w, file, outFile, gaps[file])
+				})
+				if err != nil {
+					if ctx.Err() != nil {
+						exit(1)
+					}
+					fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+					continue
+				}
+				fmt.Printf("coverage tests generated for file: %s\n", outFile)
+			}
+		}
+	},
+}
+
+// packageCoverage runs the tests in dir and returns per-function and total coverage
+func packageCoverage(ctx context.Context, dir, profile string) ([]coverage.FuncCoverage, float64, error) {
+	if out, err := gotool.Cover(ctx, dir, profile); err != nil {
+		return nil, 0, fmt.Errorf("%w\n%s", err, out)
+	}
+	profiles, err := coverage.ParseProfiles(profile)
+	if err != nil {
+		return nil, 0, err
+	}
+	resolve, err := coverage.PackageResolver(ctx, dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	funcs, err := coverage.Funcs(profiles, resolve)
+	if err != nil {
+		return nil, 0, err
+	}
+	return funcs, coverage.Percent(profiles), nil
+}
+
+// uncoveredByFile describes the uncovered code of each source file for the prompt
+func uncoveredByFile(funcs []coverage.FuncCoverage) map[string]string {
+	gaps := make(map[string]string)
+	for _, fn := range funcs {
+		if fn.Covered == fn.Total || strings.HasSuffix(fn.File, "_test.go") {
+			continue
+		}
+		var ranges []string
+		for _, r := range fn.Uncovered {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", r[0], r[1]))
+		}
+		gaps[fn.File] += fmt.Sprintf("- %s (lines %d-%d): %.1f%% covered, uncovered lines %s\n",
+			fn.Name, fn.StartLine, fn.EndLine, fn.Percent(), strings.Join(ranges, ", "))
+	}
+	return gaps
+}
+
+func generateCoverageFile(ctx context.Context, w testWriter, file, outFile, gaps string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+	existing, _ := os.ReadFile(testFileFor(file))
+	previous, _ := os.ReadFile(outFile)
+
+	tests, err := generator.GenerateCoverageTests(ctx, string(content), string(existing), string(previous), gaps, w.provider, w.opts)
+	if err != nil {
+		return fmt.Errorf("generation error: %w", err)
+	}
+	return w.write(ctx, string(content), tests, outFile)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	rootCmd.AddCommand(improveCoverageCmd)
+	improveCoverageCmd.Flags().StringVarP(&improveDir, "dir", "d", ".", "Package directory to improve")
+	improveCoverageCmd.Flags().Float64VarP(&coverageTarget, "target", "t", 80, "Coverage percentage to reach")
+	improveCoverageCmd.Flags().IntVar(&maxIterations, "max-iterations", 3, "Maximum number of generation rounds")
+	improveCoverageCmd.Flags().IntVar(&improveMaxRepairs, "max-repairs", 2, "Maximum attempts to fix generated tests that fail to compile or pass")
+	improveCoverageCmd.Flags().StringVar(&improveFramework, "framework", generator.FrameworkStdlib, "Test framework to generate for (stdlib, testify, ginkgo)")
+	improveProvider.addFlags(improveCoverageCmd)
+}
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/pipeline"
+	"github.com/knbr13/aitestgen/pkg/runner"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+var (
+	integrationInputFile   string
+	integrationOutputFile  string
+	integrationInputFolder string
+	integrationConcurrency int
+	integrationMaxRepairs  int
+	integrationTag         string
+	integrationMigrations  string
+	integrationVerify      bool
+	integrationForce       bool
+	integrationProvider    providerOptions
+)
+
+// errNoServices is returned for files that use none of the services
+// integration tests are generated for
+var errNoServices = errors.New("no Postgres, Redis or Kafka client used")
+
+var integrationCmd = &cobra.Command{
+	Use:   "integration",
+	Short: "Generate integration tests running against services in containers",
+	Long: `Generate integration tests for code using Postgres, Redis or Kafka clients.
+The tests start the services in Docker with testcontainers-go, apply the SQL
+migrations found in migrations/ or db/migrations/ of the module (or --migrations)
+to Postgres through a replaceable migrate hook, and tear the containers down
+with t.Cleanup. They are written to <file>_integration_test.go behind a
+//go:build integration constraint, so they only run with go test -tags
+integration. Generated tests are checked to compile; --verify also runs them,
+which needs Docker.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if integrationInputFile == "" && integrationInputFolder == "" {
+			fmt.Println("You must specify either --file or --folder.")
+			os.Exit(1)
+		}
+		report, ctx := newReport(cmd.Context(), "integration")
+
+		provider, err := integrationProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		report.setModel(integrationProvider.name, integrationProvider.modelName())
+
+		output := integrationFileFor
+		if integrationInputFile != "" && integrationOutputFile != "" {
+			output = func(string) string { return integrationOutputFile }
+		}
+		p := filePipeline(report, integrationConcurrency, output, func(ctx context.Context, job pipeline.Job) error {
+			return generateIntegrationFile(ctx, provider, job.Input, job.Output)
+		}, "integration tests generated", "integration test generation failed")
+
+		if integrationInputFile != "" {
+			// a failure is printed rather than logged, as it ends the run
+			p.Report = func(job pipeline.Job, err error) {
+				logOutcome(job, err, "integration tests generated", "")
+			}
+			err := p.Do(ctx, integrationInputFile)
+			report.finish()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		p.Walk = func(ctx context.Context) ([]string, error) {
+			return inputFiles(ctx, "", integrationInputFolder)
+		}
+		_, err = p.Run(ctx)
+		report.finish()
+		if errors.Is(err, pipeline.ErrNoFiles) {
+			fmt.Println("No Go files found in folder.")
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if ctx.Err() != nil {
+			fmt.Println(stopReason(ctx))
+			os.Exit(1)
+		}
+		if report.failed() {
+			os.Exit(1)
+		}
+	},
+}
+
+// integrationFileFor returns the integration test file name for a Go source
+// file
+func integrationFileFor(file string) string {
+	return strings.TrimSuffix(file, ".go") + "_integration_test.go"
+}
+
+// testcontainersWarning makes sure a module missing testcontainers-go is only
+// reported once
+var testcontainersWarning sync.Once
+
+// generateIntegrationFile generates integration tests for the services
+// inFile uses and writes them to outFile
+func generateIntegrationFile(ctx context.Context, provider generator.Provider, inFile, outFile string) error {
+	content, err := os.ReadFile(inFile)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+	file, err := source.Parse(inFile, content)
+	if err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+
+	dir := filepath.Dir(inFile)
+	mod, modErr := source.FindModule(dir)
+	var requires []string
+	if modErr == nil {
+		requires = mod.Requires
+	}
+	services := file.Services(requires)
+	if len(services) == 0 {
+		return errNoServices
+	}
+	if _, err := os.Stat(outFile); err == nil && !integrationForce {
+		return fmt.Errorf("%s already exists (use --force)", outFile)
+	}
+	if modErr == nil && !slices.Contains(mod.Requires, generator.TestcontainersModule) {
+		testcontainersWarning.Do(func() {
+			slog.Warn("the module doesn't require testcontainers-go, run: go get " + generator.TestcontainersModule)
+		})
+	}
+
+	// the tests share their containers' state, so they stay serial
+	conventions := testConventions()
+	conventions.Parallel = nil
+	opts := generator.TestOptions{
+		Framework:      generator.FrameworkStdlib,
+		Prompt:         projectConfig.Prompts.Tests,
+		Conventions:    conventions,
+		Imports:        importPolicy(),
+		Integration:    services,
+		IntegrationTag: integrationTag,
+		Migrations:     migrationsFor(dir, mod),
+	}
+	tests, err := generator.GenerateUnitTests(ctx, string(content), provider, opts)
+	if err != nil {
+		return fmt.Errorf("generation error: %w", err)
+	}
+	w := testWriter{
+		provider:   provider,
+		maxRepairs: integrationMaxRepairs,
+		verify:     integrationVerify,
+		opts:       opts,
+		review:     reviewer(),
+		tags:       []string{integrationTag},
+	}
+	return w.write(ctx, string(content), tests, outFile)
+}
+
+// migrationDirs are where modules commonly keep their SQL migrations
+var migrationDirs = []string{"migrations", "db/migrations", "sql/migrations", "internal/db/migrations"}
+
+// migrationsFor returns the migrations directory relative to the package in
+// dir, slash separated: --migrations, or the first of migrationDirs found in
+// the module, or "" without one
+func migrationsFor(dir string, mod *source.Module) string {
+	migrations := integrationMigrations
+	if migrations == "" && mod != nil {
+		for _, d := range migrationDirs {
+			path := filepath.Join(mod.Dir, filepath.FromSlash(d))
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				migrations = path
+				break
+			}
+		}
+	}
+	if migrations == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return filepath.ToSlash(migrations)
+	}
+	if !filepath.IsAbs(migrations) {
+		if migrations, err = filepath.Abs(migrations); err != nil {
+			return ""
+		}
+	}
+	rel, err := filepath.Rel(abs, migrations)
+	if err != nil {
+		return filepath.ToSlash(migrations)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func init() {
+	rootCmd.AddCommand(integrationCmd)
+	integrationCmd.Flags().StringVarP(&integrationInputFile, "file", "f", "", "Input Go file")
+	integrationCmd.Flags().StringVarP(&integrationOutputFile, "output", "o", "", "Output test file (only for single file mode)")
+	integrationCmd.Flags().StringVarP(&integrationInputFolder, "folder", "d", "", "Input folder (recursively processes the Go files using Postgres, Redis or Kafka)")
+	integrationCmd.Flags().IntVarP(&integrationConcurrency, "concurrency", "c", runner.DefaultConcurrency, "Number of files processed in parallel in folder mode")
+	integrationCmd.Flags().IntVar(&integrationMaxRepairs, "max-repairs", 2, "Maximum attempts to fix generated tests that fail to compile or, with --verify, pass")
+	integrationCmd.Flags().StringVar(&integrationTag, "tag", generator.IntegrationTag, "Build tag the tests are put behind")
+	integrationCmd.Flags().StringVar(&integrationMigrations, "migrations", "", "Directory of SQL migrations to apply to Postgres (default: migrations/ or db/migrations/ of the module, if any)")
+	integrationCmd.Flags().BoolVar(&integrationVerify, "verify", false, "Run the generated tests, which needs Docker, and only keep them once they pass")
+	integrationCmd.Flags().BoolVar(&integrationForce, "force", false, "Overwrite existing integration test files")
+	integrationCmd.Flags().BoolVar(&showDiff, "diff", false, "Show a diff against the existing file and ask before writing")
+	integrationCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "With --diff, write without asking")
+	addFileFilterFlags(integrationCmd)
+	integrationProvider.addFlags(integrationCmd)
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/formatter"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/lsp"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+var lspProvider providerOptions
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Offer test and documentation generation as editor code actions",
+	Long: `Run a Language Server Protocol server on stdin/stdout offering two code
+actions on Go files, so editors such as VS Code or Neovim can run the generator
+on the code under the cursor:
+
+  Generate tests for <func>  adds tests for the function or method under the
+                             cursor to its test file, creating it if needed
+  Document <name>            writes a godoc comment above the undocumented
+                             exported declaration under the cursor
+
+The changes are sent to the editor as workspace edits, so they show up in the
+buffers unsaved and can be undone. Configure the editor to start "aitestgen lsp"
+for Go files, alongside gopls, with the usual provider flags or environment
+variables.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		provider, err := lspProvider.newProvider()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		// stdout carries the protocol; anything else printed goes to stderr
+		out := os.Stdout
+		os.Stdout = os.Stderr
+
+		srv := lsp.NewServer("aitestgen", buildVersion(), lspActions(provider)...)
+		slog.Info("lsp server started", "model", lspProvider.modelName())
+		if err := srv.Serve(ctx, os.Stdin, out); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// lspActions returns the code actions offered by the lsp command
+func lspActions(provider generator.Provider) []lsp.Action {
+	return []lsp.Action{
+		{
+			Command: "aitestgen.generateTests",
+			Kind:    "source",
+			Offer: func(doc lsp.Document, pos lsp.Position) (string, bool) {
+				_, fn, ok := lspFuncAt(doc, pos)
+				if !ok {
+					return "", false
+				}
+				return "Generate tests for " + fn.Key(), true
+			},
+			Run: func(ctx context.Context, doc lsp.Document, pos lsp.Position, read lsp.ReadFunc) (*lsp.WorkspaceEdit, error) {
+				parsed, fn, ok := lspFuncAt(doc, pos)
+				if !ok {
+					return nil, errors.New("no function under the cursor")
+				}
+				return lspGenerateTests(ctx, provider, doc, parsed, fn, read)
+			},
+		},
+		{
+			Command: "aitestgen.document",
+			Kind:    "refactor.rewrite",
+			Offer: func(doc lsp.Document, pos lsp.Position) (string, bool) {
+				_, target, ok := lspDocTargetAt(doc, pos)
+				if !ok {
+					return "", false
+				}
+				return "Document " + target.Key, true
+			},
+			Run: func(ctx context.Context, doc lsp.Document, pos lsp.Position, read lsp.ReadFunc) (*lsp.WorkspaceEdit, error) {
+				parsed, target, ok := lspDocTargetAt(doc, pos)
+				if !ok {
+					return nil, errors.New("no undocumented exported declaration under the cursor")
+				}
+				return lspDocument(ctx, provider, doc, parsed, target)
+			},
+		},
+	}
+}
+
+// lspParse parses doc when it is a Go source file tests can be written for
+func lspParse(doc lsp.Document) (*source.File, bool) {
+	if !strings.HasSuffix(doc.Path, ".go") || strings.HasSuffix(doc.Path, "_test.go") {
+		return nil, false
+	}
+	parsed, err := source.Parse(doc.Path, []byte(doc.Text))
+	if err != nil {
+		return nil, false
+	}
+	return parsed, true
+}
+
+// lspFuncAt returns the function at pos of doc
+func lspFuncAt(doc lsp.Document, pos lsp.Position) (*source.File, source.Func, bool) {
+	parsed, ok := lspParse(doc)
+	if !ok {
+		return nil, source.Func{}, false
+	}
+	fn, ok := parsed.FuncAt(lsp.Offset(doc.Text, pos))
+	if !ok || fn.Directive() == source.DirectiveSkip {
+		return nil, source.Func{}, false
+	}
+	return parsed, fn, true
+}
+
+// lspDocTargetAt returns the undocumented exported declaration at pos of doc
+func lspDocTargetAt(doc lsp.Document, pos lsp.Position) (*source.File, source.DocTarget, bool) {
+	parsed, ok := lspParse(doc)
+	if !ok {
+		return nil, source.DocTarget{}, false
+	}
+	target, ok := parsed.UndocumentedAt(lsp.Offset(doc.Text, pos))
+	return parsed, target, ok
+}
+
+// lspGenerateTests generates tests for fn of doc and returns the edit adding
+// them to its test file, which is created when missing
+func lspGenerateTests(ctx context.Context, provider generator.Provider, doc lsp.Document, parsed *source.File, fn source.Func, read lsp.ReadFunc) (*lsp.WorkspaceEdit, error) {
+	snippet, err := parsed.Context(fn.Key())
+	if err != nil {
+		return nil, err
+	}
+	opts := testOptions()
+	opts.PackageContext = packageContext(ctx, doc.Path, parsed)
+	tests, err := generator.GenerateUnitTests(ctx, snippet, provider, opts)
+	if err != nil {
+		return nil, fmt.Errorf("generation error: %w", err)
+	}
+	dir := filepath.Dir(doc.Path)
+	tests = fixPackage(doc.Text, tests, dir, false)
+	if disallowed := disallowedIn(tests, dir); len(disallowed) > 0 {
+		return nil, fmt.Errorf("generated tests import packages the config doesn't allow: %s", strings.Join(disallowed, ", "))
+	}
+
+	testFile := testFileFor(doc.Path)
+	existing, err := read(testFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		formatted, err := formatter.GoImports(testFile, []byte(tests))
+		if err != nil {
+			return nil, fmt.Errorf("format error: %w", err)
+		}
+		edit := &lsp.WorkspaceEdit{}
+		edit.Create(lsp.URI(testFile), string(formatted))
+		return edit, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read error: %w", err)
+	}
+
+	merged, err := source.AppendTests(string(existing), []string{tests})
+	if err != nil {
+		return nil, fmt.Errorf("merge error: %w", err)
+	}
+	formatted, err := formatter.GoImports(testFile, []byte(merged))
+	if err != nil {
+		return nil, fmt.Errorf("format error: %w", err)
+	}
+	if string(formatted) == string(existing) {
+		return nil, fmt.Errorf("%s already has the generated tests", filepath.Base(testFile))
+	}
+	edit := &lsp.WorkspaceEdit{}
+	edit.Replace(lsp.URI(testFile), nil, string(existing), source.KeepLineEndings(string(existing), string(formatted)))
+	return edit, nil
+}
+
+// lspDocument generates the doc comment of target in doc and returns the
+// edit inserting it
+func lspDocument(ctx context.Context, provider generator.Provider, doc lsp.Document, parsed *source.File, target source.DocTarget) (*lsp.WorkspaceEdit, error) {
+	comments, err := generator.GenerateDocComments(ctx, doc.Text, []string{target.Key}, provider)
+	if err != nil {
+		return nil, fmt.Errorf("generation error: %w", err)
+	}
+	updated, err := parsed.InsertDocComments(map[string]string{target.Key: comments[target.Key]})
+	if err != nil {
+		return nil, fmt.Errorf("format error: %w", err)
+	}
+	if updated == doc.Text {
+		return nil, fmt.Errorf("no doc comment was generated for %s", target.Key)
+	}
+	edit := &lsp.WorkspaceEdit{}
+	edit.Replace(doc.URI, doc.Version, doc.Text, source.KeepLineEndings(doc.Text, updated))
+	return edit, nil
+}
+
+func init() {
+	rootCmd.AddCommand(lspCmd)
+	lspProvider.addFlags(lspCmd)
+}
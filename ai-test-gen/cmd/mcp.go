@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/coverage"
+	"github.com/knbr13/aitestgen/pkg/formatter"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/mcp"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+var mcpProvider providerOptions
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve test and documentation generation as Model Context Protocol tools",
+	Long: `Run a Model Context Protocol server on stdin/stdout, so AI coding assistants
+such as Claude Desktop or Cursor can call the generator directly. It offers the
+tools generate_tests, generate_docs and run_coverage; file paths are resolved
+against the directory the server was started in.
+
+Register it with the assistant as a stdio server running
+"aitestgen mcp" with the usual provider flags or environment variables.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		provider, err := mcpProvider.newProvider()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		// stdout carries the protocol; anything else printed goes to stderr
+		out := os.Stdout
+		os.Stdout = os.Stderr
+
+		srv := mcp.NewServer("aitestgen", buildVersion(), mcpTools(provider)...)
+		slog.Info("mcp server started", "model", mcpProvider.modelName())
+		if err := srv.Serve(ctx, os.Stdin, out); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// mcpWriteMu serializes the tool calls that write test files, since they
+// share the generate command's settings
+var mcpWriteMu sync.Mutex
+
+// mcpTools returns the tools offered by the mcp command
+func mcpTools(provider generator.Provider) []mcp.Tool {
+	return []mcp.Tool{
+		{
+			Name:        "generate_tests",
+			Description: "Generate Go unit tests for a source file. Returns the tests, or writes them next to the file as <name>_test.go when write is true.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"file":      map[string]any{"type": "string", "description": "Path of the Go source file"},
+					"framework": map[string]any{"type": "string", "enum": generator.Frameworks, "description": "Test framework to generate for"},
+					"write":     map[string]any{"type": "boolean", "description": "Write the test file instead of returning the tests"},
+					"overwrite": map[string]any{"type": "boolean", "description": "With write, replace an existing test file"},
+				},
+				"required": []string{"file"},
+			},
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				var args struct {
+					File      string `json:"file"`
+					Framework string `json:"framework"`
+					Write     bool   `json:"write"`
+					Overwrite bool   `json:"overwrite"`
+				}
+				if err := mcp.DecodeArgs(raw, &args); err != nil {
+					return "", err
+				}
+				return mcpGenerateTests(ctx, provider, args.File, args.Framework, args.Write, args.Overwrite)
+			},
+		},
+		{
+			Name:        "generate_docs",
+			Description: "Generate Markdown documentation for a Go source file.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"file": map[string]any{"type": "string", "description": "Path of the Go source file"},
+				},
+				"required": []string{"file"},
+			},
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				var args struct {
+					File string `json:"file"`
+				}
+				if err := mcp.DecodeArgs(raw, &args); err != nil {
+					return "", err
+				}
+				if args.File == "" {
+					return "", fmt.Errorf("%w: file is required", mcp.ErrInvalidArguments)
+				}
+				content, err := os.ReadFile(args.File)
+				if err != nil {
+					return "", fmt.Errorf("read error: %w", err)
+				}
+				docs, err := generateDocs(ctx, string(content), provider)
+				if err != nil {
+					return "", fmt.Errorf("generation error: %w", err)
+				}
+				return formatter.FormatDocumentation(docs), nil
+			},
+		},
+		{
+			Name:        "run_coverage",
+			Description: "Run the Go tests with coverage enabled and return the coverage of each package.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"package": map[string]any{"type": "string", "description": "Package pattern to test, default ./..."},
+				},
+			},
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				var args struct {
+					Package string `json:"package"`
+				}
+				if err := mcp.DecodeArgs(raw, &args); err != nil {
+					return "", err
+				}
+				if args.Package == "" {
+					args.Package = "./..."
+				}
+				return mcpCoverage(ctx, args.Package)
+			},
+		},
+	}
+}
+
+// mcpGenerateTests generates tests for file, returning them or, with write,
+// writing the test file and returning where it went
+func mcpGenerateTests(ctx context.Context, provider generator.Provider, file, testFramework string, write, overwrite bool) (string, error) {
+	if file == "" {
+		return "", fmt.Errorf("%w: file is required", mcp.ErrInvalidArguments)
+	}
+	if testFramework == "" {
+		testFramework = generator.FrameworkStdlib
+	}
+	if err := generator.ValidateFramework(testFramework); err != nil {
+		return "", fmt.Errorf("%w: %v", mcp.ErrInvalidArguments, err)
+	}
+
+	if !write {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("read error: %w", err)
+		}
+		opts := generator.TestOptions{Framework: testFramework, Prompt: projectConfig.Prompts.Tests, Conventions: testConventions(), Imports: importPolicy()}
+		if parsed, err := source.Parse(file, content); err == nil {
+			opts.PackageContext = packageContext(ctx, file, parsed)
+		}
+		tests, err := generator.GenerateUnitTests(ctx, string(content), provider, opts)
+		if err != nil {
+			return "", fmt.Errorf("generation error: %w", err)
+		}
+		return fixPackage(string(content), tests, filepath.Dir(file), false), nil
+	}
+
+	mcpWriteMu.Lock()
+	defer mcpWriteMu.Unlock()
+	framework, forceOverwrite = testFramework, overwrite
+	outFile := strings.TrimSuffix(file, ".go") + "_test.go"
+	if err := writeTestFile(ctx, provider, file, outFile, "", nil); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Tests written to %s", outFile), nil
+}
+
+// mcpCoverage runs the tests of pkg with coverage and returns a table of the
+// coverage per package
+func mcpCoverage(ctx context.Context, pkg string) (string, error) {
+	profile, err := os.CreateTemp("", "aitestgen-cover-*.out")
+	if err != nil {
+		return "", err
+	}
+	profile.Close()
+	defer os.Remove(profile.Name())
+
+	var output bytes.Buffer
+	testCmd := exec.CommandContext(ctx, "go", "test", pkg, "-coverprofile", profile.Name())
+	testCmd.Stdout = &output
+	testCmd.Stderr = &output
+	if err := testCmd.Run(); err != nil {
+		return "", fmt.Errorf("tests failed: %w\n%s", err, output.String())
+	}
+
+	profiles, err := coverage.ParseProfiles(profile.Name())
+	if err != nil {
+		return "", fmt.Errorf("reading coverage profile: %w", err)
+	}
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tSTATEMENTS\tCOVERAGE")
+	for _, p := range coverage.Packages(profiles) {
+		fmt.Fprintf(w, "%s\t%d/%d\t%.1f%%\n", p.Path, p.Covered, p.Total, p.Percent())
+	}
+	fmt.Fprintf(w, "total\t\t%.1f%%\n", coverage.Percent(profiles))
+	w.Flush()
+	return b.String(), nil
+}
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+	mcpProvider.addFlags(mcpCmd)
+}
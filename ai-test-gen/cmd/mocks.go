@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/formatter"
+	"github.com/knbr13/aitestgen/pkg/mockgen"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+var (
+	mocksInputFile  string
+	mocksOutputFile string
+	mocksStyle      string
+)
+
+var mocksCmd = &cobra.Command{
+	Use:   "mocks",
+	Short: "Generate mock implementations of the interfaces in a Go file",
+	Run: func(cmd *cobra.Command, args []string) {
+		if mocksInputFile == "" {
+			fmt.Println("You must specify --file.")
+			os.Exit(1)
+		}
+		if mocksOutputFile == "" {
+			mocksOutputFile = mockFileFor(mocksInputFile)
+		}
+
+		if err := writeMocks(mocksInputFile, mocksOutputFile, mocksStyle); err != nil {
+			fmt.Printf("Error generating mocks: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Mocks generated: %s\n", mocksOutputFile)
+	},
+}
+
+// mockFileFor returns the mock file name for a Go source file
+func mockFileFor(file string) string {
+	return strings.TrimSuffix(file, ".go") + "_mock_test.go"
+}
+
+// writeMocks generates mocks for the interfaces in inFile and writes them to outFile
+func writeMocks(inFile, outFile, style string) error {
+	content, err := os.ReadFile(inFile)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+	file, err := source.Parse(inFile, content)
+	if err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+
+	mocks, skipped, err := mockgen.Generate(file, style)
+	for _, s := range skipped {
+		fmt.Fprintf(os.Stderr, "skipped interface %s\n", s)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(outFile, []byte(mocks), 0644); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+	if err := formatter.RunGoImports(outFile); err != nil {
+		return fmt.Errorf("goimports error: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(mocksCmd)
+	mocksCmd.Flags().StringVarP(&mocksInputFile, "file", "f", "", "Input Go file")
+	mocksCmd.Flags().StringVarP(&mocksOutputFile, "output", "o", "", "Output file (default <file>_mock_test.go)")
+	mocksCmd.Flags().StringVar(&mocksStyle, "style", mockgen.StyleFunc, "Mock style (func, mockery)")
+}
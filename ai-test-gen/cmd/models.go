@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+)
+
+var modelsProvider string
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "List known models with their token limits and defaults",
+	Run: func(cmd *cobra.Command, args []string) {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROVIDER\tMODEL\tCONTEXT\tMAX OUTPUT\tTEMPERATURE\tDEFAULT")
+		for _, m := range generator.Models(modelsProvider) {
+			def := ""
+			if generator.DefaultModel(m.Provider) == m.Name {
+				def = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.1f\t%s\n", m.Provider, m.Name, m.ContextWindow, m.MaxOutputTokens, m.Temperature, def)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(modelsCmd)
+	modelsCmd.Flags().StringVar(&modelsProvider, "provider", "", "Only list models for this provider")
+}
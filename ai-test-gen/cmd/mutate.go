@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/mutate"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+var (
+	mutateTests      bool
+	mutateIterations int
+)
+
+// maxMutants caps the mutants tried per file, since each costs a go test run
+const maxMutants = 30
+
+// strengthenTests runs the package tests against mutants of inFile and asks
+// for tests in outFile that catch the surviving ones, for up to
+// --mutate-iterations rounds
+func strengthenTests(ctx context.Context, provider generator.Provider, inFile, outFile string) error {
+	content, err := os.ReadFile(inFile)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+	file, err := source.Parse(inFile, content)
+	if err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+	targets, _, err := testTargets(file)
+	if err != nil {
+		return err
+	}
+	mutants := mutate.Generate(file, targets, maxMutants)
+	if len(mutants) == 0 {
+		return nil
+	}
+
+	opts := testOptions()
+	if existing, err := os.ReadFile(outFile); err == nil {
+		if parsed, err := source.Parse(outFile, existing); err == nil {
+			opts.BlackBox = strings.HasSuffix(parsed.Package(), "_test")
+		}
+	}
+	w := testWriter{provider: provider, maxRepairs: maxRepairs, verify: true, opts: opts, review: reviewer()}
+
+	for iteration := 0; ; iteration++ {
+		results, err := mutate.Run(ctx, inFile, mutants)
+		if err != nil {
+			return err
+		}
+		var survivors []mutate.Mutant
+		killed := 0
+		for i, r := range results {
+			switch r {
+			case mutate.Survived:
+				survivors = append(survivors, mutants[i])
+			case mutate.Killed:
+				killed++
+			}
+		}
+		slog.Info("mutation testing", "file", inFile, "round", iteration, "killed", killed, "survived", len(survivors))
+		if len(survivors) == 0 || iteration >= mutateIterations {
+			return nil
+		}
+
+		tests, err := os.ReadFile(outFile)
+		if err != nil {
+			return fmt.Errorf("read error: %w", err)
+		}
+		var list strings.Builder
+		for _, m := range survivors {
+			fmt.Fprintf(&list, "- %s\n", m)
+		}
+		strengthened, err := generator.GenerateMutantTests(ctx, string(content), string(tests), list.String(), provider, opts)
+		if err != nil {
+			return fmt.Errorf("generation error: %w", err)
+		}
+		if err := w.write(ctx, string(content), strengthened, outFile); err != nil {
+			return err
+		}
+		// the killed mutants stay killed as long as the tests only grow
+		mutants = survivors
+	}
+}
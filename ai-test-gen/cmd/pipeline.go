@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/knbr13/aitestgen/pkg/pipeline"
+)
+
+// filePipeline returns the pipeline running process on the files of a
+// command, each recorded in report and its outcome logged: done for the files
+// processed, unless empty when process logs them itself, and failed with the
+// error of the files that fail, unless empty when the caller prints it
+func filePipeline(report *runReport, workers int, output func(file string) string, process func(ctx context.Context, job pipeline.Job) error, done, failed string) *pipeline.Pipeline {
+	return &pipeline.Pipeline{
+		Output: output,
+		Process: func(ctx context.Context, job pipeline.Job) error {
+			return report.track(ctx, job.Input, job.Output, func(ctx context.Context) error {
+				return process(ctx, job)
+			})
+		},
+		Report: func(job pipeline.Job, err error) {
+			logOutcome(job, err, done, failed)
+		},
+		Workers: workers,
+	}
+}
+
+// leftAlone reports whether err is that of a file deliberately left alone
+func leftAlone(err error) bool {
+	return errors.Is(err, errSkipped) || errors.Is(err, errDeclined) || errors.Is(err, errNoTargets) || errors.Is(err, errNoServices)
+}
+
+// logOutcome logs how job went, see filePipeline
+func logOutcome(job pipeline.Job, err error, done, failed string) {
+	switch {
+	case err == nil:
+		if done != "" {
+			slog.Info(done, "file", job.Input, "output", job.Output)
+		}
+	case errors.Is(err, errSkipped):
+		slog.Info("skipped existing test file", "output", job.Output)
+	case errors.Is(err, errNoTargets):
+		slog.Info("skipped, no functions selected", "file", job.Input)
+	case errors.Is(err, errDeclined):
+		slog.Info("not written", "output", job.Output)
+	case errors.Is(err, errNoServices):
+		slog.Debug("skipped", "file", job.Input, "reason", err)
+	case errors.Is(err, context.Canceled), failed == "":
+	default:
+		slog.Error(failed, "file", job.Input, "err", err)
+	}
+}
+
+// runFolder runs p on the Go files of folder, or with --changed those that
+// changed, recording their outcomes for --resume. It reports false when
+// there is nothing to do, and exits when the files can't be listed.
+func runFolder(ctx context.Context, report *runReport, p *pipeline.Pipeline, folder string) (pipeline.Result, bool) {
+	p.Walk = func(ctx context.Context) ([]string, error) {
+		return inputFiles(ctx, "", folder)
+	}
+	next := p.Filter
+	p.Filter = func(files []string) ([]string, error) {
+		files, err := report.useState(stateDir(folder), files)
+		if err != nil || next == nil {
+			return files, err
+		}
+		return next(files)
+	}
+
+	res, err := p.Run(ctx)
+	switch {
+	case errors.Is(err, pipeline.ErrNoFiles) && changedOnly:
+		fmt.Println("No changed Go files.")
+		return res, false
+	case errors.Is(err, pipeline.ErrNoFiles):
+		fmt.Println("No Go files found in folder.")
+		os.Exit(1)
+	case err != nil:
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	report.closeState(res.Files)
+	return res, true
+}
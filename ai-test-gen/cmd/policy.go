@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+var (
+	// noPolicy is --no-policy, which drops the policy of the config file
+	noPolicy bool
+	// policyPrompt is the policy file named by the config, appended to every
+	// prompt
+	policyPrompt string
+)
+
+// loadPolicy reads the policy file of the config file, relative to its
+// directory. A policy that can't be read fails the run rather than letting
+// prompts go out without it.
+func loadPolicy() error {
+	policyPrompt = ""
+	path := projectConfig.Policy
+	if path == "" {
+		return nil
+	}
+	if noPolicy {
+		slog.Warn("the organization policy is not applied (--no-policy)", "policy", path)
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(projectDir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config policy: %w", err)
+	}
+	policyPrompt = string(data)
+	return nil
+}
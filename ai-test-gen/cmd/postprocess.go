@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/knbr13/aitestgen/pkg/postprocess"
+)
+
+var (
+	// postProcessCommands are the --post-process commands, which replace
+	// those of the config file
+	postProcessCommands []string
+	// postProcessor rewrites generated tests and docs before they are written
+	postProcessor postprocess.Chain
+)
+
+// setupPostProcess builds postProcessor from --post-process, or the
+// post_process commands of the config file
+func setupPostProcess() error {
+	lines, dir := postProcessCommands, "."
+	if len(lines) == 0 {
+		lines, dir = projectConfig.PostProcess, projectDir
+	}
+	postProcessor = nil
+	for _, line := range lines {
+		c, err := postprocess.ParseCommand(line, dir)
+		if err != nil {
+			return err
+		}
+		postProcessor = append(postProcessor, c)
+	}
+	return nil
+}
+
+// postProcess passes content, generated for path, through the post-processors
+func postProcess(ctx context.Context, path, kind string, content []byte) ([]byte, error) {
+	if len(postProcessor) == 0 {
+		return content, nil
+	}
+	return postProcessor.Process(ctx, postprocess.File{Path: path, Kind: kind, Content: content})
+}
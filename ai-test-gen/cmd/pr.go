@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/github"
+	"github.com/knbr13/aitestgen/pkg/pipeline"
+	"github.com/knbr13/aitestgen/pkg/runner"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// Ways the pr command delivers generated tests
+const (
+	prModeComment = "comment"
+	prModeCommit  = "commit"
+)
+
+// maxCommentLen stays below GitHub's 65536 character limit on comment bodies
+const maxCommentLen = 60000
+
+var (
+	prRepo        string
+	prNumber      int
+	prToken       string
+	prAPIURL      string
+	prMode        string
+	prConcurrency int
+	prProvider    providerOptions
+)
+
+var prCmd = &cobra.Command{
+	Use:   "pr",
+	Short: "Generate tests for the Go files changed by a GitHub pull request",
+	Long: `Fetch the Go files changed by a pull request, generate tests for those that
+have no test file yet, and either post them as a comment on the pull request
+(--mode comment) or push them as a commit to its branch (--mode commit).
+
+The token is read from --token or GITHUB_TOKEN and needs permission to comment
+on, or push to, the pull request. Since the code is not checked out, generated
+tests are not compiled before they are delivered.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		report, ctx := newReport(cmd.Context(), "pr")
+
+		if prRepo == "" || prNumber <= 0 {
+			fmt.Println("--repo and --pr are required.")
+			os.Exit(1)
+		}
+		if prMode != prModeComment && prMode != prModeCommit {
+			fmt.Printf("Unknown mode %q (use comment or commit).\n", prMode)
+			os.Exit(1)
+		}
+		if prToken == "" {
+			prToken = os.Getenv("GITHUB_TOKEN")
+		}
+		if err := generator.ValidateFramework(framework); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		gh := github.NewClient(prToken, prAPIURL)
+		pr, err := gh.PullRequest(ctx, prRepo, prNumber)
+		if err != nil {
+			fmt.Printf("Error fetching pull request: %v\n", err)
+			os.Exit(1)
+		}
+		files, err := prGoFiles(ctx, gh, pr)
+		if err != nil {
+			fmt.Printf("Error listing pull request files: %v\n", err)
+			os.Exit(1)
+		}
+		if len(files) == 0 {
+			fmt.Println("No changed Go files.")
+			return
+		}
+
+		provider, err := prProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		report.setModel(prProvider.name, prProvider.modelName())
+
+		headRepo := pr.Head.Repo.FullName
+		var (
+			mu    sync.Mutex
+			tests = make(map[string]string)
+		)
+		p := filePipeline(report, prConcurrency, testFileFor, func(ctx context.Context, job pipeline.Job) error {
+			generated, err := generatePRTests(ctx, gh, provider, headRepo, pr.Head.SHA, job.Input, job.Output)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			tests[job.Output] = generated
+			mu.Unlock()
+			return nil
+		}, "tests generated", "generation failed")
+		p.Walk = func(context.Context) ([]string, error) { return files, nil }
+		if _, err := p.Run(ctx); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		report.finish()
+		if ctx.Err() != nil {
+			fmt.Printf("%s: nothing was posted\n", stopReason(ctx))
+			os.Exit(1)
+		}
+		if len(tests) == 0 {
+			fmt.Println("No tests generated.")
+			if report.failed() {
+				os.Exit(1)
+			}
+			return
+		}
+
+		if prMode == prModeCommit {
+			message := fmt.Sprintf("Add generated tests for %d files", len(tests))
+			sha, err := gh.Commit(ctx, headRepo, pr.Head.Ref, pr.Head.SHA, message, tests)
+			if err != nil {
+				fmt.Printf("Error pushing commit: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Pushed %s to %s:%s\n", sha, headRepo, pr.Head.Ref)
+		} else {
+			if err := gh.Comment(ctx, prRepo, prNumber, prComment(tests, report.Model)); err != nil {
+				fmt.Printf("Error posting comment: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Commented on %s#%d\n", prRepo, prNumber)
+		}
+		if report.failed() {
+			os.Exit(1)
+		}
+	},
+}
+
+// prGoFiles returns the non-test Go files the pull request adds or modifies,
+// leaving out those folder mode would skip
+func prGoFiles(ctx context.Context, gh *github.Client, pr *github.PullRequest) ([]string, error) {
+	changed, err := gh.PullRequestFiles(ctx, prRepo, pr.Number)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := fileFilter(".")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, f := range changed {
+		name := f.Filename
+		if f.Status == "removed" || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if filter.Skip(name, false) || ignoredPath(name) {
+			continue
+		}
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// generatePRTests returns tests for file as of ref, or errSkipped if its test
+// file already exists
+func generatePRTests(ctx context.Context, gh *github.Client, provider generator.Provider, repo, ref, file, outFile string) (string, error) {
+	if _, err := gh.FileContent(ctx, repo, outFile, ref); err == nil {
+		return "", errSkipped
+	} else if !errors.Is(err, github.ErrNotFound) {
+		return "", err
+	}
+
+	content, err := gh.FileContent(ctx, repo, file, ref)
+	if err != nil {
+		return "", fmt.Errorf("fetch error: %w", err)
+	}
+	tests, err := generator.GenerateUnitTests(ctx, content, provider, testOptions())
+	if err != nil {
+		return "", fmt.Errorf("generation error: %w", err)
+	}
+	// the repository isn't checked out, so only the package clause can be checked
+	if parsed, err := source.Parse(file, []byte(content)); err == nil {
+		if fixed, err := source.FixTestPackage(tests, source.TestPackage{Name: parsed.Package(), External: blackBox}); err == nil {
+			tests = fixed
+		}
+	}
+	return tests, nil
+}
+
+// prComment renders the generated tests as a pull request comment, leaving
+// out files once the comment would grow too long
+func prComment(tests map[string]string, model string) string {
+	paths := make([]string, 0, len(tests))
+	for path := range tests {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "### Generated tests\n\nTests for %d changed files", len(paths))
+	if model != "" {
+		fmt.Fprintf(&sb, ", written by %s", model)
+	}
+	sb.WriteString(". They have not been compiled or run; review them before adding them to the branch.\n")
+
+	for i, path := range paths {
+		section := fmt.Sprintf("\n<details>\n<summary><code>%s</code></summary>\n\n```go\n%s\n```\n\n</details>\n", path, strings.TrimSpace(tests[path]))
+		if sb.Len()+len(section) > maxCommentLen {
+			fmt.Fprintf(&sb, "\n%d more files did not fit in this comment; use --mode commit to push them instead.\n", len(paths)-i)
+			break
+		}
+		sb.WriteString(section)
+	}
+	return sb.String()
+}
+
+func init() {
+	rootCmd.AddCommand(prCmd)
+	prCmd.Flags().StringVar(&prRepo, "repo", "", "GitHub repository as owner/name (required)")
+	prCmd.Flags().IntVar(&prNumber, "pr", 0, "Pull request number (required)")
+	prCmd.Flags().StringVar(&prToken, "token", "", "GitHub token (default $GITHUB_TOKEN)")
+	prCmd.Flags().StringVar(&prAPIURL, "github-url", github.DefaultBaseURL, "GitHub API URL, for GitHub Enterprise")
+	prCmd.Flags().StringVar(&prMode, "mode", prModeComment, "How to deliver the tests (comment, commit)")
+	prCmd.Flags().IntVarP(&prConcurrency, "concurrency", "c", runner.DefaultConcurrency, "Number of files processed in parallel")
+	prCmd.Flags().StringVar(&framework, "framework", generator.FrameworkStdlib, "Test framework to generate for (stdlib, testify, ginkgo)")
+	prCmd.Flags().BoolVar(&blackBox, "black-box", false, "Generate external package foo_test tests that only exercise the exported API")
+	addFileFilterFlags(prCmd)
+	prProvider.addFlags(prCmd)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+)
+
+// progressLine shows how much of each streamed response has arrived on a
+// single status line on stderr. It stays silent when stderr is not a terminal.
+type progressLine struct {
+	mu sync.Mutex
+	// received is keyed by input path, as files of different packages may
+	// share a name
+	received map[string]int
+	drawn    time.Time
+}
+
+var progress = &progressLine{received: make(map[string]int)}
+
+// stderrIsTerminal reports whether stderr is attached to a terminal
+func stderrIsTerminal() bool {
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// track returns a context reporting streamed progress for file, and a function
+// to call once the file is done
+func (p *progressLine) track(ctx context.Context, file string) (context.Context, func()) {
+	if !stderrIsTerminal() {
+		return ctx, func() {}
+	}
+	ctx = generator.WithProgress(ctx, func(received int) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.received[file] = received
+		if time.Since(p.drawn) > 200*time.Millisecond {
+			p.draw()
+		}
+	})
+	return ctx, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if _, ok := p.received[file]; ok {
+			delete(p.received, file)
+			p.draw()
+		}
+	}
+}
+
+// draw redraws the status line; p.mu must be held
+func (p *progressLine) draw() {
+	p.drawn = time.Now()
+	files := make([]string, 0, len(p.received))
+	for file := range p.received {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	parts := make([]string, len(files))
+	for i, file := range files {
+		parts[i] = fmt.Sprintf("%s ~%d tokens", progressName(file), (p.received[file]+3)/4)
+	}
+	fmt.Fprintf(os.Stderr, "\r\033[K%s", strings.Join(parts, " | "))
+}
+
+// progressName shortens file to its directory and name for the status line
+func progressName(file string) string {
+	return filepath.Join(filepath.Base(filepath.Dir(file)), filepath.Base(file))
+}
+
+// salvagePartial saves the output of a response that was cut short next to
+// outFile, so it isn't lost, and notes where it went in the returned error
+func salvagePartial(outFile string, err error) error {
+	var partial *generator.PartialError
+	if !errors.As(err, &partial) || partial.Text == "" {
+		return err
+	}
+	path := outFile + ".partial"
+	if writeErr := os.WriteFile(path, []byte(partial.Text), 0644); writeErr != nil {
+		return err
+	}
+	return fmt.Errorf("%w (partial output saved to %s)", err, path)
+}
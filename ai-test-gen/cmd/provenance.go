@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/audit"
+)
+
+var provenanceLog string
+
+var provenanceCmd = &cobra.Command{
+	Use:   "verify-provenance [file...]",
+	Short: "Check files against the generations recorded in the audit log",
+	Long: `Check that files match what the audit log recorded when they were generated.
+The log is written with --audit, or audit: true in the config file, and lists
+for every generated file its source, the provider and model, a hash of the
+prompts and a hash of the file written.
+
+Each file is reported as generated when its content matches its last recorded
+generation, modified when it was changed since, earlier when it matches an
+older generation only, unknown when no generation of it is recorded and
+missing when it no longer exists. Without arguments, every file of the log is
+checked.
+
+The log itself is checked first: every line holds the hash of the one before,
+so lines removed or edited break the chain. When ` + auditKeyEnv + ` is set,
+entries are signed with it as they are written, and here every entry must
+carry a valid signature.
+
+Exits with status 1 unless every file matches its last generation.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := provenanceLog
+		if path == "" {
+			path = filepath.Join(projectDir, audit.FileName)
+		}
+		if _, err := os.Stat(path); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		key := auditKey()
+		entries, err := audit.Load(path, key)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if key == nil && audit.Signed(entries) {
+			slog.Warn("the log is signed but " + auditKeyEnv + " is not set, signatures are not checked")
+		}
+
+		var files []string
+		for _, file := range args {
+			files = append(files, projectPath(file))
+		}
+		if len(args) == 0 {
+			seen := make(map[string]bool)
+			for _, e := range entries {
+				if !seen[e.Output] {
+					seen[e.Output] = true
+					files = append(files, e.Output)
+				}
+			}
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		ok := true
+		for _, file := range files {
+			status, detail := provenance(entries, file)
+			ok = ok && status == "generated"
+			fmt.Fprintf(w, "%s\t%s\t%s\n", status, file, detail)
+		}
+		w.Flush()
+		if !ok {
+			os.Exit(1)
+		}
+	},
+}
+
+// provenance returns the status of file, relative to projectDir as in the
+// log, against entries and what it was generated by
+func provenance(entries []audit.Entry, file string) (status, detail string) {
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(projectDir, filepath.FromSlash(file))
+	}
+	sum, err := audit.SumFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		sum = ""
+	} else if err != nil {
+		return "error", err.Error()
+	}
+	latest, match, recorded, matched := audit.Find(entries, file, sum)
+	switch {
+	case !recorded:
+		return "unknown", "no recorded generation"
+	case sum == "":
+		return "missing", "last " + generation(latest)
+	case matched && match == latest:
+		return "generated", generation(match)
+	case matched:
+		return "earlier", generation(match) + ", not the last one"
+	}
+	return "modified", "since " + generation(latest)
+}
+
+// generation describes a recorded generation, e.g. "generated 2024-05-01
+// 10:00 UTC by gemini/gemini-1.5-pro from a.go"
+func generation(e audit.Entry) string {
+	s := "generated " + e.Time.UTC().Format(time.DateTime) + " UTC"
+	if e.Provider != "" {
+		s += " by " + e.Provider + "/" + e.Model
+	}
+	if e.Source != "" {
+		s += " from " + e.Source
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(provenanceCmd)
+	provenanceCmd.Flags().StringVar(&provenanceLog, "log", "", "Audit log to check (default: "+audit.FileName+" next to the config file)")
+}
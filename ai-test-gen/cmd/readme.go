@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/formatter"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/runner"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+var (
+	readmeDir      string
+	readmeOutput   string
+	readmeProvider providerOptions
+)
+
+var readmeCmd = &cobra.Command{
+	Use:   "readme",
+	Short: "Generate or update the project README",
+	Long: `Summarize the whole module (its packages, exported API, main packages and
+cobra commands) and ask the model for a README.md with installation, usage and
+API sections. An existing README is updated rather than replaced, keeping the
+hand-written content that is still accurate.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		report, ctx := newReport(cmd.Context(), "readme")
+
+		output := readmeOutput
+		if output == "" {
+			output = filepath.Join(readmeDir, "README.md")
+		}
+		filter, err := fileFilter(readmeDir)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		files, err := runner.GoFiles(readmeDir, filter)
+		if err != nil {
+			fmt.Printf("Error listing files: %v\n", err)
+			os.Exit(1)
+		}
+		if len(files) == 0 {
+			fmt.Println("No Go files found in folder.")
+			os.Exit(1)
+		}
+		summary, err := source.ModuleSummary(readmeDir, files)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		existing, _ := os.ReadFile(output)
+
+		if dryRun {
+			plan := dryRunPlan{model: readmeProvider.modelName()}
+			plan.add(readmeDir, output, []string{generator.ReadmeRequestPrompt(summary, string(existing))}, nil)
+			plan.summary()
+			return
+		}
+
+		provider, err := readmeProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		report.setModel(readmeProvider.name, readmeProvider.modelName())
+
+		err = report.track(ctx, readmeDir, output, func(ctx context.Context) error {
+			readme, err := generator.GenerateReadme(ctx, summary, string(existing), provider)
+			if err != nil {
+				return fmt.Errorf("generation error: %w", err)
+			}
+			readme = formatter.FormatDocumentation(readme) + "\n"
+			if showDiff && !reviewChange(output, string(existing), readme) {
+				return errDeclined
+			}
+			if err := os.WriteFile(output, []byte(readme), 0644); err != nil {
+				return fmt.Errorf("write error: %w", err)
+			}
+			return nil
+		})
+		report.finish()
+		if errors.Is(err, errDeclined) {
+			fmt.Printf("Not written: %s\n", output)
+			return
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("README written: %s\n", output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(readmeCmd)
+	readmeCmd.Flags().StringVarP(&readmeDir, "dir", "d", ".", "Root of the module to describe")
+	readmeCmd.Flags().StringVarP(&readmeOutput, "output", "o", "", "README file to write (default README.md in --dir)")
+	readmeCmd.Flags().BoolVar(&showDiff, "diff", false, "Show a diff against the existing README and ask before writing")
+	readmeCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "With --diff, write without asking")
+	readmeCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the prompt size without calling the API or writing files")
+	readmeCmd.Flags().BoolVar(&showPrompt, "show-prompt", false, "With --dry-run, print the full prompt")
+	addFileFilterFlags(readmeCmd)
+	readmeProvider.addFlags(readmeCmd)
+}
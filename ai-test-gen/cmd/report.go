@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/history"
+	"github.com/knbr13/aitestgen/pkg/state"
+	"github.com/knbr13/aitestgen/pkg/tokens"
+)
+
+var (
+	// maxCost is the --max-cost budget in US dollars; zero means unlimited
+	maxCost float64
+	// failFast stops a folder run at the first file that fails
+	failFast bool
+	// resumeRun skips the files a previous folder run finished
+	resumeRun bool
+)
+
+// stateDir returns the directory holding the run state of a folder run,
+// which is the current directory for --changed without --folder
+func stateDir(folder string) string {
+	if folder == "" {
+		return "."
+	}
+	return folder
+}
+
+// stopReason describes why a folder run ended early
+func stopReason(ctx context.Context) string {
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, errBudgetExceeded):
+		return fmt.Sprintf("Stopped at the --max-cost budget of %s", tokens.FormatCost(maxCost))
+	case errors.Is(cause, errFailFast):
+		return "Stopped at the first failure (--fail-fast)"
+	case errors.Is(cause, errDeadline):
+		return fmt.Sprintf("Stopped at the --deadline of %s", runDeadline)
+	}
+	return "Interrupted"
+}
+
+var (
+	jsonOutput bool
+	// jsonOut receives the --json report. With --json, os.Stdout is pointed at
+	// stderr so progress messages don't mix with the report.
+	jsonOut io.Writer = os.Stdout
+)
+
+// File statuses used in the --json report
+const (
+	statusGenerated = "generated"
+	statusSkipped   = "skipped"
+	statusFailed    = "failed"
+	statusCovered   = "covered"
+	// statusCancelled marks files cut short when the run was stopped
+	statusCancelled = "cancelled"
+)
+
+// fileResult is the outcome for one processed file
+type fileResult struct {
+	Input          string   `json:"input"`
+	Output         string   `json:"output,omitempty"`
+	Status         string   `json:"status"`
+	Error          string   `json:"error,omitempty"`
+	Coverage       *float64 `json:"coverage,omitempty"`
+	PromptTokens   int64    `json:"prompt_tokens"`
+	ResponseTokens int64    `json:"response_tokens"`
+	CostUSD        *float64 `json:"cost_usd,omitempty"`
+	DurationMS     int64    `json:"duration_ms"`
+}
+
+// runReport collects per-file outcomes for the --json output of a command
+type runReport struct {
+	Command   string       `json:"command"`
+	Files     []fileResult `json:"files"`
+	Processed int          `json:"processed"`
+	Failed    int          `json:"failed"`
+	Skipped   int          `json:"skipped"`
+	// CoverageBefore is the coverage at the start of the run, for commands
+	// that raise it
+	CoverageBefore *float64 `json:"coverage_before,omitempty"`
+	Coverage       *float64 `json:"coverage,omitempty"`
+	Provider       string   `json:"provider,omitempty"`
+	Model          string   `json:"model,omitempty"`
+	PromptTokens   int64    `json:"prompt_tokens"`
+	ResponseTokens int64    `json:"response_tokens"`
+	// TokensEstimated is set when the provider did not report some counts
+	TokensEstimated bool     `json:"tokens_estimated,omitempty"`
+	CostUSD         *float64 `json:"cost_usd,omitempty"`
+	DurationMS      int64    `json:"duration_ms"`
+	// Packages are the test results of --run-tests
+	Packages []packageResult `json:"packages,omitempty"`
+
+	mu     sync.Mutex
+	start  time.Time
+	usage  generator.Usage
+	cancel context.CancelCauseFunc
+	// state, when set, records the outcome of each file for --resume
+	state *state.Manifest
+}
+
+// The causes a run is cancelled with. They wrap context.Canceled because
+// requests fail with the cause, and the files cut short should be counted as
+// cancelled rather than failed.
+var (
+	// errBudgetExceeded stops a run whose cost passes --max-cost
+	errBudgetExceeded = fmt.Errorf("cost budget exceeded: %w", context.Canceled)
+	// errFailFast stops a run after a file failed with --fail-fast
+	errFailFast = fmt.Errorf("a file failed: %w", context.Canceled)
+	// errDeadline stops a run that takes longer than --deadline
+	errDeadline = fmt.Errorf("run deadline reached: %w", context.Canceled)
+)
+
+// newReport starts a report for command. Requests made with the returned
+// context count towards its token totals; the context is cancelled with
+// errBudgetExceeded once the run costs more than --max-cost.
+func newReport(ctx context.Context, command string) (*runReport, context.Context) {
+	r := &runReport{Command: command, Files: []fileResult{}, start: time.Now()}
+	ctx, r.cancel = context.WithCancelCause(ctx)
+	return r, generator.WithUsage(ctx, &r.usage)
+}
+
+// setModel records the provider and model used, which prices the run
+func (r *runReport) setModel(provider, model string) {
+	r.Provider, r.Model = provider, model
+	if _, ok := tokens.PriceOf(model); !ok && maxCost > 0 {
+		slog.Warn("no price known for the model, --max-cost is not enforced", "model", model)
+	}
+}
+
+// cost returns the cost of the given token counts on the report's model
+func (r *runReport) cost(promptTokens, responseTokens int64) *float64 {
+	usd, ok := tokens.Cost(r.Model, promptTokens, responseTokens)
+	if !ok {
+		return nil
+	}
+	return &usd
+}
+
+// track runs fn for input and records its outcome, duration and token usage.
+// Files left alone on purpose (see leftAlone) are recorded as skips and files
+// cut short by cancellation as cancelled. With --fail-fast, a failure cancels
+// the run. Generated files are added to the audit log when it is enabled.
+// fn's error is returned unchanged.
+func (r *runReport) track(ctx context.Context, input, output string, fn func(ctx context.Context) error) error {
+	var (
+		usage   generator.Usage
+		prompts generator.PromptDigest
+	)
+	start := time.Now()
+	ctx = generator.WithPromptDigest(generator.WithUsage(ctx, &usage), &prompts)
+	ctx, done := progress.track(ctx, input)
+	err := salvagePartial(output, fn(ctx))
+	done()
+
+	res := fileResult{
+		Input:          input,
+		Output:         output,
+		Status:         statusGenerated,
+		PromptTokens:   usage.PromptTokens(),
+		ResponseTokens: usage.ResponseTokens(),
+		CostUSD:        r.cost(usage.PromptTokens(), usage.ResponseTokens()),
+		DurationMS:     time.Since(start).Milliseconds(),
+	}
+	switch {
+	case leftAlone(err):
+		res.Status = statusSkipped
+	case errors.Is(err, context.Canceled):
+		res.Status = statusCancelled
+	case err != nil:
+		res.Status = statusFailed
+		res.Error = err.Error()
+	}
+	r.add(res)
+	if res.Status == statusGenerated {
+		r.audit(input, output, &prompts)
+	}
+	if r.state != nil && res.Status != statusCancelled {
+		if err := r.state.Record(input, res.Status, res.Error); err != nil {
+			slog.Warn("saving run state failed", "file", r.state.Path(), "err", err)
+		}
+	}
+	slog.DebugContext(ctx, "file finished", "file", input, "status", res.Status, "duration", time.Duration(res.DurationMS)*time.Millisecond,
+		"prompt_tokens", res.PromptTokens, "response_tokens", res.ResponseTokens)
+
+	// a watch keeps going whatever happens to a single file
+	if res.Status == statusFailed && failFast && !watchMode {
+		r.cancel(errFailFast)
+	}
+
+	if maxCost > 0 {
+		if total := r.cost(r.usage.PromptTokens(), r.usage.ResponseTokens()); total != nil && *total > maxCost {
+			r.cancel(errBudgetExceeded)
+		}
+	}
+	return err
+}
+
+// useState records the outcome of each file of a folder run in a manifest in
+// dir and returns the files to process: all of files, or with --resume those
+// the previous run didn't finish
+func (r *runReport) useState(dir string, files []string) ([]string, error) {
+	r.state = state.New(dir, r.Command)
+	if !resumeRun {
+		return files, nil
+	}
+	m, err := state.Load(dir, r.Command)
+	if err != nil {
+		return nil, fmt.Errorf("reading run state: %w", err)
+	}
+	r.state = m
+	pending := m.Pending(files, statusGenerated, statusSkipped)
+	if done := len(files) - len(pending); done > 0 {
+		slog.Info("resuming", "done", done, "remaining", len(pending))
+	}
+	return pending, nil
+}
+
+// closeState removes the manifest once every one of files is finished, so
+// only runs that have something left to resume leave one behind
+func (r *runReport) closeState(files []string) {
+	if r.state == nil || len(r.state.Pending(files, statusGenerated, statusSkipped)) > 0 {
+		return
+	}
+	if err := r.state.Remove(); err != nil {
+		slog.Warn("removing run state failed", "file", r.state.Path(), "err", err)
+	}
+}
+
+func (r *runReport) add(res fileResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Files = append(r.Files, res)
+	switch res.Status {
+	case statusSkipped, statusCancelled:
+		r.Skipped++
+	case statusFailed:
+		r.Failed++
+	default:
+		r.Processed++
+	}
+}
+
+// finish writes the report to stdout when --json is set, and otherwise prints
+// a summary of the tokens used and of the files that failed
+func (r *runReport) finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.PromptTokens = r.usage.PromptTokens()
+	r.ResponseTokens = r.usage.ResponseTokens()
+	r.TokensEstimated = r.usage.Estimated()
+	r.CostUSD = r.cost(r.PromptTokens, r.ResponseTokens)
+	r.DurationMS = time.Since(r.start).Milliseconds()
+	r.saveHistory()
+
+	if !jsonOutput {
+		r.printFailures()
+		if r.usage.Requests() == 0 || quiet {
+			return
+		}
+		approx := ""
+		if r.TokensEstimated {
+			approx = "~"
+		}
+		fmt.Printf("Tokens: %s%d prompt + %s%d response in %d requests", approx, r.PromptTokens, approx, r.ResponseTokens, r.usage.Requests())
+		if r.CostUSD != nil {
+			fmt.Printf(", estimated cost %s (%s)", tokens.FormatCost(*r.CostUSD), r.Model)
+		}
+		fmt.Println()
+		return
+	}
+
+	enc := json.NewEncoder(jsonOut)
+	enc.SetIndent("", "  ")
+	enc.Encode(r)
+}
+
+// saveHistory adds the run to the history log of the project, for the
+// report command, unless --no-history is set or nothing was done
+func (r *runReport) saveHistory() {
+	if noHistory || (len(r.Files) == 0 && r.usage.Requests() == 0) {
+		return
+	}
+	run := history.Run{
+		Time:           r.start.UTC(),
+		Command:        r.Command,
+		Provider:       r.Provider,
+		Model:          r.Model,
+		Processed:      r.Processed,
+		Failed:         r.Failed,
+		Skipped:        r.Skipped,
+		CoverageBefore: r.CoverageBefore,
+		Coverage:       r.Coverage,
+		PromptTokens:   r.PromptTokens,
+		ResponseTokens: r.ResponseTokens,
+		CostUSD:        r.CostUSD,
+		DurationMS:     r.DurationMS,
+	}
+	if err := history.Append(projectDir, run); err != nil {
+		slog.Warn("saving run history failed", "err", err)
+	}
+}
+
+// printFailures lists the files that failed, in path order, on stderr
+func (r *runReport) printFailures() {
+	if r.Failed == 0 || len(r.Files) < 2 {
+		return
+	}
+	var failed []fileResult
+	for _, res := range r.Files {
+		if res.Status == statusFailed {
+			failed = append(failed, res)
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Input < failed[j].Input })
+
+	fmt.Fprintf(os.Stderr, "%d of %d files failed:\n", len(failed), len(r.Files))
+	for _, res := range failed {
+		fmt.Fprintf(os.Stderr, "  %s: %s\n", res.Input, res.Error)
+	}
+}
+
+// failed reports whether any file failed
+func (r *runReport) failed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Failed > 0
+}
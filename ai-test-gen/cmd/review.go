@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/diff"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/tui"
+)
+
+var (
+	showDiff  bool
+	assumeYes bool
+)
+
+// errDeclined is returned when a change shown with --diff is not accepted
+var errDeclined = errors.New("changes not applied")
+
+var (
+	// reviewMu keeps diffs and prompts of concurrent files from interleaving
+	reviewMu sync.Mutex
+	stdin    = bufio.NewReader(os.Stdin)
+)
+
+// reviewer returns the review hook selected by --diff, or nil
+func reviewer() func(path, old, new string) bool {
+	if !showDiff {
+		return nil
+	}
+	return reviewChange
+}
+
+// reviewChange prints the diff from old to new for path and asks whether to
+// apply it, unless --yes is set
+func reviewChange(path, old, new string) bool {
+	reviewMu.Lock()
+	defer reviewMu.Unlock()
+
+	patch := diff.Unified(path, path, old, new)
+	if patch == "" {
+		fmt.Printf("%s: no changes\n", path)
+		return true
+	}
+	fmt.Print(patch)
+	if assumeYes {
+		return true
+	}
+
+	fmt.Printf("Apply changes to %s? [y/N] ", path)
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+var reviewProvider providerOptions
+
+var reviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Generate tests and review each file interactively before writing it",
+	Long: `Generate tests file by file and review each one in a terminal UI showing
+the diff against the existing test file. Accept writes the file, skip leaves it
+untouched, and regenerate asks for it again with extra instructions. Nothing
+is written until a file is accepted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		report, ctx := newReport(cmd.Context(), "review")
+
+		if err := generator.ValidateFramework(framework); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		files, err := inputFiles(ctx, inputFile, inputFolder)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(files) == 0 {
+			fmt.Println("No Go files to review.")
+			return
+		}
+
+		provider, err := reviewProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		report.setModel(reviewProvider.name, reviewProvider.modelName())
+
+		// existing test files are replaced only once the diff is accepted
+		forceOverwrite = !appendTests
+
+		for i, file := range files {
+			if reviewFile(ctx, report, provider, file, i+1, len(files)) == tui.Quit || ctx.Err() != nil {
+				break
+			}
+		}
+		report.finish()
+	},
+}
+
+// reviewFile generates tests for file until the reviewer accepts, skips or
+// quits, and returns that decision
+func reviewFile(ctx context.Context, report *runReport, provider generator.Provider, file string, index, total int) tui.Decision {
+	outFile := testFileFor(file)
+	var instructions []string
+	for {
+		decision := tui.Skip
+		var feedback string
+		review := func(path, old, new string) bool {
+			var err error
+			change := tui.Change{Path: path, Diff: diff.Unified(path, path, old, new), Index: index, Total: total}
+			if decision, feedback, err = tui.Review(change); err != nil {
+				slog.Error("review failed", "file", path, "err", err)
+				decision = tui.Quit
+			}
+			return decision == tui.Accept
+		}
+
+		err := report.track(ctx, file, outFile, func(ctx context.Context) error {
+			return writeTestFile(ctx, provider, file, outFile, strings.Join(instructions, "\n"), review)
+		})
+		switch {
+		case err == nil:
+			slog.Info("tests written", "file", file, "output", outFile)
+		case errors.Is(err, errDeclined) && decision == tui.Regenerate:
+			slog.Info("regenerating", "file", file, "instructions", feedback)
+		case errors.Is(err, errDeclined), errors.Is(err, errSkipped), errors.Is(err, errNoTargets):
+			slog.Info("not written", "output", outFile)
+		case !errors.Is(err, context.Canceled):
+			slog.Error("generation failed", "file", file, "err", err)
+		}
+
+		if decision != tui.Regenerate {
+			return decision
+		}
+		instructions = append(instructions, feedback)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(reviewCmd)
+	reviewCmd.Flags().StringVarP(&inputFile, "file", "f", "", "Input Go file")
+	reviewCmd.Flags().StringVarP(&inputFolder, "folder", "d", "", "Input folder (recursively processes all Go files)")
+	reviewCmd.Flags().IntVar(&maxRepairs, "max-repairs", 2, "Maximum attempts to fix generated tests that fail to compile (0 disables the check)")
+	reviewCmd.Flags().BoolVar(&verifyTests, "verify", false, "Run the generated tests before review, sending failures back for repair")
+	reviewCmd.Flags().BoolVar(&perFunction, "per-function", false, "Generate tests one function at a time, sending only the declarations each depends on")
+	reviewCmd.Flags().BoolVar(&onlyExport, "only-exported", false, "Only generate tests for exported functions and methods")
+	reviewCmd.Flags().StringVar(&framework, "framework", generator.FrameworkStdlib, "Test framework to generate for (stdlib, testify, ginkgo)")
+	reviewCmd.Flags().BoolVar(&blackBox, "black-box", false, "Generate external package foo_test tests that only exercise the exported API")
+	reviewCmd.Flags().BoolVar(&noPkgCtx, "no-package-context", false, "Don't add the declarations the file uses from the rest of its package to the prompt")
+	reviewCmd.Flags().BoolVar(&withMocks, "mocks", false, "Generate mocks for the file's interfaces and use them in the tests")
+	reviewCmd.Flags().StringVar(&mocksStyle, "mock-style", "func", "Mock style used with --mocks (func, mockery)")
+	reviewCmd.Flags().BoolVar(&appendTests, "append", false, "Add tests to existing test files for functions that have none")
+	reviewCmd.Flags().BoolVar(&changedOnly, "changed", false, "Only review Go files changed since --base (within --folder, default the current directory)")
+	reviewCmd.Flags().StringVar(&baseRef, "base", "HEAD", "Git ref that --changed compares the working tree against")
+	addFileFilterFlags(reviewCmd)
+	reviewProvider.addFlags(reviewCmd)
+}
@@ -1,20 +1,196 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/knbr13/aitestgen/pkg/audit"
+	"github.com/knbr13/aitestgen/pkg/config"
+	"github.com/knbr13/aitestgen/pkg/formatter"
+	"github.com/knbr13/aitestgen/pkg/history"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+var (
+	configFile    string
+	projectConfig config.Config
+	// projectDir is the directory of the config file, or the current
+	// directory without one, where the run history is kept
+	projectDir  = "."
+	runDeadline time.Duration
+	noHistory   bool
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "aigen",
 	Short: "AI-powered Go unit test generator",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if jsonOutput {
+			jsonOut = os.Stdout
+			os.Stdout = os.Stderr
+		}
+		if err := setupLogging(); err != nil {
+			return err
+		}
+		if runDeadline > 0 {
+			// cancelled rather than timed out, so files cut short count as
+			// cancelled like on Ctrl-C, with stopReason telling them apart
+			ctx, cancel := context.WithCancelCause(cmd.Context())
+			time.AfterFunc(runDeadline, func() { cancel(errDeadline) })
+			cmd.SetContext(ctx)
+		}
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		if err := loadPolicy(); err != nil {
+			return err
+		}
+		setupBuildEnv()
+		return setupPostProcess()
+	},
 }
 
+// loadConfig reads the project configuration and uses it to fill in any flags
+// not given on the command line
+func loadConfig(cmd *cobra.Command) error {
+	path := configFile
+	if path == "" {
+		found, err := config.Find(".")
+		if err != nil || found == "" {
+			return err
+		}
+		path = found
+	}
+
+	c, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	projectConfig = c
+	projectDir = filepath.Dir(path)
+
+	defaults := map[string]string{
+		"provider":  c.Provider,
+		"model":     c.Model,
+		"base-url":  c.BaseURL,
+		"framework": c.Framework,
+	}
+	if c.Concurrency > 0 {
+		defaults["concurrency"] = strconv.Itoa(c.Concurrency)
+	}
+	defaults["fallback"] = strings.Join(c.Fallback, ",")
+	defaults["proxy"] = c.Network.Proxy
+	// certificate paths are relative to the config file
+	for flag, file := range map[string]string{"ca-cert": c.Network.CACert, "client-cert": c.Network.ClientCert, "client-key": c.Network.ClientKey} {
+		if file != "" && !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		defaults[flag] = file
+	}
+	for name, value := range defaults {
+		f := cmd.Flags().Lookup(name)
+		if value == "" || f == nil || f.Changed {
+			continue
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("config %s: %w", name, err)
+		}
+	}
+
+	if err := testConventions().Validate(); err != nil {
+		return fmt.Errorf("config conventions: %w", err)
+	}
+
+	if err := usePromptPack(); err != nil {
+		// version and self-update must run to report and install the pack
+		if cmd != versionCmd && cmd != selfUpdateCmd {
+			return err
+		}
+		slog.Warn("prompt pack not loaded", "error", err)
+	}
+	// the prompts of the config are passed with each request, see
+	// testOptions and generateDocs
+	if c.Prompts.Tests != "" {
+		promptSources["tests"] = "config"
+	}
+	if c.Prompts.Docs != "" {
+		promptSources["docs"] = "config"
+	}
+	return nil
+}
+
+// testFileFor returns the test file name for a Go source file
+func testFileFor(file string) string {
+	return projectConfig.TestFile(file)
+}
+
+// testConventions returns the test conventions of the project configuration
+func testConventions() source.Conventions {
+	c := projectConfig.Conventions
+	return source.Conventions{SubtestNames: c.SubtestNames, Parallel: c.Parallel, Assertions: c.Assertions}
+}
+
+// importPolicy returns the imports the config file allows generated tests
+func importPolicy() source.ImportPolicy {
+	return source.ImportPolicy{Allow: projectConfig.Imports.Allow, Deny: projectConfig.Imports.Deny}
+}
+
+// mirrorPath returns path moved from under root to the same place under
+// outDir, e.g. docs/pkg/a_doc.md for pkg/a_doc.md. Paths outside root keep
+// only their file name.
+func mirrorPath(outDir, root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = filepath.Base(path)
+	}
+	return filepath.Join(outDir, rel)
+}
+
+// deprecatedAlias registers old as a hidden alias of the flag name of cmd,
+// warning when it is used
+func deprecatedAlias(cmd *cobra.Command, name, old string) {
+	f := cmd.Flags().Lookup(name)
+	cmd.Flags().AddFlag(&pflag.Flag{Name: old, Usage: f.Usage, Value: f.Value, DefValue: f.DefValue})
+	cmd.Flags().MarkDeprecated(old, "use --"+name+" instead")
+}
+
+// docFileFor returns the documentation file name for a Go source file
+func docFileFor(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file)) + projectConfig.DocSuffix()
+}
+
+// Execute runs the root command, cancelling its context on SIGINT or SIGTERM
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Print a machine-readable JSON report of the run to stdout (generate, doc, cover)")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Log API requests, retries and per-file timing")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only log warnings and errors")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logFormatText, "Log format (text, json)")
+	rootCmd.PersistentFlags().DurationVar(&runDeadline, "deadline", 0, "Stop the whole run after this long, e.g. 30m (0 for no limit)")
+	rootCmd.PersistentFlags().BoolVar(&formatter.UseGoImportsBinary, "goimports-binary", false, "Fix imports with the goimports command in PATH instead of the built-in formatter")
+	rootCmd.PersistentFlags().BoolVar(&noHistory, "no-history", false, "Don't add this run to the "+history.FileName+" log the report command reads")
+	rootCmd.PersistentFlags().StringArrayVar(&postProcessCommands, "post-process", nil, "Command that rewrites generated tests and docs, reading them on stdin and printing the result (repeatable; replaces post_process of the config file)")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Config file (default: nearest "+config.FileName+")")
+	rootCmd.PersistentFlags().BoolVar(&auditLog, "audit", false, "Record every generated file in the "+audit.FileName+" log that verify-provenance checks (also set by audit in the config)")
+	rootCmd.PersistentFlags().BoolVar(&noPolicy, "no-policy", false, "Don't append the organization policy file of the config to prompts")
+}
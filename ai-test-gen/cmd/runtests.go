@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/knbr13/aitestgen/pkg/coverage"
+	"github.com/knbr13/aitestgen/pkg/gotool"
+)
+
+// runTests is --run-tests: after a folder run, the tests of the packages that
+// got new tests are run and summarized
+var runTests bool
+
+// Package statuses used in the --run-tests summary
+const (
+	packagePassed      = "passed"
+	packageFailed      = "failed"
+	packageBuildFailed = "build failed"
+)
+
+// packageResult is the outcome of running the tests of one package
+type packageResult struct {
+	Dir         string   `json:"dir"`
+	Status      string   `json:"status"`
+	Passed      int      `json:"passed"`
+	Failed      int      `json:"failed"`
+	FailedTests []string `json:"failed_tests,omitempty"`
+	Coverage    *float64 `json:"coverage,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// testedDirs returns the directories tests were written to in the run,
+// sorted
+func (r *runReport) testedDirs() []string {
+	var dirs []string
+	for _, res := range r.Files {
+		if res.Status == statusGenerated && strings.HasSuffix(res.Output, "_test.go") {
+			dirs = append(dirs, filepath.Dir(res.Output))
+		}
+	}
+	slices.Sort(dirs)
+	return slices.Compact(dirs)
+}
+
+// testPackages runs the tests of every package the run wrote tests to,
+// records the outcomes in the report and prints them as a table unless --json
+// is set. It reports whether all of them passed.
+func testPackages(ctx context.Context, r *runReport) bool {
+	dirs := r.testedDirs()
+	if len(dirs) == 0 {
+		return true
+	}
+	tmp, err := os.MkdirTemp("", "aitestgen-run-*")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+	defer os.RemoveAll(tmp)
+
+	ok := true
+	for i, dir := range dirs {
+		if ctx.Err() != nil {
+			break
+		}
+		res := runPackageTests(ctx, dir, filepath.Join(tmp, fmt.Sprintf("cover%d.out", i)))
+		ok = ok && res.Status == packagePassed
+		r.Packages = append(r.Packages, res)
+	}
+	if !jsonOutput {
+		printPackageResults(r.Packages)
+	}
+	return ok && ctx.Err() == nil
+}
+
+// runPackageTests runs the tests of the package in dir, writing its coverage
+// profile to profile
+func runPackageTests(ctx context.Context, dir, profile string) packageResult {
+	res := packageResult{Dir: dir, Status: packagePassed}
+	out, err := gotool.CoverVerbose(ctx, dir, profile, buildTags...)
+	res.Passed = len(gotool.PassedTests(out))
+	res.FailedTests = gotool.FailedTests(out)
+	res.Failed = len(res.FailedTests)
+	switch {
+	case err != nil && res.Failed == 0:
+		res.Status = packageBuildFailed
+		res.Error = strings.TrimSpace(out)
+		return res
+	case err != nil:
+		res.Status = packageFailed
+	}
+
+	profiles, err := coverage.ParseProfiles(profile)
+	if err != nil {
+		return res
+	}
+	var covered, total int
+	for _, p := range profiles {
+		c, t := p.Statements()
+		covered, total = covered+c, total+t
+	}
+	if total > 0 {
+		pct := 100 * float64(covered) / float64(total)
+		res.Coverage = &pct
+	}
+	return res
+}
+
+// printPackageResults prints the outcome of every package, then the failed
+// tests and build errors
+func printPackageResults(results []packageResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tRESULT\tPASSED\tFAILED\tCOVERAGE")
+	for _, res := range results {
+		cover := "-"
+		if res.Coverage != nil {
+			cover = fmt.Sprintf("%.1f%%", *res.Coverage)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", relPath(res.Dir), res.Status, res.Passed, res.Failed, cover)
+	}
+	w.Flush()
+
+	for _, res := range results {
+		switch res.Status {
+		case packageFailed:
+			fmt.Printf("\n%s: failed %s\n", relPath(res.Dir), strings.Join(res.FailedTests, ", "))
+		case packageBuildFailed:
+			fmt.Printf("\n%s:\n%s\n", relPath(res.Dir), res.Error)
+		}
+	}
+}
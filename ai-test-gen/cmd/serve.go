@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/runner"
+	"github.com/knbr13/aitestgen/pkg/server"
+)
+
+var (
+	serveAddr          string
+	serveKeys          []string
+	serveNoAuth        bool
+	serveMaxConcurrent int
+	serveProvider      providerOptions
+)
+
+// serverKeysEnv holds the keys clients must present, comma separated
+const serverKeysEnv = "AITESTGEN_SERVER_KEYS"
+
+// legacyServerKeysEnv is the variable read before serverKeysEnv, still
+// honoured with a deprecation warning
+const legacyServerKeysEnv = "AIGEN_SERVER_KEYS"
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve test and documentation generation over HTTP",
+	Long: `Run an HTTP API wrapping the generator, so editors and internal tools can
+share one instance and its provider key:
+
+  POST /v1/tests  {"code": "...", "framework": "testify", "instructions": "..."}
+  POST /v1/docs   {"code": "..."}
+
+Both return {"output": "...", "prompt_tokens": N, "response_tokens": N}.
+Clients authenticate with one of the --server-key values (or
+AITESTGEN_SERVER_KEYS, comma separated) as a bearer token or in the X-API-Key
+header. --key is the provider's API key, as in the other commands.
+
+GET /metrics serves request, failure, token and latency counters in the
+Prometheus text format, authenticated like the API (set the scraper's bearer
+token). They are kept in memory only and sent nowhere.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		keys := serveKeys
+		if len(keys) == 0 {
+			keys = serverKeysFromEnv()
+		}
+		if len(keys) == 0 && !serveNoAuth {
+			fmt.Println("Set --server-key or " + serverKeysEnv + ", or pass --no-auth to serve without authentication.")
+			os.Exit(1)
+		}
+
+		provider, err := serveProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		client, err := generator.New(generator.WithCustomProvider(provider))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		handler := server.New(client, server.Options{
+			APIKeys:       keys,
+			MaxConcurrent: serveMaxConcurrent,
+			Provider:      serveProvider.name,
+			Model:         serveProvider.modelName(),
+		})
+		srv := &http.Server{
+			Addr:              serveAddr,
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			<-ctx.Done()
+			// let requests in flight finish, within reason
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				slog.Warn("shutdown cut requests short", "error", err)
+			}
+		}()
+
+		slog.Info("serving", "addr", serveAddr, "model", serveProvider.modelName())
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		// ListenAndServe returns as soon as Shutdown starts
+		<-done
+	},
+}
+
+// serverKeysFromEnv returns the client keys of AITESTGEN_SERVER_KEYS, or of
+// the deprecated AIGEN_SERVER_KEYS when it is unset
+func serverKeysFromEnv() []string {
+	env, ok := os.LookupEnv(serverKeysEnv)
+	if !ok {
+		if env, ok = os.LookupEnv(legacyServerKeysEnv); ok {
+			slog.Warn(legacyServerKeysEnv + " is deprecated, set " + serverKeysEnv + " instead")
+		}
+	}
+	var keys []string
+	for _, k := range strings.Split(env, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveAddr, "addr", ":8080", "Address to listen on")
+	serveCmd.Flags().StringSliceVar(&serveKeys, "server-key", nil, "Key clients must present (repeatable)")
+	serveCmd.Flags().BoolVar(&serveNoAuth, "no-auth", false, "Accept requests without an API key")
+	serveCmd.Flags().IntVarP(&serveMaxConcurrent, "concurrency", "c", runner.DefaultConcurrency, "Maximum generations in flight; further requests wait")
+	serveProvider.addFlags(serveCmd)
+	deprecatedAlias(serveCmd, "server-key", "api-key")
+	deprecatedAlias(serveCmd, "concurrency", "max-concurrent")
+}
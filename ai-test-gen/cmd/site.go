@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/formatter"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/pipeline"
+	"github.com/knbr13/aitestgen/pkg/runner"
+)
+
+var (
+	siteInputFolder string
+	siteOutputDir   string
+	siteTitle       string
+	siteRegenerate  bool
+	siteConcurrency int
+	siteProvider    providerOptions
+)
+
+var docSiteCmd = &cobra.Command{
+	Use:   "site",
+	Short: "Build a static HTML documentation site for a folder",
+	Long: `Render documentation for every Go file in a folder as themed HTML pages with
+an index, ready to publish to GitHub Pages.
+
+Existing Markdown documentation files (as written by doc) are reused; files
+without one are documented with the configured provider. Use --regenerate to
+document every file afresh.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		files, err := inputFiles(ctx, "", siteInputFolder)
+		if err != nil {
+			fmt.Printf("Error walking folder: %v\n", err)
+			os.Exit(1)
+		}
+		if len(files) == 0 {
+			fmt.Println("No Go files found in folder.")
+			os.Exit(1)
+		}
+
+		var provider generator.Provider
+		for _, file := range files {
+			if _, err := os.Stat(docFileFor(file)); err != nil || siteRegenerate {
+				if provider, err = siteProvider.newProvider(); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				break
+			}
+		}
+
+		if err := os.MkdirAll(siteOutputDir, 0755); err != nil {
+			fmt.Printf("Error creating output directory: %v\n", err)
+			os.Exit(1)
+		}
+
+		var (
+			mu    sync.Mutex
+			pages []formatter.Page
+		)
+		p := &pipeline.Pipeline{
+			Walk: func(context.Context) ([]string, error) { return files, nil },
+			Process: func(ctx context.Context, job pipeline.Job) error {
+				page, err := writeSitePage(ctx, provider, job.Input)
+				if err != nil {
+					return err
+				}
+				mu.Lock()
+				pages = append(pages, page)
+				mu.Unlock()
+				slog.Info("page written", "file", job.Input, "output", filepath.Join(siteOutputDir, filepath.FromSlash(page.Path)))
+				return nil
+			},
+			Report: func(job pipeline.Job, err error) {
+				if err != nil && !errors.Is(err, context.Canceled) {
+					slog.Error("documentation failed", "file", job.Input, "err", err)
+				}
+			},
+			Workers: siteConcurrency,
+		}
+		if _, err := p.Run(ctx); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if ctx.Err() != nil {
+			fmt.Printf("Interrupted: %d of %d pages written\n", len(pages), len(files))
+			os.Exit(1)
+		}
+
+		index, err := formatter.RenderIndex(siteTitle, pages)
+		if err != nil {
+			fmt.Printf("Error rendering index: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(filepath.Join(siteOutputDir, "index.html"), []byte(index), 0644); err != nil {
+			fmt.Printf("Error writing index: %v\n", err)
+			os.Exit(1)
+		}
+		// stop GitHub Pages from running the site through Jekyll
+		if err := os.WriteFile(filepath.Join(siteOutputDir, ".nojekyll"), nil, 0644); err != nil {
+			fmt.Printf("Error writing .nojekyll: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Site generated: %s (%d of %d pages)\n", siteOutputDir, len(pages), len(files))
+	},
+}
+
+// writeSitePage renders the documentation for file into the site directory
+func writeSitePage(ctx context.Context, provider generator.Provider, file string) (formatter.Page, error) {
+	rel, err := filepath.Rel(siteInputFolder, file)
+	if err != nil {
+		return formatter.Page{}, err
+	}
+	rel = filepath.ToSlash(rel)
+	page := formatter.Page{Path: strings.TrimSuffix(rel, ".go") + ".html", Title: rel}
+
+	docs, err := os.ReadFile(docFileFor(file))
+	if err != nil || siteRegenerate {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return page, fmt.Errorf("read error: %w", err)
+		}
+		generated, err := generateDocs(ctx, string(content), provider)
+		if err != nil {
+			return page, fmt.Errorf("generation error: %w", err)
+		}
+		docs = []byte(formatter.FormatDocumentation(generated))
+	}
+
+	root := strings.TrimSuffix(strings.Repeat("../", strings.Count(page.Path, "/")), "/")
+	if root == "" {
+		root = "."
+	}
+	html, err := formatter.RenderHTML(page.Title, string(docs), root)
+	if err != nil {
+		return page, fmt.Errorf("render error: %w", err)
+	}
+
+	out := filepath.Join(siteOutputDir, filepath.FromSlash(page.Path))
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return page, fmt.Errorf("write error: %w", err)
+	}
+	if err := os.WriteFile(out, []byte(html), 0644); err != nil {
+		return page, fmt.Errorf("write error: %w", err)
+	}
+	return page, nil
+}
+
+func init() {
+	docCmd.AddCommand(docSiteCmd)
+	docSiteCmd.Flags().StringVarP(&siteInputFolder, "folder", "d", ".", "Input folder (recursively processes all Go files)")
+	docSiteCmd.Flags().StringVarP(&siteOutputDir, "output", "o", "site", "Output directory for the HTML site")
+	docSiteCmd.Flags().StringVar(&siteTitle, "title", "Documentation", "Title of the index page")
+	docSiteCmd.Flags().BoolVar(&siteRegenerate, "regenerate", false, "Generate documentation even for files that already have a Markdown doc file")
+	docSiteCmd.Flags().IntVarP(&siteConcurrency, "concurrency", "c", runner.DefaultConcurrency, "Number of files processed in parallel")
+	addFileFilterFlags(docSiteCmd)
+	siteProvider.addFlags(docSiteCmd)
+}
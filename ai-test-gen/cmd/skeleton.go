@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// skeletonMode is --skeleton: tests are scaffolded from the function
+// signatures without calling a provider
+var skeletonMode bool
+
+// checkSkeletonFlags rejects the flags --skeleton can't honour, as they need
+// a model or tests with cases
+func checkSkeletonFlags() error {
+	if !skeletonMode {
+		return nil
+	}
+	if dryRun || mutateTests || propertyMode || goldenMode || stabilityRuns > 0 || framework == generator.FrameworkGinkgo {
+		return fmt.Errorf("--skeleton writes table tests without calling the API, so it can't be used with --dry-run, --mutate, --property, --golden, --validate-stability or --framework ginkgo")
+	}
+	return nil
+}
+
+// writeSkeleton writes table-driven test skeletons for the target functions
+// of inFile to outFile, or with --append adds them for the functions it has
+// no test for. The tables are left for the augment command, or a person, to
+// fill in.
+func writeSkeleton(ctx context.Context, inFile, outFile string) error {
+	content, err := os.ReadFile(inFile)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+	existing, readErr := os.ReadFile(outFile)
+	if readErr == nil && !forceOverwrite && !appendTests {
+		if skipExisting {
+			return errSkipped
+		}
+		return fmt.Errorf("%s already exists (use --append, --skip-existing or --force)", outFile)
+	}
+
+	file, err := source.Parse(inFile, content)
+	if err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+	targets, _, err := testTargets(file)
+	recordSkipped(inFile, file, targets)
+	if err != nil {
+		return err
+	}
+	generics := genericFuncs(ctx, file, targets)
+
+	var tests string
+	if readErr == nil && appendTests {
+		existingFile, err := source.Parse(outFile, existing)
+		if err != nil {
+			return fmt.Errorf("parse error: %w", err)
+		}
+		testNames := existingFile.TestNames()
+		var missing []source.Func
+		for _, fn := range targets {
+			if !source.HasTest(testNames, fn) {
+				missing = append(missing, fn)
+			}
+		}
+		if len(missing) == 0 {
+			return errSkipped
+		}
+		skeleton, err := file.Skeleton(missing, generics)
+		if err != nil {
+			return errSkipped
+		}
+		if tests, err = source.AppendTests(string(existing), []string{skeleton}); err != nil {
+			return err
+		}
+	} else if tests, err = file.Skeleton(targets, generics); err != nil {
+		return err
+	}
+
+	opts := testOptions()
+	w := testWriter{opts: opts, pkgDir: filepath.Dir(inFile), review: reviewer()}
+	return w.write(ctx, string(content), tests, outFile)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+)
+
+// stdio as --file reads the source from stdin, and as --output prints the
+// tests to stdout
+const stdio = "-"
+
+// pipeOut receives the tests printed with --output -. os.Stdout is pointed at
+// stderr so that nothing else is mixed with them.
+var pipeOut io.Writer = os.Stdout
+
+// usePipe prepares generate for reading stdin or writing stdout: progress
+// messages are silenced and everything but the tests goes to stderr. Options
+// that need a terminal or the rest of the package are rejected.
+func usePipe() error {
+	switch {
+	case jsonOutput:
+		return errors.New("--json can't be used when printing tests to stdout")
+	case showDiff:
+		return errors.New("--diff can't be used when printing tests to stdout")
+	case goldenMode:
+		return errors.New("--golden can't be used when printing tests to stdout")
+	case inputFile == stdio && dryRun:
+		return errors.New("--dry-run can't be used with source from stdin")
+	case inputFile == stdio && (verifyTests || stabilityRuns > 0 || mutateTests || appendTests):
+		return errors.New("--verify, --validate-stability, --mutate and --append need the source file on disk, not stdin")
+	}
+	pipeOut = os.Stdout
+	os.Stdout = os.Stderr
+	quiet = true
+	return setupLogging()
+}
+
+// generateToPipe generates tests for --file, or the source read from stdin
+// when it is "-", and prints them to stdout. Source from stdin is generated
+// for on its own in a temporary directory, so the tests are not compiled and
+// get no context from the rest of its package.
+func generateToPipe(ctx context.Context, report *runReport, provider generator.Provider) error {
+	inFile, outFile := inputFile, ""
+	if inputFile == stdio {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("read error: %w", err)
+		}
+		dir, err := os.MkdirTemp("", "aitestgen-stdin-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		inFile = filepath.Join(dir, "stdin.go")
+		if err := os.WriteFile(inFile, src, 0644); err != nil {
+			return fmt.Errorf("write error: %w", err)
+		}
+		outFile = testFileFor(inFile)
+		maxRepairs = 0
+	} else {
+		// written next to the source so the compile check sees the package
+		outFile = strings.TrimSuffix(testFileFor(inFile), "_test.go") + "_aitestgen_stdout_test.go"
+		defer os.Remove(outFile)
+	}
+
+	err := report.track(ctx, inputFile, stdio, func(ctx context.Context) error {
+		return generateTestFile(ctx, provider, inFile, outFile)
+	})
+	if err != nil {
+		return err
+	}
+	tests, err := os.ReadFile(outFile)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+	_, err = pipeOut.Write(tests)
+	return err
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"log/slog"
+	"path/filepath"
+	"sync"
+
+	"github.com/knbr13/aitestgen/pkg/runner"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// Style examples sent with each prompt: how many, and the size each is
+// trimmed to
+const (
+	styleExampleCount = 2
+	styleExampleBytes = 4 << 10
+)
+
+var (
+	// noStyleExamples is --no-style-examples
+	noStyleExamples bool
+
+	// moduleTests caches the test files of each module, by module directory,
+	// so a folder run walks it once
+	moduleTestsMu sync.Mutex
+	moduleTests   = make(map[string][]string)
+)
+
+// styleExamples returns existing tests of inFile's module, nearest first, for
+// the tests written to outFile to follow, or nil with --no-style-examples or
+// outside a module
+func styleExamples(inFile, outFile string) []source.StyleExample {
+	if noStyleExamples {
+		return nil
+	}
+	dir := filepath.Dir(inFile)
+	mod, err := source.FindModule(dir)
+	if err != nil {
+		return nil
+	}
+
+	moduleTestsMu.Lock()
+	files, ok := moduleTests[mod.Dir]
+	if !ok {
+		if files, err = runner.TestFiles(mod.Dir); err != nil {
+			slog.Debug("style examples unavailable", "module", mod.Dir, "err", err)
+		}
+		moduleTests[mod.Dir] = files
+	}
+	moduleTestsMu.Unlock()
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	absOut, err := filepath.Abs(outFile)
+	if err != nil {
+		return nil
+	}
+	examples := source.StyleExamples(files, abs, absOut, styleExampleCount, styleExampleBytes)
+	// shown relative to the module, which is all the model needs to know
+	for i, e := range examples {
+		if rel, err := filepath.Rel(mod.Dir, e.Path); err == nil {
+			examples[i].Path = filepath.ToSlash(rel)
+		}
+	}
+	return examples
+}
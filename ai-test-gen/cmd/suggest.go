@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/diff"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/gotool"
+	"github.com/knbr13/aitestgen/pkg/pipeline"
+	"github.com/knbr13/aitestgen/pkg/runner"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+var (
+	suggestFile        string
+	suggestFolder      string
+	suggestOutput      string
+	suggestKinds       []string
+	suggestApply       bool
+	suggestConcurrency int
+	suggestProvider    providerOptions
+)
+
+var suggestCmd = &cobra.Command{
+	Use:   "suggest",
+	Short: "Suggest targeted refactorings as patches",
+	Long: `Ask the model for small refactorings of Go files: error wrapping, context
+plumbing and naming (see --kind). Each suggestion is checked to still compile
+with go vet, without touching the files, and those that don't are dropped.
+
+The suggestions are printed to stdout, or written to --output, as unified diffs
+for git apply, each preceded by a comment saying what it does and why. The
+diffs of a file follow each other, so they apply in the order given. With
+--apply the files are rewritten instead.
+
+Renames are limited to what is only used within the file, as other files are
+not changed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		report, ctx := newReport(cmd.Context(), "suggest")
+
+		for _, kind := range suggestKinds {
+			if !slices.Contains(generator.RefactorKinds, kind) {
+				fmt.Printf("Unknown kind %q (use %s).\n", kind, strings.Join(generator.RefactorKinds, ", "))
+				os.Exit(1)
+			}
+		}
+		if suggestFile == "" && suggestFolder == "" {
+			fmt.Println("You must specify either --file or --folder.")
+			os.Exit(1)
+		}
+		if suggestApply && suggestOutput != "" {
+			fmt.Println("--apply rewrites the files, so it can't be used with --output.")
+			os.Exit(1)
+		}
+
+		walk := func(ctx context.Context) ([]string, error) {
+			return inputFiles(ctx, suggestFile, suggestFolder)
+		}
+		if dryRun {
+			files, err := walk(ctx)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			plan := dryRunPlan{model: suggestProvider.modelName()}
+			for _, file := range files {
+				code, err := os.ReadFile(file)
+				output := "patch"
+				if suggestApply {
+					output = file
+				}
+				plan.add(file, output, []string{generator.SuggestRequestPrompt(string(code), suggestKinds)}, err)
+			}
+			plan.summary()
+			return
+		}
+
+		// the patches alone go to stdout, so they can be piped to git apply
+		out := io.Writer(os.Stdout)
+		if !suggestApply && suggestOutput == "" {
+			os.Stdout = os.Stderr
+		}
+
+		provider, err := suggestProvider.newProvider()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		report.setModel(suggestProvider.name, suggestProvider.modelName())
+
+		var (
+			mu      sync.Mutex
+			patches = make(map[string]string)
+		)
+		// applied changes are checked against the files already rewritten,
+		// so the files are done one at a time
+		workers := suggestConcurrency
+		if suggestApply {
+			workers = 1
+		}
+		p := filePipeline(report, workers, nil, func(ctx context.Context, job pipeline.Job) error {
+			patch, err := refactorFile(ctx, provider, job.Input)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			patches[job.Input] = patch
+			mu.Unlock()
+			return nil
+		}, "", "suggestion failed")
+		p.Walk = walk
+		res, err := p.Run(ctx)
+		report.finish()
+		if errors.Is(err, pipeline.ErrNoFiles) {
+			fmt.Println("No Go files found.")
+			return
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var all strings.Builder
+		for _, file := range res.Pending {
+			all.WriteString(patches[file])
+		}
+		switch {
+		case suggestApply:
+		case all.Len() == 0:
+			fmt.Println("No suggestions.")
+		case suggestOutput != "":
+			if err := os.WriteFile(suggestOutput, []byte(all.String()), 0644); err != nil {
+				fmt.Printf("Error writing patch: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Suggestions written: %s (apply with git apply %s)\n", suggestOutput, suggestOutput)
+		default:
+			io.WriteString(out, all.String())
+		}
+		if ctx.Err() != nil {
+			fmt.Println(stopReason(ctx))
+			os.Exit(1)
+		}
+		if report.failed() {
+			os.Exit(1)
+		}
+	},
+}
+
+// refactorFile asks for refactorings of file and returns the patches of those
+// that still compile, each against the file with the ones before it applied.
+// With --apply the file is rewritten with all of them.
+func refactorFile(ctx context.Context, provider generator.Provider, file string) (string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("read error: %w", err)
+	}
+	suggestions, err := generator.Suggest(ctx, string(content), suggestKinds, provider)
+	if err != nil {
+		return "", fmt.Errorf("generation error: %w", err)
+	}
+	if len(suggestions) == 0 {
+		slog.Info("no suggestions", "file", file)
+		return "", nil
+	}
+	// otherwise every suggestion would look broken
+	if out, err := gotool.Vet(ctx, filepath.Dir(file)); err != nil {
+		return "", fmt.Errorf("the package doesn't build before refactoring: %w\n%s", err, out)
+	}
+
+	name := filepath.ToSlash(file)
+	current := string(content)
+	var patches strings.Builder
+	kept := 0
+	for _, s := range suggestions {
+		updated, err := applySuggestion(file, current, s)
+		if err == nil && updated == current {
+			continue
+		}
+		if err == nil {
+			var out string
+			if out, err = gotool.VetSource(ctx, file, updated); err != nil {
+				err = fmt.Errorf("doesn't compile: %s", strings.TrimSpace(out))
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			slog.Warn("suggestion dropped", "file", file, "suggestion", s.Title, "err", err)
+			continue
+		}
+
+		fmt.Fprintf(&patches, "# %s: %s\n", s.Kind, s.Title)
+		for _, line := range strings.Split(strings.TrimSpace(s.Reason), "\n") {
+			if line != "" {
+				patches.WriteString("# " + line + "\n")
+			}
+		}
+		patches.WriteString(diff.Unified("a/"+name, "b/"+name, current, updated))
+		patches.WriteString("\n")
+		current = updated
+		kept++
+	}
+
+	if suggestApply && kept > 0 {
+		if err := os.WriteFile(file, []byte(source.KeepLineEndings(string(content), current)), 0644); err != nil {
+			return "", fmt.Errorf("write error: %w", err)
+		}
+		slog.Info("refactored", "file", file, "applied", kept, "dropped", len(suggestions)-kept)
+	} else {
+		slog.Info("suggestions", "file", file, "kept", kept, "dropped", len(suggestions)-kept)
+	}
+	return patches.String(), nil
+}
+
+// applySuggestion returns src, the content of file, with the declarations of
+// s replaced and the imports it needs added
+func applySuggestion(file, src string, s generator.Suggestion) (string, error) {
+	parsed, err := source.Parse(file, []byte(src))
+	if err != nil {
+		return "", fmt.Errorf("parse error: %w", err)
+	}
+	updated, err := parsed.ReplaceDecls(s.Replaces, s.Code)
+	if err != nil {
+		return "", err
+	}
+	var imports []string
+	for _, path := range s.Imports {
+		if !strings.HasPrefix(path, `"`) {
+			path = strconv.Quote(path)
+		}
+		imports = append(imports, path)
+	}
+	return source.AddImports(updated, imports)
+}
+
+func init() {
+	rootCmd.AddCommand(suggestCmd)
+	suggestCmd.Flags().StringVarP(&suggestFile, "file", "f", "", "Input Go file")
+	suggestCmd.Flags().StringVarP(&suggestFolder, "folder", "d", "", "Input folder (recursively processes all Go files)")
+	suggestCmd.Flags().StringVarP(&suggestOutput, "output", "o", "", "Write the patches to this file instead of stdout")
+	suggestCmd.Flags().StringSliceVar(&suggestKinds, "kind", generator.RefactorKinds, "Kinds of refactoring to suggest ("+strings.Join(generator.RefactorKinds, ", ")+")")
+	suggestCmd.Flags().BoolVar(&suggestApply, "apply", false, "Rewrite the files with the suggestions that compile instead of printing patches")
+	suggestCmd.Flags().IntVarP(&suggestConcurrency, "concurrency", "c", runner.DefaultConcurrency, "Number of files processed in parallel in folder mode, without --apply")
+	suggestCmd.Flags().BoolVar(&changedOnly, "changed", false, "Only process Go files changed since --base (within --folder, default the current directory)")
+	suggestCmd.Flags().StringVar(&baseRef, "base", "HEAD", "Git ref that --changed compares the working tree against")
+	addFileFilterFlags(suggestCmd)
+	suggestCmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the files and prompts that would be processed without calling the API or writing files")
+	suggestCmd.Flags().BoolVar(&showPrompt, "show-prompt", false, "With --dry-run, print the full prompts")
+	suggestProvider.addFlags(suggestCmd)
+}
@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/knbr13/aitestgen/pkg/config"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/promptpack"
+)
+
+var (
+	updateVersion string
+	updateChannel string
+	updatePin     bool
+	updateList    bool
+)
+
+// embeddedPrompts and embeddedModels are the defaults built into the binary,
+// before a prompt pack or the config replaces them
+var (
+	embeddedPrompts = snapshotPrompts()
+	embeddedModels  = snapshotModels()
+)
+
+// promptSources records where each prompt in use comes from: the embedded
+// defaults when missing, else a pack version or the config
+var promptSources = map[string]string{}
+
+// activePack is the version of the prompt pack in use
+var activePack = promptpack.Embedded
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version, the prompt pack and the default models in use",
+	Long: `Print the version of aitestgen and what generation runs with: the prompt pack,
+the SHA-256 of every default prompt and where it comes from (the prompts built
+into the binary, a pinned pack or the config), and the default model of each
+provider. Compare the hashes across machines to check that a team generates
+with the same instructions.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Printf("aitestgen %s (%s %s/%s)\n", buildVersion(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		pack := activePack
+		if pack == promptpack.Embedded {
+			pack += " (sha256 " + short(embeddedDigest()) + ")"
+		}
+		fmt.Printf("Prompt pack: %s\n\n", pack)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROMPT\tSHA256\tSOURCE")
+		for _, name := range generator.PromptNames() {
+			source := promptSources[name]
+			if source == "" {
+				source = promptpack.Embedded
+			}
+			text := *generator.DefaultPrompts[name]
+			if override := configPrompt(name); override != "" {
+				text = override
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, short(promptpack.Sum([]byte(text))), source)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "PROVIDER\tDEFAULT MODEL\t")
+		for _, provider := range generator.Providers() {
+			if model := generator.DefaultModel(provider); model != "" {
+				fmt.Fprintf(w, "%s\t%s\t\n", provider, model)
+			}
+		}
+		w.Flush()
+
+		dir, err := promptpack.DefaultDir()
+		if err != nil {
+			return
+		}
+		if installed, err := promptpack.Installed(dir); err == nil && len(installed) > 0 {
+			fmt.Printf("\nInstalled prompt packs: %s\n", strings.Join(installed, ", "))
+		}
+	},
+}
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Fetch the default prompts and models of a prompt pack from the release channel",
+	Long: `Download a prompt pack, a versioned set of the default prompts and models, from
+the release channel and store it under the user config directory. The pack
+pinned in the config is fetched, or the latest one with --pin or without a pin,
+unless --version names one; its SHA-256 is checked against the channel.
+
+A pack is only used once pinned in the project config, as prompts.pack, so
+every member of a team generates with the same prompts until the pin is
+changed. --pin fetches the pack and pins it in one step; commit the config
+file to share it. A pinned pack that isn't installed makes every command fail
+until self-update fetches it.
+
+The channel is the project's releases unless prompts.channel in the config or
+--channel names another URL or local file, e.g. an internal mirror.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		channel := updateChannel
+		if channel == "" {
+			channel = projectConfig.Prompts.Channel
+		}
+		if channel == "" {
+			channel = promptpack.DefaultChannel
+		}
+		dir, err := promptpack.DefaultDir()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if updateList {
+			installed, err := promptpack.Installed(dir)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			for _, v := range installed {
+				fmt.Println(v)
+			}
+			return
+		}
+
+		client, err := generator.NewHTTPClient(configTransport())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		version := updateVersion
+		if version == "" && !updatePin {
+			// install what the team pinned rather than the latest
+			version = projectConfig.Prompts.Pack
+		}
+		pack, data, err := promptpack.Fetch(cmd.Context(), client, channel, version)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := checkPack(pack); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := promptpack.Save(dir, pack.Version, data); err != nil {
+			fmt.Printf("Error saving prompt pack: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Prompt pack %s installed (%d prompts, %d models)\n", pack.Version, len(pack.Prompts), len(pack.Models))
+
+		if !updatePin {
+			if projectConfig.Prompts.Pack != pack.Version {
+				fmt.Printf("Pin it with: aigen config set prompts.pack %s\n", pack.Version)
+			}
+			return
+		}
+		path := configPath()
+		c := projectConfig
+		c.Prompts.Pack = pack.Version
+		if err := c.Save(path); err != nil {
+			fmt.Printf("Error writing config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Pinned in %s\n", path)
+	},
+}
+
+// buildVersion returns the module version the binary was built from
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "(devel)"
+	}
+	return info.Main.Version
+}
+
+// snapshotPrompts returns the current text of every default prompt
+func snapshotPrompts() map[string]string {
+	prompts := make(map[string]string, len(generator.DefaultPrompts))
+	for name, p := range generator.DefaultPrompts {
+		prompts[name] = *p
+	}
+	return prompts
+}
+
+// snapshotModels returns the current default model of every provider
+func snapshotModels() map[string]string {
+	models := make(map[string]string)
+	for _, provider := range generator.Providers() {
+		models[provider] = generator.DefaultModel(provider)
+	}
+	return models
+}
+
+// embeddedDigest returns the SHA-256 over the embedded prompts, in name order
+func embeddedDigest() string {
+	var b strings.Builder
+	for _, name := range generator.PromptNames() {
+		fmt.Fprintf(&b, "%s\x00%s\x00", name, embeddedPrompts[name])
+	}
+	return promptpack.Sum([]byte(b.String()))
+}
+
+// short abbreviates a hex hash
+func short(sum string) string {
+	return sum[:12]
+}
+
+// configPrompt returns the prompt the config sets in place of the default
+// prompt name, if any
+func configPrompt(name string) string {
+	switch name {
+	case "tests":
+		return projectConfig.Prompts.Tests
+	case "docs":
+		return projectConfig.Prompts.Docs
+	}
+	return ""
+}
+
+// checkPack returns an error for a pack naming prompts or providers this
+// version doesn't know
+func checkPack(p promptpack.Pack) error {
+	for name := range p.Prompts {
+		if _, ok := generator.DefaultPrompts[name]; !ok {
+			return fmt.Errorf("prompt pack %s: unknown prompt %q (known: %s)", p.Version, name, strings.Join(generator.PromptNames(), ", "))
+		}
+	}
+	for provider := range p.Models {
+		if _, ok := embeddedModels[strings.ToLower(provider)]; !ok {
+			return fmt.Errorf("prompt pack %s: unknown provider %q", p.Version, provider)
+		}
+	}
+	return nil
+}
+
+// usePromptPack replaces the default prompts and models with those of the
+// pack pinned in the config, if any
+func usePromptPack() error {
+	version := projectConfig.Prompts.Pack
+	if version == "" {
+		return nil
+	}
+	dir, err := promptpack.DefaultDir()
+	if err != nil {
+		return err
+	}
+	pack, err := promptpack.Load(dir, version)
+	if errors.Is(err, promptpack.ErrNotInstalled) {
+		return fmt.Errorf("the config pins prompt pack %s, which is not installed: run aigen self-update --version %s", version, version)
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkPack(pack); err != nil {
+		return err
+	}
+	for name, text := range pack.Prompts {
+		*generator.DefaultPrompts[name] = text
+		promptSources[name] = "pack " + pack.Version
+	}
+	for provider, model := range pack.Models {
+		if err := generator.SetDefaultModel(provider, model); err != nil {
+			return err
+		}
+	}
+	activePack = pack.Version
+	return nil
+}
+
+// configPath returns the config file in use, or the one config set would
+// create in the current directory
+func configPath() string {
+	if configFile != "" {
+		return configFile
+	}
+	if found, err := config.Find("."); err == nil && found != "" {
+		return found
+	}
+	return config.FileName
+}
+
+// configTransport returns the network settings of the config, with the
+// certificate paths resolved against its directory
+func configTransport() generator.TransportConfig {
+	n := projectConfig.Network
+	resolve := func(file string) string {
+		if file != "" && !filepath.IsAbs(file) {
+			return filepath.Join(projectDir, file)
+		}
+		return file
+	}
+	return generator.TransportConfig{ProxyURL: n.Proxy, CACertFile: resolve(n.CACert), ClientCertFile: resolve(n.ClientCert), ClientKeyFile: resolve(n.ClientKey)}
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(selfUpdateCmd)
+	selfUpdateCmd.Flags().StringVar(&updateVersion, "version", "", "Version of the prompt pack to fetch (default: the pinned one, else the latest)")
+	selfUpdateCmd.Flags().StringVar(&updateChannel, "channel", "", "URL or file of the release channel (default: prompts.channel of the config, else "+promptpack.DefaultChannel+")")
+	selfUpdateCmd.Flags().BoolVar(&updatePin, "pin", false, "Pin the fetched pack in the project config as prompts.pack")
+	selfUpdateCmd.Flags().BoolVar(&updateList, "list", false, "List the installed prompt packs instead of fetching one")
+}
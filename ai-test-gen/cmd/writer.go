@@ -0,0 +1,424 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/formatter"
+	"github.com/knbr13/aitestgen/pkg/generator"
+	"github.com/knbr13/aitestgen/pkg/gotool"
+	"github.com/knbr13/aitestgen/pkg/postprocess"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// testWriter writes generated tests to disk, checking that they compile and
+// optionally that they pass
+type testWriter struct {
+	provider   generator.Provider
+	maxRepairs int
+	verify     bool
+	// stability, when above zero, runs the finished tests that many times
+	// with the race detector, fixing or dropping the flaky ones
+	stability int
+	// golden creates the golden files of the tests once they compile
+	golden bool
+	opts   generator.TestOptions
+	// pkgDir is the directory of the package under test when the tests are
+	// written outside it, as with --out-dir
+	pkgDir string
+	// review, when set, is shown the final content before it replaces
+	// outFile and decides whether it is written
+	review func(path, old, new string) bool
+	// tags are the build tags the tests are checked and run with, besides
+	// those of --tags
+	tags []string
+}
+
+// write saves tests for code to outFile. If the result does not compile, the
+// compiler errors are fed back to the model for up to maxRepairs attempts. When
+// verify, stability or review is set the tests are written to a temporary file
+// first and only promoted to outFile once they pass and are accepted.
+func (w testWriter) write(ctx context.Context, code, tests, outFile string) (err error) {
+	if (w.verify || w.stability > 0) && crossBuild() {
+		return fmt.Errorf("the tests can't be run for --goos %s --goarch %s on this machine, so they can't be verified", buildGOOS, buildGOARCH)
+	}
+	tags := append(slices.Clone(w.tags), buildTags...)
+	target := outFile
+	old, _ := os.ReadFile(outFile)
+	if w.verify || w.stability > 0 || w.review != nil {
+		target = strings.TrimSuffix(outFile, "_test.go") + "_aitestgen_verify_test.go"
+		defer os.Remove(target)
+
+		// move any previous version aside so its tests don't clash with the new ones
+		backup := outFile + ".aitestgen.bak"
+		if err := os.Rename(outFile, backup); err == nil {
+			defer func() {
+				if _, err := os.Stat(outFile); err == nil {
+					os.Remove(backup)
+				} else {
+					os.Rename(backup, outFile)
+				}
+			}()
+		}
+	}
+	// abort puts back the previous test file when the tests were written
+	// straight to it and can't be kept
+	abort := func(err error) error {
+		if target == outFile {
+			restore(outFile, old)
+		}
+		return err
+	}
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+	pkgDir := w.pkgDir
+	if pkgDir == "" {
+		pkgDir = dir
+	}
+	tests = fixPackage(code, tests, pkgDir, w.opts.BlackBox)
+
+	if w.opts.Framework == generator.FrameworkGinkgo {
+		suite, suiteErr := ensureGinkgoSuite(dir, tests)
+		if suiteErr != nil {
+			return fmt.Errorf("ginkgo suite error: %w", suiteErr)
+		}
+		if suite != "" {
+			// the suite is needed to vet and run the specs, but not worth
+			// leaving behind without them
+			defer func() {
+				if err != nil {
+					os.Remove(suite)
+				}
+			}()
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := os.WriteFile(target, []byte(tests), 0644); err != nil {
+			return abort(fmt.Errorf("write error: %w", err))
+		}
+		if err := formatter.RunGoImports(target); err != nil {
+			return abort(fmt.Errorf("goimports error: %w", err))
+		}
+		// checked after goimports, which adds the imports the tests use
+		if disallowed := disallowedImports(target, pkgDir); len(disallowed) > 0 {
+			if attempt >= w.maxRepairs {
+				return abort(fmt.Errorf("generated tests import packages the config doesn't allow: %s", strings.Join(disallowed, ", ")))
+			}
+			fixed, err := generator.FixDisallowedImports(ctx, code, tests, disallowed, w.provider, w.opts)
+			if err != nil {
+				return abort(fmt.Errorf("repair error: %w", err))
+			}
+			tests = fixPackage(code, fixed, pkgDir, w.opts.BlackBox)
+			continue
+		}
+		if w.maxRepairs <= 0 && !w.verify && w.stability <= 0 && !w.golden {
+			return w.promote(ctx, target, outFile, old)
+		}
+
+		if out, err := gotool.Vet(ctx, dir, tags...); err != nil {
+			if compileErrors := gotool.ErrorsFor(out, target); compileErrors != "" {
+				if attempt >= w.maxRepairs {
+					return abort(fmt.Errorf("generated tests still fail to compile after %d repair attempts:\n%s", w.maxRepairs, compileErrors))
+				}
+				tests, err = generator.RepairUnitTests(ctx, code, tests, compileErrors, w.provider, w.opts)
+				if err != nil {
+					return abort(fmt.Errorf("repair error: %w", err))
+				}
+				tests = fixPackage(code, tests, pkgDir, w.opts.BlackBox)
+				continue
+			}
+			// otherwise the package is broken for reasons unrelated to the generated file
+		}
+
+		if w.golden {
+			if w.pkgDir != "" && filepath.Clean(w.pkgDir) != filepath.Clean(dir) {
+				slog.Warn("golden files are only written next to the package under test", "file", outFile)
+			} else if err := writeGoldens(ctx, dir, target, tags); err != nil {
+				return abort(fmt.Errorf("golden file error: %w", err))
+			}
+		}
+
+		if !w.verify {
+			return w.stabilize(ctx, code, target, outFile, pkgDir, old, tags)
+		}
+
+		written, err := os.ReadFile(target)
+		if err != nil {
+			return fmt.Errorf("read error: %w", err)
+		}
+		parsed, err := source.Parse(target, written)
+		if err != nil {
+			return fmt.Errorf("parse error: %w", err)
+		}
+		out, err := gotool.Test(ctx, dir, parsed.TestNames(), tags...)
+		if err == nil {
+			return w.stabilize(ctx, code, target, outFile, pkgDir, old, tags)
+		}
+		if attempt >= w.maxRepairs {
+			return fmt.Errorf("generated tests fail, not writing %s:\n%s", outFile, out)
+		}
+		tests, err = generator.FixFailingTests(ctx, code, tests, out, w.provider, w.opts)
+		if err != nil {
+			return abort(fmt.Errorf("repair error: %w", err))
+		}
+		tests = fixPackage(code, tests, pkgDir, w.opts.BlackBox)
+	}
+}
+
+// stabilize runs the tests in target --validate-stability times with the race
+// detector before promoting them to outFile. Tests that fail are sent back to
+// the model up to maxRepairs times; those still failing are then dropped, and
+// the file isn't written when none are left. The tests are built with tags.
+func (w testWriter) stabilize(ctx context.Context, code, target, outFile, pkgDir string, old []byte, tags []string) error {
+	if w.stability <= 0 {
+		return w.promote(ctx, target, outFile, old)
+	}
+	dir := filepath.Dir(target)
+	for attempt := 0; ; attempt++ {
+		tests, err := os.ReadFile(target)
+		if err != nil {
+			return fmt.Errorf("read error: %w", err)
+		}
+		parsed, err := source.Parse(target, tests)
+		if err != nil {
+			return fmt.Errorf("parse error: %w", err)
+		}
+		names := parsed.TestNames()
+		if len(names) == 0 {
+			return w.promote(ctx, target, outFile, old)
+		}
+		out, err := gotool.Stress(ctx, dir, names, w.stability, tags...)
+		if err == nil {
+			return w.promote(ctx, target, outFile, old)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		flaky := ownTests(gotool.FailedTests(out), names)
+		if len(flaky) == 0 {
+			if compileErrors := gotool.ErrorsFor(out, target); compileErrors != "" && attempt > 0 {
+				return fmt.Errorf("tests fixed for stability fail to compile:\n%s", compileErrors)
+			}
+			return fmt.Errorf("stability check failed:\n%s", out)
+		}
+
+		if attempt >= w.maxRepairs {
+			if len(flaky) == len(names) {
+				return fmt.Errorf("all generated tests are flaky when run %d times with -race, not writing %s:\n%s", w.stability, outFile, out)
+			}
+			kept, err := source.RemoveTests(string(tests), flaky)
+			if err != nil {
+				return fmt.Errorf("dropping flaky tests: %w", err)
+			}
+			if err := os.WriteFile(target, []byte(kept), 0644); err != nil {
+				return fmt.Errorf("write error: %w", err)
+			}
+			if err := formatter.RunGoImports(target); err != nil {
+				return fmt.Errorf("goimports error: %w", err)
+			}
+			slog.Warn("dropped flaky tests", "file", outFile, "tests", flaky)
+			return w.promote(ctx, target, outFile, old)
+		}
+
+		slog.Info("fixing flaky tests", "file", outFile, "tests", flaky)
+		fixed, err := generator.FixFlakyTests(ctx, code, string(tests), out, w.stability, w.provider, w.opts)
+		if err != nil {
+			return fmt.Errorf("repair error: %w", err)
+		}
+		if err := os.WriteFile(target, []byte(fixPackage(code, fixed, pkgDir, w.opts.BlackBox)), 0644); err != nil {
+			return fmt.Errorf("write error: %w", err)
+		}
+		if err := formatter.RunGoImports(target); err != nil {
+			return fmt.Errorf("goimports error: %w", err)
+		}
+	}
+}
+
+// disallowedImports returns the imports of the test file that the imports
+// policy of the config doesn't allow, the packages of the module of pkgDir
+// being allowed
+func disallowedImports(file, pkgDir string) []string {
+	if importPolicy().IsZero() {
+		return nil
+	}
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	return disallowedIn(string(src), pkgDir)
+}
+
+// disallowedIn returns the imports of the tests src that the imports policy
+// of the config doesn't allow, the packages of the module of pkgDir being
+// allowed
+func disallowedIn(src, pkgDir string) []string {
+	policy := importPolicy()
+	if policy.IsZero() {
+		return nil
+	}
+	if mod, err := source.FindModule(pkgDir); err == nil {
+		policy.Module = mod.Path
+	}
+	// a file that doesn't parse is left to the compile check
+	disallowed, _ := policy.Disallowed(src)
+	return disallowed
+}
+
+// restore puts back the content old of file, or removes file if it had none
+func restore(file string, old []byte) {
+	if len(old) == 0 {
+		os.Remove(file)
+		return
+	}
+	os.WriteFile(file, old, 0644)
+}
+
+// ownTests returns the tests of failed that are among names, leaving out
+// failures of other tests of the package
+func ownTests(failed, names []string) []string {
+	var own []string
+	for _, name := range failed {
+		if slices.Contains(names, name) {
+			own = append(own, name)
+		}
+	}
+	return own
+}
+
+// fixPackage sets the package clause of tests to that of code, the source
+// under test in dir, or its _test package when external is set, corrects
+// imports of the module's packages and gives the tests the build constraint
+// of code. Tests that don't parse are returned
+// unchanged for the repair loop to handle.
+func fixPackage(code, tests, dir string, external bool) string {
+	parsed, err := source.Parse("code.go", []byte(code))
+	if err != nil {
+		return tests
+	}
+	pkg := source.TestPackage{Name: parsed.Package(), External: external, Exported: parsed.ExportedNames()}
+	if external {
+		// black-box tests may use anything the package exports
+		pkg.Exported = append(pkg.Exported, packageExports(dir, pkg.Name)...)
+	}
+	mod, err := source.FindModule(dir)
+	if err == nil {
+		pkg.ImportPath, err = mod.ImportPath(dir)
+	}
+	if err == nil {
+		pkg.Module = mod
+	}
+	fixed, err := source.FixTestPackage(tests, pkg)
+	if err != nil {
+		return tests
+	}
+	return source.CopyBuildConstraint(code, fixed)
+}
+
+// packageExports returns the exported names declared by the non-test files of
+// package name in dir
+func packageExports(dir, name string) []string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	var names []string
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		parsed, err := source.Parse(path, src)
+		if err != nil || parsed.Package() != name {
+			continue
+		}
+		names = append(names, parsed.ExportedNames()...)
+	}
+	return names
+}
+
+// promote moves the finished tests from target to outFile, whose previous
+// content was old, after passing them through the post-processors, keeping
+// the line endings of old, warning about those sharing state and passing them
+// to review
+func (w testWriter) promote(ctx context.Context, target, outFile string, old []byte) error {
+	tests, err := os.ReadFile(target)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+	processed, err := postProcess(ctx, outFile, postprocess.KindTest, tests)
+	if err != nil {
+		return err
+	}
+	processed = []byte(source.KeepLineEndings(string(old), string(processed)))
+	if string(processed) != string(tests) {
+		tests = processed
+		if err := os.WriteFile(target, tests, 0644); err != nil {
+			return fmt.Errorf("write error: %w", err)
+		}
+	}
+	warnSharedState(outFile, tests)
+	if target == outFile {
+		return nil
+	}
+	if w.review != nil && !w.review(outFile, string(old), string(tests)) {
+		return errDeclined
+	}
+	if err := os.Rename(target, outFile); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+	return nil
+}
+
+// warnSharedState warns about the tests that change process-wide state
+// without the testing package undoing it, which makes them depend on the
+// order tests run in
+func warnSharedState(outFile string, tests []byte) {
+	parsed, err := source.Parse(outFile, tests)
+	if err != nil {
+		return
+	}
+	for _, s := range parsed.SharedState() {
+		if s.Managed {
+			continue
+		}
+		args := []any{"file", outFile, "test", s.Test, "line", s.Line, "changes", s.What}
+		if s.Hint != "" {
+			args = append(args, "hint", s.Hint)
+		}
+		slog.Warn("test changes process-wide state", args...)
+	}
+}
+
+// ensureGinkgoSuite writes a suite bootstrap to dir unless one of its test
+// files already calls RunSpecs, and returns the file written, if any
+func ensureGinkgoSuite(dir, tests string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err == nil && strings.Contains(string(content), "RunSpecs(") {
+			return "", nil
+		}
+	}
+
+	parsed, err := source.Parse("tests.go", []byte(tests))
+	if err != nil {
+		return "", err
+	}
+	pkg := parsed.Package()
+	suite := filepath.Join(dir, strings.TrimSuffix(pkg, "_test")+"_suite_test.go")
+	if err := os.WriteFile(suite, []byte(generator.GinkgoSuite(pkg)), 0644); err != nil {
+		return "", err
+	}
+	return suite, nil
+}
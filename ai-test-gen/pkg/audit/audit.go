@@ -0,0 +1,227 @@
+// Package audit keeps an append-only log of the files written from model
+// output, one JSON line per file: what it was generated from, with which
+// provider and model, and the hash of what was written. Each line holds the
+// hash of the one before it, so lines removed or edited later break the
+// chain, and is signed with HMAC-SHA256 when a key is given.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileName is the log written to the root of the project
+const FileName = ".aitestgen-audit.jsonl"
+
+// ErrTampered is returned by Load for a log whose chain or signatures don't
+// check out
+var ErrTampered = errors.New("audit log has been tampered with")
+
+// Entry records one generated file. Paths are relative to the directory of
+// the log, with forward slashes.
+type Entry struct {
+	Time         time.Time `json:"time"`
+	Command      string    `json:"command"`
+	Source       string    `json:"source,omitempty"`
+	SourceSHA256 string    `json:"source_sha256,omitempty"`
+	Output       string    `json:"output"`
+	OutputSHA256 string    `json:"output_sha256"`
+	// PromptSHA256 hashes the prompts sent for the file, in order, and
+	// Prompts counts them
+	PromptSHA256 string `json:"prompt_sha256"`
+	Prompts      int    `json:"prompts"`
+	Provider     string `json:"provider,omitempty"`
+	Model        string `json:"model,omitempty"`
+	// Prev is the SHA-256 of the line before, empty for the first one
+	Prev string `json:"prev"`
+	// Signature is the HMAC-SHA256 of the line without it, when the log is
+	// written with a key
+	Signature string `json:"signature,omitempty"`
+}
+
+// Sum returns the hex SHA-256 of data
+func Sum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// SumFile returns the hex SHA-256 of the file at path
+func SumFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return Sum(data), nil
+}
+
+// mu serializes the appends of the files of a run, which are generated
+// concurrently, so each links to the line written before it
+var mu sync.Mutex
+
+// Append adds e to the log in dir, chained to the last line and signed with
+// key unless it is empty
+func Append(dir string, e Entry, key []byte) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	path := filepath.Join(dir, FileName)
+	last, err := lastLine(path)
+	if err != nil {
+		return err
+	}
+	e.Prev, e.Signature = "", ""
+	if last != nil {
+		e.Prev = Sum(last)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if len(key) > 0 {
+		e.Signature = sign(data, key)
+		if data, err = json.Marshal(e); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	// a single write, so that concurrent runs don't interleave their lines
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lastLine returns the last non-empty line of the file at path, or nil when
+// it is missing or empty
+func lastLine(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return data[bytes.LastIndexByte(data, '\n')+1:], nil
+}
+
+// sign returns the hex HMAC-SHA256 of data with key
+func sign(data, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Load reads the entries of the log at path, oldest first, checking that
+// every line links to the one before it. With a key, every entry must also
+// carry a valid signature; without one, signatures are not checked. A missing
+// log has no entries.
+func Load(path string, key []byte) ([]Entry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		entries []Entry
+		prev    []byte
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		raw := scanner.Bytes()
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		want := ""
+		if prev != nil {
+			want = Sum(prev)
+		}
+		if e.Prev != want {
+			return nil, fmt.Errorf("%w: %s:%d doesn't follow the line before it", ErrTampered, path, line)
+		}
+		if len(key) > 0 {
+			if err := verify(e, key); err != nil {
+				return nil, fmt.Errorf("%w: %s:%d: %v", ErrTampered, path, line, err)
+			}
+		}
+		entries = append(entries, e)
+		prev = append(prev[:0], raw...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// verify checks the signature of e with key
+func verify(e Entry, key []byte) error {
+	if e.Signature == "" {
+		return errors.New("entry is not signed")
+	}
+	signature := e.Signature
+	e.Signature = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(signature), []byte(sign(data, key))) {
+		return errors.New("signature doesn't match")
+	}
+	return nil
+}
+
+// Signed reports whether any of entries carries a signature
+func Signed(entries []Entry) bool {
+	for _, e := range entries {
+		if e.Signature != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the latest entry for output, and the latest one whose output
+// hash is sum, reporting whether each was found
+func Find(entries []Entry, output, sum string) (latest, match Entry, foundLatest, foundMatch bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.Output != output {
+			continue
+		}
+		if !foundLatest {
+			latest, foundLatest = e, true
+		}
+		if e.OutputSHA256 == sum {
+			return latest, e, foundLatest, true
+		}
+	}
+	return latest, match, foundLatest, false
+}
@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeLog appends an entry for each output to a log in a new directory and
+// returns its path
+func writeLog(t *testing.T, key []byte, outputs ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, out := range outputs {
+		e := Entry{
+			Time:         time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Command:      "generate",
+			Output:       out,
+			OutputSHA256: Sum([]byte(out)),
+			PromptSHA256: Sum([]byte("prompt " + out)),
+			Prompts:      1,
+		}
+		if err := Append(dir, e, key); err != nil {
+			t.Fatalf("Append(%s): %v", out, err)
+		}
+	}
+	return filepath.Join(dir, FileName)
+}
+
+// editLog rewrites the log at path with edit applied to its lines
+func editLog(t *testing.T, path string, edit func(lines [][]byte) [][]byte) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := edit(bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n")))
+	if err := os.WriteFile(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppendLoad(t *testing.T) {
+	for _, key := range [][]byte{nil, []byte("secret")} {
+		path := writeLog(t, key, "a_test.go", "b_test.go", "c_test.go")
+		entries, err := Load(path, key)
+		if err != nil {
+			t.Fatalf("Load with key %q: %v", key, err)
+		}
+		if len(entries) != 3 {
+			t.Fatalf("Load with key %q: got %d entries, want 3", key, len(entries))
+		}
+		if entries[0].Prev != "" || entries[1].Prev == "" {
+			t.Errorf("Prev of the first two entries = %q, %q; want empty, then set", entries[0].Prev, entries[1].Prev)
+		}
+		if got := Signed(entries); got != (key != nil) {
+			t.Errorf("Signed with key %q = %v", key, got)
+		}
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	entries, err := Load(filepath.Join(t.TempDir(), FileName), nil)
+	if err != nil || entries != nil {
+		t.Errorf("Load of a missing log = %v, %v; want no entries", entries, err)
+	}
+}
+
+func TestLoadTampered(t *testing.T) {
+	key := []byte("secret")
+	tests := []struct {
+		name string
+		key  []byte
+		// loadKey is the key Load checks with
+		loadKey []byte
+		edit    func(lines [][]byte) [][]byte
+	}{
+		{
+			name: "edited line",
+			edit: func(lines [][]byte) [][]byte {
+				lines[0] = bytes.Replace(lines[0], []byte("a_test.go"), []byte("x_test.go"), 1)
+				return lines
+			},
+		},
+		{
+			name: "deleted line",
+			edit: func(lines [][]byte) [][]byte {
+				return append(lines[:1], lines[2:]...)
+			},
+		},
+		{
+			name: "reordered lines",
+			edit: func(lines [][]byte) [][]byte {
+				lines[0], lines[1] = lines[1], lines[0]
+				return lines
+			},
+		},
+		{
+			name:    "edited last signed line",
+			key:     key,
+			loadKey: key,
+			edit: func(lines [][]byte) [][]byte {
+				last := len(lines) - 1
+				lines[last] = bytes.Replace(lines[last], []byte("c_test.go"), []byte("x_test.go"), 1)
+				return lines
+			},
+		},
+		{
+			name:    "unsigned line in a signed log",
+			key:     nil,
+			loadKey: key,
+			edit:    func(lines [][]byte) [][]byte { return lines },
+		},
+		{
+			name:    "wrong key",
+			key:     key,
+			loadKey: []byte("other"),
+			edit:    func(lines [][]byte) [][]byte { return lines },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeLog(t, tt.key, "a_test.go", "b_test.go", "c_test.go")
+			editLog(t, path, tt.edit)
+			if _, err := Load(path, tt.loadKey); !errors.Is(err, ErrTampered) {
+				t.Errorf("Load = %v, want ErrTampered", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+// Package auth stores provider API keys under named profiles, in the OS
+// keychain or, where none is available, in a file encrypted with a passphrase.
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/zalando/go-keyring"
+)
+
+// DefaultProfile is the profile used when none is named
+const DefaultProfile = "default"
+
+// service is the name keys are stored under in the OS keychain
+const service = "aitestgen"
+
+// Where a profile's key is kept
+const (
+	StoreKeychain = "keychain"
+	StoreFile     = "file"
+)
+
+// ErrNoProfile is returned for profiles that were never saved
+var ErrNoProfile = errors.New("no such profile")
+
+// Profile describes a saved key. The key itself is never part of it.
+type Profile struct {
+	Name     string    `json:"name"`
+	Provider string    `json:"provider"`
+	Store    string    `json:"store"`
+	Updated  time.Time `json:"updated"`
+}
+
+// Store holds the profiles saved in a directory. The directory keeps the
+// list of profiles and, for profiles not in the keychain, the encrypted keys.
+type Store struct {
+	dir string
+	// passphrase returns the passphrase of the encrypted key file
+	passphrase func() ([]byte, error)
+	// secret is the passphrase once asked for
+	secret []byte
+}
+
+// DefaultDir returns the profile location under the user config directory,
+// e.g. ~/.config/aitestgen on Linux
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "aitestgen"), nil
+}
+
+// Open returns the store in dir. passphrase is only called when the
+// encrypted key file is read or written.
+func Open(dir string, passphrase func() ([]byte, error)) *Store {
+	return &Store{dir: dir, passphrase: passphrase}
+}
+
+func (s *Store) indexPath() string {
+	return filepath.Join(s.dir, "profiles.json")
+}
+
+func (s *Store) keyFilePath() string {
+	return filepath.Join(s.dir, "keys.enc")
+}
+
+// List returns the saved profiles sorted by name
+func (s *Store) List() ([]Profile, error) {
+	index, err := s.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]Profile, 0, len(index))
+	for _, p := range index {
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
+
+// Save stores key for provider under profile. The key goes to the OS keychain
+// unless useFile is set or no keychain is available, in which case it goes to
+// the encrypted key file.
+func (s *Store) Save(profile, provider, key string, useFile bool) (Profile, error) {
+	index, err := s.readIndex()
+	if err != nil {
+		return Profile{}, err
+	}
+	p := Profile{Name: profile, Provider: provider, Store: StoreFile, Updated: time.Now().UTC()}
+	if !useFile {
+		if err := keyring.Set(service, profile, key); err == nil {
+			p.Store = StoreKeychain
+		}
+	}
+	if p.Store == StoreFile {
+		if err := s.updateKeyFile(func(keys map[string]string) { keys[profile] = key }); err != nil {
+			return Profile{}, err
+		}
+	}
+	// a profile moved between stores leaves nothing behind in the other one
+	if old, ok := index[profile]; ok && old.Store != p.Store {
+		s.deleteKey(old)
+	}
+	index[profile] = p
+	return p, s.writeIndex(index)
+}
+
+// Key returns the profile and its key
+func (s *Store) Key(profile string) (Profile, string, error) {
+	index, err := s.readIndex()
+	if err != nil {
+		return Profile{}, "", err
+	}
+	p, ok := index[profile]
+	if !ok {
+		return Profile{}, "", fmt.Errorf("%w %q", ErrNoProfile, profile)
+	}
+	if p.Store == StoreKeychain {
+		key, err := keyring.Get(service, profile)
+		if err != nil {
+			return Profile{}, "", fmt.Errorf("reading profile %q from the keychain: %w", profile, err)
+		}
+		return p, key, nil
+	}
+	keys, err := s.readKeyFile()
+	if err != nil {
+		return Profile{}, "", err
+	}
+	key, ok := keys[profile]
+	if !ok {
+		return Profile{}, "", fmt.Errorf("%w %q in %s", ErrNoProfile, profile, s.keyFilePath())
+	}
+	return p, key, nil
+}
+
+// Delete removes profile and its key
+func (s *Store) Delete(profile string) error {
+	index, err := s.readIndex()
+	if err != nil {
+		return err
+	}
+	p, ok := index[profile]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrNoProfile, profile)
+	}
+	if err := s.deleteKey(p); err != nil {
+		return err
+	}
+	delete(index, profile)
+	return s.writeIndex(index)
+}
+
+func (s *Store) deleteKey(p Profile) error {
+	if p.Store == StoreKeychain {
+		if err := keyring.Delete(service, p.Name); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			return err
+		}
+		return nil
+	}
+	return s.updateKeyFile(func(keys map[string]string) { delete(keys, p.Name) })
+}
+
+func (s *Store) readIndex() (map[string]Profile, error) {
+	index := make(map[string]Profile)
+	data, err := os.ReadFile(s.indexPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.indexPath(), err)
+	}
+	return index, nil
+}
+
+func (s *Store) writeIndex(index map[string]Profile) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writePrivate(s.indexPath(), data)
+}
+
+// writePrivate writes data readable by the current user only, through a
+// temporary file so a crash never leaves a truncated file
+func writePrivate(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
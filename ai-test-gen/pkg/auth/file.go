@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// pbkdf2Iterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256
+const pbkdf2Iterations = 600_000
+
+// ErrWrongPassphrase is returned when the key file can't be decrypted
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted key file")
+
+// keyFile is the saved form of the encrypted keys: the AES-256-GCM sealed
+// JSON of a profile to key map, with the key derived from the passphrase
+type keyFile struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+func (s *Store) readKeyFile() (map[string]string, error) {
+	keys := make(map[string]string)
+	data, err := os.ReadFile(s.keyFilePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.keyFilePath(), err)
+	}
+	gcm, err := s.cipher(kf.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, kf.Nonce, kf.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if err := json.Unmarshal(plain, &keys); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.keyFilePath(), err)
+	}
+	return keys, nil
+}
+
+// updateKeyFile applies fn to the saved keys and encrypts them again, with a
+// fresh salt and nonce
+func (s *Store) updateKeyFile(fn func(keys map[string]string)) error {
+	keys, err := s.readKeyFile()
+	if err != nil {
+		return err
+	}
+	fn(keys)
+	plain, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	kf := keyFile{Salt: make([]byte, 16)}
+	if _, err := rand.Read(kf.Salt); err != nil {
+		return err
+	}
+	gcm, err := s.cipher(kf.Salt)
+	if err != nil {
+		return err
+	}
+	kf.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(kf.Nonce); err != nil {
+		return err
+	}
+	kf.Data = gcm.Seal(nil, kf.Nonce, plain, nil)
+
+	data, err := json.Marshal(kf)
+	if err != nil {
+		return err
+	}
+	return writePrivate(s.keyFilePath(), data)
+}
+
+// cipher returns the AES-GCM cipher keyed by the passphrase and salt
+func (s *Store) cipher(salt []byte) (cipher.AEAD, error) {
+	if s.secret == nil {
+		passphrase, err := s.passphrase()
+		if err != nil {
+			return nil, err
+		}
+		s.secret = passphrase
+	}
+	key, err := pbkdf2.Key(sha256.New, string(s.secret), salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
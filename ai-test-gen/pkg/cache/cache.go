@@ -0,0 +1,139 @@
+// Package cache stores model responses on disk so unchanged inputs are not
+// sent to the provider again.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+)
+
+// Cache is a directory of responses keyed by a hash of the provider, endpoint,
+// model and prompt. Since prompts embed the source being processed, a changed
+// file misses the cache while an unchanged one hits it.
+type Cache struct {
+	dir string
+}
+
+// DefaultDir returns the cache location under the user cache directory,
+// e.g. ~/.cache/aitestgen on Linux
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "aitestgen"), nil
+}
+
+// Open returns the cache stored in dir
+func Open(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Dir returns the cache directory
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Key returns the cache key of a request. endpoint tells apart servers of the
+// same provider exposing a model under the same name, e.g. the base URL and
+// Azure deployment; it is empty for the provider's default.
+func Key(provider, endpoint, model, prompt string) string {
+	h := sha256.New()
+	for _, s := range []string{provider, endpoint, model, prompt} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// Get returns the cached response for key
+func (c *Cache) Get(key string) (string, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Put stores a response under key
+func (c *Cache) Put(key, response string) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// write to a temporary file first so concurrent readers never see a partial entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(response); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Clear removes every cached response, returning how many there were
+func (c *Cache) Clear() (int, error) {
+	n := 0
+	err := filepath.WalkDir(c.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			n++
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, os.RemoveAll(c.dir)
+}
+
+// Wrap returns a provider that answers from the cache when it can and stores
+// the responses of p otherwise. endpoint is as for Key.
+func (c *Cache) Wrap(p generator.Provider, provider, endpoint, model string) generator.Provider {
+	return &cachedProvider{Provider: p, cache: c, provider: provider, endpoint: endpoint, model: model}
+}
+
+type cachedProvider struct {
+	generator.Provider
+	cache    *Cache
+	provider string
+	endpoint string
+	model    string
+}
+
+func (p *cachedProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	key := Key(p.provider, p.endpoint, p.model, prompt)
+	if resp, ok := p.cache.Get(key); ok {
+		return resp, nil
+	}
+	resp, err := p.Provider.Generate(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	// a failed write only costs a cache miss next time
+	p.cache.Put(key, resp)
+	return resp, nil
+}
@@ -0,0 +1,246 @@
+// Package changelog groups the commits of a release for the model writing its
+// notes and merges the notes into a Keep a Changelog file.
+package changelog
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/vcs"
+)
+
+// Header starts a new changelog file
+const Header = `# Changelog
+
+All notable changes to this project will be documented in this file.
+
+The format is based on [Keep a Changelog](https://keepachangelog.com/en/1.1.0/),
+and this project adheres to [Semantic Versioning](https://semver.org/spec/v2.0.0.html).
+`
+
+// Categories are the Keep a Changelog sections, in the order they appear
+var Categories = []string{"Added", "Changed", "Deprecated", "Removed", "Fixed", "Security"}
+
+// maintenance is the category of commits that don't belong in release notes,
+// such as CI and test changes
+const maintenance = "Maintenance"
+
+// Cluster is a set of related commits: of the same category, touching the
+// same area of the project
+type Cluster struct {
+	// Category is the Keep a Changelog section the commits suggest, or
+	// "Maintenance"
+	Category string
+	// Area is the conventional commit scope or the directory most of the
+	// commits touched, "" when there is none
+	Area    string
+	Commits []vcs.Commit
+}
+
+// conventional matches a conventional commit subject: type(scope)!: summary
+var conventional = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.*)$`)
+
+// typeCategories maps conventional commit types to categories
+var typeCategories = map[string]string{
+	"feat":       "Added",
+	"feature":    "Added",
+	"fix":        "Fixed",
+	"bugfix":     "Fixed",
+	"perf":       "Changed",
+	"refactor":   "Changed",
+	"docs":       "Changed",
+	"revert":     "Removed",
+	"security":   "Security",
+	"deprecate":  "Deprecated",
+	"chore":      maintenance,
+	"ci":         maintenance,
+	"build":      maintenance,
+	"test":       maintenance,
+	"tests":      maintenance,
+	"style":      maintenance,
+	"release":    maintenance,
+	"dependabot": maintenance,
+}
+
+// keywordCategories guesses the category of other subjects from their
+// wording, first match wins
+var keywordCategories = []struct {
+	re       *regexp.Regexp
+	category string
+}{
+	{regexp.MustCompile(`(?i)\b(security|vulnerab\w*|cve-\d+|xss|injection)\b`), "Security"},
+	{regexp.MustCompile(`(?i)\bdeprecat\w*`), "Deprecated"},
+	{regexp.MustCompile(`(?i)^(remove|drop|delete)\w*\b`), "Removed"},
+	{regexp.MustCompile(`(?i)^(fix|correct|resolve|handle)\w*\b|\bbug\b`), "Fixed"},
+	{regexp.MustCompile(`(?i)^(add|introduce|support|implement|new)\w*\b`), "Added"},
+	{regexp.MustCompile(`(?i)^(bump|update deps|merge)\b`), maintenance},
+}
+
+// Group clusters commits by category and area. Clusters are ordered by
+// category, maintenance last, then by size; commits keep their order.
+func Group(commits []vcs.Commit) []Cluster {
+	index := make(map[string]int)
+	var clusters []Cluster
+	for _, c := range commits {
+		category, area := classify(c)
+		key := category + "\x00" + area
+		i, ok := index[key]
+		if !ok {
+			i = len(clusters)
+			index[key] = i
+			clusters = append(clusters, Cluster{Category: category, Area: area})
+		}
+		clusters[i].Commits = append(clusters[i].Commits, c)
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		ri, rj := rank(clusters[i].Category), rank(clusters[j].Category)
+		if ri != rj {
+			return ri < rj
+		}
+		return len(clusters[i].Commits) > len(clusters[j].Commits)
+	})
+	return clusters
+}
+
+// classify returns the category and area of a commit
+func classify(c vcs.Commit) (category, area string) {
+	subject := c.Subject
+	if m := conventional.FindStringSubmatch(subject); m != nil {
+		if cat, ok := typeCategories[strings.ToLower(m[1])]; ok {
+			category, area, subject = cat, m[2], m[4]
+			if m[3] == "!" && category == maintenance {
+				category = "Changed"
+			}
+		}
+	}
+	if category == "" {
+		category = "Changed"
+		for _, k := range keywordCategories {
+			if k.re.MatchString(subject) {
+				category = k.category
+				break
+			}
+		}
+	}
+	if area == "" {
+		area = commonArea(c.Files)
+	}
+	return category, area
+}
+
+// commonArea returns the directory, at most two levels deep, most of files
+// are in
+func commonArea(files []string) string {
+	counts := make(map[string]int)
+	best := ""
+	for _, f := range files {
+		dir := path.Dir(f)
+		if parts := strings.Split(dir, "/"); len(parts) > 2 {
+			dir = strings.Join(parts[:2], "/")
+		}
+		counts[dir]++
+		if counts[dir] > counts[best] || counts[dir] == counts[best] && dir < best {
+			best = dir
+		}
+	}
+	if best == "." {
+		return ""
+	}
+	return best
+}
+
+// rank orders categories as Categories does, unknown ones and maintenance last
+func rank(category string) int {
+	for i, c := range Categories {
+		if c == category {
+			return i
+		}
+	}
+	return len(Categories)
+}
+
+// Describe renders clusters for the prompt: a heading per cluster and a line
+// per commit, with the commit body indented below it
+func Describe(clusters []Cluster) string {
+	var sb strings.Builder
+	for i, cl := range clusters {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		title := cl.Category
+		if cl.Area != "" {
+			title += " (" + cl.Area + ")"
+		}
+		fmt.Fprintf(&sb, "## %s\n", title)
+		for _, c := range cl.Commits {
+			hash := c.Hash
+			if len(hash) > 7 {
+				hash = hash[:7]
+			}
+			fmt.Fprintf(&sb, "- %s %s\n", hash, c.Subject)
+			if c.Body != "" {
+				fmt.Fprintf(&sb, "  %s\n", strings.ReplaceAll(c.Body, "\n", "\n  "))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// Insert returns the changelog existing with section added above its latest
+// release, below the header and any Unreleased section. An empty existing
+// changelog starts with Header. A section for a version already present
+// replaces it.
+func Insert(existing, section string) string {
+	section = strings.TrimSpace(section) + "\n"
+	if strings.TrimSpace(existing) == "" {
+		return Header + "\n" + section
+	}
+
+	lines := strings.SplitAfter(existing, "\n")
+	heading := strings.TrimSpace(strings.SplitN(section, "\n", 2)[0])
+	// the section goes in place of lines[start:end]
+	start, end := -1, -1
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "## ") {
+			continue
+		}
+		if start >= 0 {
+			end = i
+			break
+		}
+		line = strings.TrimSpace(line)
+		if sameRelease(line, heading) {
+			start = i
+			continue
+		}
+		if !sameRelease(line, "## [Unreleased]") {
+			start, end = i, i
+			break
+		}
+	}
+	if start < 0 {
+		// no releases yet
+		return strings.TrimRight(existing, "\n") + "\n\n" + section
+	}
+	if end < 0 {
+		end = len(lines)
+	}
+	after := strings.Join(lines[end:], "")
+	if after != "" {
+		section += "\n"
+	}
+	return strings.Join(lines[:start], "") + section + after
+}
+
+// releaseName matches the version in a release heading: "## [1.2.0] - date"
+// or "## 1.2.0"
+var releaseName = regexp.MustCompile(`^##\s+\[?([^\]\s]+)\]?`)
+
+// sameRelease reports whether two release headings name the same version
+func sameRelease(a, b string) bool {
+	ma, mb := releaseName.FindStringSubmatch(a), releaseName.FindStringSubmatch(b)
+	return ma != nil && mb != nil && strings.EqualFold(ma[1], mb[1])
+}
@@ -0,0 +1,22 @@
+// Package codefence finds the fenced code blocks of Markdown text, for both
+// the parsing of model responses and the formatting of documentation.
+package codefence
+
+import "strings"
+
+// Len returns the length of the ``` or ~~~ fence line starts with, or 0
+func Len(line string) int {
+	for _, c := range []string{"`", "~"} {
+		n := len(line) - len(strings.TrimLeft(line, c))
+		if n >= 3 {
+			return n
+		}
+	}
+	return 0
+}
+
+// Closes reports whether line, trimmed of spaces, closes the block opened by
+// fence: a run of the same character at least as long
+func Closes(line, fence string) bool {
+	return strings.HasPrefix(line, fence) && strings.Trim(line, fence[:1]) == ""
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the project-level configuration file
+const FileName = ".aitestgen.yaml"
+
+// Config is the project-level configuration shared by all commands. Command
+// line flags take precedence over values set here.
+type Config struct {
+	Provider    string      `yaml:"provider,omitempty"`
+	Model       string      `yaml:"model,omitempty"`
+	BaseURL     string      `yaml:"base_url,omitempty"`
+	APIKeyEnv   string      `yaml:"api_key_env,omitempty"`
+	Framework   string      `yaml:"framework,omitempty"`
+	Concurrency int         `yaml:"concurrency,omitempty"`
+	Exclude     []string    `yaml:"exclude,omitempty"`
+	Prompts     Prompts     `yaml:"prompts,omitempty"`
+	Output      Output      `yaml:"output,omitempty"`
+	Conventions Conventions `yaml:"conventions,omitempty"`
+	Network     Network     `yaml:"network,omitempty"`
+	// Fallback lists the providers to continue with when requests to the
+	// configured one keep failing, as provider/model, provider or model
+	Fallback []string `yaml:"fallback,omitempty"`
+	// PostProcess lists commands that rewrite generated tests and docs before
+	// they are written, in order. Relative paths are resolved against the
+	// directory of the config file.
+	PostProcess []string `yaml:"post_process,omitempty"`
+	// Policy is a file of rules appended to every prompt, such as "always use
+	// our errors package", relative to the directory of the config file. Only
+	// --no-policy leaves it out, so it is not one of the keys Set accepts.
+	Policy string `yaml:"policy,omitempty"`
+	// Imports restricts the packages generated tests may import
+	Imports Imports `yaml:"imports,omitempty"`
+	// Audit logs every generated file to the audit log next to the config
+	// file, for the verify-provenance command
+	Audit bool `yaml:"audit,omitempty"`
+}
+
+// Prompts overrides the instructions sent to the model
+type Prompts struct {
+	Tests string `yaml:"tests,omitempty"`
+	Docs  string `yaml:"docs,omitempty"`
+	// Pack pins the version of the prompt pack, fetched with self-update,
+	// whose prompts and models replace the built-in defaults. Tests and Docs
+	// still take precedence over it.
+	Pack string `yaml:"pack,omitempty"`
+	// Channel is the URL of the release channel self-update fetches packs
+	// from, instead of the project's releases
+	Channel string `yaml:"channel,omitempty"`
+}
+
+// Output controls how generated files are named
+type Output struct {
+	TestSuffix string `yaml:"test_suffix,omitempty"`
+	// TestName, when set, replaces TestSuffix with a template for the test
+	// file name, e.g. "{{.Base}}_ai_test.go", where Base is the source file
+	// name without .go
+	TestName  string `yaml:"test_name,omitempty"`
+	DocSuffix string `yaml:"doc_suffix,omitempty"`
+}
+
+// Conventions are the rules enforced on generated tests
+type Conventions struct {
+	// SubtestNames is the style of subtest names: snake, sentence or camel
+	SubtestNames string `yaml:"subtest_names,omitempty"`
+	// Parallel adds t.Parallel() to the tests when true and removes it when
+	// false; unset leaves the tests as generated
+	Parallel *bool `yaml:"parallel,omitempty"`
+	// Assertions is the testify package assertions use: assert or require
+	Assertions string `yaml:"assertions,omitempty"`
+}
+
+// Imports lists import path patterns: paths, paths ending in /... for the
+// packages below them too, or std for the standard library
+type Imports struct {
+	// Allow, when set, lists the only packages tests may import besides
+	// those of their own module
+	Allow []string `yaml:"allow,omitempty"`
+	// Deny lists packages tests may not import, even when allowed
+	Deny []string `yaml:"deny,omitempty"`
+}
+
+// Network configures how API requests leave the machine. Relative paths are
+// resolved against the directory of the config file.
+type Network struct {
+	Proxy      string `yaml:"proxy,omitempty"`
+	CACert     string `yaml:"ca_cert,omitempty"`
+	ClientCert string `yaml:"client_cert,omitempty"`
+	ClientKey  string `yaml:"client_key,omitempty"`
+}
+
+// Default returns the configuration written by config init
+func Default() Config {
+	return Config{
+		Provider:    "gemini",
+		APIKeyEnv:   "AITESTGEN_API_KEY",
+		Concurrency: 4,
+		Exclude:     []string{"vendor/**", "testdata/**"},
+		Output: Output{
+			TestSuffix: "_test.go",
+			DocSuffix:  "_doc.md",
+		},
+	}
+}
+
+// Find looks for the configuration file in dir and its parents, returning ""
+// if there is none
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Load reads the configuration file at path
+func Load(path string) (Config, error) {
+	var c Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if _, err := testName(c.Output.TestName); err != nil {
+		return c, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Save writes the configuration to path
+func (c Config) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// TestSuffix returns the suffix used for generated test files
+func (c Config) TestSuffix() string {
+	if c.Output.TestSuffix == "" {
+		return "_test.go"
+	}
+	return c.Output.TestSuffix
+}
+
+// TestFile returns the name of the test file generated for the source file
+// path, in the same directory
+func (c Config) TestFile(path string) string {
+	tmpl, err := testName(c.Output.TestName)
+	if err != nil || tmpl == nil {
+		return strings.TrimSuffix(path, ".go") + c.TestSuffix()
+	}
+	var sb strings.Builder
+	tmpl.Execute(&sb, testNameData{Base: strings.TrimSuffix(filepath.Base(path), ".go")})
+	return filepath.Join(filepath.Dir(path), sb.String())
+}
+
+// testNameData is what output.test_name templates are executed with
+type testNameData struct {
+	// Base is the source file name without .go
+	Base string
+}
+
+// testName parses an output.test_name template, checking that the names it
+// gives are test files in the same directory. It returns nil for "".
+func testName(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("test_name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("output.test_name: %w", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, testNameData{Base: "file"}); err != nil {
+		return nil, fmt.Errorf("output.test_name: %w", err)
+	}
+	if name := sb.String(); !strings.HasSuffix(name, "_test.go") || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("output.test_name must give a file name ending in _test.go, got %q", name)
+	}
+	return tmpl, nil
+}
+
+// DocSuffix returns the suffix used for generated documentation files
+func (c Config) DocSuffix() string {
+	if c.Output.DocSuffix == "" {
+		return "_doc.md"
+	}
+	return c.Output.DocSuffix
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownKey is returned by Set for keys that are not part of the configuration
+var ErrUnknownKey = errors.New("unknown config key")
+
+// Keys lists the names accepted by Set
+var Keys = []string{
+	"provider", "model", "base_url", "api_key_env", "framework", "concurrency", "exclude",
+	"prompts.tests", "prompts.docs", "prompts.pack", "prompts.channel", "output.test_suffix", "output.test_name", "output.doc_suffix",
+	"conventions.subtest_names", "conventions.parallel", "conventions.assertions",
+	"network.proxy", "network.ca_cert", "network.client_cert", "network.client_key", "post_process",
+	"imports.allow", "imports.deny", "audit",
+}
+
+// Set updates a single value by its dotted YAML key. Lists are comma separated.
+func (c *Config) Set(key, value string) error {
+	switch key {
+	case "provider":
+		c.Provider = value
+	case "model":
+		c.Model = value
+	case "base_url":
+		c.BaseURL = value
+	case "api_key_env":
+		c.APIKeyEnv = value
+	case "framework":
+		c.Framework = value
+	case "concurrency":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("concurrency must be a positive integer")
+		}
+		c.Concurrency = n
+	case "exclude":
+		c.Exclude = splitList(value)
+	case "prompts.tests":
+		c.Prompts.Tests = value
+	case "prompts.docs":
+		c.Prompts.Docs = value
+	case "prompts.pack":
+		c.Prompts.Pack = value
+	case "prompts.channel":
+		c.Prompts.Channel = value
+	case "output.test_suffix":
+		if !strings.HasSuffix(value, "_test.go") {
+			return fmt.Errorf("test suffix must end in _test.go")
+		}
+		c.Output.TestSuffix = value
+	case "output.test_name":
+		if _, err := testName(value); err != nil {
+			return err
+		}
+		c.Output.TestName = value
+	case "output.doc_suffix":
+		c.Output.DocSuffix = value
+	case "conventions.subtest_names":
+		c.Conventions.SubtestNames = value
+	case "conventions.parallel":
+		if value == "" {
+			c.Conventions.Parallel = nil
+			break
+		}
+		parallel, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("conventions.parallel must be true or false")
+		}
+		c.Conventions.Parallel = &parallel
+	case "conventions.assertions":
+		c.Conventions.Assertions = value
+	case "network.proxy":
+		c.Network.Proxy = value
+	case "network.ca_cert":
+		c.Network.CACert = value
+	case "network.client_cert":
+		c.Network.ClientCert = value
+	case "network.client_key":
+		c.Network.ClientKey = value
+	case "post_process":
+		c.PostProcess = splitList(value)
+	case "imports.allow":
+		c.Imports.Allow = splitList(value)
+	case "imports.deny":
+		c.Imports.Deny = splitList(value)
+	case "audit":
+		audit, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("audit must be true or false")
+		}
+		c.Audit = audit
+	default:
+		return fmt.Errorf("%w %q (valid keys: %s)", ErrUnknownKey, key, strings.Join(Keys, ", "))
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
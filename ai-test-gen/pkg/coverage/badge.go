@@ -0,0 +1,70 @@
+package coverage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// badgeColors are the colors of the badge from the lowest coverage each
+// applies to, highest first
+var badgeColors = []struct {
+	min   float64
+	color string
+}{
+	{90, "#4c1"},
+	{80, "#97ca00"},
+	{70, "#a4a61d"},
+	{60, "#dfb317"},
+	{40, "#fe7d37"},
+	{0, "#e05d44"},
+}
+
+// Badge returns an SVG badge showing the coverage pct, in the flat style of
+// the shields.io badges READMEs commonly show
+func Badge(pct float64) string {
+	const label = "coverage"
+	value := fmt.Sprintf("%.1f%%", pct)
+	color := badgeColors[len(badgeColors)-1].color
+	for _, c := range badgeColors {
+		if pct >= c.min {
+			color = c.color
+			break
+		}
+	}
+	lw, vw := textWidth(label)+10, textWidth(value)+10
+	w := lw + vw
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`+"\n", w, label, value)
+	fmt.Fprintf(&sb, `<title>%s: %s</title>`+"\n", label, value)
+	sb.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>` + "\n")
+	fmt.Fprintf(&sb, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`+"\n", w)
+	fmt.Fprintf(&sb, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`+"\n", lw, lw, vw, color, w)
+	sb.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">` + "\n")
+	for _, t := range []struct {
+		x    int
+		text string
+	}{{lw / 2, label}, {lw + vw/2, value}} {
+		fmt.Fprintf(&sb, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`+"\n", t.x, t.text, t.x, t.text)
+	}
+	sb.WriteString("</g>\n</svg>\n")
+	return sb.String()
+}
+
+// textWidth estimates the width in pixels of text in 11px Verdana
+func textWidth(text string) int {
+	width := 0.0
+	for _, r := range text {
+		switch {
+		case r == '.' || r == ' ':
+			width += 3.5
+		case r == '%':
+			width += 11
+		case r >= '0' && r <= '9':
+			width += 7
+		default:
+			width += 6.5
+		}
+	}
+	return int(width + 0.5)
+}
@@ -0,0 +1,79 @@
+package coverage
+
+import "sort"
+
+// FuncDelta is the change in coverage of one function between two profiles
+type FuncDelta struct {
+	File      string
+	Name      string
+	StartLine int
+	// Before and After are nil when the function is missing from that profile
+	Before *FuncCoverage
+	After  *FuncCoverage
+}
+
+// Change returns the difference in coverage percentage points, counting a
+// missing function as uncovered
+func (d FuncDelta) Change() float64 {
+	return percentOf(d.After) - percentOf(d.Before)
+}
+
+func percentOf(f *FuncCoverage) float64 {
+	if f == nil || f.Total == 0 {
+		return 0
+	}
+	return f.Percent()
+}
+
+// DiffFuncs pairs the functions of two per-function coverage lists by file
+// and name and returns those whose covered statements changed, sorted by file
+// and line
+func DiffFuncs(before, after []FuncCoverage) []FuncDelta {
+	type key struct{ file, name string }
+	deltas := make(map[key]*FuncDelta)
+	get := func(f FuncCoverage) *FuncDelta {
+		k := key{f.File, f.Name}
+		d, ok := deltas[k]
+		if !ok {
+			d = &FuncDelta{File: f.File, Name: f.Name, StartLine: f.StartLine}
+			deltas[k] = d
+		}
+		return d
+	}
+	for i := range before {
+		get(before[i]).Before = &before[i]
+	}
+	for i := range after {
+		d := get(after[i])
+		d.After = &after[i]
+		d.StartLine = after[i].StartLine
+	}
+
+	var changed []FuncDelta
+	for _, d := range deltas {
+		if covered(d.Before) != covered(d.After) || total(d.Before) != total(d.After) {
+			changed = append(changed, *d)
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		if changed[i].File != changed[j].File {
+			return changed[i].File < changed[j].File
+		}
+		return changed[i].StartLine < changed[j].StartLine
+	})
+	return changed
+}
+
+func covered(f *FuncCoverage) int {
+	if f == nil {
+		return 0
+	}
+	return f.Covered
+}
+
+func total(f *FuncCoverage) int {
+	if f == nil {
+		return 0
+	}
+	return f.Total
+}
@@ -0,0 +1,134 @@
+package coverage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// FuncCoverage is the statement coverage of a single function
+type FuncCoverage struct {
+	File string
+	// ProfileFile is the name of the file in the profile, its import path
+	// and file name
+	ProfileFile string
+	Name        string
+	StartLine   int
+	EndLine     int
+	Covered     int
+	Total       int
+	// Uncovered lists the line ranges of blocks that never ran
+	Uncovered [][2]int
+}
+
+// Percent returns the function's statement coverage
+func (f FuncCoverage) Percent() float64 {
+	return percent(f.Covered, f.Total)
+}
+
+// Resolver maps a profile file name (import path + file) to a path on disk
+type Resolver func(profileFile string) (string, error)
+
+// Funcs computes per-function coverage, in the spirit of go tool cover -func
+func Funcs(profiles []*Profile, resolve Resolver) ([]FuncCoverage, error) {
+	var funcs []FuncCoverage
+	for _, p := range profiles {
+		file, err := resolve(p.FileName)
+		if err != nil {
+			return nil, err
+		}
+
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			start := fset.Position(fn.Pos())
+			end := fset.Position(fn.End())
+			fc := FuncCoverage{
+				File:        file,
+				ProfileFile: p.FileName,
+				Name:        funcName(fn),
+				StartLine:   start.Line,
+				EndLine:     end.Line,
+			}
+			for _, b := range p.Blocks {
+				if !within(b, start, end) {
+					continue
+				}
+				fc.Total += b.NumStmt
+				if b.Count > 0 {
+					fc.Covered += b.NumStmt
+				} else if b.NumStmt > 0 {
+					fc.Uncovered = append(fc.Uncovered, [2]int{b.StartLine, b.EndLine})
+				}
+			}
+			funcs = append(funcs, fc)
+		}
+	}
+	return funcs, nil
+}
+
+func within(b Block, start, end token.Position) bool {
+	if b.StartLine < start.Line || (b.StartLine == start.Line && b.StartCol < start.Column) {
+		return false
+	}
+	if b.EndLine > end.Line || (b.EndLine == end.Line && b.EndCol > end.Column) {
+		return false
+	}
+	return true
+}
+
+// funcName returns the function name, prefixed with its receiver type for methods
+func funcName(fn *ast.FuncDecl) string {
+	if recv := source.ReceiverType(fn); recv != "" {
+		return recv + "." + fn.Name.Name
+	}
+	return fn.Name.Name
+}
+
+// PackageResolver returns a Resolver that locates profile files using go list,
+// run from dir
+func PackageResolver(ctx context.Context, dir string) (Resolver, error) {
+	var out, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", "list", "-f", "{{.ImportPath}}\t{{.Dir}}", "./...")
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go list failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	dirs := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		importPath, pkgDir, ok := strings.Cut(line, "\t")
+		if ok {
+			dirs[importPath] = pkgDir
+		}
+	}
+
+	return func(profileFile string) (string, error) {
+		if pkgDir, ok := dirs[path.Dir(profileFile)]; ok {
+			return filepath.Join(pkgDir, path.Base(profileFile)), nil
+		}
+		if filepath.IsAbs(profileFile) {
+			return profileFile, nil
+		}
+		return "", fmt.Errorf("cannot locate %s", profileFile)
+	}, nil
+}
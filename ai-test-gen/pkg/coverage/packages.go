@@ -0,0 +1,41 @@
+package coverage
+
+import (
+	"path"
+	"sort"
+)
+
+// PackageCoverage is the statement coverage of one package
+type PackageCoverage struct {
+	Path    string
+	Covered int
+	Total   int
+}
+
+// Percent returns the package's statement coverage
+func (p PackageCoverage) Percent() float64 {
+	return percent(p.Covered, p.Total)
+}
+
+// Packages sums the profiles per package, sorted by import path
+func Packages(profiles []*Profile) []PackageCoverage {
+	byPath := make(map[string]*PackageCoverage)
+	for _, p := range profiles {
+		dir := path.Dir(p.FileName)
+		pkg, ok := byPath[dir]
+		if !ok {
+			pkg = &PackageCoverage{Path: dir}
+			byPath[dir] = pkg
+		}
+		covered, total := p.Statements()
+		pkg.Covered += covered
+		pkg.Total += total
+	}
+
+	pkgs := make([]PackageCoverage, 0, len(byPath))
+	for _, pkg := range byPath {
+		pkgs = append(pkgs, *pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Path < pkgs[j].Path })
+	return pkgs
+}
@@ -0,0 +1,158 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Block is a single basic block entry from a coverage profile
+type Block struct {
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+	NumStmt   int
+	Count     int
+}
+
+// Profile holds the coverage blocks recorded for one source file
+type Profile struct {
+	FileName string
+	Mode     string
+	Blocks   []Block
+}
+
+// ParseProfiles reads a profile written by go test -coverprofile
+func ParseProfiles(path string) ([]*Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	files := make(map[string]*Profile)
+	var mode string
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if lineNo == 1 && strings.HasPrefix(line, "mode: ") {
+			mode = strings.TrimPrefix(line, "mode: ")
+			continue
+		}
+
+		fileName, block, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		p := files[fileName]
+		if p == nil {
+			p = &Profile{FileName: fileName, Mode: mode}
+			files[fileName] = p
+		}
+		p.Blocks = append(p.Blocks, block)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	profiles := make([]*Profile, 0, len(files))
+	for _, p := range files {
+		p.Blocks = mergeBlocks(p.Blocks, mode)
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].FileName < profiles[j].FileName })
+	return profiles, nil
+}
+
+// parseLine parses "file.go:startLine.startCol,endLine.endCol numStmt count"
+func parseLine(line string) (string, Block, error) {
+	var b Block
+	colon := strings.LastIndex(line, ":")
+	if colon < 0 {
+		return "", b, fmt.Errorf("malformed profile line %q", line)
+	}
+	fields := strings.Fields(line[colon+1:])
+	if len(fields) != 3 {
+		return "", b, fmt.Errorf("malformed profile line %q", line)
+	}
+	if _, err := fmt.Sscanf(fields[0], "%d.%d,%d.%d", &b.StartLine, &b.StartCol, &b.EndLine, &b.EndCol); err != nil {
+		return "", b, fmt.Errorf("malformed block %q: %w", fields[0], err)
+	}
+	var err error
+	if b.NumStmt, err = strconv.Atoi(fields[1]); err != nil {
+		return "", b, fmt.Errorf("malformed statement count %q: %w", fields[1], err)
+	}
+	if b.Count, err = strconv.Atoi(fields[2]); err != nil {
+		return "", b, fmt.Errorf("malformed hit count %q: %w", fields[2], err)
+	}
+	return line[:colon], b, nil
+}
+
+// mergeBlocks combines duplicate entries, which appear when several test
+// binaries cover the same file
+func mergeBlocks(blocks []Block, mode string) []Block {
+	sort.SliceStable(blocks, func(i, j int) bool {
+		if blocks[i].StartLine != blocks[j].StartLine {
+			return blocks[i].StartLine < blocks[j].StartLine
+		}
+		return blocks[i].StartCol < blocks[j].StartCol
+	})
+	merged := blocks[:0]
+	for _, b := range blocks {
+		if n := len(merged); n > 0 && merged[n-1].StartLine == b.StartLine && merged[n-1].StartCol == b.StartCol &&
+			merged[n-1].EndLine == b.EndLine && merged[n-1].EndCol == b.EndCol {
+			if mode == "set" {
+				merged[n-1].Count |= b.Count
+			} else {
+				merged[n-1].Count += b.Count
+			}
+			continue
+		}
+		merged = append(merged, b)
+	}
+	return merged
+}
+
+// Statements returns the number of covered and total statements in the profile
+func (p *Profile) Statements() (covered, total int) {
+	for _, b := range p.Blocks {
+		total += b.NumStmt
+		if b.Count > 0 {
+			covered += b.NumStmt
+		}
+	}
+	return covered, total
+}
+
+// Percent returns the statement coverage of the profile's file
+func (p *Profile) Percent() float64 {
+	return percent(p.Statements())
+}
+
+// Percent returns the statement coverage across all profiles
+func Percent(profiles []*Profile) float64 {
+	var covered, total int
+	for _, p := range profiles {
+		c, t := p.Statements()
+		covered += c
+		total += t
+	}
+	return percent(covered, total)
+}
+
+// percent returns covered as a percentage of total, 0 when there are no
+// statements, as go tool cover reports, so that an empty profile never passes
+// a coverage threshold
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(covered) / float64(total)
+}
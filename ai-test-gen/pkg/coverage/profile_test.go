@@ -0,0 +1,116 @@
+package coverage
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func parse(t *testing.T, name string) []*Profile {
+	t.Helper()
+	profiles, err := ParseProfiles(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("ParseProfiles(%s): %v", name, err)
+	}
+	return profiles
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+func TestParseProfiles(t *testing.T) {
+	profiles := parse(t, "set.out")
+	if len(profiles) != 2 {
+		t.Fatalf("got %d profiles, want 2", len(profiles))
+	}
+	a, b := profiles[0], profiles[1]
+	if a.FileName != "example.com/m/a/a.go" || b.FileName != "example.com/m/b/b.go" {
+		t.Errorf("profiles are %s, %s; want a.go, then b.go", a.FileName, b.FileName)
+	}
+	if a.Mode != "set" {
+		t.Errorf("Mode = %q, want set", a.Mode)
+	}
+	// the two entries of the block at 4.12 are merged, in line order
+	want := []Block{
+		{StartLine: 3, StartCol: 24, EndLine: 4, EndCol: 12, NumStmt: 1, Count: 1},
+		{StartLine: 4, StartCol: 12, EndLine: 6, EndCol: 3, NumStmt: 2, Count: 1},
+		{StartLine: 7, StartCol: 2, EndLine: 7, EndCol: 14, NumStmt: 1, Count: 1},
+		{StartLine: 8, StartCol: 2, EndLine: 9, EndCol: 3, NumStmt: 1, Count: 0},
+	}
+	if len(a.Blocks) != len(want) {
+		t.Fatalf("a.go has %d blocks, want %d: %+v", len(a.Blocks), len(want), a.Blocks)
+	}
+	for i := range want {
+		if a.Blocks[i] != want[i] {
+			t.Errorf("block %d = %+v, want %+v", i, a.Blocks[i], want[i])
+		}
+	}
+}
+
+func TestParseProfilesCountMode(t *testing.T) {
+	profiles := parse(t, "count.out")
+	if len(profiles) != 1 || len(profiles[0].Blocks) != 2 {
+		t.Fatalf("got %+v, want one profile of two blocks", profiles)
+	}
+	// counts of duplicate blocks add up
+	if got := profiles[0].Blocks[0].Count; got != 5 {
+		t.Errorf("merged count = %d, want 5", got)
+	}
+}
+
+func TestParseProfilesErrors(t *testing.T) {
+	if _, err := ParseProfiles(filepath.Join("testdata", "malformed.out")); err == nil {
+		t.Error("ParseProfiles of a malformed profile succeeded")
+	}
+	if _, err := ParseProfiles(filepath.Join("testdata", "missing.out")); err == nil {
+		t.Error("ParseProfiles of a missing profile succeeded")
+	}
+}
+
+func TestPercent(t *testing.T) {
+	profiles := parse(t, "set.out")
+	a, b := profiles[0], profiles[1]
+
+	if covered, total := a.Statements(); covered != 4 || total != 5 {
+		t.Errorf("a.go Statements() = %d, %d; want 4, 5", covered, total)
+	}
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"a.go", a.Percent(), 80},
+		{"b.go", b.Percent(), 0},
+		{"total", Percent(profiles), 100 * 4.0 / 7},
+		{"count mode", Percent(parse(t, "count.out")), 100 / 3.0},
+		// no statements is no coverage, so it never passes a threshold
+		{"empty profile", Percent(parse(t, "empty.out")), 0},
+		{"no profiles", Percent(nil), 0},
+		{"no blocks", (&Profile{}).Percent(), 0},
+	}
+	for _, tt := range tests {
+		if !approx(tt.got, tt.want) {
+			t.Errorf("%s: got %.2f%%, want %.2f%%", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestPackages(t *testing.T) {
+	pkgs := Packages(parse(t, "set.out"))
+	want := []PackageCoverage{
+		{Path: "example.com/m/a", Covered: 4, Total: 5},
+		{Path: "example.com/m/b", Covered: 0, Total: 2},
+	}
+	if len(pkgs) != len(want) {
+		t.Fatalf("Packages = %+v, want %+v", pkgs, want)
+	}
+	for i := range want {
+		if pkgs[i] != want[i] {
+			t.Errorf("package %d = %+v, want %+v", i, pkgs[i], want[i])
+		}
+	}
+	if got := (PackageCoverage{Path: "empty"}).Percent(); got != 0 {
+		t.Errorf("Percent of a package without statements = %.1f, want 0", got)
+	}
+}
@@ -0,0 +1,312 @@
+// Package docindex builds the DOCS.md index of a documented folder: links to
+// the documentation of each file grouped by package, the dependencies between
+// the packages and the files each type is used in.
+package docindex
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// FileName is the index written at the root of the folder
+const FileName = "DOCS.md"
+
+// file is a parsed source file and its documentation
+type file struct {
+	// rel is path relative to the folder root, slash separated
+	rel string
+	// doc is the link to the documentation, "" for undocumented files
+	doc    string
+	parsed *source.File
+	// pkg identifies the package: its import path, or its directory outside
+	// a module
+	pkg string
+}
+
+// typeRef is a type declared in one file and used in others
+type typeRef struct {
+	name string
+	decl *file
+	uses []*file
+}
+
+// Build returns the index of files for a folder at root, to be written to
+// indexDir, which links are relative to. docFor returns the documentation
+// file of a source file; documentation that doesn't exist is not linked.
+// Files that fail to parse are listed without cross-references.
+func Build(root, indexDir, title string, files []string, docFor func(string) string) (string, error) {
+	mod, err := source.FindModule(root)
+	if err != nil && !errors.Is(err, source.ErrNoModule) {
+		return "", err
+	}
+
+	var parsed []*file
+	for _, path := range files {
+		f := &file{rel: relLink(root, path), pkg: filepath.ToSlash(filepath.Dir(path))}
+		if doc := docFor(path); doc != "" {
+			if _, err := os.Stat(doc); err == nil {
+				f.doc = relLink(indexDir, doc)
+			}
+		}
+		if mod != nil {
+			if importPath, err := mod.ImportPath(filepath.Dir(path)); err == nil {
+				f.pkg = importPath
+			}
+		}
+		if src, err := os.ReadFile(path); err == nil {
+			f.parsed, _ = source.Parse(path, src)
+		}
+		parsed = append(parsed, f)
+	}
+	sort.Slice(parsed, func(i, j int) bool { return parsed[i].rel < parsed[j].rel })
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	writePackages(&sb, parsed)
+	writeDependencies(&sb, parsed)
+	writeCrossReferences(&sb, parsed)
+	return sb.String(), nil
+}
+
+// writePackages lists the files of each package with links to their docs
+func writePackages(sb *strings.Builder, files []*file) {
+	sb.WriteString("## Packages\n\n")
+	for _, group := range byPackage(files) {
+		name := ""
+		for _, f := range group {
+			if f.parsed != nil {
+				name = f.parsed.Package()
+				break
+			}
+		}
+		fmt.Fprintf(sb, "### %s\n\n", packageTitle(group[0], name))
+		for _, f := range group {
+			fmt.Fprintf(sb, "- %s\n", link(f))
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// writeDependencies lists, for each package, the packages of the folder it
+// imports
+func writeDependencies(sb *strings.Builder, files []*file) {
+	groups := byPackage(files)
+	known := make(map[string][]*file, len(groups))
+	for _, group := range groups {
+		known[group[0].pkg] = group
+	}
+
+	var lines []string
+	for _, group := range groups {
+		imports := make(map[string]bool)
+		for _, f := range group {
+			if f.parsed == nil {
+				continue
+			}
+			for _, imp := range f.parsed.AST.Imports {
+				path, _ := strconv.Unquote(imp.Path.Value)
+				if _, ok := known[path]; ok && path != group[0].pkg {
+					imports[path] = true
+				}
+			}
+		}
+		if len(imports) == 0 {
+			continue
+		}
+		deps := make([]string, 0, len(imports))
+		for path := range imports {
+			deps = append(deps, "`"+packageDir(known[path][0])+"`")
+		}
+		sort.Strings(deps)
+		lines = append(lines, fmt.Sprintf("- `%s` imports %s", packageDir(group[0]), strings.Join(deps, ", ")))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	sb.WriteString("## Package dependencies\n\n")
+	sb.WriteString(strings.Join(lines, "\n"))
+	sb.WriteString("\n\n")
+}
+
+// writeCrossReferences lists the types used outside the file declaring them,
+// linking to the documentation of each file involved
+func writeCrossReferences(sb *strings.Builder, files []*file) {
+	refs := crossReferences(files)
+	if len(refs) == 0 {
+		return
+	}
+	sb.WriteString("## Cross-references\n\n")
+	sb.WriteString("Types used in more than one file, with the file declaring them first.\n\n")
+	for _, ref := range refs {
+		uses := make([]string, len(ref.uses))
+		for i, f := range ref.uses {
+			uses[i] = link(f)
+		}
+		fmt.Fprintf(sb, "- `%s` declared in %s, used in %s\n", ref.name, link(ref.decl), strings.Join(uses, ", "))
+	}
+	sb.WriteString("\n")
+}
+
+// crossReferences finds the types declared in one of files and used in
+// another, in the same package or through an import
+func crossReferences(files []*file) []typeRef {
+	// declaring file by package and type name
+	decls := make(map[string]*file)
+	for _, f := range files {
+		if f.parsed == nil {
+			continue
+		}
+		for _, d := range f.parsed.AST.Decls {
+			gen, ok := d.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok {
+					decls[f.pkg+"."+ts.Name.Name] = f
+				}
+			}
+		}
+	}
+
+	uses := make(map[string][]*file)
+	for _, f := range files {
+		if f.parsed == nil {
+			continue
+		}
+		imports := importNames(f.parsed.AST)
+		seen := make(map[string]bool)
+		use := func(key string) {
+			if decl, ok := decls[key]; ok && decl != f && !seen[key] {
+				seen[key] = true
+				uses[key] = append(uses[key], f)
+			}
+		}
+		// fields and methods selected from values are not type names
+		selected := make(map[*ast.Ident]bool)
+		ast.Inspect(f.parsed.AST, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.SelectorExpr:
+				if id, ok := x.X.(*ast.Ident); ok {
+					if path, ok := imports[id.Name]; ok {
+						use(path + "." + x.Sel.Name)
+						return false
+					}
+				}
+				selected[x.Sel] = true
+			case *ast.Ident:
+				if !selected[x] {
+					use(f.pkg + "." + x.Name)
+				}
+			}
+			return true
+		})
+	}
+
+	refs := make([]typeRef, 0, len(uses))
+	for key, files := range uses {
+		decl := decls[key]
+		name := key[strings.LastIndex(key, ".")+1:]
+		if !samePackage(files, decl.pkg) {
+			// types used from other packages are named with their package
+			name = decl.parsed.Package() + "." + name
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].rel < files[j].rel })
+		refs = append(refs, typeRef{name: name, decl: decl, uses: files})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].name < refs[j].name })
+	return refs
+}
+
+// samePackage reports whether all files are in package pkg
+func samePackage(files []*file, pkg string) bool {
+	for _, f := range files {
+		if f.pkg != pkg {
+			return false
+		}
+	}
+	return true
+}
+
+// importNames maps the names a file refers to its imports by, to their paths.
+// Packages imported without a name are assumed to be named after the last
+// element of their path.
+func importNames(f *ast.File) map[string]string {
+	names := make(map[string]string)
+	for _, imp := range f.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path[strings.LastIndex(path, "/")+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if name != "_" && name != "." {
+			names[name] = path
+		}
+	}
+	return names
+}
+
+// byPackage groups files by package, in the order of their first file
+func byPackage(files []*file) [][]*file {
+	var groups [][]*file
+	index := make(map[string]int)
+	for _, f := range files {
+		i, ok := index[f.pkg]
+		if !ok {
+			i = len(groups)
+			index[f.pkg] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], f)
+	}
+	return groups
+}
+
+// packageDir returns the directory of f's package relative to the root
+func packageDir(f *file) string {
+	dir := path.Dir(f.rel)
+	if dir == "." {
+		return "./"
+	}
+	return dir + "/"
+}
+
+// packageTitle names a package by its directory and, when it differs from
+// the directory's name, its package name
+func packageTitle(f *file, name string) string {
+	dir := packageDir(f)
+	if name == "" || path.Base(strings.TrimSuffix(dir, "/")) == name {
+		return "`" + dir + "`"
+	}
+	return fmt.Sprintf("`%s` (package %s)", dir, name)
+}
+
+// link returns a Markdown link to the documentation of f, or its plain name
+// when it has none
+func link(f *file) string {
+	if f.doc == "" {
+		return "`" + f.rel + "`"
+	}
+	return fmt.Sprintf("[%s](%s)", f.rel, f.doc)
+}
+
+// relLink returns path relative to root with forward slashes, for links
+func relLink(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	return filepath.ToSlash(rel)
+}
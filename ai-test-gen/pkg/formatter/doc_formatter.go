@@ -1,25 +1,259 @@
 package formatter
 
 import (
+	"bytes"
 	"regexp"
+	"sort"
 	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+
+	"github.com/knbr13/aitestgen/pkg/codefence"
 )
 
-// FormatDocumentation formats raw documentation based on requested format
+// MarkdownOptions controls NormalizeMarkdown
+type MarkdownOptions struct {
+	// HeadingLevel, when above zero, shifts the headings so that the highest
+	// one has this level, e.g. 2 for docs embedded under a page title
+	HeadingLevel int
+	// Wrap, when above zero, rewraps paragraphs to lines of at most this many
+	// characters. Code, tables, headings, HTML and paragraphs with hard line
+	// breaks are left as they are.
+	Wrap int
+}
+
+// FormatDocumentation cleans up generated Markdown documentation
 func FormatDocumentation(docs string) string {
-	return cleanMarkdown(docs)
+	return NormalizeMarkdown(docs, MarkdownOptions{})
 }
 
-func cleanMarkdown(docs string) string {
-	// Clean up common Gemini artifacts
-	docs = strings.ReplaceAll(docs, "** ", "**")
-	docs = strings.ReplaceAll(docs, " **", "**")
-	docs = strings.ReplaceAll(docs, "* ", "*")
-	docs = strings.ReplaceAll(docs, " *", "*")
+// paddedStrong matches bold spans with spaces inside their delimiters, which
+// Markdown doesn't render as bold, e.g. "** Note:**"
+var paddedStrong = regexp.MustCompile(`\*\*[ \t]*([^*\s]|[^*\s][^*\n]*?[^*\s])[ \t]*\*\*`)
+
+// atxHeading matches the opening hashes of an ATX heading line
+var atxHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+|$)`)
+
+// NormalizeMarkdown tidies Markdown written by a model without changing what
+// it says: code fences left open are closed, bold spans padded with spaces
+// are fixed, runs of blank lines are collapsed, and with opts the headings
+// are shifted and the paragraphs rewrapped. The text inside code blocks and
+// code spans is never touched.
+func NormalizeMarkdown(docs string, opts MarkdownOptions) string {
+	lines := strings.Split(strings.TrimSpace(docs), "\n")
+	code := make([]bool, len(lines))
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence == "":
+			if n := codefence.Len(trimmed); n > 0 {
+				fence = trimmed[:n]
+				code[i] = true
+			}
+		default:
+			code[i] = true
+			if codefence.Closes(trimmed, fence) {
+				fence = ""
+			}
+		}
+	}
+	if fence != "" {
+		lines = append(lines, fence)
+		code = append(code, true)
+	}
+	for i, line := range lines {
+		if !code[i] {
+			lines[i] = outsideCodeSpans(line, func(s string) string {
+				return paddedStrong.ReplaceAllString(s, "**$1**")
+			})
+		}
+	}
+
+	src := []byte(strings.Join(lines, "\n") + "\n")
+	doc := markdown.Parser().Parse(text.NewReader(src))
+	starts := lineStarts(src)
+	lineOf := func(offset int) int {
+		return sort.Search(len(starts), func(i int) bool { return starts[i] > offset }) - 1
+	}
+
+	var headings []*ast.Heading
+	// paragraphs, and the text of tight list items
+	var paragraphs []ast.Node
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.CodeBlock, *ast.HTMLBlock:
+			for i := 0; i < n.Lines().Len(); i++ {
+				code[lineOf(n.Lines().At(i).Start)] = true
+			}
+		case *ast.Heading:
+			headings = append(headings, n)
+		case *ast.Paragraph, *ast.TextBlock:
+			paragraphs = append(paragraphs, n)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	if opts.HeadingLevel > 0 {
+		shiftHeadings(lines, code, headings, lineOf, opts.HeadingLevel)
+	}
+	if opts.Wrap > 0 {
+		for _, p := range paragraphs {
+			wrapParagraph(lines, src, starts, p, lineOf, opts.Wrap)
+		}
+	}
+
+	// collapse blank lines, including those left by rewrapped paragraphs
+	var out []string
+	blank := 0
+	for i, line := range lines {
+		if line == removed {
+			continue
+		}
+		if !code[i] && strings.TrimSpace(line) == "" {
+			blank++
+			if blank > 1 {
+				continue
+			}
+			line = ""
+		} else {
+			blank = 0
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
 
-	// Ensure proper code blocks
-	docs = regexp.MustCompile("(?m)^```go$").ReplaceAllString(docs, "```go")
-	docs = regexp.MustCompile("(?m)^```$").ReplaceAllString(docs, "```")
+// removed marks the lines a paragraph no longer needs after rewrapping
+const removed = "\x00"
+
+// shiftHeadings changes the level of the ATX headings so that the highest
+// has level, within 1 to 6. Setext headings, underlined with = or -, are
+// rewritten as ATX headings.
+func shiftHeadings(lines []string, code []bool, headings []*ast.Heading, lineOf func(int) int, level int) {
+	top := 7
+	for _, h := range headings {
+		top = min(top, h.Level)
+	}
+	shift := level - top
+	for _, h := range headings {
+		if h.Lines().Len() == 0 {
+			continue
+		}
+		first := lineOf(h.Lines().At(0).Start)
+		last := lineOf(h.Lines().At(h.Lines().Len() - 1).Start)
+		if code[first] {
+			continue
+		}
+		newLevel := min(max(h.Level+shift, 1), 6)
+		hashes := strings.Repeat("#", newLevel)
+		if m := atxHeading.FindStringSubmatchIndex(lines[first]); m != nil {
+			lines[first] = lines[first][:m[2]] + hashes + " " + strings.TrimLeft(lines[first][m[3]:], " \t")
+			continue
+		}
+		// setext: the text lines and the underline after them
+		var words []string
+		for i := first; i <= last; i++ {
+			words = append(words, strings.TrimSpace(lines[i]))
+			lines[i] = removed
+		}
+		lines[first] = hashes + " " + strings.Join(words, " ")
+		if last+1 < len(lines) {
+			lines[last+1] = removed
+		}
+	}
+}
+
+// wrapParagraph rewraps the lines of p to width, keeping the list marker or
+// indentation of its first line and aligning the following lines with its
+// text
+func wrapParagraph(lines []string, src []byte, starts []int, p ast.Node, lineOf func(int) int, width int) {
+	segs := p.Lines()
+	if segs.Len() == 0 {
+		return
+	}
+	first := lineOf(segs.At(0).Start)
+	prefix := string(src[starts[first]:segs.At(0).Start])
+	if strings.Contains(prefix, ">") || strings.Contains(prefix, "|") {
+		return
+	}
+	var words []string
+	for i := 0; i < segs.Len(); i++ {
+		seg := segs.At(i)
+		line := lines[lineOf(seg.Start)]
+		if strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\") || line == removed {
+			// hard line breaks are deliberate
+			return
+		}
+		words = append(words, strings.Fields(string(bytes.TrimSpace(seg.Value(src))))...)
+	}
+	words = joinCodeSpans(words)
+
+	indent := strings.Repeat(" ", len(prefix))
+	var wrapped []string
+	current, n := prefix, 0
+	for _, w := range words {
+		if n > 0 && len(current)+1+len(w) > width {
+			wrapped = append(wrapped, current)
+			current, n = indent, 0
+		}
+		if n > 0 {
+			current += " "
+		}
+		current += w
+		n++
+	}
+	wrapped = append(wrapped, current)
+
+	last := lineOf(segs.At(segs.Len() - 1).Start)
+	for i := first; i <= last; i++ {
+		lines[i] = removed
+	}
+	lines[first] = strings.Join(wrapped, "\n")
+}
+
+// joinCodeSpans joins back the words of code spans, which wrapping
+// shouldn't break
+func joinCodeSpans(words []string) []string {
+	var joined []string
+	open := false
+	for _, w := range words {
+		if open {
+			joined[len(joined)-1] += " " + w
+		} else {
+			joined = append(joined, w)
+		}
+		if strings.Count(w, "`")%2 == 1 {
+			open = !open
+		}
+	}
+	return joined
+}
+
+// outsideCodeSpans applies fix to the parts of line outside `code spans`
+func outsideCodeSpans(line string, fix func(string) string) string {
+	parts := strings.Split(line, "`")
+	if len(parts)%2 == 0 {
+		// an unmatched backtick: leave the line alone
+		return line
+	}
+	for i := 0; i < len(parts); i += 2 {
+		parts[i] = fix(parts[i])
+	}
+	return strings.Join(parts, "`")
+}
 
-	return strings.TrimSpace(docs)
+// lineStarts returns the offset of the start of each line of src
+func lineStarts(src []byte) []int {
+	starts := []int{0}
+	for i, b := range src {
+		if b == '\n' && i+1 < len(src) {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
 }
@@ -0,0 +1,134 @@
+package formatter
+
+import (
+	"bytes"
+	"html/template"
+	"path"
+	"sort"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+// Page is a documentation page linked from the site index
+type Page struct {
+	// Path is the page location relative to the site root, using slashes
+	Path  string
+	Title string
+}
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+// RenderHTML converts Markdown documentation into a standalone themed HTML
+// page. root is the relative path from the page back to the site root, used
+// for the index link; leave it empty for a page outside a site.
+func RenderHTML(title, docs, root string) (string, error) {
+	var body bytes.Buffer
+	if err := markdown.Convert([]byte(docs), &body); err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	err := pageTemplate.Execute(&out, map[string]any{
+		"Title": title,
+		"Body":  template.HTML(body.String()),
+		"Index": indexLink(root),
+	})
+	return out.String(), err
+}
+
+// RenderIndex returns an HTML index page linking to pages, grouped by directory
+func RenderIndex(title string, pages []Page) (string, error) {
+	groups := make(map[string][]Page)
+	for _, p := range pages {
+		dir := path.Dir(p.Path)
+		groups[dir] = append(groups[dir], p)
+	}
+	dirs := make([]string, 0, len(groups))
+	for dir := range groups {
+		dirs = append(dirs, dir)
+		sort.Slice(groups[dir], func(i, j int) bool { return groups[dir][i].Path < groups[dir][j].Path })
+	}
+	sort.Strings(dirs)
+
+	type group struct {
+		Dir   string
+		Pages []Page
+	}
+	var ordered []group
+	for _, dir := range dirs {
+		ordered = append(ordered, group{Dir: dir, Pages: groups[dir]})
+	}
+
+	var out bytes.Buffer
+	err := indexTemplate.Execute(&out, map[string]any{"Title": title, "Groups": ordered})
+	return out.String(), err
+}
+
+func indexLink(root string) string {
+	if root == "" {
+		return ""
+	}
+	return path.Join(root, "index.html")
+}
+
+const pageStyle = `
+:root { --fg: #1f2328; --muted: #59636e; --bg: #ffffff; --code-bg: #f6f8fa; --border: #d1d9e0; --link: #0969da; }
+@media (prefers-color-scheme: dark) {
+  :root { --fg: #e6edf3; --muted: #9198a1; --bg: #0d1117; --code-bg: #151b23; --border: #3d444d; --link: #4493f8; }
+}
+body { margin: 0; background: var(--bg); color: var(--fg); font: 16px/1.6 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; }
+main { max-width: 860px; margin: 0 auto; padding: 2rem 1.5rem 4rem; }
+nav { font-size: .9rem; margin-bottom: 1.5rem; }
+a { color: var(--link); text-decoration: none; }
+a:hover { text-decoration: underline; }
+h1, h2, h3 { line-height: 1.25; border-bottom: 1px solid var(--border); padding-bottom: .3em; }
+h3 { border-bottom: none; }
+code { font: 85% ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; background: var(--code-bg); padding: .2em .4em; border-radius: 6px; }
+pre { background: var(--code-bg); padding: 1rem; border-radius: 6px; overflow-x: auto; }
+pre code { padding: 0; background: none; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid var(--border); padding: .4em .8em; }
+.dir { color: var(--muted); font-size: 1rem; }
+`
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>` + pageStyle + `</style>
+</head>
+<body>
+<main>
+{{if .Index}}<nav><a href="{{.Index}}">&larr; Index</a></nav>
+{{end}}{{.Body}}
+</main>
+</body>
+</html>
+`))
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>` + pageStyle + `</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+{{range .Groups}}<h2 class="dir">{{.Dir}}</h2>
+<ul>
+{{range .Pages}}<li><a href="{{.Path}}">{{.Title}}</a></li>
+{{end}}</ul>
+{{end}}</main>
+</body>
+</html>
+`))
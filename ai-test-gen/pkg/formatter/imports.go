@@ -1,8 +1,61 @@
 package formatter
 
-import "os/exec"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
 
+	"golang.org/x/tools/imports"
+)
+
+// UseGoImportsBinary makes RunGoImports run the goimports command found in
+// PATH instead of fixing imports in process
+var UseGoImportsBinary bool
+
+// RunGoImports formats the Go file at filePath and fixes its imports, adding
+// the missing ones and removing those it doesn't use, like goimports -w. A
+// file that doesn't parse is left as it is and the syntax errors are returned.
 func RunGoImports(filePath string) error {
-	cmd := exec.Command("goimports", "-w", filePath)
-	return cmd.Run()
+	if UseGoImportsBinary {
+		return runGoImportsBinary(filePath)
+	}
+	src, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	out, err := GoImports(filePath, src)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(src, out) {
+		return nil
+	}
+	return os.WriteFile(filePath, out, 0644)
+}
+
+// GoImports returns src, the content of the Go file filePath, formatted and
+// with its imports fixed like RunGoImports, without writing it. Syntax errors
+// are returned prefixed with the file name.
+func GoImports(filePath string, src []byte) ([]byte, error) {
+	return imports.Process(filePath, src, &imports.Options{Comments: true, TabIndent: true, TabWidth: 8})
+}
+
+// runGoImportsBinary runs goimports -w on filePath, returning its diagnostics
+// in the error when it fails
+func runGoImportsBinary(filePath string) error {
+	path, err := exec.LookPath("goimports")
+	if err != nil {
+		return errors.New("goimports not found in PATH, install it with go install golang.org/x/tools/cmd/goimports@latest")
+	}
+	out, err := exec.Command(path, "-w", filePath).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("goimports: %s", msg)
+		}
+		return fmt.Errorf("goimports: %w", err)
+	}
+	return nil
 }
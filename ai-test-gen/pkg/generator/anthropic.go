@@ -0,0 +1,210 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const anthropicVersion = "2023-06-01"
+
+// anthropicDefaultMaxTokens is sent as max_tokens, which the Messages API
+// requires, for models the registry doesn't know the output limit of
+const anthropicDefaultMaxTokens = 8192
+
+// Anthropic messages API request and response structures
+type (
+	anthropicRequest struct {
+		Model       string             `json:"model"`
+		MaxTokens   int                `json:"max_tokens"`
+		Temperature float64            `json:"temperature"`
+		TopP        float64            `json:"top_p,omitempty"`
+		Messages    []anthropicMessage `json:"messages"`
+		Stream      bool               `json:"stream,omitempty"`
+	}
+
+	anthropicMessage struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+
+	anthropicResponse struct {
+		Content []anthropicContent `json:"content"`
+		Usage   struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+
+	anthropicContent struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+
+	// anthropicEvent is one server-sent event of a streamed message
+	anthropicEvent struct {
+		Type  string `json:"type"`
+		Delta struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"delta"`
+		Message struct {
+			Usage struct {
+				InputTokens int `json:"input_tokens"`
+			} `json:"usage"`
+		} `json:"message"`
+		Usage struct {
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	anthropicErrorResponse struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+)
+
+type anthropicProvider struct {
+	client  *apiClient
+	apiKey  string
+	model   ModelInfo
+	baseURL string
+	stream  bool
+}
+
+func (a *anthropicProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	maxTokens := a.model.MaxOutputTokens
+	if maxTokens == 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+	reqBody := anthropicRequest{
+		Model:       a.model.Name,
+		MaxTokens:   maxTokens,
+		Temperature: a.model.Temperature,
+		TopP:        a.model.TopP,
+		Messages: []anthropicMessage{
+			{Role: "user", Content: prompt},
+		},
+	}
+
+	headers := map[string]string{
+		"x-api-key":         a.apiKey,
+		"anthropic-version": anthropicVersion,
+	}
+	if a.stream {
+		reqBody.Stream = true
+		return a.generateStream(ctx, headers, reqBody)
+	}
+
+	var anthropicResp anthropicResponse
+	if err := a.client.postJSON(ctx, a.baseURL+"/messages", headers, reqBody, &anthropicResp); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			return "", anthropicError(apiErr)
+		}
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, c := range anthropicResp.Content {
+		if c.Type == "text" {
+			sb.WriteString(c.Text)
+		}
+	}
+	recordUsage(ctx, prompt, sb.String(), anthropicResp.Usage.InputTokens, anthropicResp.Usage.OutputTokens)
+	if sb.Len() == 0 {
+		return "", fmt.Errorf("no content in API response")
+	}
+
+	return sb.String(), nil
+}
+
+// generateStream reads a streamed message, collecting the text deltas and the
+// token counts reported in the message_start and message_delta events
+func (a *anthropicProvider) generateStream(ctx context.Context, headers map[string]string, reqBody anthropicRequest) (string, error) {
+	buf := newStreamBuffer(ctx)
+	var inputTokens, outputTokens int
+	err := a.client.postStream(ctx, a.baseURL+"/messages", headers, reqBody, func(line []byte) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		var ev anthropicEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return fmt.Errorf("error decoding stream: %w", err)
+		}
+		switch ev.Type {
+		case "message_start":
+			inputTokens = ev.Message.Usage.InputTokens
+		case "content_block_delta":
+			if ev.Delta.Type == "text_delta" {
+				buf.add(ev.Delta.Text)
+			}
+		case "message_delta":
+			outputTokens = ev.Usage.OutputTokens
+		case "error":
+			return fmt.Errorf("anthropic: %s: %s", ev.Error.Type, ev.Error.Message)
+		}
+		return nil
+	})
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		err = anthropicError(apiErr)
+	}
+	recordUsage(ctx, reqBody.Messages[0].Content, buf.text(), inputTokens, outputTokens)
+	return buf.result(err)
+}
+
+// anthropicAPIError describes a failed Anthropic request in terms of the
+// API's documented error classes
+type anthropicAPIError struct {
+	apiErr  *APIError
+	reason  string
+	message string
+}
+
+func (e *anthropicAPIError) Error() string {
+	return fmt.Sprintf("anthropic: %s: %s", e.reason, e.message)
+}
+
+func (e *anthropicAPIError) Unwrap() error {
+	return e.apiErr
+}
+
+// anthropicError maps Anthropic status codes to descriptive errors, keeping
+// the underlying APIError available to errors.As
+func anthropicError(apiErr *APIError) error {
+	var errResp anthropicErrorResponse
+	msg := apiErr.Body
+	if err := json.Unmarshal([]byte(apiErr.Body), &errResp); err == nil && errResp.Error.Message != "" {
+		msg = errResp.Error.Message
+	}
+
+	reason := fmt.Sprintf("API returned %d", apiErr.StatusCode)
+	switch apiErr.StatusCode {
+	case http.StatusBadRequest:
+		reason = "invalid request"
+	case http.StatusUnauthorized:
+		reason = "invalid API key"
+	case http.StatusForbidden:
+		reason = "permission denied"
+	case http.StatusNotFound:
+		reason = "model or resource not found"
+	case http.StatusRequestEntityTooLarge:
+		reason = "request too large"
+	case http.StatusTooManyRequests:
+		reason = "rate limit exceeded"
+	case 529:
+		reason = "API overloaded"
+	}
+	return &anthropicAPIError{apiErr: apiErr, reason: reason, message: msg}
+}
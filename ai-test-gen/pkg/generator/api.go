@@ -0,0 +1,169 @@
+// Package generator asks language models to write tests and documentation
+// for Go code. New returns a Client for embedding generation in other tools;
+// the package level functions and Provider allow finer control.
+package generator
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Client generates tests and documentation for Go source code. It is the
+// entry point for tools embedding the generator:
+//
+//	c, err := generator.New(
+//		generator.WithProvider(generator.ProviderOpenAI, os.Getenv("OPENAI_API_KEY")),
+//		generator.WithModel("gpt-4o-mini"),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	tests, err := c.Tests(ctx, string(src))
+//
+// A Client is safe for concurrent use.
+type Client struct {
+	provider  Provider
+	tests     TestOptions
+	docPrompt string
+}
+
+// Option configures a Client created by New
+type Option func(*clientConfig)
+
+type clientConfig struct {
+	cfg       Config
+	provider  Provider
+	tests     TestOptions
+	docPrompt string
+}
+
+// WithProvider selects one of the built-in providers (see the Provider
+// constants) and the API key it authenticates with. The default is Gemini.
+func WithProvider(name, apiKey string) Option {
+	return func(c *clientConfig) {
+		c.cfg.Provider = name
+		c.cfg.APIKey = apiKey
+	}
+}
+
+// WithModel overrides the provider's default model
+func WithModel(model string) Option {
+	return func(c *clientConfig) { c.cfg.Model = model }
+}
+
+// WithBaseURL overrides the provider's API endpoint
+func WithBaseURL(url string) Option {
+	return func(c *clientConfig) { c.cfg.BaseURL = url }
+}
+
+// WithHTTPClient sends API requests through hc, e.g. to set timeouts, a proxy
+// or a custom transport
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *clientConfig) { c.cfg.HTTPClient = hc }
+}
+
+// WithTimeout bounds each API call, including retries' individual attempts
+func WithTimeout(d time.Duration) Option {
+	return func(c *clientConfig) { c.cfg.Timeout = d }
+}
+
+// WithMaxAttempts caps the tries made for rate-limited or failed requests
+func WithMaxAttempts(n int) Option {
+	return func(c *clientConfig) { c.cfg.MaxAttempts = n }
+}
+
+// WithSampling overrides the model's temperature, top-p, maximum output
+// tokens and seed
+func WithSampling(s Sampling) Option {
+	return func(c *clientConfig) { c.cfg.Sampling = s }
+}
+
+// WithConfig replaces the provider configuration as a whole. Options given
+// after it still apply on top.
+func WithConfig(cfg Config) Option {
+	return func(c *clientConfig) { c.cfg = cfg }
+}
+
+// WithCustomProvider sends prompts to p instead of a built-in provider. The
+// provider settings of other options are then ignored.
+func WithCustomProvider(p Provider) Option {
+	return func(c *clientConfig) { c.provider = p }
+}
+
+// WithFramework selects the test framework, one of the Framework constants
+func WithFramework(name string) Option {
+	return func(c *clientConfig) { c.tests.Framework = name }
+}
+
+// WithTestPrompt replaces SystemPrompt as the instructions for generating tests
+func WithTestPrompt(prompt string) Option {
+	return func(c *clientConfig) { c.tests.Prompt = prompt }
+}
+
+// WithDocPrompt replaces DocPrompt as the instructions for generating documentation
+func WithDocPrompt(prompt string) Option {
+	return func(c *clientConfig) { c.docPrompt = prompt }
+}
+
+// New returns a Client configured by opts
+func New(opts ...Option) (*Client, error) {
+	var c clientConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if err := ValidateFramework(c.tests.Framework); err != nil {
+		return nil, err
+	}
+
+	p := c.provider
+	if p == nil {
+		var err error
+		if p, err = NewProvider(c.cfg); err != nil {
+			return nil, err
+		}
+	}
+	return &Client{provider: p, tests: c.tests, docPrompt: c.docPrompt}, nil
+}
+
+// Provider returns the provider the client sends prompts to
+func (c *Client) Provider() Provider {
+	return c.provider
+}
+
+// TestOptions returns the options the client generates tests with, for use
+// with the lower level functions such as RepairUnitTests
+func (c *Client) TestOptions() TestOptions {
+	return c.tests
+}
+
+// Tests returns a test file for the Go source code
+func (c *Client) Tests(ctx context.Context, code string) (string, error) {
+	return GenerateUnitTests(ctx, code, c.provider, c.tests)
+}
+
+// RepairTests returns tests fixed to compile, given the compiler errors
+func (c *Client) RepairTests(ctx context.Context, code, tests, compileErrors string) (string, error) {
+	return RepairUnitTests(ctx, code, tests, compileErrors, c.provider, c.tests)
+}
+
+// Benchmarks returns benchmarks for the Go source code
+func (c *Client) Benchmarks(ctx context.Context, code string) (string, error) {
+	return GenerateBenchmarks(ctx, code, c.provider)
+}
+
+// Examples returns godoc examples for the exported API of the Go source code
+func (c *Client) Examples(ctx context.Context, code string) (string, error) {
+	return GenerateExamples(ctx, code, c.provider)
+}
+
+// Documentation returns Markdown documentation for the Go source code
+func (c *Client) Documentation(ctx context.Context, code string) (string, error) {
+	return c.provider.Generate(ctx, CustomDocumentationPrompt(c.docPrompt, code))
+}
+
+// DocComments returns godoc comments for the named declarations in the Go
+// source code, keyed by name
+func (c *Client) DocComments(ctx context.Context, code string, names []string) (map[string]string, error) {
+	return GenerateDocComments(ctx, code, names, c.provider)
+}
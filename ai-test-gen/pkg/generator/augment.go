@@ -0,0 +1,32 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TableCasesPrompt is the instruction preamble sent when asking for new rows
+// for the case table of an existing table-driven test
+var TableCasesPrompt = `You are an expert Go developer improving an existing table-driven test. Propose additional test cases for its case table. Focus on what the existing rows miss:
+1. Edge cases: zero values, empty and nil inputs, single elements, very large inputs
+2. Boundary values around every limit and comparison in the code
+3. Error paths, with the error expectations the table already uses
+4. No duplicates of existing rows
+Return ONLY the new rows, each written exactly as an element inside the table's braces and followed by a comma, using the same field names and style as the existing rows. Do not repeat the existing rows, the table type or the rest of the test. Do not output any explanations, only the code block.`
+
+// GenerateTableCases asks the provider for new rows for the case table of
+// test, the source of a table-driven test with cases rows, given the code
+// under test. The rows are returned as literal elements ready to be added to
+// the table.
+func GenerateTableCases(ctx context.Context, code, test string, cases int, p Provider) (string, error) {
+	fullPrompt := TableCasesPrompt +
+		fmt.Sprintf("\n\nTest (its table has %d cases):\n\n", cases) + test +
+		"\n\nCode under test:\n\n" + code
+
+	text, err := p.Generate(ctx, fullPrompt)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(extractCodeBlock(text)), nil
+}
@@ -0,0 +1,21 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// azureProvider targets an Azure OpenAI deployment, which speaks the OpenAI
+// chat completions protocol behind a tenant-specific URL and api-key header
+type azureProvider struct {
+	openAIProvider
+	deployment string
+	apiVersion string
+}
+
+func (a *azureProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	endpoint := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		a.baseURL, url.PathEscape(a.deployment), url.QueryEscape(a.apiVersion))
+	return a.chatCompletion(ctx, endpoint, "api-key", a.apiKey, prompt)
+}
@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// BatchFile is one of the source files sent together by GenerateBatchTests
+type BatchFile struct {
+	// Name is the file's base name, e.g. strings.go
+	Name string
+	Code string
+}
+
+// batchMarker starts each test file of a batch response: // file: x_test.go
+var batchMarker = regexp.MustCompile(`(?m)^//\s*file:\s*(\S+_test\.go)\s*$`)
+
+// BatchTestPrompt returns the prompt GenerateBatchTests sends for files
+func BatchTestPrompt(files []BatchFile, opts TestOptions) string {
+	var sb strings.Builder
+	sb.WriteString(opts.prompt())
+	sb.WriteString("\n\nGenerate tests for each of the following Go files of the same package, as one test file per source file. " +
+		"Respond with one code block per test file. The first line of each block must be a comment naming the test file, " +
+		"e.g. // file: strings_test.go for strings.go; the package declaration follows it.")
+	for _, f := range files {
+		fmt.Fprintf(&sb, "\n\nFile %s:\n\n%s", f.Name, f.Code)
+	}
+	return sb.String()
+}
+
+// GenerateBatchTests asks the provider for the tests of several files in one
+// request. The result maps the name of each source file to its tests; files
+// missing from the response are left out, for the caller to generate on
+// their own.
+func GenerateBatchTests(ctx context.Context, files []BatchFile, p Provider, opts TestOptions) (map[string]string, error) {
+	text, err := p.Generate(ctx, BatchTestPrompt(files, opts))
+	if err != nil {
+		return nil, err
+	}
+
+	byTestFile := make(map[string]string)
+	for _, block := range splitBatch(text) {
+		m := batchMarker.FindStringSubmatchIndex(block)
+		if m == nil {
+			continue
+		}
+		name := block[m[2]:m[3]]
+		// the marker line is dropped wherever the model put it
+		code := strings.TrimLeft(block[:m[0]]+block[m[1]:], "\n")
+		byTestFile[name] = fixTests(code, opts)
+	}
+
+	tests := make(map[string]string, len(files))
+	for _, f := range files {
+		if t, ok := byTestFile[strings.TrimSuffix(f.Name, ".go")+"_test.go"]; ok {
+			tests[f.Name] = t
+		}
+	}
+	return tests, nil
+}
+
+// splitBatch returns the test files of a batch response: its code blocks, or
+// when the model left the fences out, the text between file markers
+func splitBatch(text string) []string {
+	var blocks []string
+	for _, b := range fencedBlocks(text) {
+		blocks = append(blocks, b.body)
+	}
+	if len(blocks) > 0 {
+		return blocks
+	}
+	starts := batchMarker.FindAllStringIndex(text, -1)
+	for i, s := range starts {
+		end := len(text)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		blocks = append(blocks, text[s[0]:end])
+	}
+	return blocks
+}
@@ -0,0 +1,82 @@
+package generator
+
+import (
+	"context"
+	"go/ast"
+	"sort"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// BenchPrompt is the instruction preamble sent with every benchmark
+// generation request
+var BenchPrompt = `You are an expert Go developer. Write complete, runnable benchmarks for the provided Go code using the standard testing package. Your output MUST be valid, compilable, idiomatic Go code. Include:
+1. One BenchmarkXxx function per exported function or method that does meaningful work
+2. Sub-benchmarks (b.Run) over realistic input sizes, e.g. 10, 1_000 and 100_000 elements for slice or string inputs
+3. Input construction outside the timed loop, followed by b.ResetTimer()
+4. b.ReportAllocs() at the start of every benchmark
+5. Sinking results into a package-level variable so the compiler cannot optimise calls away
+6. Only output valid Go test code with package declaration
+7. Do not output any explanations, only the code block.`
+
+// GenerateBenchmarks asks the provider to write benchmarks for the given Go code
+func GenerateBenchmarks(ctx context.Context, code string, p Provider) (string, error) {
+	fullPrompt := BenchPrompt + "\n\nWrite benchmarks for this Go code:\n\n" + code
+
+	text, err := p.Generate(ctx, fullPrompt)
+	if err != nil {
+		return "", err
+	}
+
+	return ensureReportAllocs(extractCodeBlock(text)), nil
+}
+
+// ensureReportAllocs inserts b.ReportAllocs() into benchmarks that don't call it
+func ensureReportAllocs(code string) string {
+	f, err := source.Parse("bench_test.go", []byte(code))
+	if err != nil {
+		return code
+	}
+
+	type insertion struct {
+		offset int
+		text   string
+	}
+	var inserts []insertion
+	for _, fn := range f.Funcs() {
+		decl := fn.Decl
+		if fn.Receiver != "" || !strings.HasPrefix(fn.Name, "Benchmark") || decl.Body == nil {
+			continue
+		}
+		params := decl.Type.Params.List
+		if len(params) != 1 || len(params[0].Names) != 1 {
+			continue
+		}
+		b := params[0].Names[0].Name
+		if b == "_" || callsReportAllocs(decl.Body) {
+			continue
+		}
+		inserts = append(inserts, insertion{
+			offset: f.Fset.Position(decl.Body.Lbrace).Offset + 1,
+			text:   "\n\t" + b + ".ReportAllocs()",
+		})
+	}
+
+	sort.Slice(inserts, func(i, j int) bool { return inserts[i].offset > inserts[j].offset })
+	for _, ins := range inserts {
+		code = code[:ins.offset] + ins.text + code[ins.offset:]
+	}
+	return code
+}
+
+func callsReportAllocs(body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == "ReportAllocs" {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,46 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ChangelogPrompt is the instruction preamble sent when generating release
+// notes. It can be replaced to customise the generated notes.
+var ChangelogPrompt = `You are an expert technical writer. Write the release notes for the version below in Keep a Changelog format (https://keepachangelog.com/en/1.1.0/).
+Rules:
+1. Start with the release heading given below and nothing before it
+2. Use only the sections Added, Changed, Deprecated, Removed, Fixed and Security, in that order, as "### " headings, and leave out empty ones
+3. The commits are grouped by a suggested section and the area of the project they touch; move a commit to another section when its message clearly belongs there
+4. Write one bullet per user-visible change, merging commits that make the same change, in plain language for users of the project rather than its developers
+5. Leave out commits grouped under Maintenance, such as CI, test and dependency updates, unless they change what users see
+6. Do not mention commit hashes or authors, and do not invent changes the commits don't show
+
+Respond with the Markdown section only.`
+
+// ChangelogRequestPrompt returns the prompt GenerateChangelog sends for the
+// commits of a release, described by changelog.Describe
+func ChangelogRequestPrompt(version, date, commits string) string {
+	heading := fmt.Sprintf("## [%s]", version)
+	if date != "" {
+		heading += " - " + date
+	}
+	var sb strings.Builder
+	sb.WriteString(ChangelogPrompt)
+	sb.WriteString("\n\nRelease heading:\n")
+	sb.WriteString(heading)
+	sb.WriteString("\n\nCommits:\n")
+	sb.WriteString(commits)
+	return sb.String()
+}
+
+// GenerateChangelog asks the provider for the Keep a Changelog section of a
+// release made of commits
+func GenerateChangelog(ctx context.Context, version, date, commits string, p Provider) (string, error) {
+	text, err := p.Generate(ctx, ChangelogRequestPrompt(version, date, commits))
+	if err != nil {
+		return "", err
+	}
+	return unwrapMarkdown(text), nil
+}
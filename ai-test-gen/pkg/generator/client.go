@@ -0,0 +1,289 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxAttempts is the number of tries made for a request when Config.MaxAttempts is unset
+const DefaultMaxAttempts = 4
+
+const (
+	baseBackoff = time.Second
+	maxBackoff  = 30 * time.Second
+)
+
+// APIError is returned when a provider responds with a non-200 status
+type APIError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is the delay requested by the server, if any
+	RetryAfter time.Duration
+	// RequestID identifies the request in the provider's logs, if reported
+	RequestID string
+}
+
+func (e *APIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("API returned %d (request %s): %s", e.StatusCode, e.RequestID, e.Body)
+	}
+	return fmt.Sprintf("API returned %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the request may succeed if retried
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// apiClient sends JSON requests to provider APIs, retrying rate-limited and
+// failed requests with jittered exponential backoff
+type apiClient struct {
+	http        *http.Client
+	maxAttempts int
+	timeout     time.Duration
+	// apiKey is removed from error messages and logs
+	apiKey string
+}
+
+func newAPIClient(cfg Config) *apiClient {
+	attempts := cfg.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultMaxAttempts
+	}
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &apiClient{http: httpClient, maxAttempts: attempts, timeout: cfg.Timeout, apiKey: cfg.APIKey}
+}
+
+// redact replaces the API key in s, e.g. in an error quoting the request URL
+// or a response body echoing the request
+func (c *apiClient) redact(s string) string {
+	if c.apiKey == "" {
+		return s
+	}
+	s = strings.ReplaceAll(s, c.apiKey, "[REDACTED]")
+	return strings.ReplaceAll(s, url.QueryEscape(c.apiKey), "[REDACTED]")
+}
+
+// redactedError is an error whose message had the API key removed; the
+// wrapped error is kept so errors.Is still sees cancellations and timeouts
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// redactErr returns err with the API key removed from its message
+func (c *apiClient) redactErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if msg := c.redact(err.Error()); msg != err.Error() {
+		return &redactedError{msg: msg, err: err}
+	}
+	return err
+}
+
+// withTimeout bounds ctx by the client's per-call timeout, if any
+func (c *apiClient) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// postJSON marshals body, posts it to url with the given headers and decodes
+// the JSON response into out
+func (c *apiClient) postJSON(ctx context.Context, url string, headers map[string]string, body, out any) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	var respBody []byte
+	err = c.retry(ctx, func() (err error) {
+		respBody, err = c.post(ctx, url, headers, jsonBody)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// postStream marshals body and posts it to url like postJSON, then calls
+// onLine for each line of the streamed response. Only opening the stream is
+// retried; a failure part way through is returned as is.
+func (c *apiClient) postStream(ctx context.Context, url string, headers map[string]string, body any, onLine func(line []byte) error) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error marshaling request: %w", err)
+	}
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var resp *http.Response
+	err = c.retry(ctx, func() (err error) {
+		resp, err = c.open(ctx, url, headers, jsonBody)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if err := onLine(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if c.timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("API request timed out after %s: %w", c.timeout, err)
+		}
+		return fmt.Errorf("error reading stream: %w", err)
+	}
+	return nil
+}
+
+// retry calls send until it succeeds, fails permanently or runs out of attempts
+func (c *apiClient) retry(ctx context.Context, send func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := send()
+		if err == nil {
+			return nil
+		}
+
+		wait := backoff(attempt)
+		if apiErr, ok := err.(*APIError); ok {
+			if !apiErr.Temporary() {
+				return err
+			}
+			if apiErr.RetryAfter > 0 {
+				wait = apiErr.RetryAfter
+			}
+		}
+		if attempt >= c.maxAttempts || ctx.Err() != nil {
+			return err
+		}
+		slog.WarnContext(ctx, "request failed, retrying", "attempt", attempt, "max_attempts", c.maxAttempts, "wait", wait.Round(time.Millisecond), "err", err)
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *apiClient) post(ctx context.Context, url string, headers map[string]string, body []byte) ([]byte, error) {
+	// each attempt gets the full timeout
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	resp, err := c.open(ctx, url, headers, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if c.timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("API request timed out after %s: %w", c.timeout, err)
+		}
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	return respBody, nil
+}
+
+// open sends the request and returns the response if its status is 200
+func (c *apiClient) open(ctx context.Context, url string, headers map[string]string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, c.redactErr(fmt.Errorf("error creating request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// the error quotes the request URL
+		if c.timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+			return nil, c.redactErr(fmt.Errorf("API request timed out after %s: %w", c.timeout, err))
+		}
+		return nil, c.redactErr(fmt.Errorf("API request failed: %w", err))
+	}
+	slog.DebugContext(ctx, "api request", "host", req.URL.Host, "path", req.URL.Path, "status", resp.StatusCode,
+		"request_id", requestID(resp.Header), "duration", time.Since(start).Round(time.Millisecond))
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       c.redact(string(respBody)),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			RequestID:  requestID(resp.Header),
+		}
+	}
+	return resp, nil
+}
+
+// requestID returns the request identifier set by the provider, under
+// whichever header it uses
+func requestID(h http.Header) string {
+	for _, key := range []string{"X-Request-Id", "Request-Id", "Apim-Request-Id", "X-Goog-Request-Id"} {
+		if id := h.Get(key); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// backoff returns a jittered exponential delay for the given attempt
+func backoff(attempt int) time.Duration {
+	d := baseBackoff << (attempt - 1)
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// parseRetryAfter handles both the delay-seconds and HTTP-date forms
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
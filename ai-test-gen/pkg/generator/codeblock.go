@@ -0,0 +1,145 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/codefence"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// fencedBlock is a fenced code block of a response
+type fencedBlock struct {
+	// lang is the info string after the opening fence, e.g. "go"
+	lang string
+	body string
+}
+
+// fencedBlocks returns the fenced code blocks of text in order. A block is
+// closed by a line holding only a fence at least as long as the one that
+// opened it, so shorter runs of backticks inside the code, as in raw
+// strings, don't end it. A block left open by a truncated response runs to
+// the end of text. Windows line endings are converted.
+func fencedBlocks(text string) []fencedBlock {
+	var blocks []fencedBlock
+	var body []string
+	fence, lang := "", ""
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" {
+			if n := codefence.Len(trimmed); n > 0 {
+				fence, lang = trimmed[:n], strings.ToLower(strings.TrimSpace(trimmed[n:]))
+				body = body[:0]
+			}
+			continue
+		}
+		if codefence.Closes(trimmed, fence) {
+			blocks = append(blocks, fencedBlock{lang: lang, body: strings.Join(body, "\n") + "\n"})
+			fence = ""
+			continue
+		}
+		body = append(body, line)
+	}
+	if fence != "" && len(body) > 0 {
+		blocks = append(blocks, fencedBlock{lang: lang, body: strings.Join(body, "\n") + "\n"})
+	}
+	return blocks
+}
+
+// extractCodeBlock returns the code of a response. Go blocks are preferred
+// over blocks in other languages; several Go files are merged into one, with
+// snippets that lack a package clause appended to it. A response without
+// fences is returned from its package clause on, without the prose around it.
+func extractCodeBlock(content string) string {
+	blocks := fencedBlocks(content)
+	if len(blocks) == 0 {
+		return stripProse(content)
+	}
+	var code []string
+	for _, b := range blocks {
+		if b.lang == "go" || b.lang == "golang" || b.lang == "" {
+			code = append(code, b.body)
+		}
+	}
+	if len(code) == 0 {
+		return blocks[0].body
+	}
+	if len(code) == 1 {
+		return code[0]
+	}
+
+	var files, snippets []string
+	for _, c := range code {
+		if hasPackageClause(c) {
+			files = append(files, c)
+		} else {
+			snippets = append(snippets, c)
+		}
+	}
+	if len(files) == 0 {
+		return strings.Join(code, "\n")
+	}
+	merged := files[0]
+	if len(files) > 1 {
+		m, err := source.MergeTests(files)
+		if err != nil {
+			// the files don't fit together; the longest is the likeliest answer
+			return longest(files)
+		}
+		merged = m
+	}
+	for _, s := range snippets {
+		merged = strings.TrimRight(merged, "\n") + "\n\n" + s
+	}
+	return merged
+}
+
+// stripProse cuts the explanations a model wrote around an unfenced Go file:
+// the lines before its package clause and after its last closing brace
+func stripProse(content string) string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "package ") {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		return strings.Join(lines, "\n")
+	}
+	end := len(lines)
+	for i := len(lines) - 1; i > start; i-- {
+		if strings.HasPrefix(lines[i], "}") || strings.HasPrefix(lines[i], ")") {
+			end = i + 1
+			break
+		}
+	}
+	return strings.Join(lines[start:end], "\n") + "\n"
+}
+
+func hasPackageClause(code string) bool {
+	for _, line := range strings.Split(code, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "package ") {
+			return true
+		}
+	}
+	return false
+}
+
+func longest(texts []string) string {
+	best := ""
+	for _, t := range texts {
+		if len(t) > len(best) {
+			best = t
+		}
+	}
+	return best
+}
+
+// parseCheck returns the first syntax error of a Go file, or nil
+func parseCheck(code string) error {
+	_, err := parser.ParseFile(token.NewFileSet(), "generated_test.go", code, parser.SkipObjectResolution)
+	return err
+}
@@ -0,0 +1,115 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Severities of a review finding
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// ReviewPrompt is the instruction preamble sent when reviewing code. It can
+// be replaced to customise the review.
+var ReviewPrompt = `You are an expert Go code reviewer. Review the following Go code and report actionable findings:
+1. Bugs and incorrect behavior, including nil dereferences, off-by-one errors and unchecked errors
+2. Security issues such as injection, unsafe input handling and leaked secrets
+3. Concurrency problems: data races, leaked goroutines, deadlocks
+4. Performance problems with a clear fix
+5. Maintainability problems that make the code hard to change safely
+
+Do not report matters of taste, formatting handled by gofmt, or anything you are unsure of. Each finding must point at the lines it is about, using the line numbers shown before each line, and suggest a concrete fix.
+
+Respond with a JSON array only, one object per finding:
+{"severity": "error|warning|info", "category": "bug|security|concurrency|performance|error-handling|maintainability", "start_line": 1, "end_line": 1, "message": "what is wrong and why it matters", "suggestion": "how to fix it"}
+Respond with [] when there is nothing worth reporting.`
+
+// Finding is an issue reported by ReviewCode
+type Finding struct {
+	// Severity is SeverityError, SeverityWarning or SeverityInfo
+	Severity string `json:"severity"`
+	Category string `json:"category"`
+	// StartLine and EndLine are the lines the finding is about, starting
+	// at 1
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// ReviewRequestPrompt returns the prompt ReviewCode sends for the file at
+// path. When diff is not empty only the changes it shows are reviewed.
+func ReviewRequestPrompt(path, code, diff string) string {
+	var sb strings.Builder
+	sb.WriteString(ReviewPrompt)
+	if diff != "" {
+		sb.WriteString("\n\nOnly report findings in the lines changed by the diff below, or caused by them; the full file is given for context.\n\nDiff:\n")
+		sb.WriteString(diff)
+	}
+	fmt.Fprintf(&sb, "\n\nFile %s:\n", path)
+	for i, line := range strings.Split(strings.TrimSuffix(code, "\n"), "\n") {
+		fmt.Fprintf(&sb, "%4d  %s\n", i+1, line)
+	}
+	return sb.String()
+}
+
+// ReviewCode asks the provider to review the file at path, or the changes
+// diff makes to it, and returns the findings in the order reported. Line
+// ranges are kept within the file.
+func ReviewCode(ctx context.Context, path, code, diff string, p Provider) ([]Finding, error) {
+	text, err := p.Generate(ctx, ReviewRequestPrompt(path, code, diff))
+	if err != nil {
+		return nil, err
+	}
+
+	start := strings.Index(text, "[")
+	end := strings.LastIndex(text, "]")
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("no JSON array in response")
+	}
+	var findings []Finding
+	if err := json.Unmarshal([]byte(text[start:end+1]), &findings); err != nil {
+		return nil, fmt.Errorf("decode findings: %w", err)
+	}
+
+	lines := strings.Count(strings.TrimSuffix(code, "\n"), "\n") + 1
+	for i := range findings {
+		f := &findings[i]
+		f.Severity = normalizeSeverity(f.Severity)
+		f.StartLine = min(max(f.StartLine, 1), lines)
+		f.EndLine = min(max(f.EndLine, f.StartLine), lines)
+	}
+	return findings, nil
+}
+
+// normalizeSeverity maps the severities models use besides the requested
+// ones, defaulting to SeverityWarning
+func normalizeSeverity(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "error", "critical", "high", "blocker":
+		return SeverityError
+	case "info", "low", "note", "suggestion", "nit":
+		return SeverityInfo
+	default:
+		return SeverityWarning
+	}
+}
+
+// SeverityRank orders severities, SeverityError highest; unknown severities
+// rank 0
+func SeverityRank(s string) int {
+	switch s {
+	case SeverityError:
+		return 3
+	case SeverityWarning:
+		return 2
+	case SeverityInfo:
+		return 1
+	}
+	return 0
+}
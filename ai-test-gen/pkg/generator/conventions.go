@@ -0,0 +1,53 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// conventionsInstructions asks for tests following c, which fixConventions
+// then enforces on whatever comes back
+func conventionsInstructions(c source.Conventions) string {
+	var rules []string
+	switch c.SubtestNames {
+	case source.SubtestSnake:
+		rules = append(rules, `Name subtests in snake_case, e.g. "empty_input".`)
+	case source.SubtestSentence:
+		rules = append(rules, `Name subtests with short lowercase sentences, e.g. "empty input".`)
+	case source.SubtestCamel:
+		rules = append(rules, `Name subtests in CamelCase, e.g. "EmptyInput".`)
+	}
+	if c.Parallel != nil {
+		if *c.Parallel {
+			rules = append(rules, "Call t.Parallel() at the start of every test and subtest, and keep them independent of each other. "+
+				"Tests that must change environment variables, the working directory or package level variables must not call t.Parallel(); "+
+				"use t.Setenv, t.Chdir and t.TempDir rather than os.Setenv, os.Chdir and os.TempDir.")
+		} else {
+			rules = append(rules, "Do not call t.Parallel().")
+		}
+	}
+	switch c.Assertions {
+	case source.AssertionsAssert:
+		rules = append(rules, "Use testify's assert package for all assertions, not require.")
+	case source.AssertionsRequire:
+		rules = append(rules, "Use testify's require package for all assertions, not assert.")
+	}
+	if len(rules) == 0 {
+		return ""
+	}
+	return "\n\nFollow the project's test conventions:\n- " + strings.Join(rules, "\n- ")
+}
+
+// fixConventions enforces c on code and takes t.Parallel() out of the tests
+// that change process-wide state, whatever the conventions. Code that
+// doesn't parse is left to the compile-and-repair loop.
+func fixConventions(code string, c source.Conventions) string {
+	if fixed, err := source.ApplyConventions(code, c); err == nil {
+		code = fixed
+	}
+	if fixed, err := source.SerializeSharedTests(code); err == nil {
+		code = fixed
+	}
+	return code
+}
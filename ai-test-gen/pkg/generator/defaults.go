@@ -0,0 +1,60 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultPrompts maps the names prompt packs use to the instruction
+// preambles they replace
+var DefaultPrompts = map[string]*string{
+	"tests":             &SystemPrompt,
+	"docs":              &DocPrompt,
+	"doc_comments":      &DocCommentPrompt,
+	"package_docs":      &PackageDocPrompt,
+	"package_doc_part":  &PackageDocPartPrompt,
+	"package_doc_merge": &PackageDocMergePrompt,
+	"table_cases":       &TableCasesPrompt,
+	"bench":             &BenchPrompt,
+	"examples":          &ExamplePrompt,
+	"explain":           &ExplainPrompt,
+	"review":            &ReviewPrompt,
+	"suggest":           &SuggestPrompt,
+	"openapi":           &OpenAPIPrompt,
+	"diagram":           &DiagramPrompt,
+	"readme":            &ReadmePrompt,
+	"changelog":         &ChangelogPrompt,
+}
+
+// PromptNames returns the names of DefaultPrompts, sorted
+func PromptNames() []string {
+	names := make([]string, 0, len(DefaultPrompts))
+	for name := range DefaultPrompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Providers returns the names of the supported providers, sorted
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetDefaultModel makes model the one used for provider when none is
+// configured, e.g. as pinned by a prompt pack
+func SetDefaultModel(provider, model string) error {
+	info, ok := providers[strings.ToLower(provider)]
+	if !ok {
+		return fmt.Errorf("unknown provider %q", provider)
+	}
+	info.defaultModel = model
+	providers[strings.ToLower(provider)] = info
+	return nil
+}
@@ -0,0 +1,34 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+)
+
+// DiagramPrompt is the instruction preamble sent when drawing architecture
+// diagrams. %s is replaced by the diagram language, mermaid or plantuml. It
+// can be replaced to customise the diagrams.
+var DiagramPrompt = `You are an expert software architect documenting a Go module. Draw architecture diagrams of the module summarized below in %[1]s.
+Include:
+1. A package dependency graph showing which packages of the module import which
+2. A class diagram of the key types: their relationships (embedding, fields holding other types, interfaces) and their main methods
+3. If the module serves HTTP, a sequence or flow diagram of how a request travels from the routes through the handlers to the packages doing the work
+
+Leave out packages and types that add nothing to the picture, such as test helpers, so that each diagram stays readable. Only draw what the summary shows; do not invent packages, types or routes.
+Give each diagram a short Markdown heading and one or two sentences explaining it, and put the diagram itself in a fenced ` + "```%[1]s" + ` block. Respond with the Markdown only.`
+
+// DiagramRequestPrompt returns the prompt GenerateDiagrams sends for an
+// architecture summary and diagram language
+func DiagramRequestPrompt(summary, format string) string {
+	return fmt.Sprintf(DiagramPrompt, format) + "\n\nArchitecture summary:\n" + summary
+}
+
+// GenerateDiagrams asks the provider for Markdown with diagrams, in format,
+// of the module described by summary
+func GenerateDiagrams(ctx context.Context, summary, format string, p Provider) (string, error) {
+	text, err := p.Generate(ctx, DiagramRequestPrompt(summary, format))
+	if err != nil {
+		return "", err
+	}
+	return unwrapMarkdown(text), nil
+}
@@ -1,99 +1,115 @@
 package generator
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"maps"
+	"slices"
+	"strings"
 )
 
-// Request and response structures for Gemini API
-type geminiRequest struct {
-	Contents []content `json:"contents"`
-}
+// DocPrompt is the instruction preamble sent with every documentation
+// request. It can be replaced to customise the generated documentation.
+var DocPrompt = `You are an expert Go documentation generator. Generate comprehensive, professional documentation for the following Go code. 
+Include:
+1. Package overview
+2. Function descriptions with parameters and return values
+3. Type definitions and their purposes
+4. Usage examples where appropriate
+5. Any important notes about the code's behavior
 
-type content struct {
-	Parts []part `json:"parts"`
+Format the output in Markdown with proper headings and code blocks.`
+
+// GenerateDocumentation generates documentation for Go code using the given provider
+func GenerateDocumentation(ctx context.Context, code string, p Provider) (string, error) {
+	return p.Generate(ctx, DocumentationPrompt(code))
 }
 
-type part struct {
-	Text string `json:"text"`
+// DocumentationPrompt returns the prompt GenerateDocumentation sends for code
+func DocumentationPrompt(code string) string {
+	return documentationPrompt(DocPrompt, code)
 }
 
-type geminiResponse struct {
-	Candidates []candidate `json:"candidates"`
+// CustomDocumentationPrompt returns the prompt sent for code with preamble
+// replacing DocPrompt, as set by WithDocPrompt, or DocPrompt when it is empty
+func CustomDocumentationPrompt(preamble, code string) string {
+	if preamble == "" {
+		preamble = DocPrompt
+	}
+	return documentationPrompt(preamble, code)
 }
 
-type candidate struct {
-	Content content `json:"content"`
+func documentationPrompt(preamble, code string) string {
+	return preamble + languageInstruction() + "\n\nGo code:\n" + code
 }
 
-// GenerateDocumentation generates documentation for Go code using Gemini API
-func GenerateDocumentation(code, apiKey string) (string, error) {
-	// Construct the prompt
-	prompt := fmt.Sprintf(`You are an expert Go documentation generator. Generate comprehensive, professional documentation for the following Go code. 
-Include:
-1. Package overview
-2. Function descriptions with parameters and return values
-3. Type definitions and their purposes
-4. Usage examples where appropriate
-5. Any important notes about the code's behavior
+// DocLanguages maps the codes of the languages documentation can be written
+// in to their English names
+var DocLanguages = map[string]string{
+	"ar": "Arabic",
+	"de": "German",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"pt": "Portuguese",
+	"ru": "Russian",
+	"zh": "Simplified Chinese",
+}
 
-Format the output in Markdown with proper headings and code blocks.
+// DocLanguageCodes returns the codes of DocLanguages, sorted
+func DocLanguageCodes() []string {
+	return slices.Sorted(maps.Keys(DocLanguages))
+}
 
-Go code:
-%s`, code)
+// DocLanguage is the code, one of DocLanguages, of the language the prose of
+// documentation and doc comments is written in. Empty means English.
+var DocLanguage string
 
-	// Create the request payload
-	reqBody := geminiRequest{
-		Contents: []content{
-			{
-				Parts: []part{
-					{Text: prompt},
-				},
-			},
-		},
+// languageInstruction returns the prompt paragraph asking for prose in
+// DocLanguage, or "" for English
+func languageInstruction() string {
+	name, ok := DocLanguages[DocLanguage]
+	if !ok || DocLanguage == "en" {
+		return ""
 	}
+	return fmt.Sprintf("\n\nWrite all prose (descriptions, headings and explanations) in %s. Keep code, code samples, identifiers, package paths and Go keywords exactly as they are in Go, untranslated.", name)
+}
 
-	// Marshal to JSON
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("error marshaling request: %v", err)
-	}
+// DocCommentPrompt is the instruction preamble sent when generating inline
+// godoc comments
+var DocCommentPrompt = `You are an expert Go developer. Write idiomatic godoc comments for the listed declarations of the following Go code.
+Each comment must:
+1. Be a complete sentence starting with the declared name (for methods, the method name only)
+2. Describe what the declaration does or represents, not how it is implemented
+3. Be concise: one or two sentences unless the behavior needs more
 
-	// Create HTTP request
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/gemini-2.0-flash:generateContent?key=%s", apiKey)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", fmt.Errorf("error creating request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
+Respond with a single JSON object mapping each listed name to its comment text, without // markers. Do not output any explanations.`
 
-	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("error sending request: %v", err)
-	}
-	defer resp.Body.Close()
+// DocCommentsPrompt returns the prompt GenerateDocComments sends for code
+func DocCommentsPrompt(code string, names []string) string {
+	return DocCommentPrompt + languageInstruction() + "\n\nDeclarations to document:\n- " + strings.Join(names, "\n- ") + "\n\nGo code:\n" + code
+}
 
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API error: %s - %s", resp.Status, string(body))
+// GenerateDocComments asks the provider for godoc comments for the named
+// declarations in code. The result maps each name to its comment text.
+func GenerateDocComments(ctx context.Context, code string, names []string, p Provider) (map[string]string, error) {
+	text, err := p.Generate(ctx, DocCommentsPrompt(code, names))
+	if err != nil {
+		return nil, err
 	}
 
-	// Parse response
-	var geminiResp geminiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
-		return "", fmt.Errorf("error decoding response: %v", err)
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("no JSON object in response")
 	}
-
-	// Extract generated text
-	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no content generated")
+	var comments map[string]string
+	if err := json.Unmarshal([]byte(text[start:end+1]), &comments); err != nil {
+		return nil, fmt.Errorf("decode comments: %w", err)
 	}
-
-	return geminiResp.Candidates[0].Content.Parts[0].Text, nil
+	return comments, nil
 }
@@ -0,0 +1,27 @@
+package generator
+
+import "context"
+
+// ExamplePrompt is the instruction preamble sent with every example
+// generation request
+var ExamplePrompt = `You are an expert Go developer. Write runnable godoc examples for the exported API of the provided Go code. Your output MUST be valid, compilable, idiomatic Go code. Include:
+1. ExampleXxx functions named after the documented identifier (ExampleFunc, ExampleType, ExampleType_Method, with a _suffix for several examples of the same identifier)
+2. Short, realistic usage that reads well as documentation
+3. Results printed with fmt.Println and a trailing // Output: comment containing exactly what is printed
+4. Deterministic output only: no times, random values, map iteration order or pointers
+5. The same package declaration as the code, so unexported helpers need no import
+6. No TestXxx functions
+7. Do not output any explanations, only the code block.`
+
+// ExampleOptions returns the options used to generate and repair examples
+func ExampleOptions() TestOptions {
+	return TestOptions{Prompt: ExamplePrompt}
+}
+
+// GenerateExamples asks the provider to write ExampleXxx functions for the given Go code
+func GenerateExamples(ctx context.Context, code string, p Provider) (string, error) {
+	opts := ExampleOptions()
+	fullPrompt := opts.prompt() + "\n\nWrite examples for this Go code:\n\n" + code
+
+	return generateTests(ctx, fullPrompt, p, opts)
+}
@@ -0,0 +1,37 @@
+package generator
+
+import "context"
+
+// ExplainPrompt is the instruction preamble sent when explaining code to a
+// reader who doesn't know it. It can be replaced to customise the
+// explanations.
+var ExplainPrompt = `You are an experienced Go developer walking a colleague through code they have never seen, for example while reviewing a change to it. Explain the following Go code in plain language.
+Cover:
+1. What it is for and how the pieces fit together, in a short overview
+2. How it works, step by step for anything non-obvious
+3. Complexity: the time and memory cost of the main operations, and anything that grows with the input
+4. Side effects: I/O, global or shared state, goroutines, locks, and mutation of arguments
+5. Error paths: which errors can occur, where they come from, and how they are returned, wrapped or ignored
+6. Anything surprising or risky a reviewer should look at closely
+
+Explain rather than document: don't restate signatures, and only quote code where it helps. Format the output in Markdown with short headings.`
+
+// ExplainRequestPrompt returns the prompt Explain sends for code, focused on
+// the function fn (Name or Type.Method) unless fn is ""
+func ExplainRequestPrompt(code, fn string) string {
+	prompt := ExplainPrompt
+	if fn != "" {
+		prompt += "\n\nExplain only " + fn + "; the rest of the file is there for context."
+	}
+	return documentationPrompt(prompt, code)
+}
+
+// Explain asks the provider for a Markdown explanation of code, or of its
+// function fn unless fn is ""
+func Explain(ctx context.Context, code, fn string, p Provider) (string, error) {
+	text, err := p.Generate(ctx, ExplainRequestPrompt(code, fn))
+	if err != nil {
+		return "", err
+	}
+	return unwrapMarkdown(text), nil
+}
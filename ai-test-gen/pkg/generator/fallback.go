@@ -0,0 +1,53 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// FallbackProvider is one provider of a fallback chain
+type FallbackProvider struct {
+	Provider
+	// Name identifies the provider in logs, e.g. ollama/llama3
+	Name string
+}
+
+// Fallback returns a provider that sends prompts to the first of chain and,
+// when a request fails after its retries, repeats it with the next one. The
+// chain stays on the provider that answered for the following prompts, so a
+// persistently failing provider is only tried once. The error of the last
+// provider is returned when all fail.
+func Fallback(chain []FallbackProvider) Provider {
+	if len(chain) == 1 {
+		return chain[0].Provider
+	}
+	return &fallbackProvider{chain: chain}
+}
+
+type fallbackProvider struct {
+	chain []FallbackProvider
+	mu    sync.Mutex
+	// current is the index of the provider prompts go to first
+	current int
+}
+
+func (f *fallbackProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	f.mu.Lock()
+	i := f.current
+	f.mu.Unlock()
+
+	for {
+		text, err := f.chain[i].Generate(ctx, prompt)
+		if err == nil || ctx.Err() != nil || errors.Is(err, context.Canceled) || i == len(f.chain)-1 {
+			return text, err
+		}
+		next := f.chain[i+1]
+		slog.WarnContext(ctx, "provider failed, falling back", "provider", f.chain[i].Name, "next", next.Name, "err", err)
+		i++
+		f.mu.Lock()
+		f.current = max(f.current, i)
+		f.mu.Unlock()
+	}
+}
@@ -0,0 +1,105 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// Supported test frameworks
+const (
+	FrameworkStdlib  = "stdlib"
+	FrameworkTestify = "testify"
+	FrameworkGinkgo  = "ginkgo"
+)
+
+// Frameworks lists the accepted --framework values
+var Frameworks = []string{FrameworkStdlib, FrameworkTestify, FrameworkGinkgo}
+
+// ValidateFramework returns an error for unknown framework names
+func ValidateFramework(name string) error {
+	switch name {
+	case "", FrameworkStdlib, FrameworkTestify, FrameworkGinkgo:
+		return nil
+	}
+	return fmt.Errorf("unknown framework %q (expected %s, %s or %s)", name, FrameworkStdlib, FrameworkTestify, FrameworkGinkgo)
+}
+
+func frameworkInstructions(framework string) string {
+	switch framework {
+	case FrameworkTestify:
+		return `
+
+Use github.com/stretchr/testify instead of bare testing assertions: use require for preconditions and errors that must stop the test, and assert for value comparisons. Do not use t.Errorf or t.Fatalf for assertions.`
+	case FrameworkGinkgo:
+		return `
+
+Instead of TestXxx functions, write Ginkgo v2 specs (Describe, Context, It, and DescribeTable/Entry for table-driven cases) with Gomega matchers, using dot imports of github.com/onsi/ginkgo/v2 and github.com/onsi/gomega. Do NOT include a TestXxx function calling RunSpecs; the suite bootstrap is provided separately. Wrap the specs in var _ = Describe(...).`
+	default:
+		return ""
+	}
+}
+
+var (
+	assertUse  = regexp.MustCompile(`\bassert\.`)
+	requireUse = regexp.MustCompile(`\brequire\.`)
+	runSpecs   = regexp.MustCompile(`(?s)func Test\w*\(t \*testing\.T\) \{\s*RegisterFailHandler\(Fail\)\s*RunSpecs\(t, [^)]*\)\s*\}\n?`)
+)
+
+// applyFramework fixes up what the model commonly gets wrong for the chosen
+// framework: missing imports and, for Ginkgo, per-file suite bootstraps that
+// would clash with the package suite
+func applyFramework(code, framework string) string {
+	var imports []string
+	switch framework {
+	case FrameworkTestify:
+		if assertUse.MatchString(code) {
+			imports = append(imports, `"github.com/stretchr/testify/assert"`)
+		}
+		if requireUse.MatchString(code) {
+			imports = append(imports, `"github.com/stretchr/testify/require"`)
+		}
+	case FrameworkGinkgo:
+		code = runSpecs.ReplaceAllString(code, "")
+		imports = append(imports, `. "github.com/onsi/ginkgo/v2"`, `. "github.com/onsi/gomega"`)
+	default:
+		return code
+	}
+
+	if fixed, err := source.AddImports(code, imports); err == nil {
+		return fixed
+	}
+	// leave unparsable output for the compile-and-repair loop
+	return code
+}
+
+// GinkgoSuite returns the bootstrap file that runs the Ginkgo specs of a package
+func GinkgoSuite(pkg string) string {
+	return fmt.Sprintf(`package %s
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func Test%sSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, %q)
+}
+`, pkg, exportName(strings.TrimSuffix(pkg, "_test")), pkg+" suite")
+}
+
+func exportName(pkg string) string {
+	if pkg == "" {
+		return ""
+	}
+	b := []byte(pkg)
+	if b[0] >= 'a' && b[0] <= 'z' {
+		b[0] -= 'a' - 'A'
+	}
+	return string(b)
+}
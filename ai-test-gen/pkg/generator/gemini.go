@@ -0,0 +1,278 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Gemini API request structures
+type (
+	GeminiRequest struct {
+		Contents         []Content         `json:"contents"`
+		GenerationConfig *GenerationConfig `json:"generationConfig,omitempty"`
+	}
+
+	GenerationConfig struct {
+		Temperature     float64 `json:"temperature"`
+		TopP            float64 `json:"topP,omitempty"`
+		MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+		Seed            *int64  `json:"seed,omitempty"`
+	}
+
+	Content struct {
+		// Role is "user" or "model", needed once a conversation has more
+		// than one turn
+		Role  string `json:"role,omitempty"`
+		Parts []Part `json:"parts"`
+	}
+
+	Part struct {
+		Text string `json:"text"`
+	}
+
+	GeminiResponse struct {
+		Candidates     []Candidate     `json:"candidates"`
+		PromptFeedback *PromptFeedback `json:"promptFeedback,omitempty"`
+		UsageMetadata  *UsageMetadata  `json:"usageMetadata,omitempty"`
+	}
+
+	// PromptFeedback says why a prompt was blocked, when it was
+	PromptFeedback struct {
+		BlockReason   string         `json:"blockReason,omitempty"`
+		SafetyRatings []SafetyRating `json:"safetyRatings,omitempty"`
+	}
+
+	SafetyRating struct {
+		Category    string `json:"category"`
+		Probability string `json:"probability"`
+		Blocked     bool   `json:"blocked,omitempty"`
+	}
+
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	}
+
+	Candidate struct {
+		Content       Content        `json:"content"`
+		FinishReason  string         `json:"finishReason,omitempty"`
+		SafetyRatings []SafetyRating `json:"safetyRatings,omitempty"`
+	}
+)
+
+type geminiProvider struct {
+	client  *apiClient
+	apiKey  string
+	model   ModelInfo
+	baseURL string
+	stream  bool
+}
+
+// maxContinuations is the number of times a response stopped at the output
+// token limit is continued before giving up
+const maxContinuations = 2
+
+// continuePrompt asks for the rest of a response cut at the output token limit
+const continuePrompt = "Your response was cut off at the output token limit. Continue exactly where it stopped, without repeating anything and without any introduction."
+
+// ErrOutputLimit is returned, in a PartialError holding the text received,
+// when a response still reaches the output token limit after being continued
+var ErrOutputLimit = errors.New("response reached the output token limit")
+
+// ErrBlocked is returned when the provider's safety filters refuse a prompt
+// or stop its response
+var ErrBlocked = errors.New("blocked by the provider's safety filters")
+
+func (g *geminiProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	reqBody := GeminiRequest{
+		Contents: []Content{
+			{
+				Role: "user",
+				Parts: []Part{
+					{Text: prompt},
+				},
+			},
+		},
+		GenerationConfig: &GenerationConfig{
+			Temperature:     g.model.Temperature,
+			TopP:            g.model.TopP,
+			MaxOutputTokens: g.model.MaxOutputTokens,
+			Seed:            g.model.Seed,
+		},
+	}
+
+	// a response stopped at the output token limit is continued in the
+	// same conversation and the parts joined
+	var text strings.Builder
+	for i := 0; ; i++ {
+		part, finishReason, err := g.generateContent(ctx, reqBody)
+		text.WriteString(part)
+		if err != nil {
+			var partial *PartialError
+			if text.Len() > 0 && !errors.As(err, &partial) {
+				return "", &PartialError{Text: text.String(), Err: err}
+			}
+			if partial != nil {
+				// the continued parts come before the one cut short
+				partial.Text = text.String() + partial.Text
+			}
+			return "", err
+		}
+		if finishReason != "MAX_TOKENS" {
+			return text.String(), nil
+		}
+		if i == maxContinuations {
+			return "", &PartialError{Text: text.String(), Err: ErrOutputLimit}
+		}
+		reqBody.Contents = append(reqBody.Contents,
+			Content{Role: "model", Parts: []Part{{Text: part}}},
+			Content{Role: "user", Parts: []Part{{Text: continuePrompt}}})
+	}
+}
+
+// generateContent sends one request and returns the text of the first
+// candidate and why it finished
+func (g *geminiProvider) generateContent(ctx context.Context, reqBody GeminiRequest) (string, string, error) {
+	if g.stream {
+		return g.generateStream(ctx, reqBody)
+	}
+
+	url := fmt.Sprintf("%s/models/%s:generateContent", g.baseURL, g.model.Name)
+	var geminiResp GeminiResponse
+	if err := g.client.postJSON(ctx, url, g.headers(), reqBody, &geminiResp); err != nil {
+		return "", "", err
+	}
+
+	var text, finishReason string
+	if len(geminiResp.Candidates) > 0 {
+		c := geminiResp.Candidates[0]
+		finishReason = c.FinishReason
+		for _, p := range c.Content.Parts {
+			text += p.Text
+		}
+	}
+	prompt := promptText(reqBody.Contents)
+	if u := geminiResp.UsageMetadata; u != nil {
+		recordUsage(ctx, prompt, text, u.PromptTokenCount, u.CandidatesTokenCount)
+	} else {
+		recordUsage(ctx, prompt, text, 0, 0)
+	}
+	if err := geminiError(geminiResp, text); err != nil {
+		return "", "", err
+	}
+	return text, finishReason, nil
+}
+
+// promptText returns the text of every turn of a conversation, which the
+// token estimate is made from when the API reports no usage
+func promptText(contents []Content) string {
+	var sb strings.Builder
+	for _, c := range contents {
+		for _, p := range c.Parts {
+			sb.WriteString(p.Text)
+		}
+	}
+	return sb.String()
+}
+
+// geminiError returns why a response, whose first candidate holds text, has
+// no usable content: a prompt blocked by the safety filters, a response
+// stopped by them or for another reason, or no candidate at all
+func geminiError(resp GeminiResponse, text string) error {
+	if fb := resp.PromptFeedback; fb != nil && fb.BlockReason != "" {
+		return fmt.Errorf("the prompt was %w (%s%s); skip the flagged functions with an aitestgen:skip directive or use another provider",
+			ErrBlocked, fb.BlockReason, flaggedCategories(fb.SafetyRatings))
+	}
+	if len(resp.Candidates) == 0 {
+		return fmt.Errorf("no content in API response")
+	}
+	c := resp.Candidates[0]
+	switch c.FinishReason {
+	case "SAFETY", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return fmt.Errorf("the response was %w (%s%s); skip the flagged functions with an aitestgen:skip directive or use another provider",
+			ErrBlocked, c.FinishReason, flaggedCategories(c.SafetyRatings))
+	case "RECITATION":
+		return fmt.Errorf("the response was stopped for reciting existing sources; retry with a higher temperature or use another model")
+	}
+	if text == "" {
+		if c.FinishReason != "" && c.FinishReason != "STOP" {
+			return fmt.Errorf("no content in API response (finish reason %s)", c.FinishReason)
+		}
+		return fmt.Errorf("no content in API response")
+	}
+	return nil
+}
+
+// flaggedCategories lists the harm categories of ratings that were blocked or
+// rated a medium or high probability, e.g. ", flagged: dangerous content"
+func flaggedCategories(ratings []SafetyRating) string {
+	var flagged []string
+	for _, r := range ratings {
+		if r.Blocked || r.Probability == "MEDIUM" || r.Probability == "HIGH" {
+			category := strings.TrimPrefix(r.Category, "HARM_CATEGORY_")
+			flagged = append(flagged, strings.ToLower(strings.ReplaceAll(category, "_", " ")))
+		}
+	}
+	if len(flagged) == 0 {
+		return ""
+	}
+	return ", flagged: " + strings.Join(flagged, ", ")
+}
+
+// headers authenticate with the x-goog-api-key header rather than the key
+// query parameter, which ends up in proxy and server logs
+func (g *geminiProvider) headers() map[string]string {
+	return map[string]string{"x-goog-api-key": g.apiKey}
+}
+
+// generateStream uses streamGenerateContent, whose server-sent events each
+// carry a GeminiResponse with the next part of the text
+func (g *geminiProvider) generateStream(ctx context.Context, reqBody GeminiRequest) (string, string, error) {
+	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", g.baseURL, g.model.Name)
+	buf := newStreamBuffer(ctx)
+	var (
+		usage UsageMetadata
+		// last gathers the finish reason, safety feedback and ratings
+		// reported along the stream
+		last = GeminiResponse{Candidates: []Candidate{{}}}
+	)
+	err := g.client.postStream(ctx, url, g.headers(), reqBody, func(line []byte) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		var chunk GeminiResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("error decoding stream: %w", err)
+		}
+		if chunk.UsageMetadata != nil {
+			usage = *chunk.UsageMetadata
+		}
+		if chunk.PromptFeedback != nil {
+			last.PromptFeedback = chunk.PromptFeedback
+		}
+		for _, c := range chunk.Candidates {
+			for _, p := range c.Content.Parts {
+				buf.add(p.Text)
+			}
+			if c.FinishReason != "" {
+				last.Candidates[0].FinishReason = c.FinishReason
+			}
+			if len(c.SafetyRatings) > 0 {
+				last.Candidates[0].SafetyRatings = c.SafetyRatings
+			}
+		}
+		return nil
+	})
+	recordUsage(ctx, promptText(reqBody.Contents), buf.text(), usage.PromptTokenCount, usage.CandidatesTokenCount)
+	if err == nil {
+		if err = geminiError(last, buf.text()); err != nil {
+			return "", "", err
+		}
+	}
+	text, err := buf.result(err)
+	return text, last.Candidates[0].FinishReason, err
+}
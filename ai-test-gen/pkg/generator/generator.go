@@ -1,116 +1,245 @@
 package generator
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
 )
 
-const systemPrompt = `You are an expert Go developer. Generate comprehensive unit tests for the provided Go function using the standard testing package. Your output MUST be valid, compilable, idiomatic Go code, free of syntax errors, and ready to use. Do NOT output broken, incomplete, or partial tests. Include:
+// SystemPrompt is the instruction preamble sent with every test generation
+// request. It can be replaced to customise the generated tests.
+var SystemPrompt = `You are an expert Go developer. Generate comprehensive unit tests for the provided Go function using the standard testing package. Your output MUST be valid, compilable, idiomatic Go code, free of syntax errors, and ready to use. Do NOT output broken, incomplete, or partial tests. Include:
 1. Table-driven tests with subtests
 2. Edge cases and boundary conditions
 3. Descriptive test names (TestFunctionNameCase)
 4. Error cases where applicable
 5. Only output valid Go test code with package declaration
-6. Include benchmark stubs (BenchmarkXxx) where applicable
-7. Prefer table-driven tests
-8. Cover zero-value inputs
-9. Test error returns
-10. Make sure you are importing just the packages you are using
-11. Do not output any explanations, only the code block.`
-
-// Gemini API request structures
-type (
-	GeminiRequest struct {
-		Contents []Content `json:"contents"`
-	}
+6. Prefer table-driven tests
+7. Cover zero-value inputs
+8. Test error returns
+9. Make sure you are importing just the packages you are using
+10. Do not output any explanations, only the code block.`
+
+// TestOptions controls how tests are generated
+type TestOptions struct {
+	// Framework is one of the Framework constants; empty means stdlib
+	Framework string
+	// Mocks is the source of mock implementations available to the tests
+	Mocks string
+	// Prompt replaces SystemPrompt when set
+	Prompt string
+	// Instructions are added to the prompt, e.g. a reviewer's feedback on
+	// previously generated tests
+	Instructions string
+	// PackageContext holds declarations from the other files of the
+	// package that the code under test refers to
+	PackageContext string
+	// BlackBox asks for tests in the external _test package that only use
+	// the exported API
+	BlackBox bool
+	// HTTPHandlers names the functions of the code that are HTTP handlers,
+	// to be tested with net/http/httptest
+	HTTPHandlers []string
+	// DBMock names the functions of the code that use a database, to be
+	// tested with go-sqlmock
+	DBMock []string
+	// GRPCServices are the gRPC servers implemented by the code, to be
+	// tested over an in-memory connection, and GRPCContext the declarations
+	// of their generated code
+	GRPCServices []source.GRPCService
+	GRPCContext  string
+	// Golden names the functions of the code to be tested against golden
+	// files under testdata, rewritten with go test -update
+	Golden []string
+	// Property names the pure functions of the code to be given property
+	// based tests with rapid
+	Property []string
+	// Generics are the generic functions of the code, to be tested through
+	// their listed instantiations
+	Generics []source.Generic
+	// StyleExamples are existing tests of the project whose style the new
+	// tests follow
+	StyleExamples []source.StyleExample
+	// Conventions are the project's rules for test layout, enforced on the
+	// generated code
+	Conventions source.Conventions
+	// Imports are the packages the tests may import
+	Imports source.ImportPolicy
+	// Integration are the services the code uses, to be given integration
+	// tests against containers behind the IntegrationTag build tag (default
+	// IntegrationTag). Migrations is the directory of SQL migrations to
+	// apply to Postgres, relative to the package.
+	Integration    []source.Service
+	IntegrationTag string
+	Migrations     string
+}
 
-	Content struct {
-		Parts []Part `json:"parts"`
+// integrationTag returns the build tag of integration tests
+func (o TestOptions) integrationTag() string {
+	if o.IntegrationTag == "" {
+		return IntegrationTag
 	}
+	return o.IntegrationTag
+}
 
-	Part struct {
-		Text string `json:"text"`
+// prompt returns the instruction preamble for these options
+func (o TestOptions) prompt() string {
+	prompt := SystemPrompt
+	if o.Prompt != "" {
+		prompt = o.Prompt
 	}
-
-	GeminiResponse struct {
-		Candidates []Candidate `json:"candidates"`
+	prompt += frameworkInstructions(o.Framework)
+	if o.BlackBox {
+		prompt += "\n\nWrite black-box tests: put them in the external test package (the package name with a _test " +
+			"suffix), import the package under test, and only use its exported identifiers, qualified with the package name."
 	}
-
-	Candidate struct {
-		Content Content `json:"content"`
+	prompt += httpInstructions(o.HTTPHandlers)
+	prompt += sqlMockInstructions(o.DBMock)
+	prompt += grpcInstructions(o.GRPCServices, o.GRPCContext)
+	prompt += goldenInstructions(o.Golden)
+	prompt += propertyInstructions(o.Property)
+	prompt += genericsInstructions(o.Generics)
+	prompt += integrationInstructions(o.Integration, o.integrationTag(), o.Migrations)
+	prompt += conventionsInstructions(o.Conventions)
+	prompt += importsInstructions(o.Imports)
+	if o.Instructions != "" {
+		prompt += "\n\nAdditional instructions:\n" + o.Instructions
 	}
-)
-
-func GenerateUnitTests(code, apiKey string) (string, error) {
-	fullPrompt := systemPrompt + "\n\nGenerate tests for this Go function:\n\n" + code
-
-	// Create Gemini API request
-	reqBody := GeminiRequest{
-		Contents: []Content{
-			{
-				Parts: []Part{
-					{Text: fullPrompt},
-				},
-			},
-		},
+	if o.Mocks != "" {
+		prompt += "\n\nThe following mocks already exist in the package's test files. Use them for interface " +
+			"dependencies instead of declaring your own:\n\n" + o.Mocks
 	}
-
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("error marshaling request: %w", err)
+	if o.PackageContext != "" {
+		prompt += "\n\nThe code under test uses these declarations from other files of its package. Only use the " +
+			"types, fields, constructors and functions shown here or in the code itself, and do not redeclare them:\n\n" + o.PackageContext
 	}
+	prompt += styleInstructions(o.StyleExamples)
+	return prompt
+}
 
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/gemini-2.0-flash:generateContent?key=%s", apiKey)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
+// GenerateUnitTests asks the provider to write unit tests for the given Go code
+func GenerateUnitTests(ctx context.Context, code string, p Provider, opts TestOptions) (string, error) {
+	return generateTests(ctx, UnitTestPrompt(code, opts), p, opts)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("API request failed: %w", err)
-	}
-	defer resp.Body.Close()
+// UnitTestPrompt returns the prompt GenerateUnitTests sends for code
+func UnitTestPrompt(code string, opts TestOptions) string {
+	return opts.prompt() + "\n\nGenerate tests for this Go function:\n\n" + code
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API returned %d: %s", resp.StatusCode, string(body))
+// parseRetries is how many times a response that isn't valid Go is asked for
+// again
+const parseRetries = 1
+
+// generateTests sends the prompt and post-processes the returned test file.
+// A response whose code doesn't parse is requested again, with the syntax
+// error, up to parseRetries times.
+func generateTests(ctx context.Context, prompt string, p Provider, opts TestOptions) (string, error) {
+	request := prompt
+	for attempt := 0; ; attempt++ {
+		text, err := p.Generate(ctx, request)
+		if err != nil {
+			return "", err
+		}
+		code := extractCodeBlock(text)
+		err = parseCheck(code)
+		if err == nil {
+			return fixTests(code, opts), nil
+		}
+		if attempt >= parseRetries {
+			return "", fmt.Errorf("response is not a valid Go file: %w", err)
+		}
+		request = prompt + "\n\nA previous response to this request was not a valid Go file (" + err.Error() +
+			"). Respond with the complete test file in a single ```go code block and no other text."
 	}
+}
 
-	var geminiResp GeminiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
-		return "", fmt.Errorf("error decoding response: %w", err)
+// fixTests applies the framework and the fixes for the options to code
+func fixTests(code string, opts TestOptions) string {
+	code = applyFramework(code, opts.Framework)
+	if len(opts.HTTPHandlers) > 0 {
+		code = fixHTTPTests(code)
 	}
-
-	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no content in API response")
+	if len(opts.DBMock) > 0 {
+		code = fixSQLMockTests(code)
+	}
+	if len(opts.GRPCServices) > 0 {
+		code = fixGRPCTests(code)
+	}
+	if len(opts.Property) > 0 {
+		code = fixPropertyTests(code)
 	}
+	if len(opts.Integration) > 0 {
+		code = fixIntegrationTests(code, opts.Integration, opts.integrationTag())
+	}
+	return fixConventions(code, opts.Conventions)
+}
 
-	return extractCodeBlock(geminiResp.Candidates[0].Content.Parts[0].Text), nil
+// RepairUnitTests asks the provider to fix generated tests that failed to compile
+func RepairUnitTests(ctx context.Context, code, tests, compileErrors string, p Provider, opts TestOptions) (string, error) {
+	fullPrompt := opts.prompt() + "\n\nThe following Go test file was generated for the code below but fails to compile. " +
+		"Fix every compiler error and return the complete corrected test file.\n\n" +
+		"Compiler errors:\n\n" + compileErrors +
+		"\n\nTest file:\n\n" + tests +
+		"\n\nCode under test:\n\n" + code
+
+	return generateTests(ctx, fullPrompt, p, opts)
 }
 
-func extractCodeBlock(content string) string {
-	start := strings.Index(content, "```go")
-	if start == -1 {
-		start = strings.Index(content, "```")
-		if start == -1 {
-			return content
-		}
-		start += 3
-	} else {
-		start += 5
-	}
+// FixFailingTests asks the provider to fix generated tests whose assertions fail
+func FixFailingTests(ctx context.Context, code, tests, testOutput string, p Provider, opts TestOptions) (string, error) {
+	fullPrompt := opts.prompt() + "\n\nThe following Go test file was generated for the code below but some tests fail. " +
+		"The code under test is correct; fix the test expectations and return the complete corrected test file.\n\n" +
+		"go test output:\n\n" + testOutput +
+		"\n\nTest file:\n\n" + tests +
+		"\n\nCode under test:\n\n" + code
+
+	return generateTests(ctx, fullPrompt, p, opts)
+}
+
+// FixFlakyTests asks the provider to make tests deterministic that failed
+// when run repeatedly with the race detector, given the go test output
+func FixFlakyTests(ctx context.Context, code, tests, testOutput string, runs int, p Provider, opts TestOptions) (string, error) {
+	fullPrompt := opts.prompt() + fmt.Sprintf("\n\nThe following Go test file was generated for the code below. "+
+		"Its tests pass on their own but some failed or raced when run %d times with go test -race. ", runs) +
+		"Make them deterministic and race-free: synchronise goroutines with sync.WaitGroup or channels instead of sleeps, " +
+		"protect or stop sharing state between parallel tests and subtests, don't depend on map iteration order, timing or " +
+		"the current time, and give each test its own fixtures. Keep the assertions meaningful and return the complete corrected test file.\n\n" +
+		"go test output:\n\n" + testOutput +
+		"\n\nTest file:\n\n" + tests +
+		"\n\nCode under test:\n\n" + code
+
+	return generateTests(ctx, fullPrompt, p, opts)
+}
 
-	end := strings.LastIndex(content, "```")
-	if end <= start {
-		return content
+// GenerateCoverageTests asks the provider for tests that exercise the listed
+// uncovered code. existing holds tests already in the package, whose function
+// names must not be reused; previous is the current content of the file being
+// written, whose tests are kept.
+func GenerateCoverageTests(ctx context.Context, code, existing, previous, gaps string, p Provider, opts TestOptions) (string, error) {
+	fullPrompt := opts.prompt() + "\n\nThe code below is only partially covered by its tests. " +
+		"Write tests that specifically exercise the following uncovered functions and lines:\n\n" + gaps
+	if existing != "" {
+		fullPrompt += "\n\nExisting tests in the package (do not repeat their function names):\n\n" + existing
+	}
+	if previous != "" {
+		fullPrompt += "\n\nReturn the complete file, keeping these tests already in it:\n\n" + previous
 	}
+	fullPrompt += "\n\nCode under test:\n\n" + code
+
+	return generateTests(ctx, fullPrompt, p, opts)
+}
 
-	return content[start:end]
+// GenerateMutantTests asks the provider to strengthen tests so they catch the
+// listed mutations of the code, which the current tests let pass. The
+// complete test file is returned, keeping the existing tests.
+func GenerateMutantTests(ctx context.Context, code, tests, mutants string, p Provider, opts TestOptions) (string, error) {
+	fullPrompt := opts.prompt() + "\n\nThe tests below still pass when the code under test is changed in the following ways, " +
+		"so they do not check its behaviour closely enough. Add test cases or assertions that fail for each of these " +
+		"mutations but pass for the real code:\n\n" + mutants +
+		"\n\nReturn the complete test file, keeping the existing tests:\n\n" + tests +
+		"\n\nCode under test:\n\n" + code
+
+	return generateTests(ctx, fullPrompt, p, opts)
 }
@@ -0,0 +1,38 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// genericsInstructions returns the prompt section for generic functions,
+// listing the instantiations to test each with
+func genericsInstructions(generics []source.Generic) string {
+	if len(generics) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(`
+
+These functions are generic. Test them through concrete instantiations:
+- Instantiate them with explicit type arguments, e.g. Map[int, string](in, f), rather than relying on inference, and declare test table fields with the instantiated types
+- Test every instantiation listed below; these type arguments are known to satisfy the constraints and compile
+- Where no instantiation is listed, the constraint has methods: declare a small named type in the test file that implements it and instantiate with that
+- Share the cases of several instantiations through a generic helper such as func testMap[K comparable, V any](t *testing.T, ...) called from the Test function; Test functions themselves can't have type parameters
+- Never use a type parameter name such as T outside a generic declaration
+
+`)
+	for _, g := range generics {
+		sb.WriteString("- " + g.Signature())
+		if len(g.Instantiations) > 0 {
+			names := make([]string, len(g.Instantiations))
+			for i, args := range g.Instantiations {
+				names[i] = g.Name(args)
+			}
+			sb.WriteString(": " + strings.Join(names, ", "))
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
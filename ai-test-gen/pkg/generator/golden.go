@@ -0,0 +1,23 @@
+package generator
+
+import "strings"
+
+// GoldenFlag is the test flag that rewrites golden files, go test -update
+const GoldenFlag = "update"
+
+// goldenInstructions returns the prompt section for functions tested against
+// golden files
+func goldenInstructions(funcs []string) string {
+	if len(funcs) == 0 {
+		return ""
+	}
+	return `
+
+Test these functions against golden files instead of writing their expected output into the test: ` + strings.Join(funcs, ", ") + `.
+- Declare var update = flag.Bool("` + GoldenFlag + `", false, "update golden files") once in the file
+- In each case, format the result as text: strings and byte slices as they are, other values with json.MarshalIndent(got, "", "  ")
+- The golden file of a case is filepath.Join("testdata", t.Name()+".golden"), so each subtest has its own file
+- When *update is set, create the file's directory and write the formatted result to it; otherwise read the file and compare it with the formatted result, reporting the difference with t.Errorf
+- Keep table-driven cases for the inputs and the error expectations; only the expected outputs move to the golden files
+- Other functions are tested as usual`
+}
@@ -0,0 +1,75 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// grpcImports are the packages gRPC service tests use, by the name they are
+// referred to by
+var grpcImports = []struct {
+	use  *regexp.Regexp
+	path string
+}{
+	{regexp.MustCompile(`\bgrpc\.`), `"google.golang.org/grpc"`},
+	{regexp.MustCompile(`\bbufconn\.`), `"google.golang.org/grpc/test/bufconn"`},
+	{regexp.MustCompile(`\binsecure\.`), `"google.golang.org/grpc/credentials/insecure"`},
+	{regexp.MustCompile(`\bstatus\.(Code|Convert|FromError|Error|Errorf)\(`), `"google.golang.org/grpc/status"`},
+	{regexp.MustCompile(`\bcodes\.`), `"google.golang.org/grpc/codes"`},
+}
+
+// grpcInstructions returns the prompt section for code implementing gRPC
+// services, with the declarations of their generated code in context
+func grpcInstructions(services []source.GRPCService, context string) string {
+	if len(services) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nThe code implements gRPC services generated by protoc-gen-go-grpc:")
+	for _, s := range services {
+		register, client := "Register"+s.Service+"Server", "New"+s.Service+"Client"
+		where := "in the same package"
+		if s.Package != "" {
+			register, client = s.Package+"."+register, s.Package+"."+client
+			where = fmt.Sprintf("in package %s (%q)", s.Package, s.ImportPath)
+		}
+		methods := make([]string, len(s.Methods))
+		for i, m := range s.Methods {
+			methods[i] = m.Name
+		}
+		fmt.Fprintf(&sb, "\n- %s, implemented by %s, registered with %s and called through %s %s; RPCs: %s",
+			s.Service, s.Server, register, client, where, strings.Join(methods, ", "))
+	}
+	sb.WriteString(`
+Test each service end to end over an in-memory connection instead of calling the methods directly:
+- In one setup helper per service, listen with lis := bufconn.Listen(1024 * 1024) (google.golang.org/grpc/test/bufconn), create s := grpc.NewServer(), register the server, run go s.Serve(lis) and stop it with t.Cleanup(s.Stop)
+- Connect with grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }), grpc.WithTransportCredentials(insecure.NewCredentials())), close the connection with t.Cleanup, and return the client
+- Call every RPC through the client with a context with a timeout and assert the responses; for streaming RPCs send and receive on the stream until io.EOF and close the sending side
+- Assert errors by status code: status.Code(err) compared with codes.InvalidArgument, codes.NotFound and the other codes the server returns (google.golang.org/grpc/status and google.golang.org/grpc/codes)`)
+	if context != "" {
+		sb.WriteString("\n\nThe generated code declares:\n\n")
+		sb.WriteString(context)
+	}
+	return sb.String()
+}
+
+// fixGRPCTests adds the gRPC imports tests use but leave out, which
+// goimports may resolve to the wrong packages (status in particular)
+func fixGRPCTests(code string) string {
+	var imports []string
+	for _, imp := range grpcImports {
+		if imp.use.MatchString(code) {
+			imports = append(imports, imp.path)
+		}
+	}
+	if len(imports) == 0 {
+		return code
+	}
+	if fixed, err := source.AddImports(code, imports); err == nil {
+		return fixed
+	}
+	return code
+}
@@ -0,0 +1,31 @@
+package generator
+
+import (
+	"regexp"
+	"strings"
+)
+
+// httpInstructions returns the prompt section for code with HTTP handlers
+func httpInstructions(handlers []string) string {
+	if len(handlers) == 0 {
+		return ""
+	}
+	return `
+
+These functions are HTTP handlers or return one: ` + strings.Join(handlers, ", ") + `. Test them in-process with net/http/httptest, never against a real server or network:
+- Build each request with httptest.NewRequest(method, target, body), which returns only the request and no error; set headers and, for bodies, Content-Type on it
+- Record the response with rec := httptest.NewRecorder() and call the handler directly, or its ServeHTTP method, with rec and the request
+- For functions returning a handler, call them first and serve the request with the returned handler
+- Assert the status code (rec.Code), the relevant headers (rec.Header().Get) and the body (rec.Body.String(), or decode JSON bodies into a value and compare it)
+- Cover each method and route the handler distinguishes, invalid input and error responses, in a table-driven test`
+}
+
+// newRequestErr matches httptest.NewRequest used as if it returned an error,
+// with the check that usually follows it
+var newRequestErr = regexp.MustCompile(`(?m)^(\s*)(\w+), (?:err|_) (:?=) (httptest\.NewRequest(?:WithContext)?\(.*\))\n(?:\s*if err != nil \{[^{}]*\}\n)?`)
+
+// fixHTTPTests corrects what models commonly get wrong in httptest based
+// tests: httptest.NewRequest takes no error, unlike http.NewRequest
+func fixHTTPTests(code string) string {
+	return newRequestErr.ReplaceAllString(code, "$1$2 $3 $4\n")
+}
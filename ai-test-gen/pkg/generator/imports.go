@@ -0,0 +1,47 @@
+package generator
+
+import (
+	"context"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// importsInstructions asks for tests importing only what p allows, which the
+// test writer then checks
+func importsInstructions(p source.ImportPolicy) string {
+	if p.IsZero() {
+		return ""
+	}
+	describe := func(patterns []string) string {
+		names := make([]string, len(patterns))
+		for i, pattern := range patterns {
+			names[i] = pattern
+			if pattern == source.ImportStd {
+				names[i] = "the standard library"
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	var rules []string
+	if len(p.Allow) > 0 {
+		rules = append(rules, "Besides the packages of the module under test, only import these: "+describe(p.Allow)+
+			". Write any helper or assertion you need yourself rather than importing a library for it.")
+	}
+	if len(p.Deny) > 0 {
+		rules = append(rules, "Never import these packages: "+describe(p.Deny)+".")
+	}
+	return "\n\nThe project restricts the imports of tests (a path ending in /... covers the packages below it):\n- " + strings.Join(rules, "\n- ")
+}
+
+// FixDisallowedImports asks the provider to rewrite generated tests without
+// the imports the project doesn't allow
+func FixDisallowedImports(ctx context.Context, code, tests string, disallowed []string, p Provider, opts TestOptions) (string, error) {
+	fullPrompt := opts.prompt() + "\n\nThe following Go test file was generated for the code below but imports packages the project " +
+		"doesn't allow: " + strings.Join(disallowed, ", ") + ". Rewrite the tests without them, using the standard library or code of " +
+		"your own instead, and return the complete corrected test file.\n\n" +
+		"Test file:\n\n" + tests +
+		"\n\nCode under test:\n\n" + code
+
+	return generateTests(ctx, fullPrompt, p, opts)
+}
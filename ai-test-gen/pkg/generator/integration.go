@@ -0,0 +1,103 @@
+package generator
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// TestcontainersModule is the module tests generated with
+// TestOptions.Integration use
+const TestcontainersModule = "github.com/testcontainers/testcontainers-go"
+
+// IntegrationTag is the default build tag integration tests are put behind
+const IntegrationTag = "integration"
+
+// containerModules are the testcontainers-go modules running each service,
+// with the name they are imported as, which keeps them apart from client
+// packages of the same name, and how to start the service and get its address
+var containerModules = map[string]struct{ name, usage string }{
+	source.ServicePostgres: {
+		name:  "tcpostgres",
+		usage: `tcpostgres.Run(ctx, "postgres:16-alpine", tcpostgres.WithDatabase("test"), tcpostgres.WithUsername("test"), tcpostgres.WithPassword("test"), tcpostgres.BasicWaitStrategies()), then ctr.ConnectionString(ctx, "sslmode=disable")`,
+	},
+	source.ServiceRedis: {
+		name:  "tcredis",
+		usage: `tcredis.Run(ctx, "redis:7-alpine"), then ctr.ConnectionString(ctx) for a redis:// URL`,
+	},
+	source.ServiceKafka: {
+		name:  "tckafka",
+		usage: `tckafka.Run(ctx, "confluentinc/confluent-local:7.5.0", tckafka.WithClusterID("test-cluster")), then ctr.Brokers(ctx)`,
+	},
+}
+
+// ContainerModule returns the import path of the testcontainers-go module
+// running service
+func ContainerModule(service string) string {
+	return TestcontainersModule + "/modules/" + service
+}
+
+var testcontainersUse = regexp.MustCompile(`\btestcontainers\.`)
+
+// integrationInstructions returns the prompt section for integration tests
+// against real services, run in containers
+func integrationInstructions(services []source.Service, tag, migrations string) string {
+	if len(services) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nWrite integration tests instead of unit tests: exercise the code against real services started in Docker with " +
+		TestcontainersModule + ", never against mocks or fakes of them. The code uses:\n")
+	for _, s := range services {
+		m := containerModules[s.Name]
+		sb.WriteString("- " + s.Name + " through " + s.Client + ": start it with " + ContainerModule(s.Name) +
+			" (imported as " + m.name + "), " + m.usage + "\n")
+	}
+	sb.WriteString(`Structure the file like this:
+- Start with the line //go:build ` + tag + ` so the tests only run with go test -tags ` + tag + `
+- One setup helper per service, e.g. func setupPostgres(t *testing.T) string, that calls t.Helper(), starts the container with context.Background(), registers its teardown with t.Cleanup(func() { testcontainers.TerminateContainer(ctr) }), and returns the address or connection string; skip the test with t.Skip when the container can't be started because Docker is unavailable
+- Connect the code's own client to that address, closing it with t.Cleanup
+- Keep the tests independent of each other: use unique keys, topics and table rows per test, and never rely on data left by another test
+- Do not call t.Parallel()`)
+	if slices.ContainsFunc(services, func(s source.Service) bool { return s.Name == source.ServicePostgres }) {
+		sb.WriteString(`
+- Apply the schema right after starting Postgres through a migrations hook, declared at package level so the project can replace it:
+  var migrate = func(ctx context.Context, dsn string) error { ... }
+  called by setupPostgres, which fails the test when it returns an error,`)
+		if migrations != "" {
+			sb.WriteString(`
+  by default running, in file name order, the .sql files of the migrations directory ` + migrations + ` (relative to the test's package directory) with database/sql`)
+		} else {
+			sb.WriteString(`
+  by default creating the tables the code queries, inferred from its SQL statements`)
+		}
+	}
+	return sb.String()
+}
+
+// fixIntegrationTests puts the tests behind the build tag and adds the
+// testcontainers imports they use but leave out, which goimports can't
+// always find
+func fixIntegrationTests(code string, services []source.Service, tag string) string {
+	var imports []string
+	if testcontainersUse.MatchString(code) {
+		imports = append(imports, `"`+TestcontainersModule+`"`)
+	}
+	for _, s := range services {
+		name := containerModules[s.Name].name
+		if strings.Contains(code, name+".") {
+			imports = append(imports, name+` "`+ContainerModule(s.Name)+`"`)
+		}
+	}
+	if len(imports) > 0 {
+		if fixed, err := source.AddImports(code, imports); err == nil {
+			code = fixed
+		}
+	}
+	if tagged, err := source.RequireBuildTag(code, tag); err == nil {
+		code = tagged
+	}
+	return code
+}
@@ -0,0 +1,45 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// GenerateLanguageTests asks the provider to write tests for code in another
+// language than Go, following instructions, and returns the code block of
+// the response in one of fences, the info strings of the language
+func GenerateLanguageTests(ctx context.Context, instructions, code string, fences []string, p Provider) (string, error) {
+	text, err := p.Generate(ctx, LanguageTestPrompt(instructions, code))
+	if err != nil {
+		return "", err
+	}
+	tests := extractLanguageBlock(text, fences)
+	if strings.TrimSpace(tests) == "" {
+		return "", errors.New("response holds no code")
+	}
+	return tests, nil
+}
+
+// LanguageTestPrompt returns the prompt GenerateLanguageTests sends for code
+func LanguageTestPrompt(instructions, code string) string {
+	return instructions + "\n\nGenerate tests for this code:\n\n" + code
+}
+
+// extractLanguageBlock returns the first code block of text in one of
+// fences, or else the first without a language, or else the whole text
+func extractLanguageBlock(text string, fences []string) string {
+	blocks := fencedBlocks(text)
+	for _, b := range blocks {
+		if slices.Contains(fences, b.lang) {
+			return b.body
+		}
+	}
+	for _, b := range blocks {
+		if b.lang == "" {
+			return b.body
+		}
+	}
+	return strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n")) + "\n"
+}
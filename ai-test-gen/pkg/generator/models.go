@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"sort"
+	"strings"
+)
+
+// ModelInfo describes a known model and the defaults used when calling it
+type ModelInfo struct {
+	Name     string
+	Provider string
+	// ContextWindow is the maximum number of input tokens
+	ContextWindow int
+	// MaxOutputTokens is the maximum number of tokens the model may generate
+	MaxOutputTokens int
+	Temperature     float64
+	// TopP and Seed are only sent when set, through Config.Sampling
+	TopP float64
+	Seed *int64
+}
+
+// providerInfo holds per-provider defaults
+type providerInfo struct {
+	baseURL      string
+	defaultModel string
+}
+
+var providers = map[string]providerInfo{
+	ProviderGemini:    {baseURL: "https://generativelanguage.googleapis.com/v1beta", defaultModel: "gemini-2.0-flash"},
+	ProviderOpenAI:    {baseURL: "https://api.openai.com/v1", defaultModel: "gpt-4o-mini"},
+	ProviderAnthropic: {baseURL: "https://api.anthropic.com/v1", defaultModel: "claude-3-5-sonnet-latest"},
+	ProviderOllama:    {baseURL: "http://localhost:11434", defaultModel: "llama3"},
+	ProviderAzure:     {},
+}
+
+var models = map[string]ModelInfo{
+	"gemini-2.0-flash":         {Provider: ProviderGemini, ContextWindow: 1048576, MaxOutputTokens: 8192, Temperature: 0.2},
+	"gemini-2.0-flash-lite":    {Provider: ProviderGemini, ContextWindow: 1048576, MaxOutputTokens: 8192, Temperature: 0.2},
+	"gemini-1.5-pro":           {Provider: ProviderGemini, ContextWindow: 2097152, MaxOutputTokens: 8192, Temperature: 0.2},
+	"gemini-1.5-flash":         {Provider: ProviderGemini, ContextWindow: 1048576, MaxOutputTokens: 8192, Temperature: 0.2},
+	"gemini-2.5-pro":           {Provider: ProviderGemini, ContextWindow: 1048576, MaxOutputTokens: 65536, Temperature: 0.2},
+	"gemini-2.5-flash":         {Provider: ProviderGemini, ContextWindow: 1048576, MaxOutputTokens: 65536, Temperature: 0.2},
+	"gpt-4o":                   {Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutputTokens: 16384, Temperature: 0.2},
+	"gpt-4o-mini":              {Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutputTokens: 16384, Temperature: 0.2},
+	"gpt-4.1":                  {Provider: ProviderOpenAI, ContextWindow: 1047576, MaxOutputTokens: 32768, Temperature: 0.2},
+	"gpt-4.1-mini":             {Provider: ProviderOpenAI, ContextWindow: 1047576, MaxOutputTokens: 32768, Temperature: 0.2},
+	"claude-3-5-sonnet-latest": {Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutputTokens: 8192, Temperature: 0.2},
+	"claude-3-5-haiku-latest":  {Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutputTokens: 8192, Temperature: 0.2},
+	"claude-3-7-sonnet-latest": {Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutputTokens: 8192, Temperature: 0.2},
+	"llama3":                   {Provider: ProviderOllama, ContextWindow: 8192, MaxOutputTokens: 4096, Temperature: 0.2},
+	"codellama":                {Provider: ProviderOllama, ContextWindow: 16384, MaxOutputTokens: 4096, Temperature: 0.2},
+	"qwen2.5-coder":            {Provider: ProviderOllama, ContextWindow: 32768, MaxOutputTokens: 8192, Temperature: 0.2},
+}
+
+// LookupModel returns the registry entry for a model name
+func LookupModel(name string) (ModelInfo, bool) {
+	info, ok := models[name]
+	info.Name = name
+	return info, ok
+}
+
+// Models returns the registered models, optionally limited to one provider
+func Models(provider string) []ModelInfo {
+	var list []ModelInfo
+	for name, info := range models {
+		if provider != "" && !strings.EqualFold(info.Provider, provider) {
+			continue
+		}
+		info.Name = name
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Provider != list[j].Provider {
+			return list[i].Provider < list[j].Provider
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// DefaultModel returns the model used for a provider when none is configured
+func DefaultModel(provider string) string {
+	return providers[strings.ToLower(provider)].defaultModel
+}
+
+// modelInfo returns the registry entry for name. The limits of models the
+// registry doesn't know about are left zero, so the provider's defaults apply
+// rather than ones that could cut a larger model short.
+func modelInfo(provider, name string) ModelInfo {
+	if info, ok := LookupModel(name); ok {
+		return info
+	}
+	return ModelInfo{Name: name, Provider: provider, Temperature: 0.2}
+}
@@ -0,0 +1,109 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Ollama generate API request and response structures
+type (
+	ollamaRequest struct {
+		Model   string        `json:"model"`
+		Prompt  string        `json:"prompt"`
+		Stream  bool          `json:"stream"`
+		Options ollamaOptions `json:"options"`
+	}
+
+	ollamaOptions struct {
+		Temperature float64 `json:"temperature"`
+		TopP        float64 `json:"top_p,omitempty"`
+		NumCtx      int     `json:"num_ctx,omitempty"`
+		NumPredict  int     `json:"num_predict,omitempty"`
+		Seed        *int64  `json:"seed,omitempty"`
+	}
+
+	ollamaResponse struct {
+		Response        string `json:"response"`
+		Done            bool   `json:"done"`
+		PromptEvalCount int    `json:"prompt_eval_count"`
+		EvalCount       int    `json:"eval_count"`
+	}
+)
+
+// ollamaProvider talks to a local Ollama server so code never leaves the machine
+type ollamaProvider struct {
+	client  *apiClient
+	model   ModelInfo
+	baseURL string
+	stream  bool
+}
+
+func (o *ollamaProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	reqBody := ollamaRequest{
+		Model:  o.model.Name,
+		Prompt: prompt,
+		Options: ollamaOptions{
+			Temperature: o.model.Temperature,
+			TopP:        o.model.TopP,
+			NumCtx:      o.model.ContextWindow,
+			NumPredict:  o.model.MaxOutputTokens,
+			Seed:        o.model.Seed,
+		},
+	}
+
+	if o.stream {
+		reqBody.Stream = true
+		return o.generateStream(ctx, reqBody)
+	}
+
+	var ollamaResp ollamaResponse
+	if err := o.client.postJSON(ctx, o.baseURL+"/api/generate", nil, reqBody, &ollamaResp); err != nil {
+		return "", o.requestError(ctx, err)
+	}
+
+	recordUsage(ctx, prompt, ollamaResp.Response, ollamaResp.PromptEvalCount, ollamaResp.EvalCount)
+
+	if ollamaResp.Response == "" {
+		return "", fmt.Errorf("no content in API response")
+	}
+
+	return ollamaResp.Response, nil
+}
+
+// generateStream reads Ollama's newline-delimited JSON stream, where the final
+// object carries the token counts
+func (o *ollamaProvider) generateStream(ctx context.Context, reqBody ollamaRequest) (string, error) {
+	buf := newStreamBuffer(ctx)
+	var promptTokens, responseTokens int
+	err := o.client.postStream(ctx, o.baseURL+"/api/generate", nil, reqBody, func(line []byte) error {
+		if len(bytes.TrimSpace(line)) == 0 {
+			return nil
+		}
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return fmt.Errorf("error decoding stream: %w", err)
+		}
+		buf.add(chunk.Response)
+		if chunk.Done {
+			promptTokens, responseTokens = chunk.PromptEvalCount, chunk.EvalCount
+		}
+		return nil
+	})
+	recordUsage(ctx, reqBody.Prompt, buf.text(), promptTokens, responseTokens)
+	if err != nil {
+		err = o.requestError(ctx, err)
+	}
+	return buf.result(err)
+}
+
+// requestError points at the server when it could not be reached at all
+func (o *ollamaProvider) requestError(ctx context.Context, err error) error {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) && ctx.Err() == nil {
+		return fmt.Errorf("ollama request failed (is the server running at %s?): %w", o.baseURL, err)
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// OpenAI chat completions request and response structures
+type (
+	openAIRequest struct {
+		Model       string          `json:"model,omitempty"`
+		Messages    []openAIMessage `json:"messages"`
+		Temperature float64         `json:"temperature"`
+		TopP        float64         `json:"top_p,omitempty"`
+		MaxTokens   int             `json:"max_tokens,omitempty"`
+		Seed        *int64          `json:"seed,omitempty"`
+		Stream      bool            `json:"stream,omitempty"`
+		// StreamOptions asks for token usage in the final streamed chunk
+		StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+	}
+
+	openAIStreamOptions struct {
+		IncludeUsage bool `json:"include_usage"`
+	}
+
+	openAIMessage struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+
+	openAIResponse struct {
+		Choices []openAIChoice `json:"choices"`
+		Usage   struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+
+	openAIChoice struct {
+		Message openAIMessage `json:"message"`
+		// Delta holds the new text of a streamed chunk
+		Delta openAIMessage `json:"delta"`
+	}
+)
+
+type openAIProvider struct {
+	client  *apiClient
+	apiKey  string
+	model   ModelInfo
+	baseURL string
+	stream  bool
+	// maxTokens is only sent when set explicitly, leaving the response
+	// length to the API otherwise
+	maxTokens int
+}
+
+func (o *openAIProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return o.chatCompletion(ctx, o.baseURL+"/chat/completions", "Authorization", "Bearer "+o.apiKey, prompt)
+}
+
+// chatCompletion posts a chat completions request to url, authenticating with the given header
+func (o *openAIProvider) chatCompletion(ctx context.Context, url, authHeader, authValue, prompt string) (string, error) {
+	reqBody := openAIRequest{
+		Model: o.model.Name,
+		Messages: []openAIMessage{
+			{Role: "user", Content: prompt},
+		},
+		Temperature: o.model.Temperature,
+		TopP:        o.model.TopP,
+		MaxTokens:   o.maxTokens,
+		Seed:        o.model.Seed,
+	}
+
+	headers := map[string]string{authHeader: authValue}
+	if o.stream {
+		reqBody.Stream = true
+		reqBody.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+		return o.chatCompletionStream(ctx, url, headers, reqBody)
+	}
+
+	var openAIResp openAIResponse
+	if err := o.client.postJSON(ctx, url, headers, reqBody, &openAIResp); err != nil {
+		return "", err
+	}
+
+	if len(openAIResp.Choices) == 0 || openAIResp.Choices[0].Message.Content == "" {
+		return "", fmt.Errorf("no content in API response")
+	}
+
+	text := openAIResp.Choices[0].Message.Content
+	recordUsage(ctx, prompt, text, openAIResp.Usage.PromptTokens, openAIResp.Usage.CompletionTokens)
+	return text, nil
+}
+
+// chatCompletionStream reads a streamed chat completion, whose server-sent
+// events each carry the next text delta
+func (o *openAIProvider) chatCompletionStream(ctx context.Context, url string, headers map[string]string, reqBody openAIRequest) (string, error) {
+	buf := newStreamBuffer(ctx)
+	var promptTokens, responseTokens int
+	err := o.client.postStream(ctx, url, headers, reqBody, func(line []byte) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		var chunk openAIResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("error decoding stream: %w", err)
+		}
+		if chunk.Usage.PromptTokens > 0 || chunk.Usage.CompletionTokens > 0 {
+			promptTokens, responseTokens = chunk.Usage.PromptTokens, chunk.Usage.CompletionTokens
+		}
+		for _, c := range chunk.Choices {
+			buf.add(c.Delta.Content)
+		}
+		return nil
+	})
+	recordUsage(ctx, reqBody.Messages[0].Content, buf.text(), promptTokens, responseTokens)
+	return buf.result(err)
+}
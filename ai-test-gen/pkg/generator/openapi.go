@@ -0,0 +1,82 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// OpenAPIPrompt is the instruction preamble sent when writing an OpenAPI
+// document for the handlers of a module. It can be replaced to customise the
+// document.
+var OpenAPIPrompt = `You are an expert in HTTP APIs and OpenAPI. Write an OpenAPI 3.0.3 document in YAML for the HTTP API served by the Go code below.
+1. Take the paths and methods from the routes; when a handler is not registered in the code shown, infer its route from its name and body
+2. Describe the path and query parameters each handler reads, and the headers it relies on
+3. Describe the request bodies from the types the handlers decode, and the responses from the types they encode, as schemas under components/schemas named after the Go types, following their json struct tags
+4. List every status code each handler writes, with the error responses it sends
+5. Give each operation an operationId named after its handler and a one-line summary
+
+Only describe what the code shows; do not invent endpoints, fields or authentication. Respond with the YAML document only, in a single ` + "```yaml" + ` code block.`
+
+// openAPIRetries is how many times a document that isn't a valid OpenAPI 3
+// document is asked for again
+const openAPIRetries = 1
+
+// OpenAPIRequestPrompt returns the prompt GenerateOpenAPI sends for an API
+// summary
+func OpenAPIRequestPrompt(summary string) string {
+	return OpenAPIPrompt + languageInstruction() + "\n\nAPI summary:\n" + summary
+}
+
+// GenerateOpenAPI asks the provider for an OpenAPI 3 document in YAML for the
+// API described by summary. The document is loaded and validated with an
+// OpenAPI parser; an invalid one is requested again, with the validation
+// error, up to openAPIRetries times.
+func GenerateOpenAPI(ctx context.Context, summary string, p Provider) (string, error) {
+	prompt := OpenAPIRequestPrompt(summary)
+	request := prompt
+	for attempt := 0; ; attempt++ {
+		text, err := p.Generate(ctx, request)
+		if err != nil {
+			return "", err
+		}
+		spec := extractYAML(text)
+		err = ValidateOpenAPI(ctx, []byte(spec))
+		if err == nil {
+			return spec, nil
+		}
+		if attempt >= openAPIRetries {
+			return "", fmt.Errorf("response is not a valid OpenAPI document: %w", err)
+		}
+		request = prompt + "\n\nA previous response to this request was not a valid OpenAPI 3 document (" + err.Error() +
+			"). Respond with the complete corrected document in a single ```yaml code block and no other text."
+	}
+}
+
+// ValidateOpenAPI parses spec, in YAML or JSON, as an OpenAPI 3 document and
+// validates it, including its references
+func ValidateOpenAPI(ctx context.Context, spec []byte) error {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return err
+	}
+	return doc.Validate(ctx)
+}
+
+// extractYAML returns the YAML of a response: its yaml block, or its first
+// block, or the whole text when it has none
+func extractYAML(text string) string {
+	blocks := fencedBlocks(text)
+	for _, b := range blocks {
+		if b.lang == "yaml" || b.lang == "yml" {
+			return b.body
+		}
+	}
+	if len(blocks) > 0 {
+		return blocks[0].body
+	}
+	return strings.TrimSpace(text) + "\n"
+}
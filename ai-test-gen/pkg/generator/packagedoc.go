@@ -0,0 +1,127 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/tokens"
+)
+
+// PackageDocPrompt is the instruction preamble sent when documenting a whole
+// package. It can be replaced to customise the generated documentation.
+var PackageDocPrompt = `You are an expert Go documentation generator. Write the documentation of the Go package whose files follow, as one coherent document rather than a file by file description.
+Include:
+1. Package overview: what the package is for and its main concepts
+2. How the types and functions work together, including across files
+3. Each exported type, function, constant and variable, described once, with parameters, return values and errors
+4. Usage examples of the main workflows
+5. Any important notes about concurrency, errors or side effects
+
+Only describe the exported API; use unexported code only to explain behavior. Format the output in Markdown with proper headings and code blocks.`
+
+// PackageDocPartPrompt is the preamble for one part of a package too large
+// for a single request; the parts are then combined with
+// PackageDocMergePrompt
+var PackageDocPartPrompt = `You are an expert Go documentation generator. The Go package below is too large for one request, so you are given part of its files. Document the exported types, functions, constants and variables they declare, with parameters, return values, errors and how they relate to each other. Your notes will be combined with those of the other parts into the package documentation, so skip the package overview. Format the output in Markdown.`
+
+// PackageDocMergePrompt is the preamble for combining the documentation of
+// the parts of a package into one document
+var PackageDocMergePrompt = `You are an expert Go documentation writer. Combine the partial documentation below, each written for some of the files of one Go package, into one coherent Markdown document for the package.
+Include:
+1. Package overview: what the package is for and its main concepts
+2. How the types and functions work together
+3. Each exported declaration, described once: merge duplicates and resolve references between the parts
+4. Usage examples of the main workflows
+
+Do not invent API that the parts don't describe.`
+
+// PackageFile is a file of a package documented by GeneratePackageDocumentation
+type PackageFile struct {
+	// Name is the file's base name
+	Name string
+	Code string
+}
+
+// PackageDocumentationPrompts returns the prompts GeneratePackageDocumentation
+// sends first for package name: one for the whole package when it fits in
+// maxTokens, else one per part, each holding as many whole files as fit
+func PackageDocumentationPrompts(name string, files []PackageFile, maxTokens int) []string {
+	whole := packageDocPrompt(PackageDocPrompt, name, files)
+	if maxTokens <= 0 || tokens.Estimate(whole) <= maxTokens || len(files) == 1 {
+		return []string{whole}
+	}
+
+	var prompts []string
+	var part []PackageFile
+	for _, f := range files {
+		if len(part) > 0 && tokens.Estimate(packageDocPrompt(PackageDocPartPrompt, name, append(part, f))) > maxTokens {
+			prompts = append(prompts, packageDocPrompt(PackageDocPartPrompt, name, part))
+			part = nil
+		}
+		part = append(part, f)
+	}
+	return append(prompts, packageDocPrompt(PackageDocPartPrompt, name, part))
+}
+
+func packageDocPrompt(preamble, name string, files []PackageFile) string {
+	var sb strings.Builder
+	sb.WriteString(preamble)
+	sb.WriteString(languageInstruction())
+	fmt.Fprintf(&sb, "\n\nPackage %s\n", name)
+	for _, f := range files {
+		fmt.Fprintf(&sb, "\n// file: %s\n%s\n", f.Name, f.Code)
+	}
+	return sb.String()
+}
+
+// GeneratePackageDocumentation documents package name from its files in one
+// Markdown document. A package whose prompt would exceed maxTokens is
+// documented in parts that are then combined; maxTokens 0 means no limit.
+func GeneratePackageDocumentation(ctx context.Context, name string, files []PackageFile, maxTokens int, p Provider) (string, error) {
+	prompts := PackageDocumentationPrompts(name, files, maxTokens)
+	if len(prompts) == 1 {
+		text, err := p.Generate(ctx, prompts[0])
+		if err != nil {
+			return "", err
+		}
+		return unwrapMarkdown(text), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(PackageDocMergePrompt)
+	sb.WriteString(languageInstruction())
+	fmt.Fprintf(&sb, "\n\nPackage %s\n", name)
+	for i, prompt := range prompts {
+		text, err := p.Generate(ctx, prompt)
+		if err != nil {
+			return "", fmt.Errorf("part %d of %d: %w", i+1, len(prompts), err)
+		}
+		fmt.Fprintf(&sb, "\n## Part %d\n\n%s\n", i+1, unwrapMarkdown(text))
+	}
+	text, err := p.Generate(ctx, sb.String())
+	if err != nil {
+		return "", err
+	}
+	return unwrapMarkdown(text), nil
+}
+
+// unknownContextWindow and unknownOutputTokens are the conservative limits
+// PromptBudget assumes for models the registry doesn't know about
+const (
+	unknownContextWindow = 8192
+	unknownOutputTokens  = 4096
+)
+
+// PromptBudget returns the number of tokens a prompt to model can use while
+// leaving room for the response, with conservative limits for unknown models
+func PromptBudget(provider, model string) int {
+	info := modelInfo(provider, model)
+	if info.ContextWindow == 0 {
+		info.ContextWindow = unknownContextWindow
+	}
+	if info.MaxOutputTokens == 0 {
+		info.MaxOutputTokens = unknownOutputTokens
+	}
+	return info.ContextWindow - info.MaxOutputTokens
+}
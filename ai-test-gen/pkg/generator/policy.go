@@ -0,0 +1,38 @@
+package generator
+
+import (
+	"context"
+	"strings"
+)
+
+// PolicyHeading introduces the policy appended to prompts by WithPolicy
+var PolicyHeading = "Organization policy. Follow these rules in everything you write; they take precedence over the instructions above:"
+
+// ApplyPolicy returns prompt with policy appended, or prompt unchanged when
+// policy is empty
+func ApplyPolicy(prompt, policy string) string {
+	policy = strings.TrimSpace(policy)
+	if policy == "" {
+		return prompt
+	}
+	return prompt + "\n\n" + PolicyHeading + "\n" + policy
+}
+
+// WithPolicy returns a provider that appends policy to every prompt before
+// passing it to p, so prompt overrides and commands can't leave it out. An
+// empty policy returns p unchanged.
+func WithPolicy(p Provider, policy string) Provider {
+	if strings.TrimSpace(policy) == "" {
+		return p
+	}
+	return &policyProvider{Provider: p, policy: policy}
+}
+
+type policyProvider struct {
+	Provider
+	policy string
+}
+
+func (p *policyProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return p.Provider.Generate(ctx, ApplyPolicy(prompt, p.policy))
+}
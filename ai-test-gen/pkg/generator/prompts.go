@@ -0,0 +1,74 @@
+package generator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"sync"
+)
+
+// PromptDigest hashes the prompts sent with a context returned by
+// WithPromptDigest to a provider wrapped by RecordPrompts, for the audit log.
+// It is safe for concurrent use.
+type PromptDigest struct {
+	mu    sync.Mutex
+	h     hash.Hash
+	count int
+}
+
+// Count returns the number of prompts sent
+func (d *PromptDigest) Count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.count
+}
+
+// Sum returns the hex SHA-256 over the hashes of the prompts, in the order
+// they were sent, or "" when none was
+func (d *PromptDigest) Sum() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.h == nil {
+		return ""
+	}
+	return hex.EncodeToString(d.h.Sum(nil))
+}
+
+func (d *PromptDigest) add(prompt string) {
+	sum := sha256.Sum256([]byte(prompt))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.h == nil {
+		d.h = sha256.New()
+	}
+	d.h.Write(sum[:])
+	d.count++
+}
+
+type promptDigestKey struct{}
+
+// WithPromptDigest returns a context whose prompts are added to d, as well as
+// to any PromptDigest already attached to ctx
+func WithPromptDigest(ctx context.Context, d *PromptDigest) context.Context {
+	digests, _ := ctx.Value(promptDigestKey{}).([]*PromptDigest)
+	return context.WithValue(ctx, promptDigestKey{}, append(digests[:len(digests):len(digests)], d))
+}
+
+// RecordPrompts returns a provider that adds every prompt to the digests of
+// its context before passing it to p, cached responses included
+func RecordPrompts(p Provider) Provider {
+	return &promptRecorder{Provider: p}
+}
+
+type promptRecorder struct {
+	Provider
+}
+
+func (r *promptRecorder) Generate(ctx context.Context, prompt string) (string, error) {
+	digests, _ := ctx.Value(promptDigestKey{}).([]*PromptDigest)
+	for _, d := range digests {
+		d.add(prompt)
+	}
+	return r.Provider.Generate(ctx, prompt)
+}
@@ -0,0 +1,41 @@
+package generator
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// RapidModule is the module tests generated with TestOptions.Property use
+const RapidModule = "pgregory.net/rapid"
+
+var rapidUse = regexp.MustCompile(`\brapid\.`)
+
+// propertyInstructions returns the prompt section for pure functions given
+// property-based tests
+func propertyInstructions(funcs []string) string {
+	if len(funcs) == 0 {
+		return ""
+	}
+	return `
+
+These functions are pure: ` + strings.Join(funcs, ", ") + `. Besides the table-driven tests, write property-based tests for them with ` + RapidModule + ` (imported as rapid):
+- Name them TestXxx_Property and run them with rapid.Check(t, func(t *rapid.T) { ... })
+- Draw the inputs with generators such as rapid.Int().Draw(t, "n"), rapid.IntRange(1, 1000), rapid.String(), rapid.SliceOf(rapid.Int()) and rapid.MapOf, narrowing them to the inputs the function accepts
+- Assert properties that hold for every input rather than specific outputs: round trips and involutions (reversing twice gives the input back), invariants (a sorted slice has the same elements, in order), relations between inputs and outputs (the GCD divides both inputs), idempotence, and agreement with a simpler reference implementation
+- Report a broken property with t.Fatalf, including the drawn inputs
+- Only state properties that follow from the code; write no property test for a function without one`
+}
+
+// fixPropertyTests adds the rapid import tests use but leave out, which
+// goimports can't always find
+func fixPropertyTests(code string) string {
+	if !rapidUse.MatchString(code) {
+		return code
+	}
+	if fixed, err := source.AddImports(code, []string{`"` + RapidModule + `"`}); err == nil {
+		return fixed
+	}
+	return code
+}
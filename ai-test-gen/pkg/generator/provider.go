@@ -0,0 +1,111 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Supported provider names
+const (
+	ProviderGemini    = "gemini"
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+	ProviderAzure     = "azure"
+)
+
+// Provider sends a prompt to a language model and returns its text response
+type Provider interface {
+	Generate(ctx context.Context, prompt string) (string, error)
+}
+
+// Config holds the settings used to construct a Provider
+type Config struct {
+	Provider string
+	APIKey   string
+	// Model overrides the provider's default model when set
+	Model string
+	// BaseURL overrides the provider's default API endpoint when set
+	BaseURL string
+	// Deployment and APIVersion are used by Azure OpenAI
+	Deployment string
+	APIVersion string
+	// MaxAttempts caps the tries made for rate-limited or failed requests
+	MaxAttempts int
+	// Stream requests responses incrementally, reporting progress through
+	// WithProgress and returning partial output in a PartialError
+	Stream bool
+	// HTTPClient sends the API requests; http.DefaultClient's settings are
+	// used when nil
+	HTTPClient *http.Client
+	// Timeout bounds each API call, including reading a streamed response;
+	// zero means no limit
+	Timeout time.Duration
+	// Sampling overrides the model's temperature and other generation
+	// parameters
+	Sampling Sampling
+}
+
+// RequiresAPIKey reports whether the named provider needs an API key
+func RequiresAPIKey(provider string) bool {
+	return !strings.EqualFold(provider, ProviderOllama)
+}
+
+// KnownProvider reports whether name is a supported provider
+func KnownProvider(name string) bool {
+	_, ok := providers[strings.ToLower(name)]
+	return ok
+}
+
+// NewProvider returns the Provider selected by cfg.Provider
+func NewProvider(cfg Config) (Provider, error) {
+	name := strings.ToLower(cfg.Provider)
+	if name == "" {
+		name = ProviderGemini
+	}
+	defaults, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q", cfg.Provider)
+	}
+
+	client := newAPIClient(cfg)
+	if err := cfg.Sampling.Validate(); err != nil {
+		return nil, err
+	}
+	model := cfg.Sampling.apply(modelInfo(name, orDefault(cfg.Model, defaults.defaultModel)))
+	baseURL := orDefault(cfg.BaseURL, defaults.baseURL)
+
+	switch name {
+	case ProviderGemini:
+		return &geminiProvider{client: client, apiKey: cfg.APIKey, model: model, baseURL: baseURL, stream: cfg.Stream}, nil
+	case ProviderOpenAI:
+		return &openAIProvider{client: client, apiKey: cfg.APIKey, model: model, baseURL: baseURL, stream: cfg.Stream, maxTokens: cfg.Sampling.MaxOutputTokens}, nil
+	case ProviderAnthropic:
+		return &anthropicProvider{client: client, apiKey: cfg.APIKey, model: model, baseURL: baseURL, stream: cfg.Stream}, nil
+	case ProviderOllama:
+		return &ollamaProvider{client: client, model: model, baseURL: baseURL, stream: cfg.Stream}, nil
+	default: // ProviderAzure
+		if baseURL == "" {
+			return nil, fmt.Errorf("azure provider requires a base URL (https://<resource>.openai.azure.com)")
+		}
+		deployment := orDefault(cfg.Deployment, cfg.Model)
+		if deployment == "" {
+			return nil, fmt.Errorf("azure provider requires a deployment name")
+		}
+		return &azureProvider{
+			openAIProvider: openAIProvider{client: client, apiKey: cfg.APIKey, model: model, baseURL: baseURL, stream: cfg.Stream, maxTokens: cfg.Sampling.MaxOutputTokens},
+			deployment:     deployment,
+			apiVersion:     orDefault(cfg.APIVersion, "2024-06-01"),
+		}, nil
+	}
+}
+
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return strings.TrimSuffix(value, "/")
+}
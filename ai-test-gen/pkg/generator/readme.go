@@ -0,0 +1,53 @@
+package generator
+
+import (
+	"context"
+	"strings"
+)
+
+// ReadmePrompt is the instruction preamble sent when generating a project
+// README. It can be replaced to customise the generated README.
+var ReadmePrompt = `You are an expert technical writer for Go projects. Write a README.md for the Go module summarized below.
+Include:
+1. A title and a short description of what the project does
+2. Installation instructions (go install for commands, go get for libraries)
+3. Usage: for CLI commands, the commands and common invocations; for libraries, a short example
+4. An API section giving an overview of the main packages and their exported types and functions
+5. Any other section the project clearly needs, such as configuration
+
+Only describe what the summary shows; do not invent features, flags or badges. Format the output as Markdown and respond with the README content only.`
+
+// ReadmeRequestPrompt returns the prompt GenerateReadme sends for a module
+// summary and the existing README, if any
+func ReadmeRequestPrompt(summary, existing string) string {
+	var sb strings.Builder
+	sb.WriteString(ReadmePrompt)
+	if existing != "" {
+		sb.WriteString("\n\nThe project already has the README below. Update it rather than starting over: keep its structure and any hand-written content that is still accurate, correct what the summary contradicts and add what is missing.\n\nExisting README:\n")
+		sb.WriteString(existing)
+	}
+	sb.WriteString("\n\nModule summary:\n")
+	sb.WriteString(summary)
+	return sb.String()
+}
+
+// GenerateReadme asks the provider for a README for the module described by
+// summary, updating existing when it is not empty
+func GenerateReadme(ctx context.Context, summary, existing string, p Provider) (string, error) {
+	text, err := p.Generate(ctx, ReadmeRequestPrompt(summary, existing))
+	if err != nil {
+		return "", err
+	}
+	return unwrapMarkdown(text), nil
+}
+
+// unwrapMarkdown removes a ```markdown fence wrapped around a whole response
+func unwrapMarkdown(text string) string {
+	trimmed := strings.TrimSpace(text)
+	for _, fence := range []string{"```markdown\n", "```md\n"} {
+		if strings.HasPrefix(trimmed, fence) && strings.HasSuffix(trimmed, "```") {
+			return strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(trimmed, fence), "```"))
+		}
+	}
+	return text
+}
@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Sampling holds generation parameters that override a model's defaults. A
+// low temperature and a fixed seed make responses close to reproducible,
+// which matters when generating in CI.
+type Sampling struct {
+	// Temperature replaces the model's default temperature when not nil
+	Temperature *float64
+	// TopP limits sampling to the most likely tokens of this cumulative
+	// probability when above zero
+	TopP float64
+	// MaxOutputTokens caps the length of responses when above zero
+	MaxOutputTokens int
+	// Seed fixes the sampling seed when not nil, for the providers that
+	// support it (see SupportsSeed)
+	Seed *int64
+}
+
+// SupportsSeed reports whether the named provider accepts Sampling.Seed;
+// Anthropic ignores it
+func SupportsSeed(provider string) bool {
+	return !strings.EqualFold(provider, ProviderAnthropic)
+}
+
+// Validate checks that the parameters are in range
+func (s Sampling) Validate() error {
+	if s.Temperature != nil && (*s.Temperature < 0 || *s.Temperature > 2) {
+		return fmt.Errorf("temperature %g out of range [0, 2]", *s.Temperature)
+	}
+	if s.TopP < 0 || s.TopP > 1 {
+		return fmt.Errorf("top-p %g out of range [0, 1]", s.TopP)
+	}
+	if s.MaxOutputTokens < 0 {
+		return fmt.Errorf("max output tokens %d is negative", s.MaxOutputTokens)
+	}
+	return nil
+}
+
+// String describes the parameters that are set, e.g. "temperature=0 seed=42",
+// or returns "" when none are
+func (s Sampling) String() string {
+	var parts []string
+	if s.Temperature != nil {
+		parts = append(parts, "temperature="+strconv.FormatFloat(*s.Temperature, 'g', -1, 64))
+	}
+	if s.TopP > 0 {
+		parts = append(parts, "top_p="+strconv.FormatFloat(s.TopP, 'g', -1, 64))
+	}
+	if s.MaxOutputTokens > 0 {
+		parts = append(parts, "max_output_tokens="+strconv.Itoa(s.MaxOutputTokens))
+	}
+	if s.Seed != nil {
+		parts = append(parts, "seed="+strconv.FormatInt(*s.Seed, 10))
+	}
+	return strings.Join(parts, " ")
+}
+
+// apply returns model with the parameters that are set replacing its defaults
+func (s Sampling) apply(model ModelInfo) ModelInfo {
+	if s.Temperature != nil {
+		model.Temperature = *s.Temperature
+	}
+	if s.TopP > 0 {
+		model.TopP = s.TopP
+	}
+	if s.MaxOutputTokens > 0 {
+		model.MaxOutputTokens = s.MaxOutputTokens
+	}
+	model.Seed = s.Seed
+	return model
+}
@@ -0,0 +1,39 @@
+package generator
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// SQLMockModule is the module tests generated with TestOptions.DBMock use
+const SQLMockModule = "github.com/DATA-DOG/go-sqlmock"
+
+var sqlmockUse = regexp.MustCompile(`\bsqlmock\.`)
+
+// sqlMockInstructions returns the prompt section for code using a database
+func sqlMockInstructions(funcs []string) string {
+	if len(funcs) == 0 {
+		return ""
+	}
+	return `
+
+These functions use a database through database/sql: ` + strings.Join(funcs, ", ") + `. Never connect to a real database; use ` + SQLMockModule + ` (imported as sqlmock) instead:
+- Create the mock with db, mock, err := sqlmock.New() in each test or subtest and defer db.Close(); for a *sql.Tx start it with mock.ExpectBegin() and db.Begin()
+- Declare the statements the code runs, in order: mock.ExpectQuery(regexp.QuoteMeta(query)) with WillReturnRows(sqlmock.NewRows(columns).AddRow(...)) for queries, mock.ExpectExec(regexp.QuoteMeta(query)) with WillReturnResult(sqlmock.NewResult(lastID, rowsAffected)) for statements, WithArgs for their arguments, and ExpectCommit or ExpectRollback for transactions
+- Cover query and exec errors with WillReturnError, empty results with rows that have no AddRow, and scan errors with mismatched columns
+- End every case with if err := mock.ExpectationsWereMet(); err != nil { t.Errorf(...) }`
+}
+
+// fixSQLMockTests adds the sqlmock import tests use but leave out, which
+// goimports can't always find
+func fixSQLMockTests(code string) string {
+	if !sqlmockUse.MatchString(code) {
+		return code
+	}
+	if fixed, err := source.AddImports(code, []string{`"` + SQLMockModule + `"`}); err == nil {
+		return fixed
+	}
+	return code
+}
@@ -0,0 +1,86 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// PartialError is returned when a streamed response is cut short, for example
+// by a timeout. Text holds the output received before the failure.
+type PartialError struct {
+	Text string
+	Err  error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("response cut short after %d characters: %v", len(e.Text), e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context whose streamed responses call fn with the
+// number of characters received so far as each chunk arrives
+func WithProgress(ctx context.Context, fn func(received int)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// streamBuffer accumulates the text of a streamed response, reporting progress
+// to the callback attached to ctx
+type streamBuffer struct {
+	sb       strings.Builder
+	progress func(int)
+}
+
+func newStreamBuffer(ctx context.Context) *streamBuffer {
+	progress, _ := ctx.Value(progressKey{}).(func(int))
+	return &streamBuffer{progress: progress}
+}
+
+func (b *streamBuffer) add(text string) {
+	if text == "" {
+		return
+	}
+	b.sb.WriteString(text)
+	if b.progress != nil {
+		b.progress(b.sb.Len())
+	}
+}
+
+// text returns the text received so far
+func (b *streamBuffer) text() string {
+	return b.sb.String()
+}
+
+// result returns the collected text, wrapping err in a PartialError if some
+// text had already arrived
+func (b *streamBuffer) result(err error) (string, error) {
+	if err != nil {
+		if b.sb.Len() > 0 {
+			return "", &PartialError{Text: b.sb.String(), Err: err}
+		}
+		return "", err
+	}
+	if b.sb.Len() == 0 {
+		return "", fmt.Errorf("no content in API response")
+	}
+	return b.sb.String(), nil
+}
+
+// sseData returns the payload of a server-sent events data line
+func sseData(line []byte) ([]byte, bool) {
+	data, ok := bytes.CutPrefix(line, []byte("data:"))
+	if !ok {
+		return nil, false
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "[DONE]" {
+		return nil, false
+	}
+	return data, true
+}
@@ -0,0 +1,28 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// styleInstructions returns the prompt section showing existing tests of the
+// project as examples of its style
+func styleInstructions(examples []source.StyleExample) string {
+	if len(examples) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nMatch the style of these existing tests of the project: their layout, naming, assertion library, " +
+		"helpers and fixtures. Don't copy their cases, and where they differ from the instructions above, follow the instructions.")
+	for _, e := range examples {
+		sb.WriteString("\n\n// " + e.Path)
+		if e.SamePackage {
+			sb.WriteString(" (same package: its helpers can be used, but don't redeclare them)")
+		} else {
+			sb.WriteString(" (another package: declare any helper you need instead of using these)")
+		}
+		sb.WriteString("\n" + strings.TrimSpace(e.Code))
+	}
+	return sb.String()
+}
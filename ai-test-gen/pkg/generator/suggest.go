@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Kinds of refactoring Suggest asks for
+const (
+	RefactorErrors  = "errors"
+	RefactorContext = "context"
+	RefactorNaming  = "naming"
+)
+
+// RefactorKinds are the kinds of refactoring, in the order they are described
+var RefactorKinds = []string{RefactorErrors, RefactorContext, RefactorNaming}
+
+// refactorGuidance describes each kind of refactoring to the model
+var refactorGuidance = map[string]string{
+	RefactorErrors:  "Error handling: wrap returned errors with fmt.Errorf and %w, adding what was being done; check errors that are ignored; use errors.Is and errors.As instead of comparing errors or their messages",
+	RefactorContext: "Context plumbing: accept a context.Context as the first parameter of functions doing I/O, blocking or calling code that takes one, and pass it on instead of context.Background() or context.TODO()",
+	RefactorNaming:  "Naming: follow Go conventions, such as MixedCaps, initialisms like ID and URL in one case, short receiver names, no Get prefix on getters and no stutter with the package name",
+}
+
+// SuggestPrompt is the instruction preamble sent when asking for refactoring
+// suggestions. It can be replaced to customise the suggestions.
+var SuggestPrompt = `You are an expert Go developer. Suggest targeted refactorings of the following Go code. Each suggestion must be small and self-contained, keep the behavior of the code, and still compile. Only suggest changes that clearly improve the code; it is fine to suggest nothing.`
+
+// Suggestion is a refactoring suggested by Suggest
+type Suggestion struct {
+	// Kind is one of RefactorKinds
+	Kind   string `json:"kind"`
+	Title  string `json:"title"`
+	Reason string `json:"reason"`
+	// Replaces are the top-level declarations the suggestion rewrites, as
+	// Name or Type.Method; none when it only adds declarations
+	Replaces []string `json:"replaces"`
+	// Imports are the import paths Code needs
+	Imports []string `json:"imports,omitempty"`
+	// Code is the new source of the declarations, without a package clause
+	Code string `json:"code"`
+}
+
+// SuggestRequestPrompt returns the prompt Suggest sends for code, limited to
+// the kinds of refactoring given
+func SuggestRequestPrompt(code string, kinds []string) string {
+	var sb strings.Builder
+	sb.WriteString(SuggestPrompt)
+	sb.WriteString("\n\nOnly suggest these kinds of refactoring:\n")
+	for _, kind := range kinds {
+		fmt.Fprintf(&sb, "- %s: %s\n", kind, refactorGuidance[kind])
+	}
+	sb.WriteString(`
+Respond with a JSON array only, one object per suggestion:
+{"kind": "` + strings.Join(kinds, "|") + `", "title": "short imperative summary", "reason": "why it is better", "replaces": ["Name", "Type.Method"], "imports": ["fmt"], "code": "the complete new source of the replaced declarations, doc comments included"}
+"replaces" lists every top-level declaration the code rewrites and "code" replaces all of them; declarations of other files can't be changed, so don't rename anything used outside this file. Respond with [] when there is nothing worth changing.`)
+	sb.WriteString("\n\nGo code:\n")
+	sb.WriteString(code)
+	return sb.String()
+}
+
+// Suggest asks the provider for refactorings of code of the given kinds and
+// returns them in the order suggested, leaving out those of other kinds
+func Suggest(ctx context.Context, code string, kinds []string, p Provider) ([]Suggestion, error) {
+	text, err := p.Generate(ctx, SuggestRequestPrompt(code, kinds))
+	if err != nil {
+		return nil, err
+	}
+
+	start := strings.Index(text, "[")
+	end := strings.LastIndex(text, "]")
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("no JSON array in response")
+	}
+	var suggestions []Suggestion
+	if err := json.Unmarshal([]byte(text[start:end+1]), &suggestions); err != nil {
+		return nil, fmt.Errorf("decode suggestions: %w", err)
+	}
+
+	kept := suggestions[:0]
+	for _, s := range suggestions {
+		s.Kind = strings.ToLower(strings.TrimSpace(s.Kind))
+		if s.Code != "" && slices.Contains(kinds, s.Kind) {
+			kept = append(kept, s)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/knbr13/aitestgen/pkg/tokens"
+)
+
+// Usage accumulates the token counts reported by providers for requests made
+// with a context returned by WithUsage. It is safe for concurrent use.
+type Usage struct {
+	promptTokens   atomic.Int64
+	responseTokens atomic.Int64
+	requests       atomic.Int64
+	estimated      atomic.Bool
+}
+
+// PromptTokens returns the number of input tokens used
+func (u *Usage) PromptTokens() int64 { return u.promptTokens.Load() }
+
+// ResponseTokens returns the number of output tokens used
+func (u *Usage) ResponseTokens() int64 { return u.responseTokens.Load() }
+
+// Requests returns the number of requests made
+func (u *Usage) Requests() int64 { return u.requests.Load() }
+
+// Estimated reports whether some of the counts are estimates because the
+// provider did not report usage
+func (u *Usage) Estimated() bool { return u.estimated.Load() }
+
+type usageKey struct{}
+
+// WithUsage returns a context whose requests are added to u, as well as to any
+// Usage already attached to ctx
+func WithUsage(ctx context.Context, u *Usage) context.Context {
+	usages := append(usagesFrom(ctx), u)
+	return context.WithValue(ctx, usageKey{}, usages)
+}
+
+func usagesFrom(ctx context.Context) []*Usage {
+	usages, _ := ctx.Value(usageKey{}).([]*Usage)
+	return usages[:len(usages):len(usages)]
+}
+
+// recordUsage adds the token counts of one request to the Usages attached to
+// ctx. Counts the provider did not report are estimated from the text.
+func recordUsage(ctx context.Context, prompt, response string, promptTokens, responseTokens int) {
+	estimated := false
+	if promptTokens == 0 {
+		promptTokens = tokens.Estimate(prompt)
+		estimated = true
+	}
+	if responseTokens == 0 && response != "" {
+		responseTokens = tokens.Estimate(response)
+		estimated = true
+	}
+	for _, u := range usagesFrom(ctx) {
+		u.promptTokens.Add(int64(promptTokens))
+		u.responseTokens.Add(int64(responseTokens))
+		u.requests.Add(1)
+		if estimated {
+			u.estimated.Store(true)
+		}
+	}
+}
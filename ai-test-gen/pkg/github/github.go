@@ -0,0 +1,216 @@
+// Package github is a minimal client for the GitHub REST API calls needed to
+// review pull requests.
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultBaseURL is the API endpoint of github.com
+const DefaultBaseURL = "https://api.github.com"
+
+// ErrNotFound is returned for resources that do not exist
+var ErrNotFound = errors.New("not found")
+
+// Client calls the GitHub API with a token
+type Client struct {
+	token   string
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient returns a client for the API at baseURL, DefaultBaseURL when empty
+func NewClient(token, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{token: token, baseURL: strings.TrimSuffix(baseURL, "/"), http: &http.Client{}}
+}
+
+// PullRequest is the part of a pull request needed to review it
+type PullRequest struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Head   Ref    `json:"head"`
+	Base   Ref    `json:"base"`
+}
+
+// Ref is a branch of a pull request
+type Ref struct {
+	Ref  string `json:"ref"`
+	SHA  string `json:"sha"`
+	Repo struct {
+		FullName string `json:"full_name"`
+	} `json:"repo"`
+}
+
+// File is a file changed by a pull request
+type File struct {
+	Filename string `json:"filename"`
+	// Status is added, modified, removed, renamed, copied, changed or unchanged
+	Status string `json:"status"`
+}
+
+// PullRequest fetches pull request number of repo ("owner/name")
+func (c *Client) PullRequest(ctx context.Context, repo string, number int) (*PullRequest, error) {
+	var pr PullRequest
+	if err := c.do(ctx, "GET", fmt.Sprintf("/repos/%s/pulls/%d", repo, number), nil, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// PullRequestFiles lists the files changed by a pull request
+func (c *Client) PullRequestFiles(ctx context.Context, repo string, number int) ([]File, error) {
+	var all []File
+	for page := 1; ; page++ {
+		var files []File
+		if err := c.do(ctx, "GET", fmt.Sprintf("/repos/%s/pulls/%d/files?per_page=100&page=%d", repo, number, page), nil, &files); err != nil {
+			return nil, err
+		}
+		all = append(all, files...)
+		if len(files) < 100 {
+			return all, nil
+		}
+	}
+}
+
+// FileContent returns the content of path at ref, or ErrNotFound
+func (c *Client) FileContent(ctx context.Context, repo, path, ref string) (string, error) {
+	var content struct {
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+	}
+	endpoint := fmt.Sprintf("/repos/%s/contents/%s?ref=%s", repo, escapePath(path), url.QueryEscape(ref))
+	if err := c.do(ctx, "GET", endpoint, nil, &content); err != nil {
+		return "", err
+	}
+	if content.Encoding != "base64" {
+		return "", fmt.Errorf("%s: unsupported encoding %q", path, content.Encoding)
+	}
+	// the contents API wraps its base64 at 60 characters
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(content.Content, "\n", ""))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// Comment posts a comment on an issue or pull request
+func (c *Client) Comment(ctx context.Context, repo string, number int, body string) error {
+	return c.do(ctx, "POST", fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number), map[string]string{"body": body}, nil)
+}
+
+// Commit adds a commit with files (path to content) on top of parent and
+// moves branch to it, returning the new commit's SHA
+func (c *Client) Commit(ctx context.Context, repo, branch, parent, message string, files map[string]string) (string, error) {
+	var parentCommit struct {
+		Tree struct {
+			SHA string `json:"sha"`
+		} `json:"tree"`
+	}
+	if err := c.do(ctx, "GET", fmt.Sprintf("/repos/%s/git/commits/%s", repo, parent), nil, &parentCommit); err != nil {
+		return "", err
+	}
+
+	type treeEntry struct {
+		Path    string `json:"path"`
+		Mode    string `json:"mode"`
+		Type    string `json:"type"`
+		Content string `json:"content"`
+	}
+	entries := make([]treeEntry, 0, len(files))
+	for path, content := range files {
+		entries = append(entries, treeEntry{Path: path, Mode: "100644", Type: "blob", Content: content})
+	}
+	var tree struct {
+		SHA string `json:"sha"`
+	}
+	if err := c.do(ctx, "POST", fmt.Sprintf("/repos/%s/git/trees", repo), map[string]any{"base_tree": parentCommit.Tree.SHA, "tree": entries}, &tree); err != nil {
+		return "", err
+	}
+
+	var commit struct {
+		SHA string `json:"sha"`
+	}
+	body := map[string]any{"message": message, "tree": tree.SHA, "parents": []string{parent}}
+	if err := c.do(ctx, "POST", fmt.Sprintf("/repos/%s/git/commits", repo), body, &commit); err != nil {
+		return "", err
+	}
+
+	// not forced, so the update fails if the branch moved since parent
+	ref := map[string]any{"sha": commit.SHA, "force": false}
+	if err := c.do(ctx, "PATCH", fmt.Sprintf("/repos/%s/git/refs/heads/%s", repo, escapePath(branch)), ref, nil); err != nil {
+		return "", err
+	}
+	return commit.SHA, nil
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, endpoint string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("GitHub request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", method, endpoint, ErrNotFound)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("%s %s: GitHub returned %d: %s", method, endpoint, resp.StatusCode, apiErr.Message)
+		}
+		return fmt.Errorf("%s %s: GitHub returned %d", method, endpoint, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding GitHub response: %w", err)
+	}
+	return nil
+}
+
+// escapePath escapes each element of a slash separated path
+func escapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,187 @@
+package gotool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Vet runs go vet on the package in dir, which also type-checks its test files,
+// including those behind the build tags. The combined compiler output is
+// returned alongside any error.
+func Vet(ctx context.Context, dir string, tags ...string) (string, error) {
+	return run(ctx, dir, append(append([]string{"vet"}, tagArgs(tags)...), ".")...)
+}
+
+// Test runs go test on the package in dir with the build tags, limited to
+// the named test functions
+func Test(ctx context.Context, dir string, tests []string, tags ...string) (string, error) {
+	args := append([]string{"test", "-count=1"}, tagArgs(tags)...)
+	if len(tests) > 0 {
+		args = append(args, "-run", testPattern(tests))
+	}
+	return run(ctx, dir, append(args, ".")...)
+}
+
+// VetSource runs go vet on the package of filename as if the file held src
+// instead. The file is left untouched: src is substituted with -overlay.
+func VetSource(ctx context.Context, filename, src string) (string, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp("", "aitestgen-vet-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	replaced := filepath.Join(tmp, filepath.Base(abs))
+	if err := os.WriteFile(replaced, []byte(src), 0644); err != nil {
+		return "", err
+	}
+	overlay, err := json.Marshal(map[string]map[string]string{"Replace": {abs: replaced}})
+	if err != nil {
+		return "", err
+	}
+	overlayFile := filepath.Join(tmp, "overlay.json")
+	if err := os.WriteFile(overlayFile, overlay, 0644); err != nil {
+		return "", err
+	}
+	return run(ctx, filepath.Dir(abs), "vet", "-overlay", overlayFile, ".")
+}
+
+// tagArgs returns the -tags flag for tags, if any
+func tagArgs(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	return []string{"-tags", strings.Join(tags, ",")}
+}
+
+// Stress runs the named tests of the package in dir count times with the
+// race detector and the build tags, falling back to running them without it
+// on platforms where it is unavailable
+func Stress(ctx context.Context, dir string, tests []string, count int, tags ...string) (string, error) {
+	args := append([]string{"test", "-race", fmt.Sprintf("-count=%d", count)}, tagArgs(tags)...)
+	args = append(args, "-run", testPattern(tests), ".")
+	out, err := run(ctx, dir, args...)
+	if err != nil && raceUnsupported(out) {
+		return run(ctx, dir, append(args[:1:1], args[2:]...)...)
+	}
+	return out, err
+}
+
+// raceUnsupported reports whether go test output says the race detector
+// can't be used, e.g. without cgo
+func raceUnsupported(out string) bool {
+	return strings.Contains(out, "-race requires cgo") || strings.Contains(out, "-race is not supported")
+}
+
+// failLine matches the result line of a failed test or subtest
+var failLine = regexp.MustCompile(`(?m)^\s*--- FAIL: (\w+)`)
+
+// FailedTests returns the top-level tests go test output reports as failed,
+// in the order they failed
+func FailedTests(output string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range failLine.FindAllStringSubmatch(output, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// passLine matches the result line of a passed top-level test in go test -v
+// output; those of subtests are indented
+var passLine = regexp.MustCompile(`(?m)^--- PASS: (\w+)`)
+
+// PassedTests returns the top-level tests go test -v output reports as passed
+func PassedTests(output string) []string {
+	var names []string
+	for _, m := range passLine.FindAllStringSubmatch(output, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// testPattern returns a -run pattern matching exactly the named tests
+func testPattern(tests []string) string {
+	quoted := make([]string, len(tests))
+	for i, t := range tests {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+// TestArgs runs the named tests of the package in dir with the build tags,
+// passing args to the test binary, e.g. -update to rewrite golden files
+func TestArgs(ctx context.Context, dir string, tests, tags []string, args ...string) (string, error) {
+	cmd := append([]string{"test", "-count=1"}, tagArgs(tags)...)
+	cmd = append(cmd, "-run", testPattern(tests), ".", "-args")
+	return run(ctx, dir, append(cmd, args...)...)
+}
+
+// Cover runs the package tests in dir and writes a coverage profile
+func Cover(ctx context.Context, dir, profile string) (string, error) {
+	return run(ctx, dir, "test", "-count=1", "-coverprofile", profile, ".")
+}
+
+// CoverVerbose runs the package tests in dir with -v and the build tags,
+// writing a coverage profile, so both passed and failed tests are listed
+func CoverVerbose(ctx context.Context, dir, profile string, tags ...string) (string, error) {
+	args := append([]string{"test", "-count=1", "-v", "-coverprofile", profile}, tagArgs(tags)...)
+	return run(ctx, dir, append(args, ".")...)
+}
+
+// CoverTests runs the named tests of the package in dir and writes a coverage
+// profile of what they alone cover
+func CoverTests(ctx context.Context, dir, profile string, tests []string) (string, error) {
+	return run(ctx, dir, "test", "-count=1", "-timeout=2m", "-run", testPattern(tests), "-coverprofile", profile, ".")
+}
+
+// TestOverlay runs the package tests in dir with the file substitutions of
+// a go build -overlay file. Tests running past the timeout fail, since a
+// mutated loop condition can keep them from ever finishing.
+func TestOverlay(ctx context.Context, dir, overlay string) (string, error) {
+	return run(ctx, dir, "test", "-count=1", "-timeout=30s", "-overlay", overlay, ".")
+}
+
+// ErrorsFor returns the lines of go tool output that refer to the given file
+func ErrorsFor(output, file string) string {
+	base := filepath.Base(file)
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimLeft(line, "# \t")
+		if strings.HasPrefix(trimmed, base+":") || strings.Contains(trimmed, string(filepath.Separator)+base+":") || strings.Contains(trimmed, "/"+base+":") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Env holds extra environment variables of the go commands run, such as
+// GOOS=windows to check code for another platform
+var Env []string
+
+func run(ctx context.Context, dir string, args ...string) (string, error) {
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	if len(Env) > 0 {
+		cmd.Env = append(os.Environ(), Env...)
+	}
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return out.String(), err
+}
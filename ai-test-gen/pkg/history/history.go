@@ -0,0 +1,257 @@
+// Package history keeps a log of the runs of a project, one JSON line per
+// run, and summarizes it for the report command: files processed, failure
+// rates and cost per model, and coverage over time.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/knbr13/aitestgen/pkg/tokens"
+)
+
+// FileName is the log written to the root of the project
+const FileName = ".aitestgen-history.jsonl"
+
+// Run is the outcome of one command run
+type Run struct {
+	Time      time.Time `json:"time"`
+	Command   string    `json:"command"`
+	Provider  string    `json:"provider,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	Processed int       `json:"processed"`
+	Failed    int       `json:"failed"`
+	Skipped   int       `json:"skipped"`
+	// CoverageBefore and Coverage are the total coverage at the start and
+	// the end of the run, for the commands that measure it
+	CoverageBefore *float64 `json:"coverage_before,omitempty"`
+	Coverage       *float64 `json:"coverage,omitempty"`
+	PromptTokens   int64    `json:"prompt_tokens"`
+	ResponseTokens int64    `json:"response_tokens"`
+	CostUSD        *float64 `json:"cost_usd,omitempty"`
+	DurationMS     int64    `json:"duration_ms"`
+}
+
+// Append adds run to the log in dir
+func Append(dir string, run Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, FileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	// a single write, so that concurrent runs don't interleave their lines
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads the runs logged in the file at path, oldest first. A missing
+// log has no runs.
+func Load(path string) ([]Run, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []Run
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var run Run
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Time.Before(runs[j].Time) })
+	return runs, nil
+}
+
+// Stats are the totals of a group of runs
+type Stats struct {
+	Runs      int
+	Processed int
+	Failed    int
+	Skipped   int
+	CostUSD   float64
+	// Unpriced counts the runs whose cost is unknown
+	Unpriced       int
+	PromptTokens   int64
+	ResponseTokens int64
+}
+
+func (s *Stats) add(run Run) {
+	s.Runs++
+	s.Processed += run.Processed
+	s.Failed += run.Failed
+	s.Skipped += run.Skipped
+	s.PromptTokens += run.PromptTokens
+	s.ResponseTokens += run.ResponseTokens
+	if run.CostUSD != nil {
+		s.CostUSD += *run.CostUSD
+	} else if run.PromptTokens+run.ResponseTokens > 0 {
+		s.Unpriced++
+	}
+}
+
+// FailureRate returns the share of the attempted files that failed, in
+// percent
+func (s Stats) FailureRate() float64 {
+	if s.Processed+s.Failed == 0 {
+		return 0
+	}
+	return 100 * float64(s.Failed) / float64(s.Processed+s.Failed)
+}
+
+// Summary aggregates a log of runs
+type Summary struct {
+	From, To time.Time
+	Total    Stats
+	// ByModel maps "provider/model" to the stats of its runs
+	ByModel map[string]*Stats
+	// ByCommand maps each command to the stats of its runs
+	ByCommand map[string]*Stats
+	// Weeks holds the stats of each week with runs, keyed by its Monday
+	Weeks map[time.Time]*Stats
+	// Coverage holds the runs that measured coverage, oldest first
+	Coverage []Run
+}
+
+// Summarize aggregates the runs made at or after since
+func Summarize(runs []Run, since time.Time) Summary {
+	s := Summary{ByModel: make(map[string]*Stats), ByCommand: make(map[string]*Stats), Weeks: make(map[time.Time]*Stats)}
+	for _, run := range runs {
+		if run.Time.Before(since) {
+			continue
+		}
+		if s.From.IsZero() || run.Time.Before(s.From) {
+			s.From = run.Time
+		}
+		if run.Time.After(s.To) {
+			s.To = run.Time
+		}
+		s.Total.add(run)
+		if run.Model != "" {
+			key := run.Model
+			if run.Provider != "" {
+				key = run.Provider + "/" + run.Model
+			}
+			group(s.ByModel, key).add(run)
+		}
+		group(s.ByCommand, run.Command).add(run)
+		group(s.Weeks, weekOf(run.Time)).add(run)
+		if run.Coverage != nil {
+			s.Coverage = append(s.Coverage, run)
+		}
+	}
+	return s
+}
+
+func group[K comparable](m map[K]*Stats, key K) *Stats {
+	s, ok := m[key]
+	if !ok {
+		s = &Stats{}
+		m[key] = s
+	}
+	return s
+}
+
+// weekOf returns the start of the week of t, on Monday in UTC
+func weekOf(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// Markdown renders the summary as a Markdown dashboard
+func (s Summary) Markdown(title string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	if s.Total.Runs == 0 {
+		sb.WriteString("No runs recorded.\n")
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "%d runs from %s to %s: %d files processed, %d failed (%.1f%%), %s spent.\n",
+		s.Total.Runs, s.From.Format(time.DateOnly), s.To.Format(time.DateOnly),
+		s.Total.Processed, s.Total.Failed, s.Total.FailureRate(), cost(s.Total))
+
+	if len(s.Coverage) > 0 {
+		first, last := s.Coverage[0], s.Coverage[len(s.Coverage)-1]
+		start := *first.Coverage
+		if first.CoverageBefore != nil {
+			start = *first.CoverageBefore
+		}
+		fmt.Fprintf(&sb, "\n## Coverage\n\nCoverage went from %.1f%% to %.1f%% (%+.1f points).\n\n", start, *last.Coverage, *last.Coverage-start)
+		sb.WriteString("| Date | Command | Before | After |\n|---|---|---:|---:|\n")
+		for _, run := range s.Coverage {
+			before := "-"
+			if run.CoverageBefore != nil {
+				before = fmt.Sprintf("%.1f%%", *run.CoverageBefore)
+			}
+			fmt.Fprintf(&sb, "| %s | %s | %s | %.1f%% |\n", run.Time.Format("2006-01-02 15:04"), run.Command, before, *run.Coverage)
+		}
+	}
+
+	if len(s.ByModel) > 0 {
+		sb.WriteString("\n## By model\n\n| Model | Runs | Files | Failed | Failure rate | Tokens | Cost |\n|---|---:|---:|---:|---:|---:|---:|\n")
+		for _, key := range sortedKeys(s.ByModel, func(a, b string) bool { return a < b }) {
+			writeRow(&sb, key, s.ByModel[key])
+		}
+	}
+	sb.WriteString("\n## By command\n\n| Command | Runs | Files | Failed | Failure rate | Tokens | Cost |\n|---|---:|---:|---:|---:|---:|---:|\n")
+	for _, key := range sortedKeys(s.ByCommand, func(a, b string) bool { return a < b }) {
+		writeRow(&sb, key, s.ByCommand[key])
+	}
+	sb.WriteString("\n## By week\n\n| Week of | Runs | Files | Failed | Failure rate | Tokens | Cost |\n|---|---:|---:|---:|---:|---:|---:|\n")
+	for _, week := range sortedKeys(s.Weeks, func(a, b time.Time) bool { return a.Before(b) }) {
+		writeRow(&sb, week.Format(time.DateOnly), s.Weeks[week])
+	}
+	return sb.String()
+}
+
+func writeRow(sb *strings.Builder, name string, s *Stats) {
+	fmt.Fprintf(sb, "| %s | %d | %d | %d | %.1f%% | %d | %s |\n", name, s.Runs, s.Processed, s.Failed, s.FailureRate(),
+		s.PromptTokens+s.ResponseTokens, cost(*s))
+}
+
+// cost formats the cost of s, marking it as a lower bound when some runs
+// had no known price
+func cost(s Stats) string {
+	c := tokens.FormatCost(s.CostUSD)
+	if s.Unpriced > 0 {
+		c += "+"
+	}
+	return c
+}
+
+func sortedKeys[K comparable](m map[K]*Stats, less func(a, b K) bool) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+	return keys
+}
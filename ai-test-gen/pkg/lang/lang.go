@@ -0,0 +1,153 @@
+// Package lang describes the languages besides Go that tests are generated
+// for: which files hold code and tests, the test file written for a source
+// file, the instructions sent to the model and the formatters run on the
+// result.
+package lang
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/runner"
+)
+
+// Go is the name of the language the rest of the tool handles
+const Go = "go"
+
+// Language is a language tests can be generated for
+type Language struct {
+	// Name is the value of --lang
+	Name    string
+	Aliases []string
+	// Exts are the extensions of source files, with the dot
+	Exts []string
+	// Fences are the info strings of the code blocks holding the language
+	Fences []string
+	// testFile returns the test file name for the source file name base
+	testFile func(base string) string
+	// skip reports whether base is a test or other file not to generate
+	// tests for
+	skip func(base string) bool
+	// prompt returns the instructions for the source file at path
+	prompt func(path string) string
+	// Formatters are the commands tried in order to format a test file,
+	// whose path is appended; the first found in PATH is used
+	Formatters [][]string
+}
+
+var languages = []*Language{python, typeScript}
+
+// Names returns the names of the languages, Go included
+func Names() []string {
+	names := []string{Go}
+	for _, l := range languages {
+		names = append(names, l.Name)
+	}
+	return names
+}
+
+// Lookup returns the language called name or one of its aliases
+func Lookup(name string) (*Language, error) {
+	name = strings.ToLower(name)
+	for _, l := range languages {
+		if l.Name == name || slices.Contains(l.Aliases, name) {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported language %q (use %s)", name, strings.Join(Names(), ", "))
+}
+
+// IsSource reports whether path is a source file of the language that tests
+// are generated for
+func (l *Language) IsSource(path string) bool {
+	base := filepath.Base(path)
+	return slices.Contains(l.Exts, filepath.Ext(base)) && !l.skip(base)
+}
+
+// TestFile returns the test file for the source file at path, in the same
+// directory
+func (l *Language) TestFile(path string) string {
+	return filepath.Join(filepath.Dir(path), l.testFile(filepath.Base(path)))
+}
+
+// Prompt returns the instructions for writing tests for the source file at
+// path
+func (l *Language) Prompt(path string) string {
+	return l.prompt(path)
+}
+
+// ignoredDirs are directories of dependencies, environments and build output
+var ignoredDirs = []string{"node_modules", "venv", "env", "__pycache__", "dist", "build", "coverage", "site-packages"}
+
+// Files returns the source files of the language under root that pass
+// filter, leaving out hidden directories and those of ignoredDirs
+func (l *Language) Files(root string, filter runner.Filter) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || slices.Contains(ignoredDirs, name) || filter.Skip(rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if l.IsSource(p) && !filter.Skip(rel, false) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Format runs the first of the language's formatters found in PATH on the
+// file at path. Without one the file is left as it is.
+func (l *Language) Format(ctx context.Context, path string) error {
+	for _, f := range l.Formatters {
+		bin, err := exec.LookPath(f[0])
+		if err != nil {
+			continue
+		}
+		args := append(slices.Clone(f[1:]), path)
+		if out, err := exec.CommandContext(ctx, bin, args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %w: %s", f[0], err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	return nil
+}
+
+// nearestFile returns the content of the first file called name in dir or
+// its parents, or "" if there is none
+func nearestFile(dir, name string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			return string(data)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
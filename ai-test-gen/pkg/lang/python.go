@@ -0,0 +1,37 @@
+package lang
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// PythonPrompt is the instruction preamble for Python tests
+var PythonPrompt = `You are an expert Python developer. Generate comprehensive unit tests for the provided Python module using pytest. Your output MUST be valid, runnable Python code. Include:
+1. One test function per behaviour, named test_<function>_<case>
+2. @pytest.mark.parametrize for tables of inputs and expected results
+3. Edge cases: empty and None inputs, boundaries, and large values
+4. Error cases with pytest.raises, matching the message where the code sets one
+5. unittest.mock or the monkeypatch fixture for network, filesystem, time and other external dependencies; tmp_path for files
+6. Only plain asserts, no unittest.TestCase classes
+7. Only the imports the tests use
+8. Do not output any explanations, only the code block.`
+
+var python = &Language{
+	Name:    "python",
+	Aliases: []string{"py"},
+	Exts:    []string{".py"},
+	Fences:  []string{"python", "py", "python3"},
+	testFile: func(base string) string {
+		return "test_" + base
+	},
+	skip: func(base string) bool {
+		return strings.HasPrefix(base, "test_") || strings.HasSuffix(base, "_test.py") ||
+			base == "__init__.py" || base == "conftest.py" || base == "setup.py" || base == "__main__.py"
+	},
+	prompt: func(path string) string {
+		module := strings.TrimSuffix(filepath.Base(path), ".py")
+		return PythonPrompt + "\n\nThe code is the module " + module + " (" + filepath.Base(path) + "). The tests are saved as test_" +
+			module + ".py in the same directory and run with pytest from there, so import what they test with from " + module + " import ..."
+	},
+	Formatters: [][]string{{"ruff", "format", "--quiet"}, {"black", "--quiet"}},
+}
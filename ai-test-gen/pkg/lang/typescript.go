@@ -0,0 +1,44 @@
+package lang
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// TypeScriptPrompt is the instruction preamble for TypeScript tests; %s is
+// the test framework
+var TypeScriptPrompt = `You are an expert TypeScript developer. Generate comprehensive unit tests for the provided TypeScript module using %s. Your output MUST be valid, type-correct TypeScript. Include:
+1. A describe block per exported function or class, with one it per behaviour
+2. it.each tables for sets of inputs and expected results
+3. Edge cases: empty, undefined and null inputs, boundaries, and large values
+4. Error cases with expect(...).toThrow, and rejected promises with await expect(...).rejects
+5. Mocks of network, timers, filesystem and other modules with the framework's mocking functions
+6. Only the imports the tests use, and no use of any
+7. Do not output any explanations, only the code block.`
+
+var typeScript = &Language{
+	Name:    "ts",
+	Aliases: []string{"typescript", "tsx"},
+	Exts:    []string{".ts", ".tsx"},
+	Fences:  []string{"ts", "typescript", "tsx"},
+	testFile: func(base string) string {
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext) + ".spec" + ext
+	},
+	skip: func(base string) bool {
+		name := strings.TrimSuffix(base, filepath.Ext(base))
+		return strings.HasSuffix(name, ".spec") || strings.HasSuffix(name, ".test") || strings.HasSuffix(base, ".d.ts")
+	},
+	prompt: func(path string) string {
+		framework, imports := "Jest", "the Jest globals"
+		if strings.Contains(nearestFile(filepath.Dir(path), "package.json"), `"vitest"`) {
+			framework, imports = "Vitest", "describe, it, expect and vi from 'vitest'"
+		}
+		base := filepath.Base(path)
+		module := strings.TrimSuffix(base, filepath.Ext(base))
+		return fmt.Sprintf(TypeScriptPrompt, framework) + "\n\nThe code is the module " + base + ". The tests are saved as " +
+			module + ".spec" + filepath.Ext(base) + " in the same directory, so import what they test from './" + module + "' and use " + imports + "."
+	},
+	Formatters: [][]string{{"prettier", "--write", "--log-level", "warn"}},
+}
@@ -0,0 +1,137 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Position is a zero-based line and character offset in a document, counted
+// in UTF-16 code units
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is the part of a document between two positions
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// TextEdit replaces Range of a document with NewText
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit is a set of changes to the files of the workspace, applied
+// by the editor as one undoable step
+type WorkspaceEdit struct {
+	DocumentChanges []any `json:"documentChanges"`
+}
+
+type versionedDocument struct {
+	URI string `json:"uri"`
+	// Version is the version of the document the edit was computed for, nil
+	// for a file the editor doesn't have open
+	Version *int `json:"version"`
+}
+
+type documentEdit struct {
+	TextDocument versionedDocument `json:"textDocument"`
+	Edits        []TextEdit        `json:"edits"`
+}
+
+type createFile struct {
+	Kind string `json:"kind"`
+	URI  string `json:"uri"`
+}
+
+// Replace adds the change of the document at uri from old to new, the lines
+// that differ being replaced. version is the version of the open document old
+// was read from, so the editor rejects the edit once it has changed, or nil.
+func (e *WorkspaceEdit) Replace(uri string, version *int, old, new string) {
+	oldLines, newLines := lines(old), lines(new)
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+	edit := TextEdit{
+		Range: Range{
+			Start: Position{Line: prefix},
+			End:   Position{Line: len(oldLines) - suffix},
+		},
+		NewText: strings.Join(newLines[prefix:len(newLines)-suffix], ""),
+	}
+	e.DocumentChanges = append(e.DocumentChanges, documentEdit{TextDocument: versionedDocument{URI: uri, Version: version}, Edits: []TextEdit{edit}})
+}
+
+// Create adds the creation of the file at uri holding text
+func (e *WorkspaceEdit) Create(uri, text string) {
+	e.DocumentChanges = append(e.DocumentChanges,
+		createFile{Kind: "create", URI: uri},
+		documentEdit{TextDocument: versionedDocument{URI: uri}, Edits: []TextEdit{{NewText: text}}},
+	)
+}
+
+// lines splits text after each newline
+func lines(text string) []string {
+	l := strings.SplitAfter(text, "\n")
+	if l[len(l)-1] == "" {
+		l = l[:len(l)-1]
+	}
+	return l
+}
+
+// Offset returns the byte offset of pos in text, clamped to the end of its
+// line and of the text
+func Offset(text string, pos Position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexByte(text[offset:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		offset += i + 1
+	}
+	for units := 0; units < pos.Character && offset < len(text) && text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		units += utf16.RuneLen(r)
+		offset += size
+	}
+	return offset
+}
+
+// URI returns the file URI of path
+func URI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// C:/dir on Windows
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// Path returns the file path of a file URI
+func Path(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
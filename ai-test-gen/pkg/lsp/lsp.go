@@ -0,0 +1,397 @@
+// Package lsp implements the small part of the Language Server Protocol an
+// editor needs to offer code actions over stdio: it tracks the open documents,
+// lists the actions available under the cursor and, when one is picked, asks
+// the editor to apply the workspace edit it computes.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// JSON-RPC and LSP error codes
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	// codeRequestCancelled answers a request the editor cancelled
+	codeRequestCancelled = -32800
+	codeRequestFailed    = -32803
+)
+
+// textDocumentSyncFull has the editor send the whole text on every change
+const textDocumentSyncFull = 1
+
+// Document is a text document open in the editor
+type Document struct {
+	URI  string
+	Path string
+	// Version is the editor's version of the document, nil when it is not
+	// open there and was read from disk
+	Version *int
+	Text    string
+}
+
+// ReadFunc returns the content of the file at path: its text in the editor
+// when it is open there, else its content on disk
+type ReadFunc func(path string) ([]byte, error)
+
+// Action is a code action the server offers
+type Action struct {
+	// Command identifies the action, e.g. aitestgen.generateTests
+	Command string
+	// Kind is the LSP code action kind, e.g. source or refactor.rewrite
+	Kind string
+	// Offer returns the title of the action for pos of doc, reporting
+	// whether it applies there
+	Offer func(doc Document, pos Position) (string, bool)
+	// Run computes the edit the action makes at pos of doc. It may take a
+	// while; the editor can cancel it.
+	Run func(ctx context.Context, doc Document, pos Position, read ReadFunc) (*WorkspaceEdit, error)
+}
+
+// Server answers LSP requests with its actions
+type Server struct {
+	Name    string
+	Version string
+	actions []Action
+
+	mu sync.Mutex
+	// docs are the open documents by path, as editors escape URIs
+	// differently
+	docs map[string]Document
+}
+
+// NewServer returns a server named name offering actions
+func NewServer(name, version string, actions ...Action) *Server {
+	return &Server{Name: name, Version: version, actions: actions, docs: make(map[string]Document)}
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// nullResult is the result of the requests answered with null, which
+// omitempty would otherwise drop
+var nullResult = json.RawMessage("null")
+
+// Serve reads LSP messages from r and writes the responses to w until the
+// editor sends exit, r is exhausted or ctx is cancelled. Commands run
+// concurrently and can be cancelled by the editor.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	var (
+		writeMu sync.Mutex
+		wg      sync.WaitGroup
+		callsMu sync.Mutex
+		calls   = make(map[string]context.CancelFunc)
+		nextID  int
+	)
+	send := func(msg message) {
+		msg.JSONRPC = "2.0"
+		body, err := json.Marshal(msg)
+		if err != nil {
+			return
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body))
+		w.Write(body)
+	}
+	// applyEdit asks the editor to apply edit; its answer is not waited for
+	applyEdit := func(label string, edit *WorkspaceEdit) {
+		writeMu.Lock()
+		nextID++
+		id := json.RawMessage(strconv.Itoa(nextID))
+		writeMu.Unlock()
+		send(message{ID: id, Method: "workspace/applyEdit", Params: mustMarshal(map[string]any{"label": label, "edit": edit})})
+	}
+	defer wg.Wait()
+
+	in := bufio.NewReader(r)
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		body, err := readMessage(in)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			send(message{ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}})
+			continue
+		}
+
+		switch msg.Method {
+		case "exit":
+			return nil
+		case "$/cancelRequest":
+			var p struct {
+				ID json.RawMessage `json:"id"`
+			}
+			if json.Unmarshal(msg.Params, &p) == nil {
+				callsMu.Lock()
+				if cancel, ok := calls[string(p.ID)]; ok {
+					cancel()
+				}
+				callsMu.Unlock()
+			}
+			continue
+		case "workspace/executeCommand":
+			if msg.ID == nil {
+				continue
+			}
+			callCtx, cancel := context.WithCancel(ctx)
+			id := string(msg.ID)
+			callsMu.Lock()
+			calls[id] = cancel
+			callsMu.Unlock()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				label, edit, rpcErr := s.execute(callCtx, msg.Params)
+				callsMu.Lock()
+				delete(calls, id)
+				callsMu.Unlock()
+				cancelled := callCtx.Err() != nil && ctx.Err() == nil
+				cancel()
+				switch {
+				case cancelled:
+					send(message{ID: msg.ID, Error: &rpcError{Code: codeRequestCancelled, Message: "cancelled"}})
+				case rpcErr != nil:
+					send(message{ID: msg.ID, Error: rpcErr})
+				default:
+					applyEdit(label, edit)
+					send(message{ID: msg.ID, Result: nullResult})
+				}
+			}()
+			continue
+		}
+
+		if msg.Method == "" || msg.ID == nil {
+			// answers to applyEdit and notifications such as initialized
+			// need no response
+			s.notify(msg)
+			continue
+		}
+		result, rpcErr := s.handle(msg)
+		send(message{ID: msg.ID, Result: result, Error: rpcErr})
+	}
+}
+
+// readMessage reads the body of the next message, framed by a Content-Length
+// header
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("reading message header: %w", err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("reading message body: %w", err)
+	}
+	return body, nil
+}
+
+// handle answers the requests other than workspace/executeCommand
+func (s *Server) handle(msg message) (any, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		commands := make([]string, len(s.actions))
+		var kinds []string
+		for i, a := range s.actions {
+			commands[i] = a.Command
+			if !slices.Contains(kinds, a.Kind) {
+				kinds = append(kinds, a.Kind)
+			}
+		}
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       map[string]any{"openClose": true, "change": textDocumentSyncFull},
+				"codeActionProvider":     map[string]any{"codeActionKinds": kinds},
+				"executeCommandProvider": map[string]any{"commands": commands},
+			},
+			"serverInfo": map[string]string{"name": s.Name, "version": s.Version},
+		}, nil
+	case "shutdown":
+		return nullResult, nil
+	case "textDocument/codeAction":
+		var p struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			Range Range `json:"range"`
+		}
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return s.codeActions(p.TextDocument.URI, p.Range.Start), nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", msg.Method)}
+}
+
+// notify keeps track of the documents opened, changed and closed in the
+// editor
+func (s *Server) notify(msg message) {
+	var p struct {
+		TextDocument struct {
+			URI     string `json:"uri"`
+			Version int    `json:"version"`
+			Text    string `json:"text"`
+		} `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+	}
+	if msg.Method == "" || json.Unmarshal(msg.Params, &p) != nil {
+		return
+	}
+	uri, path := p.TextDocument.URI, Path(p.TextDocument.URI)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch msg.Method {
+	case "textDocument/didOpen":
+		version := p.TextDocument.Version
+		s.docs[path] = Document{URI: uri, Path: path, Version: &version, Text: p.TextDocument.Text}
+	case "textDocument/didChange":
+		doc, ok := s.docs[path]
+		if !ok || len(p.ContentChanges) == 0 {
+			return
+		}
+		// with full sync, the last change holds the whole text
+		doc.Text = p.ContentChanges[len(p.ContentChanges)-1].Text
+		version := p.TextDocument.Version
+		doc.Version = &version
+		s.docs[path] = doc
+	case "textDocument/didClose":
+		delete(s.docs, path)
+	}
+}
+
+// document returns the document at uri, as open in the editor or else read
+// from disk
+func (s *Server) document(uri string) (Document, error) {
+	path := Path(uri)
+	s.mu.Lock()
+	doc, ok := s.docs[path]
+	s.mu.Unlock()
+	if ok {
+		return doc, nil
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return Document{}, err
+	}
+	return Document{URI: uri, Path: path, Text: string(text)}, nil
+}
+
+// ReadFile returns the content of the file at path, as open in the editor or
+// else on disk
+func (s *Server) ReadFile(path string) ([]byte, error) {
+	s.mu.Lock()
+	doc, ok := s.docs[filepath.Clean(path)]
+	s.mu.Unlock()
+	if ok {
+		return []byte(doc.Text), nil
+	}
+	return os.ReadFile(path)
+}
+
+// codeActions returns the actions offered at pos of the document at uri
+func (s *Server) codeActions(uri string, pos Position) []map[string]any {
+	actions := []map[string]any{}
+	doc, err := s.document(uri)
+	if err != nil {
+		return actions
+	}
+	for _, a := range s.actions {
+		title, ok := a.Offer(doc, pos)
+		if !ok {
+			continue
+		}
+		actions = append(actions, map[string]any{
+			"title": title,
+			"kind":  a.Kind,
+			"command": map[string]any{
+				"title":     title,
+				"command":   a.Command,
+				"arguments": []any{uri, pos},
+			},
+		})
+	}
+	return actions
+}
+
+// execute runs the command of params on the document and position it was
+// offered for, returning the title of the action and its edit
+func (s *Server) execute(ctx context.Context, params json.RawMessage) (string, *WorkspaceEdit, *rpcError) {
+	var p struct {
+		Command   string            `json:"command"`
+		Arguments []json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return "", nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	var (
+		uri string
+		pos Position
+	)
+	if len(p.Arguments) != 2 || json.Unmarshal(p.Arguments[0], &uri) != nil || json.Unmarshal(p.Arguments[1], &pos) != nil {
+		return "", nil, &rpcError{Code: codeInvalidParams, Message: "expected the document URI and position as arguments"}
+	}
+	for _, a := range s.actions {
+		if a.Command != p.Command {
+			continue
+		}
+		doc, err := s.document(uri)
+		if err != nil {
+			return "", nil, &rpcError{Code: codeRequestFailed, Message: err.Error()}
+		}
+		title, ok := a.Offer(doc, pos)
+		if !ok {
+			return "", nil, &rpcError{Code: codeRequestFailed, Message: "the action no longer applies at this position"}
+		}
+		edit, err := a.Run(ctx, doc, pos, s.ReadFile)
+		if err != nil {
+			return "", nil, &rpcError{Code: codeRequestFailed, Message: err.Error()}
+		}
+		return title, edit, nil
+	}
+	return "", nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown command %q", p.Command)}
+}
+
+func mustMarshal(v any) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
@@ -0,0 +1,216 @@
+// Package mcp implements the tool side of the Model Context Protocol over
+// stdio, so AI assistants can call the generator as a set of tools.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ProtocolVersion is the MCP revision the server implements
+const ProtocolVersion = "2025-06-18"
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Tool is a function the client may call
+type Tool struct {
+	Name        string
+	Description string
+	// InputSchema is the JSON Schema of the arguments object
+	InputSchema map[string]any
+	// Handler runs the tool. A returned error is reported to the client as a
+	// failed tool call rather than a protocol error.
+	Handler func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// Server answers MCP requests with its tools
+type Server struct {
+	Name    string
+	Version string
+	tools   []Tool
+}
+
+// NewServer returns a server named name offering tools
+func NewServer(name, version string, tools ...Tool) *Server {
+	return &Server{Name: name, Version: version, tools: tools}
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve reads newline-delimited JSON-RPC messages from r and writes the
+// responses to w until r is exhausted or ctx is cancelled. Tool calls run
+// concurrently and can be cancelled by the client.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	var (
+		writeMu sync.Mutex
+		enc     = json.NewEncoder(w)
+		wg      sync.WaitGroup
+		callsMu sync.Mutex
+		calls   = make(map[string]context.CancelFunc)
+	)
+	send := func(msg message) {
+		msg.JSONRPC = "2.0"
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		enc.Encode(msg)
+	}
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return nil
+		}
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			send(message{ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}})
+			continue
+		}
+
+		switch msg.Method {
+		case "notifications/cancelled":
+			var p struct {
+				RequestID json.RawMessage `json:"requestId"`
+			}
+			if json.Unmarshal(msg.Params, &p) == nil {
+				callsMu.Lock()
+				if cancel, ok := calls[string(p.RequestID)]; ok {
+					cancel()
+				}
+				callsMu.Unlock()
+			}
+			continue
+		case "tools/call":
+			if msg.ID == nil {
+				continue
+			}
+			callCtx, cancel := context.WithCancel(ctx)
+			id := string(msg.ID)
+			callsMu.Lock()
+			calls[id] = cancel
+			callsMu.Unlock()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, rpcErr := s.callTool(callCtx, msg.Params)
+				callsMu.Lock()
+				delete(calls, id)
+				callsMu.Unlock()
+				// a request cancelled by the client gets no response
+				cancelled := callCtx.Err() != nil && ctx.Err() == nil
+				cancel()
+				if !cancelled {
+					send(message{ID: msg.ID, Result: result, Error: rpcErr})
+				}
+			}()
+			continue
+		}
+
+		if msg.ID == nil {
+			// other notifications, e.g. notifications/initialized, need no answer
+			continue
+		}
+		result, rpcErr := s.handle(msg)
+		send(message{ID: msg.ID, Result: result, Error: rpcErr})
+	}
+	return scanner.Err()
+}
+
+// handle answers the requests other than tools/call
+func (s *Server) handle(msg message) (any, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(msg.Params, &p)
+		version := ProtocolVersion
+		// older clients are answered in their own revision, which the tool
+		// methods used here have not changed
+		if p.ProtocolVersion != "" && p.ProtocolVersion < ProtocolVersion {
+			version = p.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": s.Name, "version": s.Version},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		tools := make([]map[string]any, len(s.tools))
+		for i, t := range s.tools {
+			tools[i] = map[string]any{"name": t.Name, "description": t.Description, "inputSchema": t.InputSchema}
+		}
+		return map[string]any{"tools": tools}, nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", msg.Method)}
+}
+
+// callTool runs the tool named in params
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (any, *rpcError) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	for _, t := range s.tools {
+		if t.Name != p.Name {
+			continue
+		}
+		args := p.Arguments
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		text, err := t.Handler(ctx, args)
+		if err != nil {
+			return toolResult(err.Error(), true), nil
+		}
+		return toolResult(text, false), nil
+	}
+	return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool %q", p.Name)}
+}
+
+func toolResult(text string, isError bool) map[string]any {
+	return map[string]any{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+// ErrInvalidArguments is wrapped by handlers rejecting their arguments
+var ErrInvalidArguments = errors.New("invalid arguments")
+
+// DecodeArgs unmarshals tool arguments into v, wrapping ErrInvalidArguments on failure
+func DecodeArgs(args json.RawMessage, v any) error {
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package mockgen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// Mock styles
+const (
+	// StyleFunc generates structs with one function field per method
+	StyleFunc = "func"
+	// StyleMockery generates testify/mock based mocks in the style of mockery
+	StyleMockery = "mockery"
+)
+
+// Interface is an interface declared in a source file
+type Interface struct {
+	Name       string
+	TypeParams *ast.FieldList
+	Methods    []Method
+}
+
+// Method is a single interface method with its parameters and results as source text
+type Method struct {
+	Name     string
+	Params   []Param
+	Results  []string
+	Variadic bool
+}
+
+// Param is a named method parameter
+type Param struct {
+	Name string
+	Type string
+}
+
+// Interfaces returns the interfaces declared in the file, with locally
+// embedded interfaces flattened. Interfaces embedding types from other
+// packages are reported in skipped.
+func Interfaces(f *source.File) (ifaces []Interface, skipped []string) {
+	decls := make(map[string]*ast.TypeSpec)
+	var order []string
+	for _, decl := range f.AST.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if _, ok := ts.Type.(*ast.InterfaceType); ok {
+				decls[ts.Name.Name] = ts
+				order = append(order, ts.Name.Name)
+			}
+		}
+	}
+
+	for _, name := range order {
+		ts := decls[name]
+		methods, err := collectMethods(f, decls, ts.Type.(*ast.InterfaceType), map[string]bool{name: true})
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		if len(methods) == 0 {
+			continue
+		}
+		ifaces = append(ifaces, Interface{Name: name, TypeParams: ts.TypeParams, Methods: methods})
+	}
+	return ifaces, skipped
+}
+
+func collectMethods(f *source.File, decls map[string]*ast.TypeSpec, it *ast.InterfaceType, seen map[string]bool) ([]Method, error) {
+	var methods []Method
+	for _, field := range it.Methods.List {
+		switch t := field.Type.(type) {
+		case *ast.FuncType:
+			for _, n := range field.Names {
+				methods = append(methods, newMethod(f, n.Name, t))
+			}
+		case *ast.Ident:
+			embedded, ok := decls[t.Name]
+			if !ok || seen[t.Name] {
+				return nil, fmt.Errorf("cannot resolve embedded interface %s", t.Name)
+			}
+			seen[t.Name] = true
+			inner, err := collectMethods(f, decls, embedded.Type.(*ast.InterfaceType), seen)
+			delete(seen, t.Name)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range inner {
+				if !hasMethod(methods, m.Name) {
+					methods = append(methods, m)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("embeds %s, which is not declared in this file", text(f, field.Type))
+		}
+	}
+	return methods, nil
+}
+
+func hasMethod(methods []Method, name string) bool {
+	for _, m := range methods {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func newMethod(f *source.File, name string, fn *ast.FuncType) Method {
+	m := Method{Name: name}
+	i := 0
+	for _, field := range fn.Params.List {
+		typ := field.Type
+		if ell, ok := typ.(*ast.Ellipsis); ok {
+			m.Variadic = true
+			typ = ell.Elt
+		}
+		typeText := text(f, typ)
+		if len(field.Names) == 0 {
+			m.Params = append(m.Params, Param{Name: fmt.Sprintf("p%d", i), Type: typeText})
+			i++
+			continue
+		}
+		for _, n := range field.Names {
+			pname := n.Name
+			if pname == "_" {
+				pname = fmt.Sprintf("p%d", i)
+			}
+			m.Params = append(m.Params, Param{Name: pname, Type: typeText})
+			i++
+		}
+	}
+	if fn.Results != nil {
+		for _, field := range fn.Results.List {
+			count := len(field.Names)
+			if count == 0 {
+				count = 1
+			}
+			for j := 0; j < count; j++ {
+				m.Results = append(m.Results, text(f, field.Type))
+			}
+		}
+	}
+	return m
+}
+
+func text(f *source.File, node ast.Node) string {
+	return string(f.Src[f.Fset.Position(node.Pos()).Offset:f.Fset.Position(node.End()).Offset])
+}
+
+// Generate returns a test file containing mocks for every interface in f
+func Generate(f *source.File, style string) (string, []string, error) {
+	ifaces, skipped := Interfaces(f)
+	if len(ifaces) == 0 {
+		return "", skipped, fmt.Errorf("no mockable interfaces found")
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// Code generated by aitestgen mocks. DO NOT EDIT.\n\npackage %s\n", f.Package())
+
+	imports := importLines(f)
+	if style == StyleMockery {
+		imports = append(imports, `"github.com/stretchr/testify/mock"`)
+	}
+	if len(imports) > 0 {
+		sb.WriteString("\nimport (\n")
+		for _, imp := range imports {
+			sb.WriteString("\t" + imp + "\n")
+		}
+		sb.WriteString(")\n")
+	}
+
+	for _, iface := range ifaces {
+		sb.WriteString("\n")
+		switch style {
+		case StyleMockery:
+			writeMockery(&sb, f, iface)
+		case StyleFunc, "":
+			writeFuncMock(&sb, f, iface)
+		default:
+			return "", skipped, fmt.Errorf("unknown mock style %q", style)
+		}
+	}
+
+	out, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return "", skipped, fmt.Errorf("generated mocks do not format: %w", err)
+	}
+	return string(out), skipped, nil
+}
+
+func importLines(f *source.File) []string {
+	var lines []string
+	for _, imp := range f.AST.Imports {
+		line := imp.Path.Value
+		if imp.Name != nil {
+			line = imp.Name.Name + " " + line
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// typeParams returns the declaration ("[T any]") and use ("[T]") forms of an
+// interface's type parameters
+func typeParams(f *source.File, fl *ast.FieldList) (decl, use string) {
+	if fl == nil || len(fl.List) == 0 {
+		return "", ""
+	}
+	var names []string
+	for _, field := range fl.List {
+		for _, n := range field.Names {
+			names = append(names, n.Name)
+		}
+	}
+	return text(f, fl), "[" + strings.Join(names, ", ") + "]"
+}
+
+func signature(m Method) (params, args, results string) {
+	var ps, as []string
+	for i, p := range m.Params {
+		typ := p.Type
+		arg := p.Name
+		if m.Variadic && i == len(m.Params)-1 {
+			typ = "..." + typ
+			arg += "..."
+		}
+		ps = append(ps, p.Name+" "+typ)
+		as = append(as, arg)
+	}
+	results = strings.Join(m.Results, ", ")
+	if len(m.Results) > 1 {
+		results = "(" + results + ")"
+	}
+	return strings.Join(ps, ", "), strings.Join(as, ", "), results
+}
+
+func writeFuncMock(sb *strings.Builder, f *source.File, iface Interface) {
+	decl, use := typeParams(f, iface.TypeParams)
+	mock := "Mock" + iface.Name
+
+	fmt.Fprintf(sb, "// %s is a mock implementation of %s. Set the XxxFunc field for each\n// method the test exercises.\n", mock, iface.Name)
+	fmt.Fprintf(sb, "type %s%s struct {\n", mock, decl)
+	for _, m := range iface.Methods {
+		params, _, results := signature(m)
+		fmt.Fprintf(sb, "\t%sFunc func(%s) %s\n", m.Name, params, results)
+	}
+	sb.WriteString("}\n")
+
+	for _, m := range iface.Methods {
+		params, args, results := signature(m)
+		fmt.Fprintf(sb, "\nfunc (_m *%s%s) %s(%s) %s {\n", mock, use, m.Name, params, results)
+		fmt.Fprintf(sb, "\tif _m.%sFunc == nil {\n\t\tpanic(\"%s.%s called but %sFunc is not set\")\n\t}\n", m.Name, mock, m.Name, m.Name)
+		if len(m.Results) > 0 {
+			fmt.Fprintf(sb, "\treturn _m.%sFunc(%s)\n", m.Name, args)
+		} else {
+			fmt.Fprintf(sb, "\t_m.%sFunc(%s)\n", m.Name, args)
+		}
+		sb.WriteString("}\n")
+	}
+}
+
+func writeMockery(sb *strings.Builder, f *source.File, iface Interface) {
+	decl, use := typeParams(f, iface.TypeParams)
+	mock := "Mock" + iface.Name
+
+	fmt.Fprintf(sb, "// %s is a testify mock implementation of %s\n", mock, iface.Name)
+	fmt.Fprintf(sb, "type %s%s struct {\n\tmock.Mock\n}\n", mock, decl)
+
+	for _, m := range iface.Methods {
+		params, _, results := signature(m)
+		var callArgs []string
+		for _, p := range m.Params {
+			callArgs = append(callArgs, p.Name)
+		}
+
+		fmt.Fprintf(sb, "\nfunc (_m *%s%s) %s(%s) %s {\n", mock, use, m.Name, params, results)
+		if len(m.Results) == 0 {
+			fmt.Fprintf(sb, "\t_m.Called(%s)\n}\n", strings.Join(callArgs, ", "))
+			continue
+		}
+		fmt.Fprintf(sb, "\t_ret := _m.Called(%s)\n", strings.Join(callArgs, ", "))
+		var rets []string
+		for i, r := range m.Results {
+			if r == "error" {
+				rets = append(rets, fmt.Sprintf("_ret.Error(%d)", i))
+				continue
+			}
+			fmt.Fprintf(sb, "\tvar _r%d %s\n\tif v := _ret.Get(%d); v != nil {\n\t\t_r%d = v.(%s)\n\t}\n", i, r, i, i, r)
+			rets = append(rets, fmt.Sprintf("_r%d", i))
+		}
+		fmt.Fprintf(sb, "\treturn %s\n}\n", strings.Join(rets, ", "))
+	}
+}
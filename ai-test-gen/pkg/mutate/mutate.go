@@ -0,0 +1,168 @@
+// Package mutate applies small mutations to Go source and runs the tests
+// against each one, finding the changes in behaviour the tests don't notice.
+package mutate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/gotool"
+	"github.com/knbr13/aitestgen/pkg/source"
+)
+
+// Mutant is a copy of a source file with one operator changed
+type Mutant struct {
+	// Func is the key of the mutated function
+	Func string
+	// Line is the line of the change
+	Line int
+	// Original and Mutated are the changed expression before and after
+	Original string
+	Mutated  string
+
+	src []byte
+}
+
+// String describes the mutation for a prompt or log
+func (m Mutant) String() string {
+	return fmt.Sprintf("line %d in %s: `%s` changed to `%s`", m.Line, m.Func, m.Original, m.Mutated)
+}
+
+// replacements maps each mutated operator to its replacement
+var replacements = map[token.Token]token.Token{
+	token.EQL:  token.NEQ,
+	token.NEQ:  token.EQL,
+	token.LSS:  token.LEQ,
+	token.LEQ:  token.LSS,
+	token.GTR:  token.GEQ,
+	token.GEQ:  token.GTR,
+	token.ADD:  token.SUB,
+	token.SUB:  token.ADD,
+	token.MUL:  token.QUO,
+	token.QUO:  token.MUL,
+	token.LAND: token.LOR,
+	token.LOR:  token.LAND,
+	token.INC:  token.DEC,
+	token.DEC:  token.INC,
+}
+
+// Generate returns the mutants of file obtained by changing one operator or
+// boolean constant in the bodies of funcs, in source order. At most limit
+// mutants are returned, spread over the file, when limit is positive.
+func Generate(file *source.File, funcs []source.Func, limit int) []Mutant {
+	var mutants []Mutant
+	for _, fn := range funcs {
+		if fn.Decl.Body == nil {
+			continue
+		}
+		ast.Inspect(fn.Decl.Body, func(n ast.Node) bool {
+			switch e := n.(type) {
+			case *ast.BinaryExpr:
+				if to, ok := replacements[e.Op]; ok {
+					mutants = append(mutants, mutant(file, fn, e, e.OpPos, e.Op.String(), to.String()))
+				}
+			case *ast.IncDecStmt:
+				mutants = append(mutants, mutant(file, fn, e, e.TokPos, e.Tok.String(), replacements[e.Tok].String()))
+			case *ast.Ident:
+				switch e.Name {
+				case "true":
+					mutants = append(mutants, mutant(file, fn, e, e.Pos(), "true", "false"))
+				case "false":
+					mutants = append(mutants, mutant(file, fn, e, e.Pos(), "false", "true"))
+				}
+			}
+			return true
+		})
+	}
+
+	if limit > 0 && len(mutants) > limit {
+		sampled := make([]Mutant, limit)
+		for i := range sampled {
+			sampled[i] = mutants[i*len(mutants)/limit]
+		}
+		mutants = sampled
+	}
+	return mutants
+}
+
+// mutant replaces the text from at to the operator to in the expression node
+func mutant(file *source.File, fn source.Func, node ast.Node, at token.Pos, from, to string) Mutant {
+	offset := file.Fset.Position(at).Offset
+	src := make([]byte, 0, len(file.Src)+len(to)-len(from))
+	src = append(src, file.Src[:offset]...)
+	src = append(src, to...)
+	src = append(src, file.Src[offset+len(from):]...)
+
+	start, end := file.Fset.Position(node.Pos()).Offset, file.Fset.Position(node.End()).Offset
+	original := string(file.Src[start:end])
+	mutated := original[:offset-start] + to + original[offset-start+len(from):]
+	return Mutant{
+		Func:     fn.Key(),
+		Line:     file.Fset.Position(at).Line,
+		Original: original,
+		Mutated:  mutated,
+		src:      src,
+	}
+}
+
+// Result is the outcome of running the tests against a mutant
+type Result int
+
+const (
+	// Killed means a test failed, so the tests catch the mutation
+	Killed Result = iota
+	// Survived means the tests passed despite the mutation
+	Survived
+	// Invalid means the mutant doesn't compile, e.g. - on strings
+	Invalid
+)
+
+// Run runs the tests of the package holding filename against each mutant and
+// returns their results. The source file is never modified: the mutants are
+// substituted with go test -overlay.
+func Run(ctx context.Context, filename string, mutants []Mutant) ([]Result, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp("", "aitestgen-mutate-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	results := make([]Result, len(mutants))
+	for i, m := range mutants {
+		mutated := filepath.Join(tmp, fmt.Sprintf("mutant%d.go", i))
+		if err := os.WriteFile(mutated, m.src, 0644); err != nil {
+			return nil, err
+		}
+		overlay, err := json.Marshal(map[string]map[string]string{"Replace": {abs: mutated}})
+		if err != nil {
+			return nil, err
+		}
+		overlayFile := filepath.Join(tmp, "overlay.json")
+		if err := os.WriteFile(overlayFile, overlay, 0644); err != nil {
+			return nil, err
+		}
+
+		out, err := gotool.TestOverlay(ctx, filepath.Dir(abs), overlayFile)
+		switch {
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case err == nil:
+			results[i] = Survived
+		case strings.Contains(out, "[build failed]") || strings.Contains(out, "[setup failed]"):
+			results[i] = Invalid
+		default:
+			results[i] = Killed
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,95 @@
+// Package pipeline runs the file processing shared by the commands: the input
+// files are listed and filtered, each is processed concurrently into its
+// output, and the outcome of every file is reported.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/knbr13/aitestgen/pkg/runner"
+)
+
+// ErrNoFiles is returned by Run when Walk lists no files
+var ErrNoFiles = errors.New("no files to process")
+
+// Job is an input file and the output written for it
+type Job struct {
+	Input  string
+	Output string
+}
+
+// Pipeline is the walk, filter, process and report stages of a run. Process
+// both produces the output of a file and writes it, since commands such as
+// generate check and repair what they write.
+type Pipeline struct {
+	// Walk lists the input files
+	Walk func(ctx context.Context) ([]string, error)
+	// Filter narrows the listed files down to those to process, e.g. the ones
+	// an interrupted run didn't finish; nil keeps them all
+	Filter func(files []string) ([]string, error)
+	// Output returns the output file of an input; nil leaves it empty
+	Output func(input string) string
+	// Process produces and writes the output of a job
+	Process func(ctx context.Context, job Job) error
+	// Report is called with the outcome of every job, from the goroutine
+	// that processed it; nil reports nothing
+	Report func(job Job, err error)
+	// Workers is the number of jobs processed in parallel, see runner.Run
+	Workers int
+}
+
+// Result lists the files a run went through
+type Result struct {
+	// Files are the files Walk listed
+	Files []string
+	// Pending are the files Filter kept, processed unless the run was
+	// cancelled first
+	Pending []string
+}
+
+// Run lists and filters the files and processes them until every one is done
+// or ctx is cancelled. The failures of single files are left to Report: the
+// error returned is that of Walk or Filter, or ErrNoFiles.
+func (p *Pipeline) Run(ctx context.Context) (Result, error) {
+	var res Result
+	files, err := p.Walk(ctx)
+	if err != nil {
+		return res, fmt.Errorf("listing files: %w", err)
+	}
+	if len(files) == 0 {
+		return res, ErrNoFiles
+	}
+	res.Files, res.Pending = files, files
+	if p.Filter != nil {
+		if res.Pending, err = p.Filter(files); err != nil {
+			return res, err
+		}
+	}
+
+	runner.Run(ctx, res.Pending, p.Workers, func(ctx context.Context, file string) {
+		p.Do(ctx, file)
+	})
+	return res, nil
+}
+
+// Do processes and reports the single file input, as Run does for each file,
+// and returns Process's error. Watches call it for the files that change.
+func (p *Pipeline) Do(ctx context.Context, input string) error {
+	job := p.Job(input)
+	err := p.Process(ctx, job)
+	if p.Report != nil {
+		p.Report(job, err)
+	}
+	return err
+}
+
+// Job returns the job of input
+func (p *Pipeline) Job(input string) Job {
+	job := Job{Input: input}
+	if p.Output != nil {
+		job.Output = p.Output(input)
+	}
+	return job
+}
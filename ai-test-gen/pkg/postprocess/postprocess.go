@@ -0,0 +1,152 @@
+// Package postprocess rewrites generated files before they are written, to
+// add license headers, build tags or fixes of in-house linters. Processors are
+// either Go values implementing Processor or external commands that read the
+// content on stdin and print the new content on stdout.
+package postprocess
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Kinds of generated files
+const (
+	KindTest = "test"
+	KindDoc  = "doc"
+)
+
+// File is a generated file about to be written
+type File struct {
+	// Path is where the file is written
+	Path string
+	// Kind is KindTest or KindDoc
+	Kind    string
+	Content []byte
+}
+
+// Processor rewrites a generated file, returning its new content. As a file
+// may be processed again after it is regenerated, processors should leave
+// content they already changed as it is.
+type Processor interface {
+	Process(ctx context.Context, file File) ([]byte, error)
+}
+
+// Func adapts a function to a Processor
+type Func func(ctx context.Context, file File) ([]byte, error)
+
+// Process calls f
+func (f Func) Process(ctx context.Context, file File) ([]byte, error) {
+	return f(ctx, file)
+}
+
+// Chain runs processors in order, each on the output of the previous one
+type Chain []Processor
+
+// Process runs the processors of c on file
+func (c Chain) Process(ctx context.Context, file File) ([]byte, error) {
+	for _, p := range c {
+		out, err := p.Process(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		file.Content = out
+	}
+	return file.Content, nil
+}
+
+// Command is an external post-processor. It gets the content on stdin, and
+// the path and kind of the file in the AITESTGEN_FILE and AITESTGEN_KIND
+// environment variables, and prints the new content on stdout. A command
+// that exits with an error fails the file, with its stderr in the error.
+type Command struct {
+	// Line is the command line as configured
+	Line string
+	Args []string
+}
+
+// ParseCommand splits a command line into its arguments. Arguments are
+// separated by spaces and may be quoted with ' or ". Programs given as a
+// relative path, like ./scripts/header.sh, are resolved against dir.
+func ParseCommand(line, dir string) (Command, error) {
+	args, err := splitArgs(line)
+	if err != nil {
+		return Command{}, fmt.Errorf("post-process command %q: %w", line, err)
+	}
+	if len(args) == 0 {
+		return Command{}, errors.New("empty post-process command")
+	}
+	if prog := args[0]; !filepath.IsAbs(prog) && strings.ContainsAny(prog, `/\`) {
+		// absolute, as exec looks up names without a slash in PATH
+		abs, err := filepath.Abs(filepath.Join(dir, prog))
+		if err != nil {
+			return Command{}, err
+		}
+		args[0] = abs
+	}
+	return Command{Line: line, Args: args}, nil
+}
+
+// Process runs the command on file
+func (c Command) Process(ctx context.Context, file File) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	cmd.Stdin = bytes.NewReader(file.Content)
+	cmd.Env = append(os.Environ(), "AITESTGEN_FILE="+file.Path, "AITESTGEN_KIND="+file.Kind)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("post-process %q: %w: %s", c.Line, err, msg)
+		}
+		return nil, fmt.Errorf("post-process %q: %w", c.Line, err)
+	}
+	if stdout.Len() == 0 && len(file.Content) > 0 {
+		return nil, fmt.Errorf("post-process %q printed nothing", c.Line)
+	}
+	return stdout.Bytes(), nil
+}
+
+// splitArgs splits line on spaces outside of quotes
+func splitArgs(line string) ([]string, error) {
+	var (
+		args   []string
+		arg    strings.Builder
+		quote  rune
+		inWord bool
+	)
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, arg.String())
+				arg.Reset()
+				inWord = false
+			}
+		default:
+			arg.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,249 @@
+// Package promptpack fetches, stores and loads prompt packs: versioned sets
+// of the default prompts and models, published on a release channel so that
+// projects can pin one and generate with the same instructions across a team.
+package promptpack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultChannel is the release channel self-update reads when none is
+// configured
+const DefaultChannel = "https://github.com/knbr13/aitestgen/releases/latest/download/prompt-packs.json"
+
+// Embedded is the version reported for the prompts built into the binary
+const Embedded = "embedded"
+
+// ErrNotInstalled is returned by Load for a version that was never fetched
+var ErrNotInstalled = errors.New("prompt pack not installed")
+
+// maxSize bounds the size of the channel and pack documents
+const maxSize = 4 << 20
+
+// Pack is a versioned set of default prompts and models. Prompts are keyed
+// by the names of generator.DefaultPrompts and models by provider; those
+// missing keep the built-in default.
+type Pack struct {
+	Version string            `json:"version"`
+	Prompts map[string]string `json:"prompts,omitempty"`
+	Models  map[string]string `json:"models,omitempty"`
+}
+
+// Channel lists the packs published on a release channel
+type Channel struct {
+	// Latest is the version self-update installs unless told otherwise
+	Latest string             `json:"latest"`
+	Packs  map[string]Release `json:"packs"`
+}
+
+// Release is where a pack is published. URL may be relative to the channel.
+type Release struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// validVersion keeps versions usable as file names
+var validVersion = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._+-]*$`)
+
+// CheckVersion returns an error for a version that can't name a pack
+func CheckVersion(version string) error {
+	if !validVersion.MatchString(version) || version == Embedded {
+		return fmt.Errorf("invalid prompt pack version %q", version)
+	}
+	return nil
+}
+
+// DefaultDir returns where packs are stored, under the user config directory,
+// e.g. ~/.config/aitestgen/prompt-packs on Linux
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "aitestgen", "prompt-packs"), nil
+}
+
+// Fetch reads the channel at channelURL and downloads the pack of version,
+// or of the latest one when version is empty, checking its hash. It returns
+// the pack and its document, as saved by Save.
+func Fetch(ctx context.Context, client *http.Client, channelURL, version string) (Pack, []byte, error) {
+	data, err := get(ctx, client, channelURL)
+	if err != nil {
+		return Pack{}, nil, fmt.Errorf("reading the release channel: %w", err)
+	}
+	var ch Channel
+	if err := json.Unmarshal(data, &ch); err != nil {
+		return Pack{}, nil, fmt.Errorf("invalid release channel %s: %w", channelURL, err)
+	}
+	if version == "" {
+		version = ch.Latest
+	}
+	rel, ok := ch.Packs[version]
+	if !ok {
+		return Pack{}, nil, fmt.Errorf("prompt pack %q is not published on %s (available: %s)", version, channelURL, strings.Join(ch.Versions(), ", "))
+	}
+	packURL, err := resolve(channelURL, rel.URL)
+	if err != nil {
+		return Pack{}, nil, err
+	}
+
+	data, err = get(ctx, client, packURL)
+	if err != nil {
+		return Pack{}, nil, fmt.Errorf("downloading prompt pack %s: %w", version, err)
+	}
+	if sum := Sum(data); !strings.EqualFold(sum, rel.SHA256) {
+		return Pack{}, nil, fmt.Errorf("prompt pack %s has SHA-256 %s, the channel lists %s", version, sum, rel.SHA256)
+	}
+	p, err := parse(data)
+	if err != nil {
+		return Pack{}, nil, err
+	}
+	if p.Version != version {
+		return Pack{}, nil, fmt.Errorf("prompt pack %s declares version %q", version, p.Version)
+	}
+	return p, data, nil
+}
+
+// Versions returns the versions published on the channel, sorted
+func (ch Channel) Versions() []string {
+	versions := make([]string, 0, len(ch.Packs))
+	for v := range ch.Packs {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// resolve returns ref relative to the URL of the channel
+func resolve(channelURL, ref string) (string, error) {
+	base, err := url.Parse(channelURL)
+	if err != nil {
+		return "", err
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt pack URL %q: %w", ref, err)
+	}
+	return u.String(), nil
+}
+
+// get returns the body of a GET request to rawURL, or the content of the
+// file it names when it is a local path or file URL
+func get(ctx context.Context, client *http.Client, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "file":
+		return os.ReadFile(filepath.FromSlash(u.Path))
+	default:
+		return os.ReadFile(rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("GET %s: response larger than %d bytes", rawURL, maxSize)
+	}
+	return data, nil
+}
+
+// parse decodes a pack document
+func parse(data []byte) (Pack, error) {
+	var p Pack
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Pack{}, fmt.Errorf("invalid prompt pack: %w", err)
+	}
+	if err := CheckVersion(p.Version); err != nil {
+		return Pack{}, err
+	}
+	return p, nil
+}
+
+// Sum returns the hex SHA-256 of data
+func Sum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Save stores the document data of pack version in dir
+func Save(dir, version string, data []byte) error {
+	if err := CheckVersion(version); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, version+".json"), data, 0644)
+}
+
+// Load reads the pack of version stored in dir
+func Load(dir, version string) (Pack, error) {
+	if err := CheckVersion(version); err != nil {
+		return Pack{}, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, version+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Pack{}, fmt.Errorf("%w: %s", ErrNotInstalled, version)
+	}
+	if err != nil {
+		return Pack{}, err
+	}
+	p, err := parse(data)
+	if err != nil {
+		return Pack{}, fmt.Errorf("%s: %w", version, err)
+	}
+	if p.Version != version {
+		return Pack{}, fmt.Errorf("prompt pack %s declares version %q", version, p.Version)
+	}
+	return p, nil
+}
+
+// Installed returns the versions stored in dir, sorted
+func Installed(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, e := range entries {
+		if v, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() && CheckVersion(v) == nil {
+			versions = append(versions, v)
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
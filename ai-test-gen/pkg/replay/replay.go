@@ -0,0 +1,97 @@
+// Package replay saves model responses as fixtures and answers prompts from
+// them later without network access, so that everything done with a
+// response (code extraction, AST fixes, formatting, writing files) can be
+// reproduced and debugged.
+package replay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+)
+
+// ErrNotRecorded is returned when replaying a prompt no fixture holds
+var ErrNotRecorded = errors.New("no recorded response")
+
+// Fixture is a recorded request, stored as JSON in a file named after the
+// hash of the prompt
+type Fixture struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Prompt   string `json:"prompt"`
+	Response string `json:"response"`
+}
+
+// Key returns the fixture name of a prompt. Only the prompt counts, so that
+// fixtures recorded with one model replay whatever model is selected.
+func Key(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+func path(dir, prompt string) string {
+	return filepath.Join(dir, Key(prompt)+".json")
+}
+
+// Record returns a provider that passes prompts to p and saves each response
+// in dir, along with the provider and model names it came from
+func Record(p generator.Provider, dir, provider, model string) generator.Provider {
+	return &recorder{Provider: p, dir: dir, provider: provider, model: model}
+}
+
+type recorder struct {
+	generator.Provider
+	dir      string
+	provider string
+	model    string
+}
+
+func (r *recorder) Generate(ctx context.Context, prompt string) (string, error) {
+	resp, err := r.Provider.Generate(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(Fixture{Provider: r.provider, Model: r.model, Prompt: prompt, Response: resp}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return "", fmt.Errorf("recording response: %w", err)
+	}
+	if err := os.WriteFile(path(r.dir, prompt), append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("recording response: %w", err)
+	}
+	return resp, nil
+}
+
+// Replay returns a provider answering from the fixtures in dir. Prompts
+// without one fail with ErrNotRecorded.
+func Replay(dir string) generator.Provider {
+	return &replayer{dir: dir}
+}
+
+type replayer struct {
+	dir string
+}
+
+func (r *replayer) Generate(ctx context.Context, prompt string) (string, error) {
+	data, err := os.ReadFile(path(r.dir, prompt))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w in %s for prompt %s", ErrNotRecorded, r.dir, Key(prompt)[:12])
+	}
+	if err != nil {
+		return "", err
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return "", fmt.Errorf("reading fixture: %w", err)
+	}
+	return f.Response, nil
+}
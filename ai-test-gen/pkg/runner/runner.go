@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultConcurrency is the number of workers used when none is configured
+const DefaultConcurrency = 4
+
+// Run calls fn for every item using a pool of at most workers goroutines.
+// Items not yet started when ctx is cancelled are skipped.
+func Run(ctx context.Context, items []string, workers int, fn func(ctx context.Context, item string)) {
+	if workers <= 0 {
+		workers = DefaultConcurrency
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				fn(ctx, item)
+			}
+		}()
+	}
+
+	for _, item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- item:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,229 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFile lists exclude globs for a folder, one per line, with blank lines
+// and lines starting with # skipped
+const IgnoreFile = ".aitestgenignore"
+
+// Filter selects files by their slash separated path relative to the walked
+// root. Globs follow the rules of Excluded.
+type Filter struct {
+	// Exclude skips matching files and directories
+	Exclude []string
+	// Include, when set, keeps only the files matching one of its globs
+	Include []string
+	// Build, when set, skips the files its GOOS, GOARCH, tags and cgo
+	// setting leave out of the build
+	Build *build.Context
+}
+
+// Skip reports whether the filter leaves out rel. Directories are only checked
+// against Exclude so that included files below them are still found.
+func (f Filter) Skip(rel string, dir bool) bool {
+	if Excluded(rel, f.Exclude) {
+		return true
+	}
+	return !dir && len(f.Include) > 0 && !Excluded(rel, f.Include)
+}
+
+// GoFiles returns the non-test Go files under root that pass filter. Like the
+// go tool, it skips vendor and testdata directories, directories starting with
+// "." or "_", generated files and, with filter.Build, the files excluded by
+// their build constraints.
+func GoFiles(root string, filter Filter) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if d.IsDir() {
+			if IgnoredDir(d.Name()) || filter.Skip(rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") || strings.HasSuffix(p, "_test.go") || filter.Skip(rel, false) {
+			return nil
+		}
+		if generated, err := IsGenerated(p); err != nil || generated {
+			return err
+		}
+		if filter.Build != nil {
+			if ok, err := Buildable(filter.Build, p); err != nil || !ok {
+				return err
+			}
+		}
+		files = append(files, p)
+		return nil
+	})
+	return files, err
+}
+
+// Buildable reports whether ctx builds file: its name suffixes and //go:build
+// constraints match ctx's GOOS, GOARCH and tags, and it doesn't import "C"
+// unless cgo is enabled
+func Buildable(ctx *build.Context, file string) (bool, error) {
+	ok, err := ctx.MatchFile(filepath.Dir(file), filepath.Base(file))
+	if err != nil || !ok || ctx.CgoEnabled {
+		return ok, err
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+	if err != nil {
+		return false, err
+	}
+	for _, imp := range f.Imports {
+		if imp.Path.Value == `"C"` {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// TestFiles returns the _test.go files of the module rooted at root, skipping
+// the directories GoFiles skips, nested modules and generated files
+func TestFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p == root {
+				return nil
+			}
+			if IgnoredDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, "_test.go") {
+			return nil
+		}
+		if generated, err := IsGenerated(p); err != nil || generated {
+			return err
+		}
+		files = append(files, p)
+		return nil
+	})
+	return files, err
+}
+
+// IgnoredDir reports whether the go tool ignores directories with this name
+func IgnoredDir(name string) bool {
+	return name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+var generatedRe = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// IsGenerated reports whether the Go file carries the standard
+// "Code generated ... DO NOT EDIT." comment before its package clause
+func IsGenerated(file string) (bool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "package ") {
+			return false, nil
+		}
+		if generatedRe.MatchString(line) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ReadIgnoreFile returns the globs listed in the IgnoreFile of dir, if any
+func ReadIgnoreFile(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, IgnoreFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var globs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// a trailing slash marks a directory, which the glob matches anyway
+		globs = append(globs, strings.TrimSuffix(line, "/"))
+	}
+	return globs, nil
+}
+
+// Excluded reports whether the relative slash path matches any of the globs
+func Excluded(rel string, globs []string) bool {
+	for _, glob := range globs {
+		if matchGlob(glob, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGlob(glob, rel string) bool {
+	glob = strings.TrimPrefix(filepath.ToSlash(glob), "./")
+	if !strings.Contains(glob, "/") {
+		for _, elem := range strings.Split(rel, "/") {
+			if ok, _ := path.Match(glob, elem); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return matchSegments(strings.Split(glob, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(glob, elems []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			if len(glob) == 1 {
+				return true
+			}
+			for i := 0; i <= len(elems); i++ {
+				if matchSegments(glob[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], elems[0]); !ok {
+			return false
+		}
+		glob, elems = glob[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
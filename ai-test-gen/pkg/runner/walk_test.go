@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestExcluded(t *testing.T) {
+	tests := []struct {
+		glob string
+		rel  string
+		want bool
+	}{
+		// globs without a slash match any path element
+		{"*.pb.go", "api/v1/service.pb.go", true},
+		{"*.pb.go", "api/v1/service.go", false},
+		{"mocks", "internal/mocks", true},
+		{"mocks", "internal/mocks/store.go", true},
+		{"mock", "internal/mocks/store.go", false},
+		// globs with a slash match the whole path
+		{"internal/gen", "internal/gen", true},
+		{"internal/gen", "pkg/internal/gen", false},
+		{"./internal/gen", "internal/gen", true},
+		{"internal/*.go", "internal/a.go", true},
+		{"internal/*.go", "internal/sub/a.go", false},
+		// ** matches any number of elements, none included
+		{"**/gen", "gen", true},
+		{"**/gen", "a/b/gen", true},
+		{"internal/**", "internal/a/b.go", true},
+		{"internal/**", "pkg/a.go", false},
+		{"a/**/b.go", "a/b.go", true},
+		{"a/**/b.go", "a/x/y/b.go", true},
+		{"a/**/b.go", "a/x/y/c.go", false},
+		{"**/*_gen.go", "pkg/api/types_gen.go", true},
+	}
+	for _, tt := range tests {
+		if got := Excluded(tt.rel, []string{tt.glob}); got != tt.want {
+			t.Errorf("Excluded(%q, [%q]) = %v, want %v", tt.rel, tt.glob, got, tt.want)
+		}
+	}
+}
+
+func TestFilterSkip(t *testing.T) {
+	f := Filter{Exclude: []string{"legacy", "*_old.go"}, Include: []string{"api/**"}}
+	tests := []struct {
+		rel  string
+		dir  bool
+		want bool
+	}{
+		{"api/server.go", false, false},
+		{"cmd/main.go", false, true},
+		// directories are only checked against Exclude, so included files
+		// below them are still found
+		{"cmd", true, false},
+		{"api", true, false},
+		// Exclude wins over Include
+		{"api/legacy", true, true},
+		{"api/legacy/handler.go", false, true},
+		{"api/server_old.go", false, true},
+	}
+	for _, tt := range tests {
+		if got := f.Skip(tt.rel, tt.dir); got != tt.want {
+			t.Errorf("Skip(%q, %v) = %v, want %v", tt.rel, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestReadIgnoreFile(t *testing.T) {
+	dir := t.TempDir()
+	if globs, err := ReadIgnoreFile(dir); err != nil || globs != nil {
+		t.Fatalf("ReadIgnoreFile without %s = %q, %v; want nothing", IgnoreFile, globs, err)
+	}
+	content := "# generated code\ninternal/gen/\n\n  *.pb.go  \n# mocks\nmocks\n"
+	if err := os.WriteFile(filepath.Join(dir, IgnoreFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	globs, err := ReadIgnoreFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"internal/gen", "*.pb.go", "mocks"}; !slices.Equal(globs, want) {
+		t.Errorf("ReadIgnoreFile = %q, want %q", globs, want)
+	}
+}
+
+func TestGoFiles(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":                "package main\n",
+		"main_test.go":           "package main\n",
+		"api/server.go":          "package api\n",
+		"api/server.pb.go":       "package api\n",
+		"api/types_gen.go":       "// Code generated by stringer. DO NOT EDIT.\n\npackage api\n",
+		"internal/gen/models.go": "package gen\n",
+		"internal/util/util.go":  "package util\n",
+		"vendor/dep/dep.go":      "package dep\n",
+		"testdata/fixture.go":    "package fixture\n",
+		".hidden/h.go":           "package hidden\n",
+		"_scratch/s.go":          "package scratch\n",
+		"README.md":              "# readme\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"no filter", Filter{}, []string{"api/server.go", "api/server.pb.go", "internal/gen/models.go", "internal/util/util.go", "main.go"}},
+		{"exclude", Filter{Exclude: []string{"internal/gen", "*.pb.go"}}, []string{"api/server.go", "internal/util/util.go", "main.go"}},
+		{"include", Filter{Include: []string{"internal/**"}}, []string{"internal/gen/models.go", "internal/util/util.go"}},
+		{"include and exclude", Filter{Include: []string{"internal/**"}, Exclude: []string{"gen"}}, []string{"internal/util/util.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := GoFiles(root, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range found {
+				rel, err := filepath.Rel(root, f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("GoFiles = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
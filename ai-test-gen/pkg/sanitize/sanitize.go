@@ -0,0 +1,193 @@
+// Package sanitize finds secrets such as API keys, passwords and private keys
+// in source code so they can be redacted before the code leaves the machine.
+package sanitize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"log/slog"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+)
+
+// Finding is a probable secret in scanned text
+type Finding struct {
+	// Kind names what was found, e.g. "aws-access-key" or "password"
+	Kind string
+	// Line is the 1-based line the secret starts on
+	Line int
+
+	start, end int
+}
+
+// pattern matches a secret; when group is set only that submatch is the
+// secret, e.g. the password of a connection string
+type pattern struct {
+	kind  string
+	re    *regexp.Regexp
+	group int
+}
+
+var patterns = []pattern{
+	{kind: "private-key", re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{kind: "aws-access-key", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{kind: "github-token", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{kind: "slack-token", re: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{kind: "google-api-key", re: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{kind: "api-key", re: regexp.MustCompile(`\bsk-(?:ant-|proj-)?[A-Za-z0-9_-]{20,}\b`)},
+	{kind: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\b`)},
+	{kind: "connection-string", re: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@"'` + "`" + `]+:([^@\s"'` + "`" + `]+)@`), group: 1},
+	{kind: "password", re: regexp.MustCompile(`(?i)\b[a-z_]*(?:password|passwd|pwd|secret|token|api_?key|apikey)"?\s*(?::=|=|:)\s*["` + "`" + `]([^"` + "`" + `\s]{4,})["` + "`" + `]`), group: 1},
+}
+
+// Entropy thresholds for string literals that look like random keys
+const (
+	minSecretLength = 20
+	minEntropy      = 4.0
+)
+
+var keyChars = regexp.MustCompile(`^[A-Za-z0-9+/=_.-]+$`)
+
+// Scan returns the probable secrets in src, in order of appearance
+func Scan(src string) []Finding {
+	var findings []Finding
+	for _, p := range patterns {
+		for _, m := range p.re.FindAllStringSubmatchIndex(src, -1) {
+			start, end := m[0], m[1]
+			if p.group > 0 {
+				start, end = m[2*p.group], m[2*p.group+1]
+			}
+			findings = append(findings, Finding{Kind: p.kind, start: start, end: end})
+		}
+	}
+	findings = append(findings, highEntropyStrings(src)...)
+
+	sort.Slice(findings, func(i, j int) bool { return findings[i].start < findings[j].start })
+	// keep the first of overlapping findings
+	var merged []Finding
+	for _, f := range findings {
+		if len(merged) > 0 && f.start < merged[len(merged)-1].end {
+			continue
+		}
+		f.Line = strings.Count(src[:f.start], "\n") + 1
+		merged = append(merged, f)
+	}
+	return merged
+}
+
+// highEntropyStrings finds string literals that look like random tokens
+func highEntropyStrings(src string) []Finding {
+	var (
+		findings []Finding
+		s        scanner.Scanner
+	)
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	// prompts mix prose with code, so scanning errors are expected
+	s.Init(file, []byte(src), func(token.Position, string) {}, 0)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok != token.STRING {
+			continue
+		}
+		value, err := strconv.Unquote(lit)
+		if err != nil || !looksRandom(value) {
+			continue
+		}
+		// redact the contents, keeping the quotes so the code still parses
+		start := file.Offset(pos) + 1
+		findings = append(findings, Finding{Kind: "high-entropy-string", start: start, end: start + len(lit) - 2})
+	}
+	return findings
+}
+
+// looksRandom reports whether s is long, free of spaces, mixes letters and
+// digits and has the character entropy of a generated key
+func looksRandom(s string) bool {
+	if len(s) < minSecretLength || !keyChars.MatchString(s) {
+		return false
+	}
+	if !strings.ContainsAny(s, "0123456789") || strings.ToLower(s) == s && strings.ToUpper(s) == s {
+		return false
+	}
+	return entropy(s) >= minEntropy
+}
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var h float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// Redact replaces the probable secrets in src with REDACTED placeholders and
+// returns the findings
+func Redact(src string) (string, []Finding) {
+	findings := Scan(src)
+	if len(findings) == 0 {
+		return src, nil
+	}
+	var sb strings.Builder
+	last := 0
+	for _, f := range findings {
+		sb.WriteString(src[last:f.start])
+		sb.WriteString("REDACTED")
+		last = f.end
+	}
+	sb.WriteString(src[last:])
+	return sb.String(), findings
+}
+
+// ErrSecretsFound is returned in strict mode for prompts containing secrets
+var ErrSecretsFound = errors.New("possible secrets found")
+
+// Describe lists findings as kind@line, e.g. "password@12, jwt@40"
+func Describe(findings []Finding) string {
+	parts := make([]string, len(findings))
+	for i, f := range findings {
+		parts[i] = fmt.Sprintf("%s@%d", f.Kind, f.Line)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Wrap returns a provider that redacts secrets from prompts before passing
+// them to p. With strict set, prompts containing secrets are not sent at all
+// and ErrSecretsFound is returned instead.
+func Wrap(p generator.Provider, strict bool) generator.Provider {
+	return &sanitizingProvider{Provider: p, strict: strict}
+}
+
+type sanitizingProvider struct {
+	generator.Provider
+	strict bool
+}
+
+func (s *sanitizingProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	redacted, findings := Redact(prompt)
+	if len(findings) > 0 {
+		if s.strict {
+			return "", fmt.Errorf("%w, not sending the code (--strict-privacy): %s", ErrSecretsFound, Describe(findings))
+		}
+		slog.WarnContext(ctx, "redacted possible secrets from prompt", "findings", Describe(findings))
+	}
+	return s.Provider.Generate(ctx, redacted)
+}
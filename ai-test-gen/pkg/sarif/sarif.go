@@ -0,0 +1,147 @@
+// Package sarif writes SARIF 2.1.0 logs, the format GitHub code scanning and
+// other static analysis tools read results from.
+package sarif
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+)
+
+// Version and Schema identify the SARIF format written
+const (
+	Version = "2.1.0"
+	Schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// Levels of a result
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+)
+
+// Log is a SARIF log with a single run
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run holds the results of one tool invocation
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+// Tool describes the tool that produced a run
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver is the tool's main component and the rules its results refer to
+type Driver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Version        string `json:"version,omitempty"`
+	Rules          []Rule `json:"rules"`
+}
+
+// Rule is a kind of result
+type Rule struct {
+	ID               string   `json:"id"`
+	ShortDescription *Message `json:"shortDescription,omitempty"`
+}
+
+// Result is a single finding
+type Result struct {
+	RuleID    string     `json:"ruleId"`
+	Level     string     `json:"level"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+}
+
+// Message is a plain text message
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Location is where a result was found
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+// PhysicalLocation is a region of a file
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+// ArtifactLocation is a file, by URI relative to the repository root
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// Region is a range of lines, starting at 1
+type Region struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+// New returns an empty log for the named tool
+func New(name, version, informationURI string) *Log {
+	return &Log{
+		Schema:  Schema,
+		Version: Version,
+		Runs: []Run{{
+			Tool:    Tool{Driver: Driver{Name: name, Version: version, InformationURI: informationURI, Rules: []Rule{}}},
+			Results: []Result{},
+		}},
+	}
+}
+
+// Add appends a result, declaring its rule with description the first time
+// the rule is used
+func (l *Log) Add(r Result, description string) {
+	run := &l.Runs[0]
+	known := false
+	for _, rule := range run.Tool.Driver.Rules {
+		if rule.ID == r.RuleID {
+			known = true
+			break
+		}
+	}
+	if !known {
+		rule := Rule{ID: r.RuleID}
+		if description != "" {
+			rule.ShortDescription = &Message{Text: description}
+		}
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+	}
+	run.Results = append(run.Results, r)
+}
+
+// FileLocation returns the location of lines start to end of path, a file
+// under root; end is left out when it is not after start and the region when
+// start is 0
+func FileLocation(root, path string, start, end int) Location {
+	uri := path
+	if rel, err := filepath.Rel(root, path); err == nil {
+		uri = rel
+	}
+	loc := Location{PhysicalLocation: PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: filepath.ToSlash(uri)}}}
+	if start > 0 {
+		loc.PhysicalLocation.Region = &Region{StartLine: start}
+		if end > start {
+			loc.PhysicalLocation.Region.EndLine = end
+		}
+	}
+	return loc
+}
+
+// Write encodes the log to w
+func (l *Log) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
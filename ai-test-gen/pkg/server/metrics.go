@@ -0,0 +1,188 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the generation duration
+// histogram; model responses take seconds to minutes
+var latencyBuckets = []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300}
+
+// routes are the paths counted by name; others are counted as "other" so
+// scanners can't grow the number of series
+var routes = []string{"/v1/tests", "/v1/docs", "/healthz", "/metrics"}
+
+// requestKey labels the HTTP request counter
+type requestKey struct {
+	path   string
+	status int
+}
+
+// histogram counts observations into latencyBuckets
+type histogram struct {
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]int64, len(latencyBuckets))
+	}
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// metrics are the counters of a Server, kept in memory only and served at
+// GET /metrics in the Prometheus text format. Generations are labelled with
+// the configured provider and model.
+type metrics struct {
+	provider string
+	model    string
+
+	mu             sync.Mutex
+	requests       map[requestKey]int64
+	generations    map[string]int64
+	failures       map[string]int64
+	promptTokens   map[string]int64
+	responseTokens map[string]int64
+	latency        map[string]*histogram
+	inFlight       int64
+}
+
+func newMetrics(provider, model string) *metrics {
+	return &metrics{
+		provider:       provider,
+		model:          model,
+		requests:       make(map[requestKey]int64),
+		generations:    make(map[string]int64),
+		failures:       make(map[string]int64),
+		promptTokens:   make(map[string]int64),
+		responseTokens: make(map[string]int64),
+		latency:        make(map[string]*histogram),
+	}
+}
+
+// request counts an HTTP request to path answered with status
+func (m *metrics) request(path string, status int) {
+	if !slices.Contains(routes, path) {
+		path = "other"
+	}
+	m.mu.Lock()
+	m.requests[requestKey{path, status}]++
+	m.mu.Unlock()
+}
+
+// start counts a generation as in flight
+func (m *metrics) start() {
+	m.mu.Lock()
+	m.inFlight++
+	m.mu.Unlock()
+}
+
+// done records a generation for endpoint that took d, and whether it failed
+func (m *metrics) done(endpoint string, d time.Duration, promptTokens, responseTokens int64, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+	m.generations[endpoint]++
+	failures := m.failures[endpoint]
+	if failed {
+		failures++
+	}
+	// set even when 0, so the series exists before the first failure
+	m.failures[endpoint] = failures
+	m.promptTokens[endpoint] += promptTokens
+	m.responseTokens[endpoint] += responseTokens
+	h := m.latency[endpoint]
+	if h == nil {
+		h = &histogram{}
+		m.latency[endpoint] = h
+	}
+	h.observe(d.Seconds())
+}
+
+func (m *metrics) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// write writes the metrics in the Prometheus text exposition format, with the
+// series in a stable order
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	header(w, "aitestgen_http_requests_total", "counter", "HTTP requests by path and status code.")
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b requestKey) int {
+		if c := strings.Compare(a.path, b.path); c != 0 {
+			return c
+		}
+		return a.status - b.status
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "aitestgen_http_requests_total{path=%q,code=\"%d\"} %d\n", k.path, k.status, m.requests[k])
+	}
+
+	m.counter(w, "aitestgen_generations_total", "Generations sent to the provider, by endpoint.", m.generations)
+	m.counter(w, "aitestgen_generation_failures_total", "Generations that failed, by endpoint.", m.failures)
+	m.counter(w, "aitestgen_prompt_tokens_total", "Prompt tokens used, by endpoint.", m.promptTokens)
+	m.counter(w, "aitestgen_response_tokens_total", "Response tokens used, by endpoint.", m.responseTokens)
+
+	header(w, "aitestgen_generation_duration_seconds", "histogram", "Time the provider took to generate, retries included, by endpoint.")
+	for _, endpoint := range sortedKeys(m.latency) {
+		h := m.latency[endpoint]
+		labels := m.labels(endpoint)
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "aitestgen_generation_duration_seconds_bucket{%s,le=%q} %d\n", labels, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(w, "aitestgen_generation_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(w, "aitestgen_generation_duration_seconds_sum{%s} %g\n", labels, h.sum)
+		fmt.Fprintf(w, "aitestgen_generation_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	header(w, "aitestgen_generations_in_flight", "gauge", "Generations in progress, waiting ones excluded.")
+	fmt.Fprintf(w, "aitestgen_generations_in_flight{provider=%q,model=%q} %d\n", m.provider, m.model, m.inFlight)
+}
+
+// counter writes a counter labelled by endpoint, provider and model
+func (m *metrics) counter(w io.Writer, name, help string, values map[string]int64) {
+	header(w, name, "counter", help)
+	for _, endpoint := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, m.labels(endpoint), values[endpoint])
+	}
+}
+
+// labels returns the labels of the generation series of endpoint
+func (m *metrics) labels(endpoint string) string {
+	return fmt.Sprintf("endpoint=%q,provider=%q,model=%q", endpoint, m.provider, m.model)
+}
+
+func header(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
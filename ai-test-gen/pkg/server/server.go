@@ -0,0 +1,218 @@
+// Package server exposes test and documentation generation over HTTP.
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/knbr13/aitestgen/pkg/generator"
+)
+
+// maxBodyBytes caps the size of a request body
+const maxBodyBytes = 1 << 20
+
+// Options configure a Server
+type Options struct {
+	// APIKeys are accepted as bearer tokens or in the X-API-Key header. With
+	// none, requests are not authenticated.
+	APIKeys []string
+	// MaxConcurrent caps the generations in flight; further requests wait
+	MaxConcurrent int
+	// Provider and Model label the generation metrics
+	Provider string
+	Model    string
+}
+
+// Server handles POST /v1/tests and POST /v1/docs by sending the code to a
+// generator client, and serves its metrics at GET /metrics
+type Server struct {
+	client  *generator.Client
+	keys    []string
+	slots   chan struct{}
+	mux     *http.ServeMux
+	metrics *metrics
+}
+
+// New returns a server generating with client
+func New(client *generator.Client, opts Options) *Server {
+	if opts.MaxConcurrent <= 0 {
+		opts.MaxConcurrent = 1
+	}
+	s := &Server{
+		client:  client,
+		keys:    opts.APIKeys,
+		slots:   make(chan struct{}, opts.MaxConcurrent),
+		mux:     http.NewServeMux(),
+		metrics: newMetrics(opts.Provider, opts.Model),
+	}
+	s.mux.HandleFunc("POST /v1/tests", s.handleTests)
+	s.mux.HandleFunc("POST /v1/docs", s.handleDocs)
+	s.mux.HandleFunc("GET /metrics", s.metrics.handle)
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return s
+}
+
+// TestsRequest is the body of POST /v1/tests
+type TestsRequest struct {
+	Code string `json:"code"`
+	// Framework is one of the generator Framework constants; empty means stdlib
+	Framework string `json:"framework,omitempty"`
+	// Instructions are added to the prompt
+	Instructions string `json:"instructions,omitempty"`
+}
+
+// DocsRequest is the body of POST /v1/docs
+type DocsRequest struct {
+	Code string `json:"code"`
+}
+
+// Response is returned by both endpoints. Output holds the test file or the
+// Markdown documentation.
+type Response struct {
+	Output         string `json:"output"`
+	PromptTokens   int64  `json:"prompt_tokens"`
+	ResponseTokens int64  `json:"response_tokens"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	if r.URL.Path != "/healthz" && !s.authorized(r) {
+		writeError(rec, http.StatusUnauthorized, "missing or invalid API key")
+	} else {
+		s.mux.ServeHTTP(rec, r)
+	}
+	s.metrics.request(r.URL.Path, rec.status)
+	slog.Info("request", "method", r.Method, "path", r.URL.Path, "status", rec.status, "duration", time.Since(start).Round(time.Millisecond))
+}
+
+// authorized reports whether the request carries one of the API keys
+func (s *Server) authorized(r *http.Request) bool {
+	if len(s.keys) == 0 {
+		return true
+	}
+	key := r.Header.Get("X-API-Key")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		key = bearer
+	}
+	if key == "" {
+		return false
+	}
+	ok := false
+	for _, k := range s.keys {
+		// compare against every key so timing doesn't reveal which matched
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
+
+func (s *Server) handleTests(w http.ResponseWriter, r *http.Request) {
+	var req TestsRequest
+	if !decode(w, r, &req) || !requireCode(w, req.Code) {
+		return
+	}
+	if err := generator.ValidateFramework(req.Framework); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts := s.client.TestOptions()
+	opts.Framework = req.Framework
+	opts.Instructions = req.Instructions
+
+	s.generate(w, r, "tests", func(r *http.Request) (string, error) {
+		return generator.GenerateUnitTests(r.Context(), req.Code, s.client.Provider(), opts)
+	})
+}
+
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	var req DocsRequest
+	if !decode(w, r, &req) || !requireCode(w, req.Code) {
+		return
+	}
+	s.generate(w, r, "docs", func(r *http.Request) (string, error) {
+		return s.client.Documentation(r.Context(), req.Code)
+	})
+}
+
+// generate runs fn once a slot is free and writes its output with the tokens
+// used, recording the generation in the endpoint's metrics
+func (s *Server) generate(w http.ResponseWriter, r *http.Request, endpoint string, fn func(r *http.Request) (string, error)) {
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-r.Context().Done():
+		return
+	}
+
+	var usage generator.Usage
+	r = r.WithContext(generator.WithUsage(r.Context(), &usage))
+	s.metrics.start()
+	start := time.Now()
+	out, err := fn(r)
+	s.metrics.done(endpoint, time.Since(start), usage.PromptTokens(), usage.ResponseTokens(), err != nil)
+	if err != nil {
+		status := http.StatusBadGateway
+		var apiErr *generator.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+			status = http.StatusTooManyRequests
+		}
+		slog.Error("generation failed", "path", r.URL.Path, "err", err)
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Output: out, PromptTokens: usage.PromptTokens(), ResponseTokens: usage.ResponseTokens()})
+}
+
+// decode reads the JSON request body into v, answering bad requests itself
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// requireCode answers requests without code and reports whether code is set
+func requireCode(w http.ResponseWriter, code string) bool {
+	if strings.TrimSpace(code) == "" {
+		writeError(w, http.StatusBadRequest, "code is required")
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+// statusRecorder remembers the status code written, for the request log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
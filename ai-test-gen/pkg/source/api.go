@@ -0,0 +1,199 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Import paths of the web frameworks whose handlers APISummary recognizes
+// besides those of net/http, which chi uses too
+const (
+	ginPath  = "github.com/gin-gonic/gin"
+	echoPath = "github.com/labstack/echo/v4"
+)
+
+// apiPackage collects what APISummary reports for one package
+type apiPackage struct {
+	dir      string
+	types    map[string]string
+	handlers []apiHandler
+	routes   []string
+}
+
+type apiHandler struct {
+	file string
+	decl *ast.FuncDecl
+	text string
+}
+
+// APISummary describes the HTTP API served by the module at root for a model
+// writing an OpenAPI document: the routes registered with net/http, chi, gin
+// or echo, and the source of every handler with the types of its package it
+// uses, such as the request and response structs. It returns "" when the
+// module has no handlers. Test files and files that fail to parse are
+// skipped.
+func APISummary(root string, files []string) (string, error) {
+	packages := make(map[string]*apiPackage)
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		file, err := Parse(path, src)
+		if err != nil {
+			continue
+		}
+		dir, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			dir = filepath.Dir(path)
+		}
+		dir = filepath.ToSlash(dir)
+		pkg, ok := packages[dir]
+		if !ok {
+			pkg = &apiPackage{dir: dir, types: make(map[string]string)}
+			packages[dir] = pkg
+		}
+		for _, decl := range file.AST.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					pkg.types[ts.Name.Name] = "type " + formatNode(file.Fset, ts)
+				}
+			}
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			rel = path
+		}
+		for _, fn := range file.apiHandlers() {
+			pkg.handlers = append(pkg.handlers, apiHandler{file: filepath.ToSlash(rel), decl: fn.Decl, text: file.Text(fn.Decl)})
+		}
+		pkg.routes = append(pkg.routes, archRoutes(file)...)
+	}
+
+	dirs := make([]string, 0, len(packages))
+	for dir, pkg := range packages {
+		if len(pkg.handlers) > 0 || len(pkg.routes) > 0 {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return "", nil
+	}
+	sort.Strings(dirs)
+
+	var sb strings.Builder
+	if mod, err := FindModule(root); err == nil {
+		fmt.Fprintf(&sb, "Module: %s\n", mod.Path)
+	}
+	for _, dir := range dirs {
+		pkg := packages[dir]
+		fmt.Fprintf(&sb, "\n## package %s\n", dir)
+		if len(pkg.routes) > 0 {
+			sb.WriteString("Routes:\n")
+			for _, r := range pkg.routes {
+				fmt.Fprintf(&sb, "- %s\n", r)
+			}
+		}
+		for _, h := range pkg.handlers {
+			fmt.Fprintf(&sb, "\n// %s\n%s\n", h.file, h.text)
+		}
+		if types := pkg.usedTypes(); len(types) > 0 {
+			sb.WriteString("\n// types used by the handlers\n")
+			for _, t := range types {
+				sb.WriteString(t + "\n\n")
+			}
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n") + "\n", nil
+}
+
+// usedTypes returns the declarations of the package's types the handlers
+// refer to, and of the types those refer to in turn, sorted by name
+func (p *apiPackage) usedTypes() []string {
+	used := make(map[string]bool)
+	var queue []string
+	for _, h := range p.handlers {
+		for _, name := range referencedNames(h.decl) {
+			if _, ok := p.types[name]; ok && !used[name] {
+				used[name] = true
+				queue = append(queue, name)
+			}
+		}
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		// the declarations are short, so scanning their words is enough
+		for _, word := range strings.FieldsFunc(p.types[name], func(r rune) bool {
+			return !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+		}) {
+			if _, ok := p.types[word]; ok && !used[word] {
+				used[word] = true
+				queue = append(queue, word)
+			}
+		}
+	}
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	types := make([]string, len(names))
+	for i, name := range names {
+		types[i] = p.types[name]
+	}
+	return types
+}
+
+// apiHandlers returns the functions of the file that are net/http handlers,
+// gin handlers taking a *gin.Context or echo handlers taking an
+// echo.Context, or that return one
+func (f *File) apiHandlers() []Func {
+	funcs := f.Funcs()
+	handlers := f.HTTPHandlers(funcs)
+	gin, echo := f.importName(ginPath), f.importName(echoPath)
+	if gin == "" && echo == "" {
+		return handlers
+	}
+	for _, fn := range funcs {
+		ft := fn.Decl.Type
+		if gin != "" && (isGinHandler(ft, gin) || returnsQualified(ft, gin, "HandlerFunc")) ||
+			echo != "" && (isEchoHandler(ft, echo) || returnsQualified(ft, echo, "HandlerFunc")) {
+			handlers = append(handlers, fn)
+		}
+	}
+	return handlers
+}
+
+// isGinHandler reports whether ft is func(*gin.Context)
+func isGinHandler(ft *ast.FuncType, gin string) bool {
+	if len(ft.Params.List) != 1 || len(ft.Params.List[0].Names) > 1 || ft.Results != nil {
+		return false
+	}
+	star, ok := ft.Params.List[0].Type.(*ast.StarExpr)
+	return ok && isQualified(star.X, gin, "Context")
+}
+
+// isEchoHandler reports whether ft is func(echo.Context) error
+func isEchoHandler(ft *ast.FuncType, echo string) bool {
+	if len(ft.Params.List) != 1 || len(ft.Params.List[0].Names) > 1 || ft.Results == nil || len(ft.Results.List) != 1 {
+		return false
+	}
+	result, ok := ft.Results.List[0].Type.(*ast.Ident)
+	return ok && result.Name == "error" && isQualified(ft.Params.List[0].Type, echo, "Context")
+}
+
+// returnsQualified reports whether ft returns only the type pkg.name
+func returnsQualified(ft *ast.FuncType, pkg, name string) bool {
+	return ft.Results != nil && len(ft.Results.List) == 1 && len(ft.Results.List[0].Names) <= 1 &&
+		isQualified(ft.Results.List[0].Type, pkg, name)
+}
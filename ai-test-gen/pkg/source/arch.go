@@ -0,0 +1,254 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// routeMethods are the router methods registering a handler for a path, as
+// named by net/http and the common routers
+var routeMethods = map[string]bool{
+	"Handle": true, "HandleFunc": true, "Route": true, "Mount": true,
+	"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "HEAD": true, "OPTIONS": true, "Any": true,
+	"Get": true, "Post": true, "Put": true, "Patch": true, "Delete": true, "Head": true, "Options": true,
+}
+
+// archPackage collects what ArchitectureSummary reports for one package
+type archPackage struct {
+	dir, name string
+	imports   map[string]bool
+	types     []string
+	handlers  []string
+	routes    []string
+}
+
+// ArchitectureSummary describes the structure of the module at root for a
+// model drawing diagrams of it: the dependencies between its packages, the
+// types of each package with the module types they hold, embed or
+// implement through methods, and the HTTP handlers and routes registered
+// with net/http or a router. Test files and files that fail to parse are
+// skipped.
+func ArchitectureSummary(root string, files []string) (string, error) {
+	mod, err := FindModule(root)
+	if err != nil {
+		return "", err
+	}
+	packages := make(map[string]*archPackage)
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		file, err := Parse(path, src)
+		if err != nil {
+			continue
+		}
+		dir, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			dir = filepath.Dir(path)
+		}
+		dir = filepath.ToSlash(dir)
+		pkg, ok := packages[dir]
+		if !ok {
+			pkg = &archPackage{dir: dir, name: file.Package(), imports: make(map[string]bool)}
+			packages[dir] = pkg
+		}
+		local := make(map[string]string)
+		for _, imp := range file.AST.Imports {
+			p, err := strconv.Unquote(imp.Path.Value)
+			if err != nil || (p != mod.Path && !strings.HasPrefix(p, mod.Path+"/")) {
+				continue
+			}
+			rel := strings.TrimPrefix(strings.TrimPrefix(p, mod.Path), "/")
+			if rel == "" {
+				rel = "."
+			}
+			pkg.imports[rel] = true
+			if name := file.importName(p); name != "" {
+				local[name] = rel
+			}
+		}
+		pkg.types = append(pkg.types, archTypes(file, local)...)
+		for _, h := range file.HTTPHandlers(file.Funcs()) {
+			pkg.handlers = append(pkg.handlers, h.Key())
+		}
+		pkg.routes = append(pkg.routes, archRoutes(file)...)
+	}
+
+	dirs := make([]string, 0, len(packages))
+	for dir := range packages {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Module: %s\n\n## Package dependencies\n", mod.Path)
+	for _, dir := range dirs {
+		pkg := packages[dir]
+		deps := make([]string, 0, len(pkg.imports))
+		for imp := range pkg.imports {
+			deps = append(deps, imp)
+		}
+		sort.Strings(deps)
+		if len(deps) == 0 {
+			fmt.Fprintf(&sb, "- %s (package %s)\n", dir, pkg.name)
+		} else {
+			fmt.Fprintf(&sb, "- %s (package %s) imports %s\n", dir, pkg.name, strings.Join(deps, ", "))
+		}
+	}
+	for _, dir := range dirs {
+		pkg := packages[dir]
+		if len(pkg.types) == 0 && len(pkg.handlers) == 0 && len(pkg.routes) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n## package %s (%s)\n", pkg.name, dir)
+		for _, t := range pkg.types {
+			fmt.Fprintf(&sb, "- %s\n", t)
+		}
+		if len(pkg.handlers) > 0 {
+			fmt.Fprintf(&sb, "HTTP handlers: %s\n", strings.Join(pkg.handlers, ", "))
+		}
+		for _, r := range pkg.routes {
+			fmt.Fprintf(&sb, "- route %s\n", r)
+		}
+	}
+	return sb.String(), nil
+}
+
+// archTypes describes the struct and interface types of file: the module
+// types each struct holds or embeds, the methods of each interface and the
+// methods declared on each type. local maps the names of the module's
+// packages imported by file to their directories.
+func archTypes(file *File, local map[string]string) []string {
+	methods := make(map[string][]string)
+	for _, fn := range file.Funcs() {
+		if fn.Receiver != "" {
+			methods[fn.Receiver] = append(methods[fn.Receiver], fn.Name)
+		}
+	}
+	declared := make(map[string]bool)
+	for _, decl := range file.AST.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+			for _, spec := range gen.Specs {
+				declared[spec.(*ast.TypeSpec).Name.Name] = true
+			}
+		}
+	}
+	// refs returns the named types of the package or the module that expr
+	// refers to
+	refs := func(expr ast.Expr) []string {
+		var names []string
+		ast.Inspect(expr, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.SelectorExpr:
+				if id, ok := x.X.(*ast.Ident); ok {
+					if dir, ok := local[id.Name]; ok {
+						names = append(names, dir+"."+x.Sel.Name)
+					}
+				}
+				return false
+			case *ast.Ident:
+				if x.IsExported() || declared[x.Name] {
+					if !basicTypes[x.Name] && x.Name != "error" && x.Name != "any" {
+						names = append(names, x.Name)
+					}
+				}
+			}
+			return true
+		})
+		return names
+	}
+
+	var types []string
+	for _, decl := range file.AST.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			var parts []string
+			switch t := ts.Type.(type) {
+			case *ast.StructType:
+				var embeds, fields []string
+				for _, field := range t.Fields.List {
+					if len(field.Names) == 0 {
+						embeds = append(embeds, refs(field.Type)...)
+						continue
+					}
+					for _, ref := range refs(field.Type) {
+						fields = append(fields, field.Names[0].Name+" "+ref)
+					}
+				}
+				parts = append(parts, "struct")
+				if len(embeds) > 0 {
+					parts = append(parts, "embeds "+strings.Join(embeds, ", "))
+				}
+				if len(fields) > 0 {
+					parts = append(parts, "holds "+strings.Join(fields, ", "))
+				}
+			case *ast.InterfaceType:
+				var names []string
+				for _, m := range t.Methods.List {
+					for _, n := range m.Names {
+						names = append(names, n.Name)
+					}
+					if len(m.Names) == 0 {
+						names = append(names, refs(m.Type)...)
+					}
+				}
+				parts = append(parts, "interface {"+strings.Join(names, ", ")+"}")
+			default:
+				continue
+			}
+			if m := methods[ts.Name.Name]; len(m) > 0 {
+				parts = append(parts, "methods "+strings.Join(m, ", "))
+			}
+			types = append(types, ts.Name.Name+": "+strings.Join(parts, "; "))
+		}
+	}
+	return types
+}
+
+// archRoutes returns the routes file registers, as "METHOD /path -> handler"
+func archRoutes(file *File) []string {
+	var routes []string
+	ast.Inspect(file.AST, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) < 2 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !routeMethods[sel.Sel.Name] {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		path, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return true
+		}
+		method := ""
+		if m := strings.ToUpper(sel.Sel.Name); m != "HANDLE" && m != "HANDLEFUNC" && m != "ROUTE" && m != "MOUNT" {
+			method = m + " "
+		}
+		handler := formatNode(file.Fset, call.Args[len(call.Args)-1])
+		if len(handler) > 60 {
+			handler = handler[:60] + "..."
+		}
+		routes = append(routes, method+path+" -> "+strings.Join(strings.Fields(handler), " "))
+		return true
+	})
+	return routes
+}
@@ -0,0 +1,56 @@
+package source
+
+import (
+	"go/build/constraint"
+	"slices"
+	"strings"
+)
+
+// CopyBuildConstraint returns tests with the //go:build constraint of code, so
+// tests of a file built only on some platforms or with some tags are too. A
+// constraint tests already have is kept and combined with that of code.
+func CopyBuildConstraint(code, tests string) string {
+	_, expr := buildConstraint(code)
+	if expr == nil {
+		return tests
+	}
+	i, own := buildConstraint(tests)
+	if own == nil {
+		return "//go:build " + expr.String() + "\n\n" + tests
+	}
+	have := conjuncts(own)
+	if !slices.ContainsFunc(conjuncts(expr), func(c string) bool { return !slices.Contains(have, c) }) {
+		return tests
+	}
+	lines := strings.SplitAfter(tests, "\n")
+	lines[i] = "//go:build " + (&constraint.AndExpr{X: expr, Y: own}).String() + "\n"
+	return strings.Join(lines, "")
+}
+
+// buildConstraint returns the line index and expression of the //go:build
+// constraint of src, or a nil expression without a valid one
+func buildConstraint(src string) (int, constraint.Expr) {
+	for i, line := range strings.Split(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "package ") {
+			break
+		}
+		if !constraint.IsGoBuild(trimmed) {
+			continue
+		}
+		expr, err := constraint.Parse(trimmed)
+		if err != nil {
+			return 0, nil
+		}
+		return i, expr
+	}
+	return 0, nil
+}
+
+// conjuncts returns the terms of the && chain expr, as strings
+func conjuncts(expr constraint.Expr) []string {
+	if and, ok := expr.(*constraint.AndExpr); ok {
+		return append(conjuncts(and.X), conjuncts(and.Y)...)
+	}
+	return []string{expr.String()}
+}
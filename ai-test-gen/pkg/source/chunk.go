@@ -0,0 +1,54 @@
+package source
+
+import (
+	"go/ast"
+	"maps"
+
+	"github.com/knbr13/aitestgen/pkg/tokens"
+)
+
+// Chunk is a part of a file too large to send whole: some of its functions
+// with the declarations they depend on
+type Chunk struct {
+	// Funcs are the keys of the functions the chunk is for
+	Funcs []string
+	Code  string
+	// Tokens is the estimated size of Code
+	Tokens int
+	// Oversized is set when a single function and its dependencies exceed
+	// the budget on their own; the chunk holds it anyway
+	Oversized bool
+}
+
+// Chunks splits the file along declaration boundaries into snippets of at
+// most maxTokens, each holding as many of funcs, in source order, as fit
+// along with the header and every declaration they reference. A function
+// that doesn't fit alone gets an Oversized chunk of its own.
+func (f *File) Chunks(funcs []Func, maxTokens int) []Chunk {
+	var chunks []Chunk
+	var current Chunk
+	included := make(map[ast.Decl]bool)
+	for _, fn := range funcs {
+		decl := ast.Decl(fn.Decl)
+		grown := maps.Clone(included)
+		grown[decl] = true
+		f.addDeps(grown, decl)
+		code := f.snippet(grown)
+		if len(current.Funcs) > 0 && tokens.Estimate(code) > maxTokens {
+			chunks = append(chunks, current)
+			current = Chunk{}
+			grown = map[ast.Decl]bool{decl: true}
+			f.addDeps(grown, decl)
+			code = f.snippet(grown)
+		}
+		included = grown
+		current.Funcs = append(current.Funcs, fn.Key())
+		current.Code = code
+		current.Tokens = tokens.Estimate(code)
+		current.Oversized = current.Tokens > maxTokens
+	}
+	if len(current.Funcs) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
@@ -0,0 +1,432 @@
+package source
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// Subtest naming styles
+const (
+	// SubtestSnake names subtests like "empty_input"
+	SubtestSnake = "snake"
+	// SubtestSentence names subtests like "empty input"
+	SubtestSentence = "sentence"
+	// SubtestCamel names subtests like "EmptyInput"
+	SubtestCamel = "camel"
+)
+
+// Assertion styles of testify tests
+const (
+	AssertionsAssert  = "assert"
+	AssertionsRequire = "require"
+)
+
+// testify import paths of the assertion styles
+var assertionPaths = map[string]string{
+	AssertionsAssert:  "github.com/stretchr/testify/assert",
+	AssertionsRequire: "github.com/stretchr/testify/require",
+}
+
+// Conventions are the layout rules enforced on generated tests, whatever the
+// model wrote. The zero value changes nothing.
+type Conventions struct {
+	// SubtestNames is the style of the names given to t.Run, one of the
+	// Subtest constants
+	SubtestNames string
+	// Parallel, when set, adds t.Parallel() to the tests and subtests that
+	// lack it if true, and removes it if false
+	Parallel *bool
+	// Assertions, one of the Assertions constants, makes testify tests use
+	// only assert or only require
+	Assertions string
+}
+
+// Validate returns an error for unknown styles
+func (c Conventions) Validate() error {
+	switch c.SubtestNames {
+	case "", SubtestSnake, SubtestSentence, SubtestCamel:
+	default:
+		return fmt.Errorf("unknown subtest naming style %q (expected %s, %s or %s)", c.SubtestNames, SubtestSnake, SubtestSentence, SubtestCamel)
+	}
+	switch c.Assertions {
+	case "", AssertionsAssert, AssertionsRequire:
+	default:
+		return fmt.Errorf("unknown assertion style %q (expected %s or %s)", c.Assertions, AssertionsAssert, AssertionsRequire)
+	}
+	return nil
+}
+
+// IsZero reports whether c enforces nothing
+func (c Conventions) IsZero() bool {
+	return c.SubtestNames == "" && c.Parallel == nil && c.Assertions == ""
+}
+
+// edit replaces src[start:end] with text
+type edit struct {
+	start, end int
+	text       string
+}
+
+// ApplyConventions rewrites the test file src to follow c: the string
+// literals naming subtests, directly or through the name field of a test
+// case table, are restyled, t.Parallel() is added or removed, and testify
+// assertions are switched to the chosen package. Tests that change
+// process-wide state, as reported by SharedState, are not made parallel, nor
+// are their subtests.
+func ApplyConventions(src string, c Conventions) (string, error) {
+	if c.IsZero() {
+		return src, nil
+	}
+	f, err := Parse("conventions_test.go", []byte(src))
+	if err != nil {
+		return "", err
+	}
+	offset := func(pos token.Pos) int { return f.Fset.Position(pos).Offset }
+
+	var edits []edit
+	if c.SubtestNames != "" {
+		for _, lit := range subtestNames(f.AST) {
+			name, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				continue
+			}
+			if styled := styleName(name, c.SubtestNames); styled != name {
+				edits = append(edits, edit{offset(lit.Pos()), offset(lit.End()), strconv.Quote(styled)})
+			}
+		}
+	}
+	if c.Parallel != nil {
+		shared := sharedTests(f)
+		for _, fn := range testFuncs(f.AST) {
+			switch {
+			case !*c.Parallel:
+				edits = append(edits, serialEdits(f, fn.Body)...)
+			case !shared[fn.Name.Name]:
+				edits = append(edits, parallelEdits(f, fn.Type, fn.Body)...)
+			}
+		}
+	}
+	out := applyEdits(src, edits)
+
+	if c.Assertions != "" {
+		if out, err = switchAssertions(out, c.Assertions); err != nil {
+			return "", err
+		}
+	}
+	formatted, err := format.Source([]byte(out))
+	if err != nil {
+		return "", fmt.Errorf("tests following the conventions do not format: %w", err)
+	}
+	return string(formatted), nil
+}
+
+// applyEdits applies non-overlapping edits to src
+func applyEdits(src string, edits []edit) string {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		src = src[:e.start] + e.text + src[e.end:]
+	}
+	return src
+}
+
+// testFuncs returns the TestXxx functions of file
+func testFuncs(file *ast.File) []*ast.FuncDecl {
+	var funcs []*ast.FuncDecl
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if ok && fn.Recv == nil && fn.Body != nil && strings.HasPrefix(fn.Name.Name, "Test") && testingParam(fn.Type) != "" {
+			funcs = append(funcs, fn)
+		}
+	}
+	return funcs
+}
+
+// testingParam returns the name of the single *testing.T parameter of ft, or ""
+func testingParam(ft *ast.FuncType) string {
+	if len(ft.Params.List) != 1 || len(ft.Params.List[0].Names) != 1 {
+		return ""
+	}
+	star, ok := ft.Params.List[0].Type.(*ast.StarExpr)
+	if !ok || !isQualified(star.X, "testing", "T") {
+		return ""
+	}
+	return ft.Params.List[0].Names[0].Name
+}
+
+// subtestRun returns the function literal run as a subtest by call, a
+// t.Run(name, func(t *testing.T) {...}) call, or nil
+func subtestRun(call *ast.CallExpr) *ast.FuncLit {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Run" || len(call.Args) != 2 {
+		return nil
+	}
+	lit, ok := call.Args[1].(*ast.FuncLit)
+	if !ok || testingParam(lit.Type) == "" {
+		return nil
+	}
+	return lit
+}
+
+// subtestNames returns the string literals naming subtests: the first
+// argument of t.Run, or the values of the table field it reads, as in
+// t.Run(tt.name, ...)
+func subtestNames(file *ast.File) []*ast.BasicLit {
+	var lits []*ast.BasicLit
+	fields := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || subtestRun(call) == nil {
+			return true
+		}
+		switch arg := call.Args[0].(type) {
+		case *ast.BasicLit:
+			if arg.Kind == token.STRING {
+				lits = append(lits, arg)
+			}
+		case *ast.SelectorExpr:
+			fields[arg.Sel.Name] = true
+		}
+		return true
+	})
+	if len(fields) == 0 {
+		return lits
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		kv, ok := n.(*ast.KeyValueExpr)
+		if !ok {
+			return true
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok || !fields[key.Name] {
+			return true
+		}
+		if lit, ok := kv.Value.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			lits = append(lits, lit)
+		}
+		return true
+	})
+	return lits
+}
+
+// styleName rewrites a subtest name in style
+func styleName(name, style string) string {
+	words := nameWords(name)
+	if len(words) == 0 {
+		return name
+	}
+	switch style {
+	case SubtestSnake, SubtestSentence:
+		for i, w := range words {
+			if !isAcronym(w) {
+				words[i] = strings.ToLower(w)
+			}
+		}
+		if style == SubtestSnake {
+			return strings.Join(words, "_")
+		}
+		return strings.Join(words, " ")
+	case SubtestCamel:
+		for i, w := range words {
+			r := []rune(w)
+			r[0] = unicode.ToUpper(r[0])
+			words[i] = string(r)
+		}
+		return strings.Join(words, "")
+	}
+	return name
+}
+
+// nameWords splits a name into words at spaces, underscores and case
+// changes, keeping acronyms such as "HTTP" whole. Other characters, as in
+// "-1" or "a/b", are kept in their words.
+func nameWords(name string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+	for i, r := range runes {
+		if unicode.IsSpace(r) || r == '_' {
+			flush()
+			continue
+		}
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := word[len(word)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
+
+// isAcronym reports whether word is written in capitals, like "ID" or "URL"
+func isAcronym(word string) bool {
+	return len(word) > 1 && strings.ToUpper(word) == word && strings.ToLower(word) != word
+}
+
+// callsParallel reports whether body calls t.Parallel(), leaving out the
+// calls of its subtests
+func callsParallel(body *ast.BlockStmt, t string) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			return testingParam(x.Type) == ""
+		case *ast.SelectorExpr:
+			if id, ok := x.X.(*ast.Ident); ok && id.Name == t && x.Sel.Name == "Parallel" {
+				found = true
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// parallelEdits adds t.Parallel() to the test or subtest with type ft and
+// body, and to its subtests
+func parallelEdits(f *File, ft *ast.FuncType, body *ast.BlockStmt) []edit {
+	var edits []edit
+	if t := testingParam(ft); !callsParallel(body, t) {
+		at := f.Fset.Position(body.Lbrace).Offset + 1
+		edits = append(edits, edit{at, at, "\n" + t + ".Parallel()"})
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if lit := subtestRun(call); lit != nil {
+				edits = append(edits, parallelEdits(f, lit.Type, lit.Body)...)
+				return false
+			}
+		}
+		return true
+	})
+	return edits
+}
+
+// serialEdits removes the t.Parallel() statements of body
+func serialEdits(f *File, body *ast.BlockStmt) []edit {
+	var edits []edit
+	ast.Inspect(body, func(n ast.Node) bool {
+		stmt, ok := n.(*ast.ExprStmt)
+		if !ok {
+			return true
+		}
+		call, ok := stmt.X.(*ast.CallExpr)
+		if !ok || len(call.Args) != 0 {
+			return true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Parallel" {
+			if _, ok := sel.X.(*ast.Ident); ok {
+				edits = append(edits, lineEdit(f.Src, f.Fset.Position(stmt.Pos()).Offset, f.Fset.Position(stmt.End()).Offset))
+			}
+		}
+		return true
+	})
+	return edits
+}
+
+// lineEdit deletes src[start:end] with the indentation before it and the
+// blank lines after it, when it is alone on its line
+func lineEdit(src []byte, start, end int) edit {
+	lineStart := bytes.LastIndexByte(src[:start], '\n') + 1
+	if len(bytes.TrimSpace(src[lineStart:start])) > 0 {
+		return edit{start, end, ""}
+	}
+	next := end
+	for i := end; i < len(src) && (src[i] == ' ' || src[i] == '\t' || src[i] == '\n' || src[i] == '\r'); i++ {
+		if src[i] == '\n' {
+			next = i + 1
+		}
+	}
+	if next == end {
+		return edit{start, end, ""}
+	}
+	return edit{lineStart, next, ""}
+}
+
+// switchAssertions makes the testify assertions of src use only the package
+// of style, which has the same functions as the other
+func switchAssertions(src, style string) (string, error) {
+	other := AssertionsRequire
+	if style == AssertionsRequire {
+		other = AssertionsAssert
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "conventions_test.go", src, parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+	name := ""
+	for _, imp := range file.Imports {
+		if path, _ := strconv.Unquote(imp.Path.Value); path == assertionPaths[other] {
+			name = other
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+		}
+	}
+	if name == "" || name == "_" || name == "." {
+		return src, nil
+	}
+	renamed := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok && id.Name == name {
+			id.Name = style
+			renamed = true
+		}
+		return true
+	})
+	if !renamed {
+		return src, nil
+	}
+	if hasImport(file, assertionPaths[style]) {
+		astutil.DeleteNamedImport(fset, file, importAlias(file, assertionPaths[other]), assertionPaths[other])
+	} else {
+		// the import is changed in place to keep its group
+		for _, imp := range file.Imports {
+			if path, _ := strconv.Unquote(imp.Path.Value); path == assertionPaths[other] {
+				imp.Path.Value = strconv.Quote(assertionPaths[style])
+				imp.Name = nil
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// importAlias returns the name path is explicitly imported under, or ""
+func importAlias(file *ast.File, path string) string {
+	for _, imp := range file.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p == path && imp.Name != nil {
+			return imp.Name.Name
+		}
+	}
+	return ""
+}
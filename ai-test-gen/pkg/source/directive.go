@@ -0,0 +1,61 @@
+package source
+
+import (
+	"go/token"
+	"strings"
+)
+
+// Directives placed in a function's doc comment to choose whether it gets
+// tests, e.g. //aitestgen:skip
+const (
+	DirectiveGenerate = "aitestgen:generate"
+	DirectiveSkip     = "aitestgen:skip"
+)
+
+// Directive returns the aitestgen directive in the function's doc comment,
+// DirectiveGenerate or DirectiveSkip, or "" if it has none
+func (f Func) Directive() string {
+	if f.Decl.Doc == nil {
+		return ""
+	}
+	for _, c := range f.Decl.Doc.List {
+		// like //go: directives, these take no space after the slashes
+		switch text := strings.TrimSpace(c.Text); text {
+		case "//" + DirectiveGenerate, "//" + DirectiveSkip:
+			return text[2:]
+		}
+	}
+	return ""
+}
+
+// IsExported reports whether the function is part of the package API: an
+// exported function, or an exported method on an exported type
+func (f Func) IsExported() bool {
+	return f.Exported && (f.Receiver == "" || token.IsExported(f.Receiver))
+}
+
+// TestTargets returns the functions of the file that tests should be
+// generated for. If any function is marked with DirectiveGenerate, only the
+// marked functions are returned. Otherwise functions marked with DirectiveSkip
+// are left out, as are unexported ones when onlyExported is set.
+func (f *File) TestTargets(onlyExported bool) []Func {
+	funcs := f.Funcs()
+	var marked []Func
+	for _, fn := range funcs {
+		if fn.Directive() == DirectiveGenerate {
+			marked = append(marked, fn)
+		}
+	}
+	if len(marked) > 0 {
+		return marked
+	}
+
+	var targets []Func
+	for _, fn := range funcs {
+		if fn.Directive() == DirectiveSkip || (onlyExported && !fn.IsExported()) {
+			continue
+		}
+		targets = append(targets, fn)
+	}
+	return targets
+}
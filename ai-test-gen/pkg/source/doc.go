@@ -0,0 +1,164 @@
+package source
+
+import (
+	"go/ast"
+	"go/format"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// DocTarget is an exported declaration without a doc comment
+type DocTarget struct {
+	// Key is the declared name, Type.Method for methods. For a const or var
+	// group it is the first exported name in the group.
+	Key string
+	// Kind is func, method, type, const or var
+	Kind string
+
+	pos, end token.Pos
+	indent   string
+}
+
+// Undocumented returns the exported declarations in the file that have no doc
+// comment, in source order. Methods are only included when their receiver type
+// is exported, since godoc hides the others.
+func (f *File) Undocumented() []DocTarget {
+	var targets []DocTarget
+	for _, decl := range f.AST.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil || !d.Name.IsExported() {
+				continue
+			}
+			kind := "func"
+			if recv := ReceiverType(d); recv != "" {
+				if !ast.IsExported(recv) {
+					continue
+				}
+				kind = "method"
+			}
+			targets = append(targets, DocTarget{Key: funcKey(d), Kind: kind, pos: d.Pos(), end: d.End()})
+		case *ast.GenDecl:
+			targets = append(targets, f.undocumentedSpecs(d)...)
+		}
+	}
+	return targets
+}
+
+// UndocumentedAt returns the target of Undocumented whose declaration holds
+// the byte offset of the source, the type spec within a group of types
+func (f *File) UndocumentedAt(offset int) (DocTarget, bool) {
+	pos := f.posOf(offset)
+	for _, t := range f.Undocumented() {
+		if t.pos <= pos && pos <= t.end {
+			return t, true
+		}
+	}
+	return DocTarget{}, false
+}
+
+func (f *File) undocumentedSpecs(d *ast.GenDecl) []DocTarget {
+	if d.Tok == token.IMPORT || d.Doc != nil {
+		return nil
+	}
+	grouped := d.Lparen.IsValid()
+
+	if d.Tok == token.TYPE {
+		var targets []DocTarget
+		for _, spec := range d.Specs {
+			s := spec.(*ast.TypeSpec)
+			if !s.Name.IsExported() || s.Doc != nil {
+				continue
+			}
+			if grouped {
+				targets = append(targets, DocTarget{Key: s.Name.Name, Kind: "type", pos: s.Pos(), end: s.End(), indent: "\t"})
+			} else {
+				targets = append(targets, DocTarget{Key: s.Name.Name, Kind: "type", pos: d.Pos(), end: d.End()})
+			}
+		}
+		return targets
+	}
+
+	// const and var groups are documented as a whole
+	for _, spec := range d.Specs {
+		for _, n := range spec.(*ast.ValueSpec).Names {
+			if n.IsExported() {
+				return []DocTarget{{Key: n.Name, Kind: d.Tok.String(), pos: d.Pos(), end: d.End()}}
+			}
+		}
+	}
+	return nil
+}
+
+// InsertDocComments returns the file source with the given comments placed
+// above the matching declarations, keyed by DocTarget.Key. Only whole comment
+// lines are inserted; existing code is left as it is.
+func (f *File) InsertDocComments(comments map[string]string) (string, error) {
+	type insertion struct {
+		offset int
+		text   string
+	}
+	var inserts []insertion
+	for _, t := range f.Undocumented() {
+		comment := strings.TrimSpace(comments[t.Key])
+		if comment == "" {
+			continue
+		}
+		pos := f.Fset.Position(t.pos)
+		var sb strings.Builder
+		for _, line := range CommentLines(comment, 77-len(t.indent)) {
+			sb.WriteString(strings.TrimRight(t.indent+"// "+line, " ") + "\n")
+		}
+		// insert at the start of the declaration's line so indentation is kept
+		inserts = append(inserts, insertion{offset: pos.Offset - (pos.Column - 1), text: sb.String()})
+	}
+	if len(inserts) == 0 {
+		return string(f.Src), nil
+	}
+
+	sort.Slice(inserts, func(i, j int) bool { return inserts[i].offset > inserts[j].offset })
+	src := string(f.Src)
+	for _, ins := range inserts {
+		src = src[:ins.offset] + ins.text + src[ins.offset:]
+	}
+
+	out, err := format.Source([]byte(src))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// CommentLines strips comment markers from text and wraps it into lines of at
+// most width characters, keeping blank lines between paragraphs
+func CommentLines(text string, width int) []string {
+	var lines []string
+	for i, para := range strings.Split(text, "\n\n") {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		var words []string
+		for _, line := range strings.Split(para, "\n") {
+			line = strings.TrimSpace(line)
+			line = strings.TrimSpace(strings.TrimPrefix(line, "//"))
+			words = append(words, strings.Fields(line)...)
+		}
+
+		var cur string
+		for _, w := range words {
+			if cur != "" && len(cur)+1+len(w) > width {
+				lines = append(lines, cur)
+				cur = ""
+			}
+			if cur != "" {
+				cur += " "
+			}
+			cur += w
+		}
+		if cur != "" {
+			lines = append(lines, cur)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,297 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// TypeParam is a type parameter and its constraint, as written in the source
+type TypeParam struct {
+	Name       string
+	Constraint string
+}
+
+// Generic is a generic function, or a method of a generic type, and the type
+// arguments to test it with
+type Generic struct {
+	Func Func
+	// TypeParams are those of the function, or for a method those of its
+	// receiver's type
+	TypeParams []TypeParam
+	// Instantiations are lists of type arguments, one per type parameter,
+	// that look like they satisfy the constraints. Constraints with methods
+	// have no candidate, leaving them none.
+	Instantiations [][]string
+}
+
+// Name returns the function as instantiated with args, e.g. Map[int, string]
+// or Stack[int].Push
+func (g Generic) Name(args []string) string {
+	inst := "[" + strings.Join(args, ", ") + "]"
+	if g.Func.Receiver != "" {
+		return g.Func.Receiver + inst + "." + g.Func.Name
+	}
+	return g.Func.Name + inst
+}
+
+// Signature returns the function with its type parameters, e.g.
+// Map[K comparable, V any] or Stack[T any].Push
+func (g Generic) Signature() string {
+	params := make([]string, len(g.TypeParams))
+	for i, p := range g.TypeParams {
+		params[i] = p.Name + " " + p.Constraint
+	}
+	return g.Name(params)
+}
+
+// constraintTypes are the type arguments tried for the common constraints,
+// the first of each preferred
+var constraintTypes = map[string][]string{
+	"any":                  {"int", "string"},
+	"interface{}":          {"int", "string"},
+	"comparable":           {"int", "string"},
+	"cmp.Ordered":          {"int", "float64", "string"},
+	"constraints.Ordered":  {"int", "float64", "string"},
+	"constraints.Integer":  {"int", "uint8"},
+	"constraints.Signed":   {"int", "int64"},
+	"constraints.Unsigned": {"uint", "uint8"},
+	"constraints.Float":    {"float64", "float32"},
+	"constraints.Complex":  {"complex128"},
+}
+
+// Generics returns the generic functions among funcs, and the methods of
+// generic types, with candidate instantiations
+func (f *File) Generics(funcs []Func) []Generic {
+	var generics []Generic
+	for _, fn := range funcs {
+		var params []TypeParam
+		if fn.Receiver == "" {
+			params = typeParams(fn.Decl.Type.TypeParams)
+		} else {
+			params = f.receiverTypeParams(fn)
+		}
+		if len(params) == 0 {
+			continue
+		}
+		generics = append(generics, Generic{Func: fn, TypeParams: params, Instantiations: instantiations(params)})
+	}
+	return generics
+}
+
+// typeParams flattens a type parameter list
+func typeParams(list *ast.FieldList) []TypeParam {
+	if list == nil {
+		return nil
+	}
+	var params []TypeParam
+	for _, field := range list.List {
+		constraint := types.ExprString(field.Type)
+		for _, name := range field.Names {
+			params = append(params, TypeParam{Name: name.Name, Constraint: constraint})
+		}
+	}
+	return params
+}
+
+// receiverTypeParams returns the type parameters of the type of the method fn,
+// named as in its receiver, when the type is declared in the file
+func (f *File) receiverTypeParams(fn Func) []TypeParam {
+	typ := fn.Decl.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	var names []ast.Expr
+	switch t := typ.(type) {
+	case *ast.IndexExpr:
+		names = []ast.Expr{t.Index}
+	case *ast.IndexListExpr:
+		names = t.Indices
+	default:
+		return nil
+	}
+
+	var declared []TypeParam
+	if gd, ok := f.decls[fn.Receiver].(*ast.GenDecl); ok {
+		for _, spec := range gd.Specs {
+			if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.Name == fn.Receiver {
+				declared = typeParams(ts.TypeParams)
+			}
+		}
+	}
+	params := make([]TypeParam, len(names))
+	for i, name := range names {
+		params[i] = TypeParam{Name: types.ExprString(name), Constraint: "any"}
+		if i < len(declared) {
+			// the receiver may rename the parameters, so the constraints
+			// refer to the declared names
+			params[i].Constraint = renameParams(declared[i].Constraint, declared, params[:i])
+		}
+	}
+	return params
+}
+
+// renameParams rewrites the type parameter names of declared used in
+// constraint to those of renamed, for the parameters renamed so far
+func renameParams(constraint string, declared, renamed []TypeParam) string {
+	for i, p := range renamed {
+		if declared[i].Name != p.Name {
+			constraint = replaceIdent(constraint, declared[i].Name, p.Name)
+		}
+	}
+	return constraint
+}
+
+// replaceIdent replaces the identifier name in expr with with
+func replaceIdent(expr, name, with string) string {
+	return regexp.MustCompile(`\b`+regexp.QuoteMeta(name)+`\b`).ReplaceAllString(expr, with)
+}
+
+// instantiations returns up to two lists of type arguments for params: the
+// first candidates of every constraint, then the second ones where there are
+// any. Constraints may refer to the parameters before them, e.g. S ~[]E.
+func instantiations(params []TypeParam) [][]string {
+	var insts [][]string
+	for n := range 2 {
+		args := make([]string, len(params))
+		varied := false
+		for i, p := range params {
+			constraint := p.Constraint
+			for j := range i {
+				constraint = replaceIdent(constraint, params[j].Name, args[j])
+			}
+			candidates := constraintCandidates(constraint)
+			if len(candidates) == 0 {
+				return insts
+			}
+			args[i] = candidates[min(n, len(candidates)-1)]
+			varied = varied || n < len(candidates)
+		}
+		if n > 0 && !varied {
+			break
+		}
+		insts = append(insts, args)
+	}
+	return insts
+}
+
+// constraintCandidates returns the type arguments to try for a constraint:
+// those of constraintTypes, or the terms of a union such as ~int | ~string
+// or ~[]int
+func constraintCandidates(constraint string) []string {
+	constraint = strings.TrimSpace(constraint)
+	if inner, ok := strings.CutPrefix(constraint, "interface{"); ok && strings.HasSuffix(inner, "}") {
+		constraint = strings.TrimSpace(strings.TrimSuffix(inner, "}"))
+		if constraint == "" {
+			return constraintTypes["any"]
+		}
+	}
+	if known, ok := constraintTypes[constraint]; ok {
+		return known
+	}
+	var candidates []string
+	for _, term := range strings.Split(constraint, "|") {
+		term = strings.TrimPrefix(strings.TrimSpace(term), "~")
+		// named constraints and interfaces with methods need a type of the
+		// tests' own
+		if term == "" || strings.ContainsAny(term, "{(;") || (!strings.ContainsAny(term, "[]*") && !isPredeclared(term)) {
+			return nil
+		}
+		candidates = append(candidates, term)
+	}
+	return candidates
+}
+
+// isPredeclared reports whether name is a predeclared type
+func isPredeclared(name string) bool {
+	obj := types.Universe.Lookup(name)
+	_, ok := obj.(*types.TypeName)
+	return ok && name != "error"
+}
+
+// instantiationsFile is the name of the file the instantiations are checked
+// in, added to the package in memory
+const instantiationsFile = "aitestgen_instantiations.go"
+
+// CheckInstantiations type-checks the instantiations of generics, found in
+// the file, against its package without writing anything to disk, and
+// returns generics with only the instantiations that compile
+func (f *File) CheckInstantiations(ctx context.Context, generics []Generic) ([]Generic, error) {
+	filename := f.Fset.Position(f.AST.Package).Filename
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// one instantiation per line, so errors can be traced back to it
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "package %s\n\nvar (\n", f.Package())
+	type ref struct{ generic, inst int }
+	lines := make(map[int]ref)
+	line := 4
+	for i, g := range generics {
+		for j, args := range g.Instantiations {
+			expr := g.Name(args)
+			if g.Func.Receiver != "" && isPointerReceiver(g.Func.Decl) {
+				expr = "(*" + g.Func.Receiver + "[" + strings.Join(args, ", ") + "])." + g.Func.Name
+			}
+			fmt.Fprintf(&sb, "\t_ = %s\n", expr)
+			lines[line] = ref{i, j}
+			line++
+		}
+	}
+	sb.WriteString(")\n")
+
+	stub := filepath.Join(filepath.Dir(abs), instantiationsFile)
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode:    packages.NeedName | packages.NeedFiles | packages.NeedTypes | packages.NeedSyntax,
+		Dir:     filepath.Dir(abs),
+		Overlay: map[string][]byte{stub: []byte(sb.String())},
+	}
+	pkgs, err := packages.Load(cfg, "file="+abs)
+	if err != nil {
+		return nil, fmt.Errorf("loading package: %w", err)
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no package found for %s", filename)
+	}
+
+	failed := make(map[ref]bool)
+	for _, e := range pkgs[0].Errors {
+		pos, rest, ok := strings.Cut(e.Pos, instantiationsFile+":")
+		if !ok || (pos != "" && !strings.HasSuffix(filepath.ToSlash(pos), "/")) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.SplitN(rest, ":", 2)[0])
+		if r, known := lines[n]; err == nil && known {
+			failed[r] = true
+		}
+	}
+
+	checked := slices.Clone(generics)
+	for i := range checked {
+		var kept [][]string
+		for j, args := range checked[i].Instantiations {
+			if !failed[ref{i, j}] {
+				kept = append(kept, args)
+			}
+		}
+		checked[i].Instantiations = kept
+	}
+	return checked, nil
+}
+
+// isPointerReceiver reports whether the method fn has a pointer receiver
+func isPointerReceiver(fn *ast.FuncDecl) bool {
+	_, ok := fn.Recv.List[0].Type.(*ast.StarExpr)
+	return ok
+}
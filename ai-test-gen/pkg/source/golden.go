@@ -0,0 +1,52 @@
+package source
+
+import (
+	"go/ast"
+)
+
+// GoldenCandidates returns the functions among funcs whose output is better
+// compared with a golden file than written into the test: those returning a
+// string, a byte slice, or a struct, slice, map or array, optionally along
+// with an error
+func (f *File) GoldenCandidates(funcs []Func) []Func {
+	var found []Func
+	for _, fn := range funcs {
+		results := fn.Decl.Type.Results
+		if results == nil || len(results.List) == 0 {
+			continue
+		}
+		if f.isLargeValue(results.List[0].Type) {
+			found = append(found, fn)
+		}
+	}
+	return found
+}
+
+// isLargeValue reports whether values of type expr tend to be large: strings,
+// byte slices, composites and the structs declared in the file
+func (f *File) isLargeValue(expr ast.Expr) bool {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if t.Name == "string" {
+			return true
+		}
+		gen, ok := f.decls[t.Name].(*ast.GenDecl)
+		if !ok {
+			return false
+		}
+		for _, spec := range gen.Specs {
+			if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.Name == t.Name {
+				switch ts.Type.(type) {
+				case *ast.StructType, *ast.ArrayType, *ast.MapType:
+					return true
+				}
+			}
+		}
+	case *ast.ArrayType, *ast.MapType, *ast.StructType:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package source
+
+import (
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GRPCService is a gRPC server implementation declared in a file: a type
+// embedding the Unimplemented<Service>Server of code generated by
+// protoc-gen-go-grpc
+type GRPCService struct {
+	// Server is the implementing type
+	Server string
+	// Service is the service name, e.g. Greeter for UnimplementedGreeterServer
+	Service string
+	// Package is the name the generated package is referred to by, "" when
+	// it is the file's own package
+	Package string
+	// ImportPath is the import path of the generated package, "" when it is
+	// the file's own package
+	ImportPath string
+	// Methods are the RPCs the server implements
+	Methods []Func
+}
+
+// GRPCServices returns the gRPC servers implemented in the file
+func (f *File) GRPCServices() []GRPCService {
+	var services []GRPCService
+	for _, decl := range f.AST.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			for _, field := range st.Fields.List {
+				if len(field.Names) > 0 {
+					continue
+				}
+				if svc, ok := f.unimplementedServer(field.Type); ok {
+					svc.Server = ts.Name.Name
+					services = append(services, svc)
+				}
+			}
+		}
+	}
+	for i := range services {
+		for _, fn := range f.Funcs() {
+			if fn.Receiver == services[i].Server && fn.Exported {
+				services[i].Methods = append(services[i].Methods, fn)
+			}
+		}
+	}
+	return services
+}
+
+// unimplementedServer reports whether the embedded type expr is an
+// Unimplemented<Service>Server and returns the service it names
+func (f *File) unimplementedServer(expr ast.Expr) (GRPCService, bool) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	var svc GRPCService
+	name := ""
+	switch t := expr.(type) {
+	case *ast.Ident:
+		name = t.Name
+	case *ast.SelectorExpr:
+		pkg, ok := t.X.(*ast.Ident)
+		if !ok {
+			return svc, false
+		}
+		name, svc.Package = t.Sel.Name, pkg.Name
+		svc.ImportPath = f.importPath(pkg.Name)
+	}
+	if !strings.HasPrefix(name, "Unimplemented") || !strings.HasSuffix(name, "Server") {
+		return svc, false
+	}
+	svc.Service = strings.TrimSuffix(strings.TrimPrefix(name, "Unimplemented"), "Server")
+	return svc, svc.Service != ""
+}
+
+// importPath returns the path of the import the file refers to as name
+func (f *File) importPath(name string) string {
+	for _, imp := range f.AST.Imports {
+		path := strings.Trim(imp.Path.Value, `"`)
+		if (imp.Name != nil && imp.Name.Name == name) || (imp.Name == nil && lastElem(path) == name) {
+			return path
+		}
+	}
+	return ""
+}
+
+// Context returns the declarations tests of the service need from its
+// generated code in dir: the client interface, the streaming client
+// interfaces and the message types of the RPCs, with their exported fields
+func (s GRPCService) Context(dir string) string {
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	var files []*File
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if file, err := Parse(path, src); err == nil {
+			files = append(files, file)
+		}
+	}
+
+	// types declared in the generated code, by name
+	types := make(map[string]*ast.TypeSpec)
+	fsets := make(map[string]*token.FileSet)
+	for _, file := range files {
+		for _, decl := range file.AST.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					types[ts.Name.Name] = ts
+					fsets[ts.Name.Name] = file.Fset
+				}
+			}
+		}
+	}
+	client, ok := types[s.Service+"Client"]
+	if !ok {
+		return ""
+	}
+
+	var decls []string
+	seen := make(map[string]bool)
+	var add func(name string)
+	add = func(name string) {
+		ts, ok := types[name]
+		if !ok || seen[name] {
+			return
+		}
+		seen[name] = true
+		if _, ok := ts.Type.(*ast.InterfaceType); ok {
+			// the messages an interface sends and receives
+			defer ast.Inspect(ts.Type, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					add(id.Name)
+				}
+				return true
+			})
+		}
+		spec := *ts
+		spec.Doc, spec.Comment = nil, nil
+		if st, ok := spec.Type.(*ast.StructType); ok {
+			spec.Type = exportedFields(st)
+		}
+		if text := formatNode(fsets[name], &ast.GenDecl{Tok: token.TYPE, Specs: []ast.Spec{&spec}}); text != "" {
+			decls = append(decls, text)
+		}
+	}
+	add(client.Name.Name)
+	// streams are named Service_MethodClient, or generic in newer versions
+	var streams []string
+	for name := range types {
+		if strings.HasPrefix(name, s.Service+"_") && strings.HasSuffix(name, "Client") {
+			streams = append(streams, name)
+		}
+	}
+	sort.Strings(streams)
+	for _, name := range streams {
+		add(name)
+	}
+	return strings.Join(decls, "\n\n")
+}
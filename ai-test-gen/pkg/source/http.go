@@ -0,0 +1,108 @@
+package source
+
+import (
+	"go/ast"
+	"strconv"
+	"strings"
+)
+
+// HTTPHandlers returns the functions among funcs that serve HTTP requests:
+// those with the signature of an http.HandlerFunc, ServeHTTP methods, and
+// functions returning an http.Handler or http.HandlerFunc
+func (f *File) HTTPHandlers(funcs []Func) []Func {
+	http := f.importName("net/http")
+	if http == "" {
+		return nil
+	}
+	var handlers []Func
+	for _, fn := range funcs {
+		if isHandlerSignature(fn.Decl.Type, http) || returnsHandler(fn.Decl.Type, http) {
+			handlers = append(handlers, fn)
+		}
+	}
+	return handlers
+}
+
+// isHandlerSignature reports whether ft is func(http.ResponseWriter, *http.Request)
+func isHandlerSignature(ft *ast.FuncType, http string) bool {
+	var params []ast.Expr
+	for _, field := range ft.Params.List {
+		for range max(len(field.Names), 1) {
+			params = append(params, field.Type)
+		}
+	}
+	if len(params) != 2 || (ft.Results != nil && len(ft.Results.List) > 0) {
+		return false
+	}
+	star, ok := params[1].(*ast.StarExpr)
+	return ok && isQualified(params[0], http, "ResponseWriter") && isQualified(star.X, http, "Request")
+}
+
+// returnsHandler reports whether ft returns an http.Handler or
+// http.HandlerFunc, or a func with the handler signature
+func returnsHandler(ft *ast.FuncType, http string) bool {
+	if ft.Results == nil || len(ft.Results.List) != 1 || len(ft.Results.List[0].Names) > 1 {
+		return false
+	}
+	switch t := ft.Results.List[0].Type.(type) {
+	case *ast.FuncType:
+		return isHandlerSignature(t, http)
+	default:
+		return isQualified(t, http, "Handler") || isQualified(t, http, "HandlerFunc")
+	}
+}
+
+// isQualified reports whether expr is the type pkg.name
+func isQualified(expr ast.Expr, pkg, name string) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != name {
+		return false
+	}
+	id, ok := sel.X.(*ast.Ident)
+	return ok && id.Name == pkg
+}
+
+// importName returns the name the file refers to the package with import
+// path by, or "" when it doesn't import it
+func (f *File) importName(path string) string {
+	for _, imp := range f.AST.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err != nil || p != path {
+			continue
+		}
+		if imp.Name != nil {
+			if imp.Name.Name == "_" || imp.Name.Name == "." {
+				return ""
+			}
+			return imp.Name.Name
+		}
+		return lastElem(path)
+	}
+	return ""
+}
+
+// lastElem returns the last element of an import path, the one before a
+// major version suffix such as /v4, which is usually the package name
+func lastElem(path string) string {
+	if i := strings.LastIndex(path, "/"); i > 0 && isMajorVersion(path[i+1:]) {
+		path = path[:i]
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '/' {
+			return path[i+1:]
+		}
+	}
+	return path
+}
+
+// isMajorVersion reports whether elem is a major version suffix, v2 or above
+func isMajorVersion(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' || elem == "v0" || elem == "v1" {
+		return false
+	}
+	for _, r := range elem[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package source
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ImportStd stands for the standard library in the patterns of an
+// ImportPolicy
+const ImportStd = "std"
+
+// ImportPolicy restricts the packages generated code may import. Patterns
+// are import paths, paths ending in /... for a path and everything below it,
+// or ImportStd. The zero value allows everything.
+type ImportPolicy struct {
+	// Allow, when set, lists the only packages that may be imported besides
+	// those of Module
+	Allow []string
+	// Deny lists packages that may not be imported, even when allowed
+	Deny []string
+	// Module is the path of the module of the code under test, whose
+	// packages are allowed unless denied
+	Module string
+}
+
+// IsZero reports whether p restricts nothing
+func (p ImportPolicy) IsZero() bool {
+	return len(p.Allow) == 0 && len(p.Deny) == 0
+}
+
+// Allowed reports whether p allows importing path
+func (p ImportPolicy) Allowed(path string) bool {
+	match := func(pattern string) bool { return matchImport(pattern, path) }
+	if slices.ContainsFunc(p.Deny, match) {
+		return false
+	}
+	if len(p.Allow) == 0 || slices.ContainsFunc(p.Allow, match) {
+		return true
+	}
+	return p.Module != "" && matchImport(p.Module+"/...", path)
+}
+
+// Disallowed returns the imports of the Go file src that p doesn't allow, in
+// the order of the file
+func (p ImportPolicy) Disallowed(src string) ([]string, error) {
+	if p.IsZero() {
+		return nil, nil
+	}
+	f, err := Parse("imports.go", []byte(src))
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, imp := range f.AST.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || path == "C" {
+			continue
+		}
+		if !p.Allowed(path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// matchImport reports whether the import path matches pattern
+func matchImport(pattern, path string) bool {
+	if pattern == ImportStd {
+		// as in the go tool, standard library paths have no dot in their
+		// first element
+		first, _, _ := strings.Cut(path, "/")
+		return !strings.Contains(first, ".")
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+		return path == prefix || strings.HasPrefix(path, prefix+"/")
+	}
+	return path == pattern
+}
@@ -0,0 +1,114 @@
+package source
+
+import (
+	"go/build/constraint"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Services run by testcontainers-go
+const (
+	ServicePostgres = "postgres"
+	ServiceRedis    = "redis"
+	ServiceKafka    = "kafka"
+)
+
+// Service is an external service the code talks to through a client library
+type Service struct {
+	// Name is one of the Service constants
+	Name string
+	// Client is the import path of the client the code uses
+	Client string
+}
+
+// serviceClients maps the paths of client libraries to the service they talk
+// to. The packages below a path, including its major versions, match too.
+var serviceClients = []struct{ path, service string }{
+	{"github.com/lib/pq", ServicePostgres},
+	{"github.com/jackc/pgx", ServicePostgres},
+	{"gorm.io/driver/postgres", ServicePostgres},
+	{"github.com/redis/go-redis", ServiceRedis},
+	{"github.com/go-redis/redis", ServiceRedis},
+	{"github.com/gomodule/redigo", ServiceRedis},
+	{"github.com/segmentio/kafka-go", ServiceKafka},
+	{"github.com/IBM/sarama", ServiceKafka},
+	{"github.com/Shopify/sarama", ServiceKafka},
+	{"github.com/confluentinc/confluent-kafka-go", ServiceKafka},
+	{"github.com/twmb/franz-go", ServiceKafka},
+}
+
+// serviceOf returns the service the client with importPath talks to, or ""
+func serviceOf(importPath string) string {
+	for _, c := range serviceClients {
+		if importPath == c.path || strings.HasPrefix(importPath, c.path+"/") {
+			return c.service
+		}
+	}
+	return ""
+}
+
+// Services returns the services the file uses through their client
+// libraries, in the order they are imported. Code using database/sql talks
+// to Postgres when requires, the modules its module requires, include a
+// Postgres driver, as the driver is usually only imported by the main
+// package.
+func (f *File) Services(requires []string) []Service {
+	var services []Service
+	add := func(s Service) {
+		if !slices.ContainsFunc(services, func(o Service) bool { return o.Name == s.Name }) {
+			services = append(services, s)
+		}
+	}
+	for _, imp := range f.AST.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		if name := serviceOf(path); name != "" {
+			add(Service{Name: name, Client: path})
+			continue
+		}
+		if path == "database/sql" && slices.ContainsFunc(requires, func(req string) bool { return serviceOf(req) == ServicePostgres }) {
+			add(Service{Name: ServicePostgres, Client: path})
+		}
+	}
+	return services
+}
+
+// RequireBuildTag returns src with a //go:build constraint requiring tag,
+// adding the tag to the constraint src already has
+func RequireBuildTag(src, tag string) (string, error) {
+	lines := strings.SplitAfter(src, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "package ") {
+			break
+		}
+		if !constraint.IsGoBuild(trimmed) {
+			continue
+		}
+		expr, err := constraint.Parse(trimmed)
+		if err != nil {
+			return "", err
+		}
+		if requiresTag(expr, tag) {
+			return src, nil
+		}
+		expr = &constraint.AndExpr{X: expr, Y: &constraint.TagExpr{Tag: tag}}
+		lines[i] = "//go:build " + expr.String() + "\n"
+		return strings.Join(lines, ""), nil
+	}
+	return "//go:build " + tag + "\n\n" + src, nil
+}
+
+// requiresTag reports whether expr is tag or a conjunction including it
+func requiresTag(expr constraint.Expr, tag string) bool {
+	switch x := expr.(type) {
+	case *constraint.TagExpr:
+		return x.Tag == tag
+	case *constraint.AndExpr:
+		return requiresTag(x.X, tag) || requiresTag(x.Y, tag)
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+package source
+
+import (
+	"go/ast"
+	"go/format"
+	"go/token"
+	"strconv"
+)
+
+// SharedState is process-wide state a test changes, which keeps it from
+// running in parallel with other tests
+type SharedState struct {
+	// Test is the name of the TestXxx function
+	Test string
+	Line int
+	// What describes the change, e.g. "os.Setenv" or "package variable cfg"
+	What string
+	// Hint suggests an isolated alternative, if there is one
+	Hint string
+	// Managed is set for changes the testing package undoes, made with
+	// t.Setenv and t.Chdir, which are only a reason to stay serial
+	Managed bool
+}
+
+// globalCalls are the functions changing process-wide state, by import path
+// and name, with the isolated alternative to suggest
+var globalCalls = map[string]map[string]string{
+	"os": {
+		"Setenv": "use t.Setenv", "Unsetenv": "use t.Setenv", "Clearenv": "",
+		"Chdir": "use t.Chdir", "TempDir": "use t.TempDir for a directory of the test's own",
+	},
+	"log":           {"SetOutput": "", "SetFlags": "", "SetPrefix": ""},
+	"log/slog":      {"SetDefault": "", "SetLogLoggerLevel": ""},
+	"flag":          {"Set": "", "Parse": ""},
+	"math/rand":     {"Seed": "use a local rand.New(rand.NewSource(seed))"},
+	"runtime":       {"GOMAXPROCS": ""},
+	"runtime/debug": {"SetGCPercent": "", "SetMemoryLimit": ""},
+}
+
+// SharedState returns the process-wide state the tests of the file change:
+// environment variables, the working directory, package level variables of
+// the test or its package, variables of other packages such as
+// http.DefaultClient, the shared temporary directory and global settings of
+// log, flag, math/rand and runtime
+func (f *File) SharedState() []SharedState {
+	calls := make(map[string]map[string]string)
+	imports := make(map[string]bool)
+	for _, imp := range f.AST.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := f.importName(path)
+		if name == "" {
+			continue
+		}
+		imports[name] = true
+		if funcs, ok := globalCalls[path]; ok {
+			calls[name] = funcs
+		}
+	}
+
+	var states []SharedState
+	for _, fn := range testFuncs(f.AST) {
+		states = append(states, f.testSharedState(fn, calls, imports)...)
+	}
+	return states
+}
+
+// testSharedState returns the process-wide state fn changes. calls maps the
+// names of the imported packages in globalCalls to their functions, and
+// imports holds the names of all imported packages.
+func (f *File) testSharedState(fn *ast.FuncDecl, calls map[string]map[string]string, imports map[string]bool) []SharedState {
+	local := localNames(fn)
+	testing := make(map[string]bool)
+	testing[testingParam(fn.Type)] = true
+
+	var states []SharedState
+	add := func(pos token.Pos, what, hint string, managed bool) {
+		states = append(states, SharedState{Test: fn.Name.Name, Line: f.Fset.Position(pos).Line, What: what, Hint: hint, Managed: managed})
+	}
+	// assigned reports a change to the variable expr is rooted at, unless
+	// it is local to the test
+	assigned := func(pos token.Pos, expr ast.Expr) {
+		for {
+			switch x := expr.(type) {
+			case *ast.SelectorExpr:
+				if id, ok := x.X.(*ast.Ident); ok && imports[id.Name] && !local[id.Name] {
+					add(pos, "package variable "+id.Name+"."+x.Sel.Name, "", false)
+					return
+				}
+				expr = x.X
+				continue
+			case *ast.IndexExpr:
+				expr = x.X
+				continue
+			case *ast.StarExpr:
+				expr = x.X
+				continue
+			case *ast.ParenExpr:
+				expr = x.X
+				continue
+			case *ast.Ident:
+				if x.Name != "_" && !local[x.Name] {
+					add(pos, "package variable "+x.Name, "", false)
+				}
+			}
+			return
+		}
+	}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			if t := testingParam(x.Type); t != "" {
+				testing[t] = true
+			}
+		case *ast.AssignStmt:
+			if x.Tok != token.DEFINE {
+				for _, lhs := range x.Lhs {
+					assigned(x.Pos(), lhs)
+				}
+			}
+		case *ast.IncDecStmt:
+			assigned(x.Pos(), x.X)
+		case *ast.CallExpr:
+			sel, ok := x.Fun.(*ast.SelectorExpr)
+			if !ok {
+				break
+			}
+			id, ok := sel.X.(*ast.Ident)
+			if !ok || local[id.Name] && !testing[id.Name] {
+				break
+			}
+			if testing[id.Name] && (sel.Sel.Name == "Setenv" || sel.Sel.Name == "Chdir") {
+				add(x.Pos(), "t."+sel.Sel.Name, "", true)
+				break
+			}
+			if hint, ok := calls[id.Name][sel.Sel.Name]; ok {
+				add(x.Pos(), id.Name+"."+sel.Sel.Name, hint, false)
+			}
+		}
+		return true
+	})
+	return states
+}
+
+// localNames returns the names declared in fn: its parameters and results,
+// and the variables, constants, types and parameters of function literals
+// declared in its body
+func localNames(fn *ast.FuncDecl) map[string]bool {
+	local := make(map[string]bool)
+	fields := func(list *ast.FieldList) {
+		if list == nil {
+			return
+		}
+		for _, field := range list.List {
+			for _, name := range field.Names {
+				local[name.Name] = true
+			}
+		}
+	}
+	fields(fn.Type.Params)
+	fields(fn.Type.Results)
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if x.Tok == token.DEFINE {
+				for _, lhs := range x.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok {
+						local[id.Name] = true
+					}
+				}
+			}
+		case *ast.RangeStmt:
+			if x.Tok == token.DEFINE {
+				for _, e := range []ast.Expr{x.Key, x.Value} {
+					if id, ok := e.(*ast.Ident); ok {
+						local[id.Name] = true
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			for _, name := range x.Names {
+				local[name.Name] = true
+			}
+		case *ast.TypeSpec:
+			local[x.Name.Name] = true
+		case *ast.FuncLit:
+			fields(x.Type.Params)
+			fields(x.Type.Results)
+		}
+		return true
+	})
+	return local
+}
+
+// sharedTests returns the names of the tests of f that change process-wide
+// state
+func sharedTests(f *File) map[string]bool {
+	shared := make(map[string]bool)
+	for _, s := range f.SharedState() {
+		shared[s.Test] = true
+	}
+	return shared
+}
+
+// SerializeSharedTests removes t.Parallel() from the tests of src that change
+// process-wide state, and from their subtests, which would otherwise race
+// with the other tests or, with t.Setenv and t.Chdir, panic
+func SerializeSharedTests(src string) (string, error) {
+	f, err := Parse("isolation_test.go", []byte(src))
+	if err != nil {
+		return "", err
+	}
+	shared := sharedTests(f)
+	if len(shared) == 0 {
+		return src, nil
+	}
+	var edits []edit
+	for _, fn := range testFuncs(f.AST) {
+		if shared[fn.Name.Name] {
+			edits = append(edits, serialEdits(f, fn.Body)...)
+		}
+	}
+	if len(edits) == 0 {
+		return src, nil
+	}
+	out, err := format.Source([]byte(applyEdits(src, edits)))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
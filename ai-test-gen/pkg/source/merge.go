@@ -0,0 +1,286 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// MergeTests assembles several generated test files for the same package into
+// one, combining their imports and dropping declarations that appear twice.
+func MergeTests(chunks []string) (string, error) {
+	var pkg string
+	imports := make(map[string]string)
+	seen := make(map[string]bool)
+	var body strings.Builder
+
+	for i, chunk := range chunks {
+		f, err := Parse(fmt.Sprintf("chunk%d_test.go", i), []byte(chunk))
+		if err != nil {
+			return "", fmt.Errorf("generated chunk %d does not parse: %w", i, err)
+		}
+		if pkg == "" {
+			pkg = f.Package()
+		}
+
+		for _, imp := range f.AST.Imports {
+			line := imp.Path.Value
+			if imp.Name != nil {
+				line = imp.Name.Name + " " + line
+			}
+			imports[line] = line
+		}
+
+		for _, decl := range f.AST.Decls {
+			if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+				continue
+			}
+			key := declKey(f, decl)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			body.WriteString("\n")
+			body.WriteString(f.Text(decl))
+			body.WriteString("\n")
+		}
+	}
+	if pkg == "" {
+		return "", fmt.Errorf("nothing to merge")
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "package %s\n", pkg)
+	if len(imports) > 0 {
+		lines := make([]string, 0, len(imports))
+		for _, line := range imports {
+			lines = append(lines, line)
+		}
+		sort.Strings(lines)
+		sb.WriteString("\nimport (\n")
+		for _, line := range lines {
+			sb.WriteString("\t" + line + "\n")
+		}
+		sb.WriteString(")\n")
+	}
+	sb.WriteString(body.String())
+
+	out, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return "", fmt.Errorf("merged tests do not format: %w", err)
+	}
+	return string(out), nil
+}
+
+// declKey identifies a declaration by the names it defines
+func declKey(f *File, decl ast.Decl) string {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return "func " + funcKey(d)
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					names = append(names, n.Name)
+				}
+			}
+		}
+		if len(names) > 0 {
+			return d.Tok.String() + " " + strings.Join(names, ",")
+		}
+	}
+	return f.Text(decl)
+}
+
+// AppendTests adds the declarations and imports from generated chunks to an
+// existing test file. The existing file is kept verbatim; generated
+// declarations whose names it already defines are dropped.
+func AppendTests(existing string, chunks []string) (string, error) {
+	merged, err := MergeTests(chunks)
+	if err != nil {
+		return "", err
+	}
+
+	ef, err := Parse("existing_test.go", []byte(existing))
+	if err != nil {
+		return "", fmt.Errorf("existing test file does not parse: %w", err)
+	}
+	nf, err := Parse("generated_test.go", []byte(merged))
+	if err != nil {
+		return "", err
+	}
+
+	have := make(map[string]bool)
+	for _, decl := range ef.AST.Decls {
+		have[declKey(ef, decl)] = true
+	}
+	haveImport := make(map[string]bool)
+	for _, imp := range ef.AST.Imports {
+		haveImport[imp.Path.Value] = true
+	}
+
+	var newImports []string
+	for _, imp := range nf.AST.Imports {
+		if haveImport[imp.Path.Value] {
+			continue
+		}
+		line := imp.Path.Value
+		if imp.Name != nil {
+			line = imp.Name.Name + " " + line
+		}
+		newImports = append(newImports, line)
+	}
+
+	var tail strings.Builder
+	for _, decl := range nf.AST.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			continue
+		}
+		if have[declKey(nf, decl)] {
+			continue
+		}
+		tail.WriteString("\n")
+		tail.WriteString(nf.Text(decl))
+		tail.WriteString("\n")
+	}
+
+	out := insertImports(ef, newImports)
+	out = strings.TrimRight(out, "\n") + "\n" + tail.String()
+
+	formatted, err := format.Source([]byte(out))
+	if err != nil {
+		return "", fmt.Errorf("appended tests do not format: %w", err)
+	}
+	return string(formatted), nil
+}
+
+// insertImports returns the source of f with extra import lines added
+func insertImports(f *File, lines []string) string {
+	src := string(f.Src)
+	if len(lines) == 0 {
+		return src
+	}
+	block := "\t" + strings.Join(lines, "\n\t") + "\n"
+
+	for _, decl := range f.AST.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		if gd.Rparen.IsValid() {
+			at := f.Fset.Position(gd.Rparen).Offset
+			return src[:at] + block + src[at:]
+		}
+		// single unparenthesized import: rewrite it as a block
+		start := f.Fset.Position(gd.Pos()).Offset
+		end := f.Fset.Position(gd.End()).Offset
+		spec := strings.TrimSpace(strings.TrimPrefix(src[start:end], "import"))
+		return src[:start] + "import (\n\t" + spec + "\n" + block + ")" + src[end:]
+	}
+
+	at := f.Fset.Position(f.AST.Name.End()).Offset
+	return src[:at] + "\n\nimport (\n" + block + ")" + src[at:]
+}
+
+// AddImports adds import lines (e.g. `"fmt"` or `. "github.com/onsi/gomega"`)
+// to Go source, skipping paths that are already imported
+func AddImports(src string, lines []string) (string, error) {
+	f, err := Parse("src.go", []byte(src))
+	if err != nil {
+		return "", err
+	}
+	have := make(map[string]bool)
+	for _, imp := range f.AST.Imports {
+		have[imp.Path.Value] = true
+	}
+	var missing []string
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if !have[fields[len(fields)-1]] {
+			missing = append(missing, line)
+		}
+	}
+	if len(missing) == 0 {
+		return src, nil
+	}
+	out, err := format.Source([]byte(insertImports(f, missing)))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// HasTest reports whether one of the test names appears to target fn, using
+// the TestName, TestName_Case, TestTypeMethod and TestType_Method conventions.
+// Anything else after the name makes it another function's test, e.g.
+// TestReverseWords or TestParse2 don't test Reverse or Parse.
+func HasTest(testNames []string, fn Func) bool {
+	prefixes := []string{"Test" + fn.Name}
+	if fn.Receiver != "" {
+		prefixes = []string{"Test" + fn.Receiver + fn.Name, "Test" + fn.Receiver + "_" + fn.Name}
+	}
+	for _, name := range testNames {
+		for _, prefix := range prefixes {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			rest := name[len(prefix):]
+			if rest == "" || rest[0] == '_' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TestNames returns the names of the TestXxx and ExampleXxx functions declared
+// in the file, i.e. those run by go test
+func (f *File) TestNames() []string {
+	var names []string
+	for _, fn := range f.Funcs() {
+		if fn.Receiver == "" && (strings.HasPrefix(fn.Name, "Test") || strings.HasPrefix(fn.Name, "Example")) {
+			names = append(names, fn.Name)
+		}
+	}
+	return names
+}
+
+// RemoveTests returns src without the top-level functions named in names.
+// Imports left unused are not removed.
+func RemoveTests(src string, names []string) (string, error) {
+	f, err := Parse("src.go", []byte(src))
+	if err != nil {
+		return "", err
+	}
+	drop := make(map[string]bool, len(names))
+	for _, name := range names {
+		drop[name] = true
+	}
+	var sb strings.Builder
+	last := 0
+	for _, decl := range f.AST.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || !drop[fn.Name.Name] {
+			continue
+		}
+		start := fn.Pos()
+		if fn.Doc != nil {
+			start = fn.Doc.Pos()
+		}
+		sb.WriteString(src[last:f.Fset.Position(start).Offset])
+		last = f.Fset.Position(fn.End()).Offset
+	}
+	sb.WriteString(src[last:])
+	out, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
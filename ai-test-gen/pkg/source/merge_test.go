@@ -0,0 +1,32 @@
+package source
+
+import "testing"
+
+func TestHasTest(t *testing.T) {
+	tests := []struct {
+		name  string
+		tests []string
+		fn    Func
+		want  bool
+	}{
+		{"exact", []string{"TestReverse"}, Func{Name: "Reverse"}, true},
+		{"case suffix", []string{"TestReverse_empty"}, Func{Name: "Reverse"}, true},
+		{"longer name", []string{"TestReverseWords"}, Func{Name: "Reverse"}, false},
+		{"digit suffix", []string{"TestParse2"}, Func{Name: "Parse"}, false},
+		{"lowercase suffix", []string{"TestParser"}, Func{Name: "Parse"}, false},
+		{"one of many", []string{"TestOther", "TestParse_invalid"}, Func{Name: "Parse"}, true},
+		{"no tests", nil, Func{Name: "Parse"}, false},
+		{"method", []string{"TestStackPush"}, Func{Name: "Push", Receiver: "Stack"}, true},
+		{"method underscore", []string{"TestStack_Push"}, Func{Name: "Push", Receiver: "Stack"}, true},
+		{"method case", []string{"TestStack_Push_full"}, Func{Name: "Push", Receiver: "Stack"}, true},
+		{"longer method", []string{"TestStackPushAll"}, Func{Name: "Push", Receiver: "Stack"}, false},
+		{"method name only", []string{"TestPush"}, Func{Name: "Push", Receiver: "Stack"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasTest(tt.tests, tt.fn); got != tt.want {
+				t.Errorf("HasTest(%q, %s) = %v, want %v", tt.tests, tt.fn.Key(), got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// Module is the Go module a source directory belongs to
+type Module struct {
+	// Path is the module path declared in go.mod
+	Path string
+	// Dir is the directory holding go.mod
+	Dir string
+	// Requires lists the paths of the required modules
+	Requires []string
+}
+
+// ErrNoModule is returned by FindModule outside a module
+var ErrNoModule = errors.New("no go.mod found")
+
+// FindModule reads the go.mod governing dir, searching its parent directories
+func FindModule(dir string) (*Module, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		gomod := filepath.Join(dir, "go.mod")
+		data, err := os.ReadFile(gomod)
+		if err == nil {
+			f, err := modfile.ParseLax(gomod, data, nil)
+			if err != nil {
+				return nil, fmt.Errorf("parse error: %w", err)
+			}
+			if f.Module == nil {
+				return nil, fmt.Errorf("%s has no module directive", gomod)
+			}
+			mod := &Module{Path: f.Module.Mod.Path, Dir: dir}
+			for _, r := range f.Require {
+				mod.Requires = append(mod.Requires, r.Mod.Path)
+			}
+			return mod, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, ErrNoModule
+		}
+		dir = parent
+	}
+}
+
+// ImportPath returns the import path of the package in dir, which must lie
+// within the module
+func (m *Module) ImportPath(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(m.Dir, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside module %s", dir, m.Path)
+	}
+	if rel == "." {
+		return m.Path, nil
+	}
+	return path.Join(m.Path, filepath.ToSlash(rel)), nil
+}
+
+// knownModules are modules generated tests commonly import before they are
+// added to go.mod, which must not be mistaken for misspelled module packages
+var knownModules = []string{
+	"github.com/stretchr/testify",
+	"github.com/onsi/ginkgo",
+	"github.com/onsi/gomega",
+	"github.com/google/go-cmp",
+	"github.com/golang/mock",
+	"go.uber.org/mock",
+	"github.com/DATA-DOG/go-sqlmock",
+	"golang.org/x",
+	"google.golang.org",
+}
+
+// PackageDir returns the directory of the package with importPath when it
+// lies within the module
+func (m *Module) PackageDir(importPath string) (string, bool) {
+	if importPath != m.Path && !strings.HasPrefix(importPath, m.Path+"/") {
+		return "", false
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(importPath, m.Path), "/")
+	dir := filepath.Join(m.Dir, filepath.FromSlash(rel))
+	return dir, hasGoFiles(dir)
+}
+
+// resolveImport returns the import path within the module that importPath
+// was most likely meant to be, e.g. github.com/example/app/internal/store for
+// github.com/acme/app/internal/store when the module is github.com/example/app.
+// Standard library paths, paths of required modules and paths that already
+// resolve are returned unchanged, as are paths matching no package directory.
+func (m *Module) resolveImport(importPath string) string {
+	if importPath == m.Path || strings.HasPrefix(importPath, m.Path+"/") {
+		rel := strings.TrimPrefix(strings.TrimPrefix(importPath, m.Path), "/")
+		if hasGoFiles(filepath.Join(m.Dir, filepath.FromSlash(rel))) {
+			return importPath
+		}
+	} else {
+		first, _, _ := strings.Cut(importPath, "/")
+		if !strings.Contains(first, ".") {
+			// the standard library
+			return importPath
+		}
+		for _, req := range append(m.Requires, knownModules...) {
+			if importPath == req || strings.HasPrefix(importPath, req+"/") {
+				return importPath
+			}
+		}
+	}
+
+	// take the longest trailing part of the path naming a package of the module
+	elems := strings.Split(importPath, "/")
+	for i := 1; i < len(elems); i++ {
+		rel := path.Join(elems[i:]...)
+		if hasGoFiles(filepath.Join(m.Dir, filepath.FromSlash(rel))) {
+			return path.Join(m.Path, rel)
+		}
+	}
+	return importPath
+}
+
+// hasGoFiles reports whether dir contains a non-test Go file
+func hasGoFiles(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+			return true
+		}
+	}
+	return false
+}
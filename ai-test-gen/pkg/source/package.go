@@ -0,0 +1,231 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// siblingDecl is a declaration from another file of the package, reduced to
+// what a caller needs to know
+type siblingDecl struct {
+	pos  token.Position
+	node ast.Node
+}
+
+// PackageContext returns the declarations from the other files of filename's
+// package that file refers to: the types it uses, with their fields and method
+// signatures, the constructors returning them, and the signatures of the
+// functions, constants and variables it calls or reads. Function bodies are
+// left out. It returns "" when file refers to nothing outside itself.
+func PackageContext(ctx context.Context, filename string, file *File) (string, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode:    packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedSyntax,
+		Dir:     filepath.Dir(abs),
+	}
+	pkgs, err := packages.Load(cfg, "file="+abs)
+	if err != nil {
+		return "", fmt.Errorf("loading package: %w", err)
+	}
+	if len(pkgs) == 0 {
+		return "", fmt.Errorf("no package found for %s", filename)
+	}
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 && len(pkg.Syntax) == 0 {
+		return "", fmt.Errorf("loading package: %v", pkg.Errors[0])
+	}
+
+	var (
+		types   = make(map[string]siblingDecl)
+		funcs   = make(map[string]siblingDecl)
+		values  = make(map[string]siblingDecl)
+		methods = make(map[string][]siblingDecl)
+	)
+	for _, syntax := range pkg.Syntax {
+		if pkg.Fset.Position(syntax.Package).Filename == abs {
+			continue
+		}
+		for _, decl := range syntax.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				sig := &ast.FuncDecl{Recv: d.Recv, Name: d.Name, Type: d.Type}
+				entry := siblingDecl{pos: pkg.Fset.Position(d.Pos()), node: sig}
+				if recv := ReceiverType(d); recv != "" {
+					methods[recv] = append(methods[recv], entry)
+				} else if d.Name.Name != "init" && d.Name.Name != "main" {
+					funcs[d.Name.Name] = entry
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					entry := siblingDecl{pos: pkg.Fset.Position(spec.Pos())}
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						entry.node = &ast.GenDecl{Tok: token.TYPE, Specs: []ast.Spec{s}}
+						types[s.Name.Name] = entry
+					case *ast.ValueSpec:
+						vs := *s
+						vs.Doc, vs.Comment = nil, nil
+						// with a declared type the initial value adds nothing
+						if vs.Type != nil {
+							vs.Values = nil
+						}
+						entry.node = &ast.GenDecl{Tok: d.Tok, Specs: []ast.Spec{&vs}}
+						for _, n := range s.Names {
+							values[n.Name] = entry
+						}
+					}
+				}
+			}
+		}
+	}
+
+	referenced := make(map[string]bool)
+	ast.Inspect(file.AST, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			if _, own := file.decls[ident.Name]; !own {
+				referenced[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	included := make(map[ast.Node]siblingDecl)
+	var queue []string
+	for name := range referenced {
+		if _, ok := types[name]; ok {
+			queue = append(queue, name)
+		}
+		if d, ok := funcs[name]; ok {
+			included[d.node] = d
+		}
+		if d, ok := values[name]; ok {
+			included[d.node] = d
+		}
+	}
+	// follow the types used by the included types, e.g. in struct fields
+	seenTypes := make(map[string]bool)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seenTypes[name] {
+			continue
+		}
+		seenTypes[name] = true
+		d := types[name]
+		included[d.node] = d
+		for _, m := range methods[name] {
+			included[m.node] = m
+		}
+		ast.Inspect(d.node, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok {
+				if _, ok := types[ident.Name]; ok && !seenTypes[ident.Name] {
+					queue = append(queue, ident.Name)
+				}
+			}
+			return true
+		})
+	}
+	// constructors are how the tests should build the types they use
+	for _, d := range funcs {
+		results := d.node.(*ast.FuncDecl).Type.Results
+		if results == nil {
+			continue
+		}
+		ast.Inspect(results, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && seenTypes[ident.Name] {
+				included[d.node] = d
+				return false
+			}
+			return true
+		})
+	}
+	if len(included) == 0 {
+		return "", nil
+	}
+
+	ordered := make([]siblingDecl, 0, len(included))
+	for _, d := range included {
+		ordered = append(ordered, d)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].pos.Filename != ordered[j].pos.Filename {
+			return ordered[i].pos.Filename < ordered[j].pos.Filename
+		}
+		return ordered[i].pos.Offset < ordered[j].pos.Offset
+	})
+
+	var sb strings.Builder
+	writeDecls(&sb, pkg.Fset, ordered)
+	return sb.String(), nil
+}
+
+// writeDecls prints decls grouped under a comment naming their file
+func writeDecls(sb *strings.Builder, fset *token.FileSet, decls []siblingDecl) {
+	lastFile := ""
+	for _, d := range decls {
+		if d.pos.Filename != lastFile {
+			if lastFile != "" {
+				sb.WriteString("\n")
+			}
+			fmt.Fprintf(sb, "// from %s\n", filepath.Base(d.pos.Filename))
+			lastFile = d.pos.Filename
+		}
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, d.node); err != nil {
+			continue
+		}
+		sb.Write(buf.Bytes())
+		sb.WriteString("\n")
+	}
+}
+
+// Package is a package loaded with LoadPackage
+type Package struct {
+	Name string
+	// Path is the import path
+	Path string
+	Dir  string
+	// Files are the Go files of the package, without its tests
+	Files []string
+}
+
+// LoadPackage loads the package pattern names, e.g. ./pkg/foo, as seen from
+// dir. The pattern must match a single package.
+func LoadPackage(ctx context.Context, dir, pattern string) (*Package, error) {
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode:    packages.NeedName | packages.NeedFiles,
+		Dir:     dir,
+	}
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("loading package: %w", err)
+	}
+	switch {
+	case len(pkgs) == 0:
+		return nil, fmt.Errorf("no package matches %s", pattern)
+	case len(pkgs) > 1:
+		return nil, fmt.Errorf("%s matches %d packages, name a single one", pattern, len(pkgs))
+	}
+	pkg := pkgs[0]
+	if len(pkg.GoFiles) == 0 {
+		if len(pkg.Errors) > 0 {
+			return nil, fmt.Errorf("loading package: %v", pkg.Errors[0])
+		}
+		return nil, fmt.Errorf("package %s has no Go files", pattern)
+	}
+	return &Package{Name: pkg.Name, Path: pkg.PkgPath, Dir: filepath.Dir(pkg.GoFiles[0]), Files: pkg.GoFiles}, nil
+}
@@ -0,0 +1,149 @@
+package source
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// impurePackages are the imports whose use makes a function depend on or
+// change the world outside its arguments
+var impurePackages = map[string]bool{
+	"bufio": true, "crypto/rand": true, "database/sql": true, "io": true,
+	"io/ioutil": true, "log": true, "log/slog": true, "math/rand": true,
+	"math/rand/v2": true, "net": true, "net/http": true, "os": true,
+	"os/exec": true, "os/signal": true, "sync": true, "sync/atomic": true,
+	"syscall": true, "time": true, "unsafe": true,
+}
+
+// basicTypes are the types rapid draws values of without help
+var basicTypes = map[string]bool{
+	"bool": true, "byte": true, "rune": true, "string": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true,
+}
+
+// PureFuncs returns the functions among funcs that look pure enough for
+// property-based tests: plain functions that take and return only basic
+// types and slices, arrays and maps of them, and whose bodies use no package
+// level variables, goroutines, channels, printing or packages such as os,
+// time and math/rand
+func (f *File) PureFuncs(funcs []Func) []Func {
+	impure := make(map[string]bool)
+	for _, imp := range f.AST.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || !impurePackages[path] {
+			continue
+		}
+		if name := f.importName(path); name != "" {
+			impure[name] = true
+		}
+	}
+	if name := f.importName("fmt"); name != "" {
+		// only fmt's printing functions have side effects
+		impure[name+".Print"] = true
+	}
+
+	var found []Func
+	for _, fn := range funcs {
+		ft := fn.Decl.Type
+		if fn.Receiver != "" || fn.Decl.Body == nil || ft.TypeParams != nil || ft.Results == nil ||
+			!generatable(ft.Params) || !generatable(ft.Results) || len(ft.Params.List) == 0 {
+			continue
+		}
+		if f.isPure(fn.Decl, impure) {
+			found = append(found, fn)
+		}
+	}
+	return found
+}
+
+// generatable reports whether all fields have basic types, or slices, arrays
+// and maps of them
+func generatable(fields *ast.FieldList) bool {
+	for _, field := range fields.List {
+		if !isGeneratable(field.Type) {
+			return false
+		}
+	}
+	return true
+}
+
+func isGeneratable(expr ast.Expr) bool {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return basicTypes[t.Name] || t.Name == "error"
+	case *ast.ArrayType:
+		return isGeneratable(t.Elt)
+	case *ast.MapType:
+		return isGeneratable(t.Key) && isGeneratable(t.Value)
+	}
+	return false
+}
+
+// isPure reports whether the body of decl avoids side effects: impure holds
+// the names of the impure packages it imports, and "fmt.Print" when fmt is
+// imported
+func (f *File) isPure(decl *ast.FuncDecl, impure map[string]bool) bool {
+	// names declared in the function shadow package level variables
+	local := make(map[string]bool)
+	for _, list := range []*ast.FieldList{decl.Type.Params, decl.Type.Results} {
+		for _, field := range list.List {
+			for _, name := range field.Names {
+				local[name.Name] = true
+			}
+		}
+	}
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if x.Tok == token.DEFINE {
+				for _, lhs := range x.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok {
+						local[id.Name] = true
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			for _, name := range x.Names {
+				local[name.Name] = true
+			}
+		case *ast.FuncLit:
+			for _, field := range x.Type.Params.List {
+				for _, name := range field.Names {
+					local[name.Name] = true
+				}
+			}
+		}
+		return true
+	})
+
+	pure := true
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.GoStmt, *ast.SelectStmt, *ast.SendStmt, *ast.ChanType, *ast.DeferStmt:
+			pure = false
+		case *ast.UnaryExpr:
+			if x.Op == token.ARROW {
+				pure = false
+			}
+		case *ast.SelectorExpr:
+			if id, ok := x.X.(*ast.Ident); ok && !local[id.Name] {
+				if impure[id.Name] || (impure[id.Name+".Print"] && strings.HasPrefix(x.Sel.Name, "Print")) {
+					pure = false
+				}
+			}
+		case *ast.Ident:
+			if local[x.Name] {
+				break
+			}
+			if gen, ok := f.decls[x.Name].(*ast.GenDecl); ok && gen.Tok == token.VAR {
+				pure = false
+			}
+		}
+		return pure
+	})
+	return pure
+}
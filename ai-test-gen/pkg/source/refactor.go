@@ -0,0 +1,56 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// ReplaceDecls returns the file source with the top-level declarations named
+// by keys (Name, or Type.Method for methods) replaced by code, which holds Go
+// declarations without a package clause. code takes the place of the first of
+// them and the others are removed; without keys it is appended to the file.
+func (f *File) ReplaceDecls(keys []string, code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if _, err := parser.ParseFile(token.NewFileSet(), "", "package p\n\n"+code, parser.SkipObjectResolution); err != nil {
+		return "", fmt.Errorf("replacement does not parse: %w", err)
+	}
+
+	seen := make(map[ast.Decl]bool)
+	var decls []ast.Decl
+	for _, key := range keys {
+		decl, ok := f.decls[key]
+		if !ok {
+			return "", fmt.Errorf("no declaration %s", key)
+		}
+		if !seen[decl] {
+			seen[decl] = true
+			decls = append(decls, decl)
+		}
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].Pos() < decls[j].Pos() })
+
+	src := string(f.Src)
+	if len(decls) == 0 {
+		src = strings.TrimRight(src, "\n") + "\n\n" + code + "\n"
+	}
+	// from the end, so the offsets of the earlier declarations hold
+	for i := len(decls) - 1; i >= 0; i-- {
+		start, end := f.declRange(decls[i])
+		replacement := ""
+		if i == 0 {
+			replacement = code
+		}
+		src = src[:start] + replacement + src[end:]
+	}
+
+	out, err := format.Source([]byte(src))
+	if err != nil {
+		return "", fmt.Errorf("refactored file does not format: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,223 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// skeletonField is a field of the case struct of a skeleton test
+type skeletonField struct {
+	name string
+	typ  string
+}
+
+// Skeleton returns a table-driven test for each of funcs, built from their
+// signatures alone: a case struct holding the arguments and the expected
+// results, a table left empty with a TODO, and the call with its checks.
+// Generic functions are instantiated with the first instantiation found for
+// them in generics and left out when there is none, as are init and main.
+func (f *File) Skeleton(funcs []Func, generics []Generic) (string, error) {
+	instantiated := make(map[string]Generic, len(generics))
+	for _, g := range generics {
+		instantiated[g.Func.Key()] = g
+	}
+
+	used := make(map[string]bool)
+	var tests []string
+	for _, fn := range funcs {
+		if fn.Receiver == "" && (fn.Name == "init" || fn.Name == "main") {
+			continue
+		}
+		var typeArgs map[string]string
+		if g, ok := instantiated[fn.Key()]; ok {
+			if len(g.Instantiations) == 0 {
+				continue
+			}
+			typeArgs = make(map[string]string, len(g.TypeParams))
+			for i, p := range g.TypeParams {
+				typeArgs[p.Name] = g.Instantiations[0][i]
+			}
+		} else if fn.Decl.Type.TypeParams != nil {
+			continue
+		}
+		tests = append(tests, skeletonTest(fn, typeArgs, used))
+	}
+	if len(tests) == 0 {
+		return "", fmt.Errorf("no functions to scaffold tests for")
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "package %s\n\nimport (\n", f.Package())
+	var imports []string
+	if used["reflect"] {
+		imports = append(imports, `"reflect"`)
+	}
+	imports = append(imports, `"testing"`)
+	for _, imp := range f.AST.Imports {
+		name := importNameOf(imp)
+		if name == "_" || name == "." || !used[name] {
+			continue
+		}
+		line := imp.Path.Value
+		if imp.Name != nil {
+			line = imp.Name.Name + " " + line
+		}
+		imports = append(imports, line)
+	}
+	for _, line := range imports {
+		sb.WriteString("\t" + line + "\n")
+	}
+	sb.WriteString(")\n")
+	for _, test := range tests {
+		sb.WriteString("\n" + test)
+	}
+
+	out, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return "", fmt.Errorf("skeleton does not format: %w", err)
+	}
+	return string(out), nil
+}
+
+// skeletonTest returns the test of fn, with its type parameters replaced by
+// typeArgs. The packages and reflect it refers to are added to used.
+func skeletonTest(fn Func, typeArgs map[string]string, used map[string]bool) string {
+	typeOf := func(expr ast.Expr) string {
+		ast.Inspect(expr, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if pkg, ok := sel.X.(*ast.Ident); ok {
+					used[pkg.Name] = true
+				}
+			}
+			return true
+		})
+		s := types.ExprString(expr)
+		for name, arg := range typeArgs {
+			s = replaceIdent(s, name, arg)
+		}
+		return s
+	}
+
+	taken := map[string]bool{"name": true}
+	field := func(name string) string {
+		for taken[name] {
+			name += "Arg"
+		}
+		taken[name] = true
+		return name
+	}
+
+	var fields []skeletonField
+	callee := fn.Name
+	display := fn.Name
+	if fn.Receiver != "" {
+		recv := field("recv")
+		fields = append(fields, skeletonField{recv, typeOf(fn.Decl.Recv.List[0].Type)})
+		callee = "tt." + recv + "." + fn.Name
+		display = fn.Receiver + "." + fn.Name
+	} else if fn.Decl.Type.TypeParams != nil {
+		var args []string
+		for _, p := range typeParams(fn.Decl.Type.TypeParams) {
+			args = append(args, typeArgs[p.Name])
+		}
+		callee += "[" + strings.Join(args, ", ") + "]"
+	}
+
+	var args []string
+	i := 0
+	for _, param := range fn.Decl.Type.Params.List {
+		typ := param.Type
+		variadic := false
+		if ell, ok := typ.(*ast.Ellipsis); ok {
+			typ, variadic = ell.Elt, true
+		}
+		names := param.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{nil}
+		}
+		for _, ident := range names {
+			name := "arg" + strconv.Itoa(i)
+			if ident != nil && ident.Name != "_" {
+				name = ident.Name
+			}
+			i++
+			name = field(name)
+			t := typeOf(typ)
+			arg := "tt." + name
+			if variadic {
+				t, arg = "[]"+t, arg+"..."
+			}
+			fields = append(fields, skeletonField{name, t})
+			args = append(args, arg)
+		}
+	}
+
+	// results, with a trailing error checked through wantErr
+	var results []ast.Expr
+	if fn.Decl.Type.Results != nil {
+		for _, r := range fn.Decl.Type.Results.List {
+			for range max(len(r.Names), 1) {
+				results = append(results, r.Type)
+			}
+		}
+	}
+	returnsErr := len(results) > 0 && types.ExprString(results[len(results)-1]) == "error"
+	if returnsErr {
+		results = results[:len(results)-1]
+	}
+	var gots, checks []string
+	for i, r := range results {
+		suffix := ""
+		if i > 0 {
+			suffix = strconv.Itoa(i)
+		}
+		if _, ok := r.(*ast.FuncType); ok {
+			// functions can't be compared
+			gots = append(gots, "_")
+			continue
+		}
+		want := field("want" + suffix)
+		fields = append(fields, skeletonField{want, typeOf(r)})
+		gots = append(gots, "got"+suffix)
+		used["reflect"] = true
+		label := display + "()"
+		if i > 0 {
+			label += " got" + suffix
+		}
+		checks = append(checks, fmt.Sprintf("if !reflect.DeepEqual(got%s, tt.%s) {\n\tt.Errorf(\"%s = %%v, want %%v\", got%s, tt.%s)\n}\n", suffix, want, label, suffix, want))
+	}
+	if returnsErr {
+		wantErr := field("wantErr")
+		fields = append(fields, skeletonField{wantErr, "bool"})
+		gots = append(gots, "err")
+		checks = slices.Insert(checks, 0, fmt.Sprintf("if (err != nil) != tt.%s {\n\tt.Fatalf(\"%s() error = %%v, wantErr %%v\", err, tt.%s)\n}\n", wantErr, display, wantErr))
+	}
+
+	call := callee + "(" + strings.Join(args, ", ") + ")"
+	if slices.ContainsFunc(gots, func(g string) bool { return g != "_" }) {
+		call = strings.Join(gots, ", ") + " := " + call
+	}
+
+	name := "Test" + fn.Name
+	if fn.Receiver != "" {
+		name = "Test" + fn.Receiver + "_" + fn.Name
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "func %s(t *testing.T) {\n\ttests := []struct {\n\t\tname string\n", name)
+	for _, f := range fields {
+		fmt.Fprintf(&sb, "\t\t%s %s\n", f.name, f.typ)
+	}
+	sb.WriteString("\t}{\n\t\t// TODO: add test cases.\n\t}\n")
+	sb.WriteString("\tfor _, tt := range tests {\n\t\tt.Run(tt.name, func(t *testing.T) {\n")
+	sb.WriteString("\t\t\t" + call + "\n")
+	for _, check := range checks {
+		sb.WriteString(check)
+	}
+	sb.WriteString("\t\t})\n\t}\n}\n")
+	return sb.String()
+}
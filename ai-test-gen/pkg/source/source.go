@@ -0,0 +1,263 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// File is a parsed Go source file whose declarations can be extracted as text
+type File struct {
+	Fset *token.FileSet
+	AST  *ast.File
+	Src  []byte
+
+	// decls maps each top-level name to the declaration that defines it.
+	// Methods are keyed as Type.Method.
+	decls map[string]ast.Decl
+}
+
+// Func describes a top-level function or method
+type Func struct {
+	Name     string
+	Receiver string
+	Exported bool
+	Decl     *ast.FuncDecl
+}
+
+// Key returns the name used to look the function up, Type.Method for methods
+func (f Func) Key() string {
+	if f.Receiver != "" {
+		return f.Receiver + "." + f.Name
+	}
+	return f.Name
+}
+
+// Parse parses Go source code
+func Parse(filename string, src []byte) (*File, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &File{Fset: fset, AST: f, Src: src, decls: make(map[string]ast.Decl)}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			file.decls[funcKey(d)] = d
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					file.decls[s.Name.Name] = d
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						file.decls[n.Name] = d
+					}
+				}
+			}
+		}
+	}
+	return file, nil
+}
+
+// FuncAt returns the function or method whose declaration, doc comment
+// aside, holds the byte offset of the source
+func (f *File) FuncAt(offset int) (Func, bool) {
+	pos := f.posOf(offset)
+	for _, fn := range f.Funcs() {
+		if fn.Decl.Pos() <= pos && pos <= fn.Decl.End() {
+			return fn, true
+		}
+	}
+	return Func{}, false
+}
+
+// posOf returns the position of the byte offset of the source, clamped to it
+func (f *File) posOf(offset int) token.Pos {
+	tf := f.Fset.File(f.AST.Pos())
+	return tf.Pos(max(0, min(offset, tf.Size())))
+}
+
+// Package returns the package name
+func (f *File) Package() string {
+	return f.AST.Name.Name
+}
+
+// Funcs returns the functions and methods declared in the file, in source order
+func (f *File) Funcs() []Func {
+	var funcs []Func
+	for _, decl := range f.AST.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		funcs = append(funcs, Func{
+			Name:     fn.Name.Name,
+			Receiver: ReceiverType(fn),
+			Exported: fn.Name.IsExported(),
+			Decl:     fn,
+		})
+	}
+	return funcs
+}
+
+// ExportedNames returns the exported top-level names the file declares,
+// excluding methods, in sorted order
+func (f *File) ExportedNames() []string {
+	var names []string
+	for name := range f.decls {
+		if !strings.Contains(name, ".") && token.IsExported(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Header returns the package clause and imports of the file
+func (f *File) Header() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "package %s\n", f.Package())
+	for _, decl := range f.AST.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			sb.WriteString("\n")
+			sb.WriteString(f.Text(gd))
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// Text returns the source of a declaration, including its doc comment
+func (f *File) Text(decl ast.Decl) string {
+	start, end := f.declRange(decl)
+	return string(f.Src[start:end])
+}
+
+// declRange returns the offsets of decl in the source, doc comment included
+func (f *File) declRange(decl ast.Decl) (int, int) {
+	start := decl.Pos()
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+	}
+	return f.Fset.Position(start).Offset, f.Fset.Position(decl.End()).Offset
+}
+
+// Context returns a self-contained snippet for the named function: the file
+// header, the function itself, and every type, constant, variable and helper
+// in the file that it references (transitively).
+func (f *File) Context(key string) (string, error) {
+	root, ok := f.decls[key]
+	if !ok {
+		return "", fmt.Errorf("function %s not found", key)
+	}
+	included := map[ast.Decl]bool{root: true}
+	f.addDeps(included, root)
+	return f.snippet(included), nil
+}
+
+// addDeps adds to included every declaration of the file that decl
+// references, transitively
+func (f *File) addDeps(included map[ast.Decl]bool, decl ast.Decl) {
+	queue := []ast.Decl{decl}
+	for len(queue) > 0 {
+		decl := queue[0]
+		queue = queue[1:]
+		for _, name := range referencedNames(decl) {
+			dep, ok := f.decls[name]
+			if !ok || included[dep] {
+				continue
+			}
+			included[dep] = true
+			queue = append(queue, dep)
+		}
+	}
+}
+
+// snippet returns the file header followed by the included declarations
+func (f *File) snippet(included map[ast.Decl]bool) string {
+	// keep the original source order so the snippet reads naturally
+	ordered := make([]ast.Decl, 0, len(included))
+	for decl := range included {
+		ordered = append(ordered, decl)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Pos() < ordered[j].Pos() })
+
+	var sb strings.Builder
+	sb.WriteString(f.Header())
+	for _, decl := range ordered {
+		sb.WriteString("\n")
+		sb.WriteString(f.Text(decl))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// referencedNames lists the identifiers used by decl, plus Type.Method keys for
+// methods of the receiver type it refers to
+func referencedNames(decl ast.Decl) []string {
+	var names []string
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			names = append(names, ident.Name)
+		}
+		return true
+	})
+	if fn, ok := decl.(*ast.FuncDecl); ok {
+		if recv := ReceiverType(fn); recv != "" {
+			names = append(names, recv)
+		}
+	}
+	return names
+}
+
+func funcKey(fn *ast.FuncDecl) string {
+	if recv := ReceiverType(fn); recv != "" {
+		return recv + "." + fn.Name.Name
+	}
+	return fn.Name.Name
+}
+
+// ReceiverType returns the base type name of a method receiver, or "" for functions
+func ReceiverType(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+	typ := fn.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	switch t := typ.(type) {
+	case *ast.IndexExpr:
+		typ = t.X
+	case *ast.IndexListExpr:
+		typ = t.X
+	}
+	if ident, ok := typ.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// KeepLineEndings returns updated, a rewrite of original, with Windows line
+// endings when original has them. Go's formatter and the models write \n
+// only, which would otherwise change every line of files checked out with
+// \r\n.
+func KeepLineEndings(original, updated string) string {
+	if !strings.Contains(original, "\r\n") {
+		return updated
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(updated, "\r\n", "\n"), "\n", "\r\n")
+}
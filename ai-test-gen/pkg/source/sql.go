@@ -0,0 +1,63 @@
+package source
+
+import (
+	"go/ast"
+)
+
+// sqlTypes are the database/sql types whose use marks a function as talking
+// to a database
+var sqlTypes = map[string]bool{"DB": true, "Tx": true, "Conn": true}
+
+// SQLFuncs returns the functions among funcs that use a database through
+// database/sql: those taking a *sql.DB, *sql.Tx or *sql.Conn, and methods of
+// types of the file holding one
+func (f *File) SQLFuncs(funcs []Func) []Func {
+	sql := f.importName("database/sql")
+	if sql == "" {
+		return nil
+	}
+	var found []Func
+	for _, fn := range funcs {
+		if usesSQL(fn.Decl.Type.Params, sql) {
+			found = append(found, fn)
+			continue
+		}
+		if fn.Receiver == "" {
+			continue
+		}
+		gen, ok := f.decls[fn.Receiver].(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.Name == fn.Receiver {
+				if st, ok := ts.Type.(*ast.StructType); ok && usesSQL(st.Fields, sql) {
+					found = append(found, fn)
+				}
+			}
+		}
+	}
+	return found
+}
+
+// usesSQL reports whether one of fields has a database/sql connection type,
+// sql being the name database/sql is imported as
+func usesSQL(fields *ast.FieldList, sql string) bool {
+	if fields == nil {
+		return false
+	}
+	for _, field := range fields.List {
+		star, ok := field.Type.(*ast.StarExpr)
+		if !ok {
+			continue
+		}
+		sel, ok := star.X.(*ast.SelectorExpr)
+		if !ok || !sqlTypes[sel.Sel.Name] {
+			continue
+		}
+		if id, ok := sel.X.(*ast.Ident); ok && id.Name == sql {
+			return true
+		}
+	}
+	return false
+}
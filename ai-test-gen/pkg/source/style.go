@@ -0,0 +1,96 @@
+package source
+
+import (
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// StyleExample is an existing test file shown to the model as an example of
+// how the project writes its tests
+type StyleExample struct {
+	// Path is the file's path as found
+	Path string
+	// SamePackage is set for test files of the package under test, whose
+	// helpers the new tests can use
+	SamePackage bool
+	// Code is the file, trimmed to its first tests when it is long
+	Code string
+}
+
+// StyleExamples picks up to n of the test files as examples for tests of the
+// package in dir: those of dir first, then those of the nearest directories.
+// exclude, the file the tests will replace, is left out. Each is trimmed to
+// its header and as many whole declarations as fit in maxBytes; files without
+// tests, or whose first declaration doesn't fit, are passed over.
+func StyleExamples(files []string, dir, exclude string, n, maxBytes int) []StyleExample {
+	dir = filepath.Clean(dir)
+	candidates := make([]string, 0, len(files))
+	for _, f := range files {
+		// leftovers of interrupted generate runs
+		if filepath.Clean(f) != filepath.Clean(exclude) && !strings.Contains(filepath.Base(f), "_aitestgen_") {
+			candidates = append(candidates, f)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		di, dj := dirDistance(dir, filepath.Dir(candidates[i])), dirDistance(dir, filepath.Dir(candidates[j]))
+		if di != dj {
+			return di < dj
+		}
+		return candidates[i] < candidates[j]
+	})
+
+	var examples []StyleExample
+	for _, path := range candidates {
+		if len(examples) == n {
+			break
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		f, err := Parse(path, src)
+		if err != nil || len(f.TestNames()) == 0 {
+			continue
+		}
+		if code, ok := f.trim(maxBytes); ok {
+			examples = append(examples, StyleExample{Path: path, SamePackage: filepath.Clean(filepath.Dir(path)) == dir, Code: code})
+		}
+	}
+	return examples
+}
+
+// trim returns the header of the file and as many of its declarations, in
+// order, as fit in maxBytes, reporting false when not even one does
+func (f *File) trim(maxBytes int) (string, bool) {
+	var sb strings.Builder
+	sb.WriteString(f.Header())
+	decls := 0
+	for _, decl := range f.AST.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			continue
+		}
+		text := "\n" + f.Text(decl) + "\n"
+		if sb.Len()+len(text) > maxBytes {
+			break
+		}
+		sb.WriteString(text)
+		decls++
+	}
+	return sb.String(), decls > 0
+}
+
+// dirDistance counts the directories between a and b through their closest
+// common parent
+func dirDistance(a, b string) int {
+	as := strings.Split(filepath.ToSlash(a), "/")
+	bs := strings.Split(filepath.ToSlash(b), "/")
+	common := 0
+	for common < len(as) && common < len(bs) && as[common] == bs[common] {
+		common++
+	}
+	return len(as) - common + len(bs) - common
+}
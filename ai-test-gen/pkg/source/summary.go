@@ -0,0 +1,280 @@
+package source
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// summaryPackage collects the public surface of one package for ModuleSummary
+type summaryPackage struct {
+	dir   string
+	name  string
+	doc   string
+	decls []string
+}
+
+// ModuleSummary describes the module at root for a model writing about the
+// whole project: each package of files with its doc comment and exported API
+// (signatures only), the main packages, and the CLI commands declared with
+// cobra. Files that fail to parse are skipped.
+func ModuleSummary(root string, files []string) (string, error) {
+	var sb strings.Builder
+	if mod, err := FindModule(root); err == nil {
+		fmt.Fprintf(&sb, "Module: %s\n", mod.Path)
+	}
+
+	packages := make(map[string]*summaryPackage)
+	commands := make(map[string]*cobraCommand)
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		file, err := Parse(path, src)
+		if err != nil {
+			continue
+		}
+		dir, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			dir = filepath.Dir(path)
+		}
+		dir = filepath.ToSlash(dir)
+		pkg, ok := packages[dir]
+		if !ok {
+			pkg = &summaryPackage{dir: dir, name: file.Package()}
+			packages[dir] = pkg
+		}
+		if file.AST.Doc != nil && pkg.doc == "" {
+			pkg.doc = strings.TrimSpace(file.AST.Doc.Text())
+		}
+		if pkg.name != "main" {
+			pkg.decls = append(pkg.decls, exportedDecls(file)...)
+		}
+		cobraCommands(file, commands)
+	}
+
+	dirs := make([]string, 0, len(packages))
+	for dir := range packages {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	var mains []string
+	for _, dir := range dirs {
+		pkg := packages[dir]
+		if pkg.name == "main" {
+			mains = append(mains, dir)
+			continue
+		}
+		fmt.Fprintf(&sb, "\n## package %s (%s)\n", pkg.name, dir)
+		if pkg.doc != "" {
+			sb.WriteString(pkg.doc + "\n")
+		}
+		if len(pkg.decls) > 0 {
+			sb.WriteString("\n" + strings.Join(pkg.decls, "\n") + "\n")
+		}
+	}
+	if len(mains) > 0 {
+		sb.WriteString("\n## Main packages\n")
+		for _, dir := range mains {
+			fmt.Fprintf(&sb, "- %s\n", dir)
+			if doc := packages[dir].doc; doc != "" {
+				fmt.Fprintf(&sb, "  %s\n", strings.ReplaceAll(doc, "\n", "\n  "))
+			}
+		}
+	}
+	if lines := commandPaths(commands); len(lines) > 0 {
+		sb.WriteString("\n## CLI commands\n")
+		sb.WriteString(strings.Join(lines, "\n") + "\n")
+	}
+	return sb.String(), nil
+}
+
+// exportedDecls returns the exported declarations of file without bodies or
+// comments: functions and methods of exported types as signatures, exported
+// types in full and exported constants and variables by name
+func exportedDecls(file *File) []string {
+	var decls []string
+	for _, decl := range file.AST.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			if recv := ReceiverType(d); recv != "" && !ast.IsExported(recv) {
+				continue
+			}
+			if sig := formatNode(file.Fset, &ast.FuncDecl{Recv: d.Recv, Name: d.Name, Type: d.Type}); sig != "" {
+				decls = append(decls, sig)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if !s.Name.IsExported() {
+						continue
+					}
+					ts := *s
+					ts.Doc, ts.Comment = nil, nil
+					if st, ok := s.Type.(*ast.StructType); ok {
+						ts.Type = exportedFields(st)
+					}
+					if text := formatNode(file.Fset, &ast.GenDecl{Tok: token.TYPE, Specs: []ast.Spec{&ts}}); text != "" {
+						decls = append(decls, text)
+					}
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						if name.IsExported() {
+							decls = append(decls, d.Tok.String()+" "+name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return decls
+}
+
+// exportedFields returns st reduced to its exported and embedded fields,
+// without comments
+func exportedFields(st *ast.StructType) *ast.StructType {
+	fields := &ast.FieldList{}
+	for _, f := range st.Fields.List {
+		field := *f
+		field.Doc, field.Comment, field.Names = nil, nil, nil
+		for _, name := range f.Names {
+			if name.IsExported() {
+				field.Names = append(field.Names, name)
+			}
+		}
+		if len(f.Names) == 0 || len(field.Names) > 0 {
+			fields.List = append(fields.List, &field)
+		}
+	}
+	return &ast.StructType{Fields: fields}
+}
+
+// cobraCommand is a cobra.Command assigned to a package level variable
+type cobraCommand struct {
+	use, short string
+	// parent is the variable of the command it is added to
+	parent string
+}
+
+// cobraCommands records the cobra.Command variables of file in commands, by
+// variable name, and the AddCommand calls linking them
+func cobraCommands(file *File, commands map[string]*cobraCommand) {
+	command := func(name string) *cobraCommand {
+		if commands[name] == nil {
+			commands[name] = &cobraCommand{}
+		}
+		return commands[name]
+	}
+	ast.Inspect(file.AST, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.ValueSpec:
+			for i, value := range x.Values {
+				if unary, ok := value.(*ast.UnaryExpr); ok {
+					value = unary.X
+				}
+				if lit, ok := value.(*ast.CompositeLit); ok && isCobraCommand(lit) && i < len(x.Names) {
+					c := command(x.Names[i].Name)
+					c.use, c.short = commandFields(lit)
+				}
+			}
+		case *ast.CallExpr:
+			sel, ok := x.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "AddCommand" {
+				return true
+			}
+			parent, ok := sel.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			for _, arg := range x.Args {
+				if child, ok := arg.(*ast.Ident); ok {
+					command(child.Name).parent = parent.Name
+				}
+			}
+		}
+		return true
+	})
+}
+
+// commandPaths returns "path: short" for each command, where path is the
+// Use of the command and of its parents, e.g. "aigen cache clear"
+func commandPaths(commands map[string]*cobraCommand) []string {
+	var lines []string
+	for _, c := range commands {
+		if c.use == "" {
+			continue
+		}
+		path := strings.Fields(c.use)[0]
+		seen := map[*cobraCommand]bool{c: true}
+		for p := commands[c.parent]; p != nil && p.use != "" && !seen[p]; p = commands[p.parent] {
+			seen[p] = true
+			path = strings.Fields(p.use)[0] + " " + path
+		}
+		if args := strings.Fields(c.use)[1:]; len(args) > 0 {
+			path += " " + strings.Join(args, " ")
+		}
+		lines = append(lines, fmt.Sprintf("- %s: %s", path, c.short))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// isCobraCommand reports whether lit is a cobra.Command literal
+func isCobraCommand(lit *ast.CompositeLit) bool {
+	sel, ok := lit.Type.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Command" {
+		return false
+	}
+	id, ok := sel.X.(*ast.Ident)
+	return ok && id.Name == "cobra"
+}
+
+// commandFields returns the Use and Short string fields of a command literal
+func commandFields(lit *ast.CompositeLit) (use, short string) {
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		value, ok := kv.Value.(*ast.BasicLit)
+		if !ok || value.Kind != token.STRING {
+			continue
+		}
+		text, _ := strconv.Unquote(value.Value)
+		switch key.Name {
+		case "Use":
+			use = text
+		case "Short":
+			short = text
+		}
+	}
+	return use, short
+}
+
+func formatNode(fset *token.FileSet, node ast.Node) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
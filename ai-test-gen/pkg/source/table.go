@@ -0,0 +1,161 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"sort"
+	"strings"
+)
+
+// Table is the case table of a table-driven test: a slice or map literal of
+// cases that the test ranges over
+type Table struct {
+	// Test is the name of the TestXxx function holding the table
+	Test string
+	// Var is the variable the table is assigned to, "" when the literal is
+	// ranged over directly
+	Var string
+	// Cases is the number of rows in the table
+	Cases int
+
+	decl *ast.FuncDecl
+	lit  *ast.CompositeLit
+}
+
+// Tables returns the case tables of the table-driven tests in the file, in
+// source order
+func (f *File) Tables() []Table {
+	var tables []Table
+	for _, decl := range f.AST.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Body == nil || !strings.HasPrefix(fn.Name.Name, "Test") {
+			continue
+		}
+
+		// literals assigned to variables, by variable name
+		literals := make(map[string]*ast.CompositeLit)
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch s := n.(type) {
+			case *ast.AssignStmt:
+				for i, rhs := range s.Rhs {
+					if lit, ok := rhs.(*ast.CompositeLit); ok && isCaseTable(lit) && i < len(s.Lhs) {
+						if ident, ok := s.Lhs[i].(*ast.Ident); ok {
+							literals[ident.Name] = lit
+						}
+					}
+				}
+			case *ast.ValueSpec:
+				for i, v := range s.Values {
+					if lit, ok := v.(*ast.CompositeLit); ok && isCaseTable(lit) && i < len(s.Names) {
+						literals[s.Names[i].Name] = lit
+					}
+				}
+			}
+			return true
+		})
+
+		seen := make(map[*ast.CompositeLit]bool)
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			rng, ok := n.(*ast.RangeStmt)
+			if !ok {
+				return true
+			}
+			table := Table{Test: fn.Name.Name, decl: fn}
+			switch x := rng.X.(type) {
+			case *ast.Ident:
+				table.Var, table.lit = x.Name, literals[x.Name]
+			case *ast.CompositeLit:
+				if isCaseTable(x) {
+					table.lit = x
+				}
+			}
+			if table.lit == nil || seen[table.lit] {
+				return true
+			}
+			seen[table.lit] = true
+			table.Cases = len(table.lit.Elts)
+			tables = append(tables, table)
+			return true
+		})
+	}
+	return tables
+}
+
+// isCaseTable reports whether lit is a slice or map literal of structs
+func isCaseTable(lit *ast.CompositeLit) bool {
+	var elem ast.Expr
+	switch t := lit.Type.(type) {
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return false
+		}
+		elem = t.Elt
+	case *ast.MapType:
+		elem = t.Value
+	default:
+		return false
+	}
+	if star, ok := elem.(*ast.StarExpr); ok {
+		elem = star.X
+	}
+	switch elem.(type) {
+	case *ast.StructType, *ast.Ident, *ast.SelectorExpr:
+		return true
+	}
+	return false
+}
+
+// TestText returns the source of the test function holding table
+func (f *File) TestText(table Table) string {
+	return f.Text(table.decl)
+}
+
+// AppendCases adds rows to case tables of the file and returns the formatted
+// source. rows maps an index into Tables to the elements to add, written as
+// they would appear inside the literal's braces.
+func (f *File) AppendCases(rows map[int]string) (string, error) {
+	tables := f.Tables()
+	indexes := make([]int, 0, len(rows))
+	elems := make(map[int]string, len(rows))
+	for i, r := range rows {
+		if i < 0 || i >= len(tables) {
+			return "", fmt.Errorf("no table %d", i)
+		}
+		r = strings.TrimSpace(r)
+		if !strings.HasSuffix(r, ",") {
+			r += ","
+		}
+		if _, err := parser.ParseExpr("[]T{\n" + r + "\n}"); err != nil {
+			return "", fmt.Errorf("cases for %s: %w", tables[i].Test, err)
+		}
+		elems[i] = r
+		indexes = append(indexes, i)
+	}
+	// insert from the end so earlier offsets stay valid
+	sort.Slice(indexes, func(a, b int) bool { return tables[indexes[a]].lit.Rbrace > tables[indexes[b]].lit.Rbrace })
+
+	src := string(f.Src)
+	for _, i := range indexes {
+		lit := tables[i].lit
+		at := f.Fset.Position(lit.Rbrace).Offset
+		insert := "\n" + elems[i] + "\n"
+		if n := len(lit.Elts); n > 0 {
+			last := f.Fset.Position(lit.Elts[n-1].End()).Offset
+			if !strings.Contains(src[last:at], ",") {
+				insert = "," + insert
+			} else if nl := strings.LastIndex(src[:at], "\n"); nl >= last && strings.TrimSpace(src[nl:at]) == "" {
+				// add the rows on their own lines above the closing brace
+				at, insert = nl+1, elems[i]+"\n"
+			}
+		}
+		src = src[:at] + insert + src[at:]
+	}
+
+	out, err := format.Source([]byte(src))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
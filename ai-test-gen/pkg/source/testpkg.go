@@ -0,0 +1,184 @@
+package source
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strconv"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// TestPackage describes the package generated tests belong to
+type TestPackage struct {
+	// Name is the package name of the code under test
+	Name string
+	// ImportPath is its import path, "" when unknown
+	ImportPath string
+	// Module is the module it belongs to, nil outside a module
+	Module *Module
+	// External selects the Name_test package
+	External bool
+	// Exported lists exported identifiers of the package that external
+	// tests may have used unqualified
+	Exported []string
+}
+
+// FixTestPackage corrects the package clause and imports of a generated test
+// file for pkg. Imports of packages of the module under a wrong path are
+// pointed at the right one. An internal test's import of its own package is
+// removed along with the qualifiers using it, while in an external test bare
+// uses of pkg.Exported are qualified and the package import is added.
+func FixTestPackage(tests string, pkg TestPackage) (string, error) {
+	f, err := parseResolved(tests)
+	if err != nil {
+		return "", err
+	}
+	file := f.AST
+
+	file.Name.Name = pkg.Name
+	if pkg.External {
+		file.Name.Name = pkg.Name + "_test"
+	}
+
+	if pkg.Module != nil {
+		for _, imp := range file.Imports {
+			p, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				continue
+			}
+			if fixed := pkg.Module.resolveImport(p); fixed != p {
+				imp.Path.Value = strconv.Quote(fixed)
+			}
+		}
+	}
+
+	switch {
+	case pkg.ImportPath == "":
+	case pkg.External:
+		qualify(file, pkg.Name, pkg.Exported)
+		if usesName(file, pkg.Name) && !hasImport(file, pkg.ImportPath) {
+			astutil.AddImport(f.Fset, file, pkg.ImportPath)
+		}
+	default:
+		for _, imp := range file.Imports {
+			if p, _ := strconv.Unquote(imp.Path.Value); p != pkg.ImportPath {
+				continue
+			}
+			name := pkg.Name
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			unqualify(file, name)
+			astutil.DeleteNamedImport(f.Fset, file, importNameOf(imp), pkg.ImportPath)
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, f.Fset, file); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// parseResolved parses src with object resolution, which qualify relies on
+// to find the identifiers the file doesn't declare
+func parseResolved(src string) (*File, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "tests.go", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Fset: fset, AST: f, Src: []byte(src)}, nil
+}
+
+// qualify prefixes the file's unresolved uses of the names with the package
+// name, turning Foo into pkg.Foo
+func qualify(file *ast.File, pkg string, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, n := range names {
+		wanted[n] = true
+	}
+	unresolved := make(map[*ast.Ident]bool)
+	for _, ident := range file.Unresolved {
+		if wanted[ident.Name] {
+			unresolved[ident] = true
+		}
+	}
+	if len(unresolved) == 0 {
+		return
+	}
+
+	astutil.Apply(file, func(c *astutil.Cursor) bool {
+		// field names in composite literals are not package identifiers
+		if kv, ok := c.Node().(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok {
+				delete(unresolved, key)
+			}
+		}
+		return true
+	}, func(c *astutil.Cursor) bool {
+		ident, ok := c.Node().(*ast.Ident)
+		if !ok || !unresolved[ident] {
+			return true
+		}
+		if sel, ok := c.Parent().(*ast.SelectorExpr); ok && sel.Sel == ident {
+			return true
+		}
+		c.Replace(&ast.SelectorExpr{X: ast.NewIdent(pkg), Sel: ast.NewIdent(ident.Name)})
+		return true
+	})
+}
+
+// hasImport reports whether file imports path
+func hasImport(file *ast.File, path string) bool {
+	for _, imp := range file.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// importNameOf returns the explicit name of an import, or ""
+func importNameOf(imp *ast.ImportSpec) string {
+	if imp.Name == nil {
+		return ""
+	}
+	return imp.Name.Name
+}
+
+// usesName reports whether file qualifies a selector with name, as in name.Foo
+func usesName(file *ast.File, name string) bool {
+	found := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == name {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// unqualify replaces selectors qualified with the package name, like name.Foo,
+// by the bare identifier
+func unqualify(file *ast.File, name string) {
+	astutil.Apply(file, nil, func(c *astutil.Cursor) bool {
+		sel, ok := c.Node().(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok && x.Name == name {
+			c.Replace(sel.Sel)
+		}
+		return true
+	})
+}
@@ -0,0 +1,123 @@
+// Package state records the progress of folder runs so an interrupted run
+// can be resumed without processing the finished files again.
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// FileName is the manifest written to the root of a folder run
+const FileName = ".aitestgen-state.json"
+
+// Entry is the outcome of one file
+type Entry struct {
+	Status string `json:"status"`
+	// Hash is the SHA-256 of the input file when it was processed; a file
+	// edited since is processed again
+	Hash    string    `json:"hash"`
+	Error   string    `json:"error,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// Manifest is the saved progress of a run of one command
+type Manifest struct {
+	Command string           `json:"command"`
+	Files   map[string]Entry `json:"files"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// New returns an empty manifest for command, saved in dir
+func New(dir, command string) *Manifest {
+	return &Manifest{Command: command, Files: make(map[string]Entry), path: filepath.Join(dir, FileName)}
+}
+
+// Load reads the manifest saved in dir. A missing manifest, or one left by a
+// different command, yields an empty one.
+func Load(dir, command string) (*Manifest, error) {
+	m := New(dir, command)
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved Manifest
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("%s: %w", m.path, err)
+	}
+	if saved.Command == command && saved.Files != nil {
+		m.Files = saved.Files
+	}
+	return m, nil
+}
+
+// Path returns the file the manifest is saved to
+func (m *Manifest) Path() string {
+	return m.path
+}
+
+// Pending returns the files that have no finished entry with one of the done
+// statuses, or whose content changed since
+func (m *Manifest) Pending(files []string, done ...string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var pending []string
+	for _, file := range files {
+		entry, ok := m.Files[file]
+		if !ok || !slices.Contains(done, entry.Status) || entry.Hash != hashFile(file) {
+			pending = append(pending, file)
+		}
+	}
+	return pending
+}
+
+// Record saves the outcome of file, which is hashed as it is now
+func (m *Manifest) Record(file, status, errMsg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Files[file] = Entry{Status: status, Hash: hashFile(file), Error: errMsg, Updated: time.Now().UTC()}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write a temporary file first so a crash never leaves a truncated manifest
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// Remove deletes the saved manifest
+func (m *Manifest) Remove() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := os.Remove(m.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func hashFile(file string) string {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
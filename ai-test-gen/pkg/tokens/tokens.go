@@ -0,0 +1,65 @@
+// Package tokens estimates token counts and the cost of model requests.
+package tokens
+
+import "fmt"
+
+// Estimate roughly estimates the number of tokens in text, assuming about four
+// characters per token. Providers count differently; use it where they don't
+// report usage.
+func Estimate(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// Price is what a model charges in US dollars per million tokens
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// prices holds list prices for the models in the generator registry. Local
+// models are free.
+var prices = map[string]Price{
+	"gemini-2.0-flash":         {Input: 0.10, Output: 0.40},
+	"gemini-2.0-flash-lite":    {Input: 0.075, Output: 0.30},
+	"gemini-1.5-pro":           {Input: 1.25, Output: 5.00},
+	"gemini-1.5-flash":         {Input: 0.075, Output: 0.30},
+	"gemini-2.5-pro":           {Input: 1.25, Output: 10.00},
+	"gemini-2.5-flash":         {Input: 0.30, Output: 2.50},
+	"gpt-4o":                   {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":              {Input: 0.15, Output: 0.60},
+	"gpt-4.1":                  {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini":             {Input: 0.40, Output: 1.60},
+	"claude-3-5-sonnet-latest": {Input: 3.00, Output: 15.00},
+	"claude-3-5-haiku-latest":  {Input: 0.80, Output: 4.00},
+	"claude-3-7-sonnet-latest": {Input: 3.00, Output: 15.00},
+	"llama3":                   {},
+	"codellama":                {},
+	"qwen2.5-coder":            {},
+}
+
+// PriceOf returns the price of a model, reporting false for unknown models
+func PriceOf(model string) (Price, bool) {
+	p, ok := prices[model]
+	return p, ok
+}
+
+// Cost returns the cost in US dollars of the given token counts on model,
+// reporting false if the model's price is unknown
+func Cost(model string, promptTokens, responseTokens int64) (float64, bool) {
+	p, ok := prices[model]
+	if !ok {
+		return 0, false
+	}
+	return (float64(promptTokens)*p.Input + float64(responseTokens)*p.Output) / 1e6, true
+}
+
+// FormatCost formats a dollar amount with enough precision for small requests
+func FormatCost(usd float64) string {
+	switch {
+	case usd > 0 && usd < 0.0001:
+		return fmt.Sprintf("$%.6f", usd)
+	case usd < 0.01:
+		return fmt.Sprintf("$%.4f", usd)
+	}
+	return fmt.Sprintf("$%.2f", usd)
+}
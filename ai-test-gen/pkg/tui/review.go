@@ -0,0 +1,240 @@
+// Package tui implements the interactive terminal screens of the CLI.
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Decision is the reviewer's verdict on a change
+type Decision int
+
+const (
+	// Skip leaves the file untouched and moves on
+	Skip Decision = iota
+	// Accept writes the change
+	Accept
+	// Regenerate asks for the file again with extra instructions
+	Regenerate
+	// Quit skips this and every remaining file
+	Quit
+)
+
+// Change is a generated file awaiting review
+type Change struct {
+	Path string
+	// Diff is the unified diff from the current content of Path
+	Diff string
+	// Index and Total give the 1-based position in the review queue
+	Index int
+	Total int
+}
+
+var (
+	titleStyle   = lipgloss.NewStyle().Bold(true)
+	addStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	delStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	hunkStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+	fileStyle    = lipgloss.NewStyle().Bold(true)
+	helpStyle    = lipgloss.NewStyle().Faint(true)
+	keywordStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("5"))
+	stringStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	commentStyle = lipgloss.NewStyle().Faint(true)
+)
+
+// Review shows the change full screen and waits for the reviewer's decision.
+// For Regenerate it also returns the instructions they typed.
+func Review(c Change) (Decision, string, error) {
+	input := textinput.New()
+	input.Placeholder = "e.g. cover the error paths, use testify"
+	input.Prompt = "Instructions: "
+
+	m, err := tea.NewProgram(&reviewModel{change: c, input: input}, tea.WithAltScreen()).Run()
+	if err != nil {
+		return Skip, "", err
+	}
+	rm := m.(*reviewModel)
+	return rm.decision, rm.instructions, nil
+}
+
+type reviewModel struct {
+	change       Change
+	viewport     viewport.Model
+	input        textinput.Model
+	ready        bool
+	prompting    bool
+	decision     Decision
+	instructions string
+}
+
+func (m *reviewModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// leave room for the title and the help or input line
+		height := msg.Height - 4
+		if !m.ready {
+			m.viewport = viewport.New(msg.Width, height)
+			m.viewport.SetContent(colorDiff(m.change.Diff))
+			m.ready = true
+		} else {
+			m.viewport.Width, m.viewport.Height = msg.Width, height
+		}
+		m.input.Width = msg.Width - len(m.input.Prompt) - 1
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.prompting {
+			switch msg.Type {
+			case tea.KeyEnter:
+				if text := strings.TrimSpace(m.input.Value()); text != "" {
+					m.decision, m.instructions = Regenerate, text
+					return m, tea.Quit
+				}
+				return m, nil
+			case tea.KeyEsc:
+				m.prompting = false
+				m.input.Blur()
+				return m, nil
+			case tea.KeyCtrlC:
+				m.decision = Quit
+				return m, tea.Quit
+			}
+			var cmd tea.Cmd
+			m.input, cmd = m.input.Update(msg)
+			return m, cmd
+		}
+
+		switch msg.String() {
+		case "a", "y", "enter":
+			m.decision = Accept
+			return m, tea.Quit
+		case "s", "n":
+			m.decision = Skip
+			return m, tea.Quit
+		case "r":
+			m.prompting = true
+			return m, m.input.Focus()
+		case "q", "ctrl+c", "esc":
+			m.decision = Quit
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+func (m *reviewModel) View() string {
+	if !m.ready {
+		return "Loading..."
+	}
+	added, removed := diffStat(m.change.Diff)
+	title := titleStyle.Render(fmt.Sprintf("[%d/%d] %s", m.change.Index, m.change.Total, m.change.Path)) +
+		fmt.Sprintf("  %s %s  %3.f%%", addStyle.Render(fmt.Sprintf("+%d", added)), delStyle.Render(fmt.Sprintf("-%d", removed)), m.viewport.ScrollPercent()*100)
+
+	footer := helpStyle.Render("a accept • r regenerate with instructions • s skip • q quit • ↑/↓ scroll")
+	if m.prompting {
+		footer = m.input.View() + "\n" + helpStyle.Render("enter regenerate • esc cancel")
+	}
+	return title + "\n\n" + m.viewport.View() + "\n" + footer
+}
+
+// diffStat counts the added and removed lines of a unified diff
+func diffStat(diff string) (added, removed int) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// colorDiff colors the lines of a unified diff by kind, highlighting the Go
+// code of added and context lines
+func colorDiff(diff string) string {
+	if diff == "" {
+		return helpStyle.Render("No changes.")
+	}
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			lines[i] = fileStyle.Render(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = hunkStyle.Render(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = addStyle.Render("+") + highlightGo(line[1:])
+		case strings.HasPrefix(line, "-"):
+			lines[i] = delStyle.Render(line)
+		case strings.HasPrefix(line, " "):
+			lines[i] = " " + highlightGo(line[1:])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var goKeywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true, "default": true,
+	"defer": true, "else": true, "fallthrough": true, "for": true, "func": true, "go": true,
+	"goto": true, "if": true, "import": true, "interface": true, "map": true, "package": true,
+	"range": true, "return": true, "select": true, "struct": true, "switch": true, "type": true, "var": true,
+}
+
+// highlightGo colors keywords, string literals and line comments in a line of
+// Go code. It works line by line, so it does not track raw strings or block
+// comments spanning lines.
+func highlightGo(line string) string {
+	var sb strings.Builder
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == '/' && strings.HasPrefix(line[i:], "//"):
+			sb.WriteString(commentStyle.Render(line[i:]))
+			return sb.String()
+		case c == '"' || c == '`' || c == '\'':
+			end := i + 1
+			for end < len(line) && line[end] != c {
+				if line[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(line))
+			sb.WriteString(stringStyle.Render(line[i:end]))
+			i = end
+		case isIdentStart(c):
+			end := i + 1
+			for end < len(line) && (isIdentStart(line[end]) || line[end] >= '0' && line[end] <= '9') {
+				end++
+			}
+			word := line[i:end]
+			if goKeywords[word] {
+				word = keywordStyle.Render(word)
+			}
+			sb.WriteString(word)
+			i = end
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return sb.String()
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
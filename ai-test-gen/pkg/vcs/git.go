@@ -0,0 +1,71 @@
+// Package vcs queries the version control state of a working tree.
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// ChangedFiles returns the files under dir that differ from the base ref in
+// the working tree, including staged and untracked files but not deleted
+// ones. Paths are slash separated and relative to dir.
+func ChangedFiles(ctx context.Context, dir, base string) ([]string, error) {
+	changed, err := git(ctx, dir, "diff", "--name-only", "--relative", "--diff-filter=d", base, "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(ctx, dir, "ls-files", "--others", "--exclude-standard", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, f := range append(changed, untracked...) {
+		if !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// FileDiff returns the unified diff of file from the base ref to the working
+// tree, "" when it is unchanged or not tracked
+func FileDiff(ctx context.Context, dir, base, file string) (string, error) {
+	return gitOutput(ctx, dir, "diff", "--no-color", "--no-ext-diff", base, "--", file)
+}
+
+// git runs a git command in dir and returns the non-empty lines it prints
+func git(ctx context.Context, dir string, args ...string) ([]string, error) {
+	out, err := gitOutput(ctx, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// gitOutput runs a git command in dir and returns what it prints
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
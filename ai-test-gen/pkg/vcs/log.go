@@ -0,0 +1,82 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Commit is a commit listed by Log
+type Commit struct {
+	Hash    string
+	Author  string
+	Subject string
+	Body    string
+	// Files are the paths the commit touched, relative to the repository root
+	Files []string
+}
+
+// Log returns the commits reachable from to but not from from, newest first,
+// skipping merges. An empty from lists the whole history of to.
+func Log(ctx context.Context, dir, from, to string) ([]Commit, error) {
+	rng := to
+	if from != "" {
+		rng = from + ".." + to
+	}
+	// records start with \x1e and their fields are separated by \x1f; the
+	// touched files follow the last field
+	out, err := gitOutput(ctx, dir, "log", "--no-merges", "--name-only", "--format=%x1e%H%x1f%an%x1f%s%x1f%b%x1f", rng)
+	if err != nil {
+		return nil, err
+	}
+	var commits []Commit
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.Split(record, "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		c := Commit{Hash: fields[0], Author: fields[1], Subject: fields[2], Body: strings.TrimSpace(fields[3])}
+		for _, f := range strings.Split(fields[4], "\n") {
+			if f = strings.TrimSpace(f); f != "" {
+				c.Files = append(c.Files, f)
+			}
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// PreviousTag returns the most recent tag reachable from the parent of ref,
+// or "" when there is none
+func PreviousTag(ctx context.Context, dir, ref string) (string, error) {
+	if _, err := git(ctx, dir, "rev-parse", "--verify", "--quiet", ref+"^"); err != nil {
+		// ref is the root commit
+		return "", nil
+	}
+	lines, err := git(ctx, dir, "describe", "--tags", "--abbrev=0", ref+"^")
+	if err != nil {
+		if strings.Contains(err.Error(), "No names found") || strings.Contains(err.Error(), "No tags can describe") {
+			return "", nil
+		}
+		return "", err
+	}
+	return lines[0], nil
+}
+
+// IsTag reports whether ref names a tag
+func IsTag(ctx context.Context, dir, ref string) bool {
+	_, err := git(ctx, dir, "rev-parse", "--verify", "--quiet", "refs/tags/"+ref)
+	return err == nil
+}
+
+// CommitDate returns the committer date of ref as YYYY-MM-DD
+func CommitDate(ctx context.Context, dir, ref string) (string, error) {
+	lines, err := git(ctx, dir, "log", "-1", "--format=%cs", ref)
+	if err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
+		return "", fmt.Errorf("no commit %s", ref)
+	}
+	return lines[0], nil
+}
@@ -0,0 +1,101 @@
+// Package watch reports Go source files as they change on disk.
+package watch
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/knbr13/aitestgen/pkg/runner"
+)
+
+// DefaultDebounce is how long a file must stay unchanged before it is reported
+const DefaultDebounce = 500 * time.Millisecond
+
+// Files watches root recursively and calls fn for each non-test Go file that is
+// written or created, once the file has been quiet for debounce. Paths skipped
+// by filter, ignored directories and generated files are not reported. fn is
+// called from a single goroutine, so a file changed while fn runs is reported
+// again afterwards. Files returns when ctx is cancelled.
+func Files(ctx context.Context, root string, filter runner.Filter, debounce time.Duration, fn func(ctx context.Context, file string)) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err := addDirs(w, root, filter); err != nil {
+		return err
+	}
+
+	pending := make(map[string]time.Time)
+	ticker := time.NewTicker(debounce / 5)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if !ev.Has(fsnotify.Write) && !ev.Has(fsnotify.Create) {
+				continue
+			}
+			rel, err := filepath.Rel(root, ev.Name)
+			if err != nil {
+				continue
+			}
+			rel = filepath.ToSlash(rel)
+			if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+				// watch directories created after startup too
+				if !runner.IgnoredDir(info.Name()) && !filter.Skip(rel, true) {
+					addDirs(w, ev.Name, runner.Filter{})
+				}
+				continue
+			}
+			if strings.HasSuffix(ev.Name, ".go") && !strings.HasSuffix(ev.Name, "_test.go") && !filter.Skip(rel, false) {
+				pending[filepath.Clean(ev.Name)] = time.Now()
+			}
+		case now := <-ticker.C:
+			for file, changed := range pending {
+				if now.Sub(changed) < debounce {
+					continue
+				}
+				delete(pending, file)
+				if generated, _ := runner.IsGenerated(file); generated {
+					continue
+				}
+				fn(ctx, file)
+			}
+		}
+	}
+}
+
+// addDirs adds root and its subdirectories to the watcher
+func addDirs(w *fsnotify.Watcher, root string, filter runner.Filter) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel != "." && (runner.IgnoredDir(d.Name()) || filter.Skip(filepath.ToSlash(rel), true)) {
+			return filepath.SkipDir
+		}
+		return w.Add(p)
+	})
+}